@@ -19,6 +19,7 @@ package main
 import (
 	goflag "flag"
 	"fmt"
+	"net/http"
 	"os"
 	"strconv"
 	"strings"
@@ -61,6 +62,8 @@ func main() {
 	var leaderElectRetryPeriod time.Duration
 	var leaderElectResourceName string
 	var leaderElectResourceNamespace string
+	var healthProbeBindAddress string
+	var configEndpoint bool
 
 	flagSet := flag.NewFlagSet("cluster-machine-approver", flag.ExitOnError)
 
@@ -76,6 +79,8 @@ func main() {
 	flagSet.StringVar(&machineNamespace, "machine-namespace", "", "restrict machine operations to a specific namespace, if not set, all machines will be observed in approval decisions")
 	flagSet.StringVar(&workloadKubeConfigPath, "workload-cluster-kubeconfig", "", "workload kubeconfig path")
 	flagSet.BoolVar(&disableStatusController, "disable-status-controller", false, "disable status controller that will update the machine-approver clusteroperator status")
+	flagSet.StringVar(&healthProbeBindAddress, "health-probe-bind-address", ":9440", "the address the readiness probe endpoint binds to, \"0\" disables it.")
+	flagSet.BoolVar(&configEndpoint, "config-endpoint", false, "serve the effective config on the metrics endpoint.")
 
 	flagSet.BoolVar(&leaderElect, "leader-elect", true, "use leader election when starting the manager.")
 	flagSet.DurationVar(&leaderElectLeaseDuration, "leader-elect-lease-duration", 137*time.Second, "the duration that non-leader candidates will wait to force acquire leadership.")
@@ -143,12 +148,38 @@ func main() {
 		klog.Fatalf("Can't set client configs: %v", err)
 	}
 
+	// Falling back to the default config would silently drop the configured
+	// restrictions, e.g. a disabled client CSR flow.
+	config, err := controller.LoadConfig(cliConfig)
+	if err != nil {
+		klog.Fatalf("Can't load config: %v", err)
+	}
+	controller.LogEffectiveConfig(config)
+
+	approver := &controller.CertificateApprover{
+		MachineRestCfg:   managementConfig,
+		MachineNamespace: machineNamespace,
+		NodeRestCfg:      workloadConfig,
+		Config:           config,
+		APIGroupVersions: parsedAPIGroupVersions,
+	}
+
+	debugHandlers := map[string]http.Handler{
+		controller.QuarantinedCSRsPath: approver.QuarantinedCSRsHandler(),
+		controller.DecisionsPath:       approver.DecisionsHandler(),
+	}
+	if configEndpoint {
+		debugHandlers[controller.ConfigPath] = approver.ConfigHandler()
+	}
+
 	// Create a new Cmd to provide shared dependencies and start components
 	klog.Info("setting up manager")
 	mgr, err := manager.New(workloadConfig, manager.Options{
 		Metrics: server.Options{
-			BindAddress: metricsPort,
+			BindAddress:   metricsPort,
+			ExtraHandlers: debugHandlers,
 		},
+		HealthProbeBindAddress:        healthProbeBindAddress,
 		LeaderElectionNamespace:       leaderElectResourceNamespace,
 		LeaderElection:                leaderElect,
 		LeaseDuration:                 &leaderElectLeaseDuration,
@@ -193,6 +224,7 @@ func main() {
 			DisableFor: []client.Object{
 				&corev1.Node{},
 				&configv1.Network{},
+				&configv1.Infrastructure{},
 				&networkv1.HostSubnet{},
 			},
 		},
@@ -203,17 +235,15 @@ func main() {
 
 	// Setup all Controllers
 	klog.Info("setting up controllers")
-	if err = (&controller.CertificateApprover{
-		MachineClient:    uncachedManagementClient,
-		MachineRestCfg:   managementConfig,
-		MachineNamespace: machineNamespace,
-		NodeClient:       uncachedWorkloadClient,
-		NodeRestCfg:      workloadConfig,
-		Config:           controller.LoadConfig(cliConfig),
-		APIGroupVersions: parsedAPIGroupVersions,
-	}).SetupWithManager(mgr, ctrl.Options{}); err != nil {
+	approver.MachineClient = uncachedManagementClient
+	approver.NodeClient = uncachedWorkloadClient
+	approver.Recorder = mgr.GetEventRecorderFor("machine-approver")
+	if err = approver.SetupWithManager(mgr, ctrl.Options{}); err != nil {
 		klog.Fatalf("unable to create CSR controller: %v", err)
 	}
+	if err := mgr.AddReadyzCheck("machines", approver.MachinesReadyzCheck); err != nil {
+		klog.Fatalf("unable to set up readiness check: %v", err)
+	}
 
 	if !disableStatusController {
 		statusController := NewStatusController(mgr.GetConfig())
@@ -2,52 +2,1051 @@ package controller
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
+	"net"
+	"path"
+	"strings"
+	"time"
 
+	configv1 "github.com/openshift/api/config/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/apimachinery/pkg/util/wait"
 	kyaml "k8s.io/apimachinery/pkg/util/yaml"
 
 	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 type ClusterMachineApproverConfig struct {
-	NodeClientCert NodeClientCert `json:"nodeClientCert,omitempty"`
+	// AuditOnly evaluates CSRs as usual, but only logs the decisions instead of
+	// approving CSRs.
+	AuditOnly bool `json:"auditOnly,omitempty"`
+
+	// DenyInvalidCSRs sets the Denied condition on node CSRs that can never be
+	// approved, such as CSRs with an invalid signature, subject or usages,
+	// instead of leaving them pending.
+	DenyInvalidCSRs bool `json:"denyInvalidCSRs,omitempty"`
+
+	// StructuredDecisionLog logs the decision taken on each node CSR in a
+	// structured log line, with consistent keys, in addition to the usual
+	// logs.
+	StructuredDecisionLog bool `json:"structuredDecisionLog,omitempty"`
+
+	NodeClientCert      NodeClientCert      `json:"nodeClientCert,omitempty"`
+	NodeServingCert     NodeServingCert     `json:"nodeServingCert,omitempty"`
+	Quarantine          Quarantine          `json:"quarantine,omitempty"`
+	Events              Events              `json:"events,omitempty"`
+	LogRedaction        LogRedaction        `json:"logRedaction,omitempty"`
+	ReconcileAll        ReconcileAll        `json:"reconcileAll,omitempty"`
+	Retries             Retries             `json:"retries,omitempty"`
+	MachineCache        MachineCache        `json:"machineCache,omitempty"`
+	MachineAddressWatch MachineAddressWatch `json:"machineAddressWatch,omitempty"`
+	DecisionTrace       DecisionTrace       `json:"decisionTrace,omitempty"`
+	Pause               Pause               `json:"pause,omitempty"`
+
+	// MachineNamespace restricts the machines CSRs are approved for to a
+	// namespace, when the --machine-namespace flag is not set.
+	MachineNamespace string `json:"machineNamespace,omitempty"`
+	// MachineLabelSelector restricts the machines CSRs are approved for to
+	// those matching the selector.
+	MachineLabelSelector *metav1.LabelSelector `json:"machineLabelSelector,omitempty"`
+
+	// NodeUser is the user name prefix of nodes, followed by a colon and the
+	// node name, e.g. in the common name of their certs. Defaults to
+	// system:node.
+	NodeUser *string `json:"nodeUser,omitempty"`
+
+	// NodeNameAllowPatterns restricts the nodes CSRs are approved for to those
+	// whose name matches one of the glob patterns, e.g. ip-10-0-*. All node
+	// names are allowed when unset.
+	NodeNameAllowPatterns []string `json:"nodeNameAllowPatterns,omitempty"`
+
+	// AllowedMachinePhases restricts the CSRs authorized against a machine to
+	// machines in one of the phases, e.g. Provisioned and Running. CSRs of
+	// machines in other phases are requeued. Machines in any phase are allowed
+	// when unset.
+	AllowedMachinePhases []string `json:"allowedMachinePhases,omitempty"`
+
+	// ClientCertTimeWindow is how long after the creation of a machine client
+	// CSRs for its node are approved. Defaults to 2h.
+	ClientCertTimeWindow metav1.Duration `json:"clientCertTimeWindow,omitempty"`
+	// ClockSkew is how long before the creation of a machine client CSRs for
+	// its node are approved, to tolerate clock skew. Defaults to 10s.
+	ClockSkew metav1.Duration `json:"clockSkew,omitempty"`
+
+	// KubeletConnectTimeout bounds connecting to kubelets to retrieve their
+	// current serving cert, before falling back to other authorization
+	// methods. Defaults to 30s.
+	KubeletConnectTimeout metav1.Duration `json:"kubeletConnectTimeout,omitempty"`
+	// ReconcileTimeout bounds the time taken to decide on a CSR, including
+	// connecting to its kubelet and the API requests. Serving CSRs whose
+	// deadline is exceeded while retrieving the current serving cert are
+	// requeued rather than falling back to other authorization methods.
+	// Unbounded when unset.
+	ReconcileTimeout metav1.Duration `json:"reconcileTimeout,omitempty"`
+	// MaxConcurrentKubeletDials bounds the number of concurrent connections
+	// to kubelets. Connections beyond it wait for one to complete, within the
+	// kubelet connect timeout. Unlimited when unset.
+	MaxConcurrentKubeletDials int `json:"maxConcurrentKubeletDials,omitempty"`
+
+	// MinRSAKeyBits is the minimum size of the RSA keys of node CSRs. Defaults
+	// to 2048.
+	MinRSAKeyBits *int `json:"minRSAKeyBits,omitempty"`
+	// AllowedKeyAlgorithms lists the public key algorithms allowed for node
+	// CSRs, among RSA, ECDSA-P256, ECDSA-P384, ECDSA-P521 and Ed25519. Defaults
+	// to RSA, ECDSA-P256 and ECDSA-P384.
+	AllowedKeyAlgorithms []string `json:"allowedKeyAlgorithms,omitempty"`
 }
 
 type NodeClientCert struct {
+	// Enabled is whether node client CSRs are approved. Defaults to true,
+	// unless Disabled is set.
+	Enabled *bool `json:"enabled,omitempty"`
+	// Disabled disables the approval of node client CSRs.
+	//
+	// Deprecated: set Enabled to false instead.
 	Disabled bool `json:"disabled,omitempty"`
+
+	SourceNetwork SourceNetwork `json:"sourceNetwork,omitempty"`
+
+	// RejectReplacedInstances declines client CSRs created before the provider
+	// instance backing the matching machine was replaced.
+	RejectReplacedInstances bool `json:"rejectReplacedInstances,omitempty"`
+
+	// MinMachineAge is the minimum age of the matching machine before client
+	// CSRs are approved. Younger machines cause the CSR to be requeued.
+	MinMachineAge metav1.Duration `json:"minMachineAge,omitempty"`
+
+	// MachineLookupGracePeriod bounds how long after their creation client
+	// CSRs no machine matches are requeued. Past it, they are declined for
+	// good, and denied when DenyInvalidCSRs is set. Requeued indefinitely when
+	// unset.
+	MachineLookupGracePeriod metav1.Duration `json:"machineLookupGracePeriod,omitempty"`
+
+	// InternalDNSFirstLabelMatching also matches node names against the first
+	// label of the internal DNS names of machines, e.g. on GCP where nodes are
+	// named after the instance while the internal DNS name of the machine is
+	// <name>.c.<project>.internal.
+	InternalDNSFirstLabelMatching bool `json:"internalDNSFirstLabelMatching,omitempty"`
+
+	ProviderIDMatching ProviderIDMatching `json:"providerIDMatching,omitempty"`
+
+	// Bootstrappers lists the identities allowed to request node client
+	// certs. Defaults to the node-bootstrapper service account of the machine
+	// config operator.
+	Bootstrappers []NodeBootstrapper `json:"bootstrappers,omitempty"`
+	// MachineConfigOperatorNamespaces lists the namespaces the machine config
+	// operator may run in, e.g. one per hosted cluster. The node-bootstrapper
+	// service account of each namespace is allowed to request node client
+	// certs, in addition to the Bootstrappers.
+	MachineConfigOperatorNamespaces []string `json:"machineConfigOperatorNamespaces,omitempty"`
 }
 
-func LoadConfig(cliConfig string) ClusterMachineApproverConfig {
-	config := ClusterMachineApproverConfig{}
-	defer func() {
-		klog.Infof("machine approver config: %+v", config)
-	}()
+// NodeBootstrapper identifies a user allowed to request node client certs.
+type NodeBootstrapper struct {
+	// Username is the name of the user, e.g. a service account.
+	Username string `json:"username"`
+	// Groups is the exact set of groups the user must belong to.
+	Groups []string `json:"groups,omitempty"`
+}
+
+// ProviderIDMatching matches the machine of a client CSR by provider ID when no
+// machine has an internal DNS address matching the node name, e.g. on
+// platforms where nodes use custom hostnames. As the node does not exist yet,
+// the provider ID of its instance is resolved through the machines.
+type ProviderIDMatching struct {
+	// NodeNameAnnotation is the machine annotation holding the name of the node
+	// expected to join for the machine. Disabled when empty.
+	NodeNameAnnotation string `json:"nodeNameAnnotation,omitempty"`
+}
+
+// SourceNetwork restricts the networks node client CSRs may originate from.
+// The source IP address is only known when the component authenticating the
+// bootstrapper records it in the extra user info of the CSR.
+type SourceNetwork struct {
+	// CIDRs lists the networks node client CSRs must originate from. The check
+	// is disabled when empty.
+	CIDRs []string `json:"cidrs,omitempty"`
+	// ExtraKey is the key of the CSR extra user info holding the source IP
+	// address. Defaults to "source-ip".
+	ExtraKey string `json:"extraKey,omitempty"`
+
+	// networks are the CIDRs parsed when the config is loaded.
+	networks []*net.IPNet
+}
+
+type NodeServingCert struct {
+	// Enabled is whether node serving CSRs are approved. Defaults to true.
+	Enabled *bool `json:"enabled,omitempty"`
+
+	// KubeletCAFile is the path of a PEM file holding the kubelet CA bundle,
+	// read instead of the csr-controller-ca ConfigMap, e.g. when mounted from
+	// a Secret. It is read again whenever it changes.
+	KubeletCAFile string `json:"kubeletCAFile,omitempty"`
+	// AdditionalKubeletCAs lists other sources of kubelet CA bundles, merged
+	// with the kubelet CA bundle, e.g. while kubelets are migrated to a CA
+	// managed outside the cluster. Serving certs signed by a CA of any source
+	// can be renewed. Sources failing to load are skipped.
+	AdditionalKubeletCAs []KubeletCASource `json:"additionalKubeletCAs,omitempty"`
+
+	// MaxExtraDNSNames limits how many more DNS names a serving CSR may request
+	// than there are DNS addresses on the matching machine. When unset, no limit
+	// is enforced.
+	MaxExtraDNSNames *int `json:"maxExtraDNSNames,omitempty"`
+	// RequiredOrganizations lists organizations serving CSRs must include in
+	// their subject, in addition to system:nodes.
+	RequiredOrganizations []string `json:"requiredOrganizations,omitempty"`
+	// RequiredSANTypes lists the types of SANs, dns or ip, serving CSRs must
+	// request at least one of. None is required when empty.
+	RequiredSANTypes []string `json:"requiredSANTypes,omitempty"`
+	// StrictUsages declines serving CSRs requesting any usage other than
+	// digital signature, key encipherment and server auth.
+	StrictUsages bool `json:"strictUsages,omitempty"`
+	// MaxSANsPerCSR limits the total number of SANs a serving CSR may request.
+	// Defaults to 10.
+	MaxSANsPerCSR *int `json:"maxSANsPerCSR,omitempty"`
+
+	SerialReplayCheck SerialReplayCheck `json:"serialReplayCheck,omitempty"`
+
+	ApprovalRateLimit ApprovalRateLimit `json:"approvalRateLimit,omitempty"`
+
+	KubeletVersionCheck KubeletVersionCheck `json:"kubeletVersionCheck,omitempty"`
+
+	ProviderNetworkInterfaces ProviderNetworkInterfaces `json:"providerNetworkInterfaces,omitempty"`
+
+	SPIFFE SPIFFE `json:"spiffe,omitempty"`
 
-	if len(cliConfig) == 0 {
+	// DisableRenewalFastPath never retrieves the serving cert presented by
+	// kubelets to authorize renewals, for networks where kubelets can't be
+	// reached, so that serving CSRs are only authorized through machines.
+	DisableRenewalFastPath bool `json:"disableRenewalFastPath,omitempty"`
+	// EnforceKeyAlgorithmContinuity only authorizes renewals requesting a key
+	// of the same algorithm as the serving cert presented by the kubelet.
+	// Changes of algorithm are logged either way.
+	EnforceKeyAlgorithmContinuity bool `json:"enforceKeyAlgorithmContinuity,omitempty"`
+
+	// VerifyOCSPStaple only trusts the serving cert presented by a kubelet for
+	// renewals when its stapled OCSP response, if any, reports it as good.
+	VerifyOCSPStaple bool `json:"verifyOCSPStaple,omitempty"`
+
+	// KubeletServerName is the name the serving cert presented by kubelets is
+	// verified against: the address the kubelet is reached on (Address, the
+	// default), the name of the node (NodeName), or no name at all (None).
+	KubeletServerName string `json:"kubeletServerName,omitempty"`
+
+	// DefaultKubeletPort is the port kubelets are connected to when their node
+	// does not report it yet, e.g. 10250. When unset, their serving cert is
+	// not retrieved until the node reports it.
+	DefaultKubeletPort *int `json:"defaultKubeletPort,omitempty"`
+
+	// CurrentCertCacheTTL is how long the serving cert retrieved from a
+	// kubelet is reused for renewals of the same node, rather than connecting
+	// to the kubelet again. Defaults to 30s.
+	CurrentCertCacheTTL metav1.Duration `json:"currentCertCacheTTL,omitempty"`
+
+	NodeRefRefresh NodeRefRefresh `json:"nodeRefRefresh,omitempty"`
+
+	// RejectTerminatingMachineCSRs declines serving CSRs authorized against
+	// the machine of the node when the machine is being deleted. Renewals are
+	// still approved.
+	RejectTerminatingMachineCSRs bool `json:"rejectTerminatingMachineCSRs,omitempty"`
+
+	// CertValidationSkew tolerates serving certs presented by kubelets that
+	// only become valid within the skew, e.g. when the clock of the node is
+	// ahead. Disabled when unset.
+	CertValidationSkew metav1.Duration `json:"certValidationSkew,omitempty"`
+
+	// DNSAddressTypes are the types of the machine addresses DNS names are
+	// matched against, e.g. to opt in to platform specific address types.
+	// Defaults to InternalDNS, ExternalDNS and Hostname.
+	DNSAddressTypes []corev1.NodeAddressType `json:"dnsAddressTypes,omitempty"`
+	// IPAddressTypes are the types of the machine addresses IP addresses are
+	// matched against. Defaults to InternalIP and ExternalIP.
+	IPAddressTypes []corev1.NodeAddressType `json:"ipAddressTypes,omitempty"`
+
+	// PreferNodeAddresses also accepts the addresses of the node, as reported
+	// by the cloud provider, as valid SANs in addition to those of the machine.
+	PreferNodeAddresses bool `json:"preferNodeAddresses,omitempty"`
+
+	// SANAddressSources selects the addresses serving CSRs may request: those
+	// of the machine (MachineOnly, the default), those of the node, as
+	// reported by the cloud provider (NodeOnly), or both (Union).
+	// PreferNodeAddresses is equivalent to Union.
+	SANAddressSources string `json:"sanAddressSources,omitempty"`
+
+	// NodeHostnameCheck requires the primary DNS name of serving CSRs to match
+	// the hostname reported by the node, when it reports one.
+	NodeHostnameCheck bool `json:"nodeHostnameCheck,omitempty"`
+
+	// AllowShortNameSANs accepts DNS names matching the hostname label of one
+	// of the DNS addresses of the machine, for platforms only recording FQDNs.
+	AllowShortNameSANs bool `json:"allowShortNameSANs,omitempty"`
+
+	// NodeInstanceIDAnnotation and MachineInstanceIDAnnotation are the
+	// annotations of nodes and machines holding the ID of their cloud
+	// instance. When both are set, the machine of a node its serving CSRs are
+	// authorized against is matched by instance ID while no machine has the
+	// node as node ref yet, e.g. on platforms where DNS names are unreliable.
+	NodeInstanceIDAnnotation    string `json:"nodeInstanceIDAnnotation,omitempty"`
+	MachineInstanceIDAnnotation string `json:"machineInstanceIDAnnotation,omitempty"`
+
+	// ProviderIDMatching matches the machine of a node its serving CSRs are
+	// authorized against by the provider ID of the node while no machine has
+	// the node as node ref yet, e.g. on platforms where nodes use custom
+	// hostnames.
+	ProviderIDMatching bool `json:"providerIDMatching,omitempty"`
+
+	// RequireFullSANCoverage also requires serving CSRs to request every DNS
+	// and IP address of the machine of the node, or of the node per
+	// SANAddressSources, as a SAN, so that the serving cert covers every
+	// address the node is reached on.
+	RequireFullSANCoverage bool `json:"requireFullSANCoverage,omitempty"`
+
+	// ControlPlane applies to serving CSRs from nodes backed by control plane
+	// machines.
+	ControlPlane ControlPlaneServingCert `json:"controlPlane,omitempty"`
+}
+
+// KubeletCASource is a source of kubelet CA bundle, either a PEM file, read
+// again whenever it changes, or a ConfigMap.
+type KubeletCASource struct {
+	File      string              `json:"file,omitempty"`
+	ConfigMap *KubeletCAConfigMap `json:"configMap,omitempty"`
+}
+
+type KubeletCAConfigMap struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Key is the key of the CA bundle in the ConfigMap. Defaults to
+	// ca-bundle.crt.
+	Key string `json:"key,omitempty"`
+}
+
+type ControlPlaneServingCert struct {
+	// ExtraAllowedSANs lists additional DNS names and IP addresses, such as
+	// API VIPs, that control plane serving CSRs may request.
+	ExtraAllowedSANs []string `json:"extraAllowedSANs,omitempty"`
+	// RequireRenewal only approves control plane serving CSRs by renewal of
+	// the serving cert currently presented by the kubelet.
+	RequireRenewal bool `json:"requireRenewal,omitempty"`
+}
+
+// NodeRefRefresh lists the machines again within the same reconcile when none
+// has the node of a serving CSR as node ref yet, e.g. when racing with the node
+// linker, rather than requeueing the CSR.
+type NodeRefRefresh struct {
+	// Attempts is the number of times machines are listed again, at most 5.
+	// Disabled when unset.
+	Attempts int `json:"attempts,omitempty"`
+	// Delay is the time waited before each list. Defaults to 1s.
+	Delay metav1.Duration `json:"delay,omitempty"`
+}
+
+// SerialReplayCheck configures tracking of the serving cert serials presented
+// by kubelets during renewals, to detect a kubelet presenting a serving cert
+// that has since been superseded.
+type SerialReplayCheck struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// Deny refuses to use a superseded serving cert to authorize a renewal,
+	// instead of only logging a warning.
+	Deny bool `json:"deny,omitempty"`
+}
+
+// ApprovalRateLimit limits the number of serving certs approved for each node,
+// to detect rogue nodes churning through serving certs.
+type ApprovalRateLimit struct {
+	// MaxApprovals is the number of serving certs approved for a node within
+	// the window, beyond which serving CSRs of the node are left for manual
+	// approval. The limit is disabled when unset.
+	MaxApprovals *int `json:"maxApprovals,omitempty"`
+	// Window is the period over which approvals are counted. Defaults to 1h.
+	Window metav1.Duration `json:"window,omitempty"`
+}
+
+// KubeletVersionCheck configures a guardrail refusing serving CSRs from nodes
+// whose kubelet version is ahead of the version expected for them.
+type KubeletVersionCheck struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// MaxVersion is the highest kubelet version allowed, used for nodes whose
+	// machine does not specify the expected version. When unset, such nodes
+	// are not checked.
+	MaxVersion string `json:"maxVersion,omitempty"`
+}
+
+// ProviderNetworkInterfaces allows serving CSRs to request IP addresses
+// listed in the network interfaces of the machine provider status, on
+// platforms reporting node IPs there rather than in the machine addresses.
+// The provider status is expected to list the interfaces under
+// networkInterfaces, each with its IPs under ipAddresses.
+type ProviderNetworkInterfaces struct {
+	// Platforms lists the platforms on which the network interfaces are
+	// consulted. Disabled when empty.
+	Platforms []configv1.PlatformType `json:"platforms,omitempty"`
+}
+
+// SPIFFE allows serving CSRs to request the SPIFFE ID of their node as a URI
+// SAN, e.g. spiffe://cluster/node/ip-10-0-1-2, for setups identifying kubelets
+// by SPIFFE ID.
+type SPIFFE struct {
+	// TrustDomain is the trust domain SPIFFE IDs must be in. URI SANs are not
+	// allowed when unset.
+	TrustDomain string `json:"trustDomain,omitempty"`
+}
+
+// Quarantine holds suspicious CSRs pending for manual review. Quarantined CSRs
+// are annotated with the reason and no longer reconciled by the controller.
+type Quarantine struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// Reasons lists the reasons for which CSRs are quarantined. CSRs failing
+	// for any other reason are handled as usual. Defaults to all supported
+	// reasons when empty.
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// Events limits the rate at which events are recorded on CSRs. Identical
+// events are only recorded once a minute.
+type Events struct {
+	// MaxPerMinute is the sustained number of events recorded per minute.
+	// Defaults to 60. Events are disabled when set to 0.
+	MaxPerMinute *int `json:"maxPerMinute,omitempty"`
+	// Burst is the number of events that may be recorded at once. A quarter of
+	// it is reserved for warning events. Defaults to 20.
+	Burst *int `json:"burst,omitempty"`
+}
+
+// LogRedaction configures how sensitive values, such as CSR requests and the
+// extra user info of CSRs, are redacted before being logged.
+type LogRedaction struct {
+	// Mode is either "hash" (default), to log a short hash of the values,
+	// "truncate", to log their first characters, or "none".
+	Mode string `json:"mode,omitempty"`
+	// TruncateLength is the number of characters logged in truncate mode.
+	// Defaults to 8.
+	TruncateLength *int `json:"truncateLength,omitempty"`
+}
+
+// ReconcileAll re-evaluates every pending CSR once on startup, clearing the
+// annotations set on them by the controller, so that CSRs stuck due to a
+// previous version of the controller get a fresh decision.
+type ReconcileAll struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// DryRun only logs the CSRs that would be re-evaluated.
+	DryRun bool `json:"dryRun,omitempty"`
+	// MaxPerMinute is the number of CSRs re-evaluated per minute. Defaults to
+	// 60.
+	MaxPerMinute *int `json:"maxPerMinute,omitempty"`
+}
+
+type Retries struct {
+	// MaxAttempts is the number of consecutive failed attempts to reconcile a
+	// CSR after which it is no longer requeued. When unset, CSRs are requeued
+	// until they are reconciled.
+	MaxAttempts *int `json:"maxAttempts,omitempty"`
+	// InitialBackoff is the delay before retrying after a first failed
+	// attempt, doubled after each further failed attempt. Defaults to 1s.
+	InitialBackoff metav1.Duration `json:"initialBackoff,omitempty"`
+	// MaxBackoff caps the delay before retrying. Defaults to 5m.
+	MaxBackoff metav1.Duration `json:"maxBackoff,omitempty"`
+	// RequeueJitter is the maximum fraction of the delay before retrying
+	// randomly added to it, so that CSRs failing together are not all retried
+	// at once. Disabled when unset.
+	RequeueJitter *float64 `json:"requeueJitter,omitempty"`
+}
+
+// Pause allows pausing the approval of all node CSRs without restarting the
+// controller, e.g. during an incident, by setting the paused key of a
+// ConfigMap to true. Approvals resume once it is unset.
+type Pause struct {
+	// Namespace and Name are those of the pause ConfigMap. Pausing is
+	// disabled when unset.
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+}
+
+// DecisionTrace keeps the last decisions taken on node CSRs in memory, to be
+// listed by the decisions debug endpoint.
+type DecisionTrace struct {
+	// Size is the number of decisions kept. Defaults to 100. Disabled when set
+	// to 0.
+	Size *int `json:"size,omitempty"`
+}
+
+// MachineCache caches the machines listed, so that the CSRs reconciled close
+// together, e.g. during a scale up, don't each list all the machines. The
+// machines are listed on every reconcile when disabled, the default, as CSRs
+// must not be authorized against stale machines.
+type MachineCache struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// TTL is how long the machines listed are reused. As CSRs declined for
+	// lack of a matching machine are retried, machines created meanwhile are
+	// found once it expires. Defaults to 10s.
+	TTL metav1.Duration `json:"ttl,omitempty"`
+}
+
+// MachineAddressWatch re-evaluates the pending serving CSRs of a node when the
+// addresses of its machine change, e.g. CSRs declined while the addresses of
+// the machine were stale, rather than waiting for them to be retried.
+type MachineAddressWatch struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// Interval is how often the machines are listed to detect address
+	// changes. Defaults to 30s.
+	Interval metav1.Duration `json:"interval,omitempty"`
+}
+
+// LoadConfig loads the config from the given YAML file. The default config,
+// the zero value, is returned when no file is given or the file is empty. The
+// defaults of unset tunables, e.g. timeouts, windows and limits, are applied
+// when they are used, so that they are not persisted with the config. When
+// the file can't be loaded or the config is invalid, an error is returned, and
+// the zero value returned along with it must not be used.
+func LoadConfig(path string) (ClusterMachineApproverConfig, error) {
+	if len(path) == 0 {
 		klog.Info("using default as no cli config specified")
-		return config
+		return ClusterMachineApproverConfig{}, nil
 	}
 
-	content, err := ioutil.ReadFile(cliConfig)
+	content, err := ioutil.ReadFile(path)
 	if err != nil {
-		klog.Infof("using default as failed to load config %s: %v", cliConfig, err)
-		return config
+		return ClusterMachineApproverConfig{}, fmt.Errorf("failed to load config %s: %v", path, err)
 	}
 	if len(content) == 0 {
-		klog.Infof("using default as config %s is empty", cliConfig)
-		return config
+		klog.Infof("using default as config %s is empty", path)
+		return ClusterMachineApproverConfig{}, nil
 	}
 
 	data, err := kyaml.ToJSON(content)
 	if err != nil {
-		klog.Infof("using default as failed to convert config %s to JSON: %v", cliConfig, err)
-		return config
+		return ClusterMachineApproverConfig{}, fmt.Errorf("failed to convert config %s to JSON: %v", path, err)
 	}
 
+	config := ClusterMachineApproverConfig{}
 	if err := json.Unmarshal(data, &config); err != nil {
-		klog.Infof("using default as failed to unmarshal config %s as JSON: %v", cliConfig, err)
-		return config
+		return ClusterMachineApproverConfig{}, fmt.Errorf("failed to unmarshal config %s as JSON: %v", path, err)
 	}
 
-	return config
+	if err := config.Validate(); err != nil {
+		return ClusterMachineApproverConfig{}, fmt.Errorf("config %s is invalid: %v", path, err)
+	}
+	// The CIDRs have been validated above.
+	config.NodeClientCert.SourceNetwork.networks, _ = config.NodeClientCert.SourceNetwork.parseCIDRs()
+
+	return config, nil
+}
+
+// Validate checks the values of the config that can't be enforced by its
+// types, and the combinations of options that can't work together.
+func (c ClusterMachineApproverConfig) Validate() error {
+	if c.ClientCertTimeWindow.Duration < 0 {
+		return fmt.Errorf("clientCertTimeWindow must not be negative: %s", c.ClientCertTimeWindow.Duration)
+	}
+	for _, pattern := range c.NodeNameAllowPatterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid nodeNameAllowPatterns pattern %q: %v", pattern, err)
+		}
+	}
+	if c.ClockSkew.Duration < 0 {
+		return fmt.Errorf("clockSkew must not be negative: %s", c.ClockSkew.Duration)
+	}
+	if _, err := metav1.LabelSelectorAsSelector(c.MachineLabelSelector); err != nil {
+		return fmt.Errorf("invalid machineLabelSelector: %v", err)
+	}
+	if _, err := c.NodeClientCert.SourceNetwork.parseCIDRs(); err != nil {
+		return err
+	}
+	if c.NodeClientCert.MachineLookupGracePeriod.Duration < 0 {
+		return fmt.Errorf("nodeClientCert.machineLookupGracePeriod must not be negative: %s", c.NodeClientCert.MachineLookupGracePeriod.Duration)
+	}
+	if c.NodeClientCert.MinMachineAge.Duration < 0 {
+		return fmt.Errorf("nodeClientCert.minMachineAge must not be negative: %s", c.NodeClientCert.MinMachineAge.Duration)
+	}
+	if c.ReconcileTimeout.Duration < 0 {
+		return fmt.Errorf("reconcileTimeout must not be negative: %s", c.ReconcileTimeout.Duration)
+	}
+	if c.KubeletConnectTimeout.Duration < 0 {
+		return fmt.Errorf("kubeletConnectTimeout must not be negative: %s", c.KubeletConnectTimeout.Duration)
+	}
+	if c.MaxConcurrentKubeletDials < 0 {
+		return fmt.Errorf("maxConcurrentKubeletDials must not be negative: %d", c.MaxConcurrentKubeletDials)
+	}
+	if maxAttempts := c.Retries.MaxAttempts; maxAttempts != nil && *maxAttempts <= 0 {
+		return fmt.Errorf("retries.maxAttempts must be positive: %d", *maxAttempts)
+	}
+	if c.Retries.InitialBackoff.Duration < 0 {
+		return fmt.Errorf("retries.initialBackoff must not be negative: %s", c.Retries.InitialBackoff.Duration)
+	}
+	if c.Retries.MaxBackoff.Duration < 0 {
+		return fmt.Errorf("retries.maxBackoff must not be negative: %s", c.Retries.MaxBackoff.Duration)
+	}
+	if jitter := c.Retries.RequeueJitter; jitter != nil && *jitter < 0 {
+		return fmt.Errorf("retries.requeueJitter must not be negative: %v", *jitter)
+	}
+	if c.NodeClientCert.Disabled && c.NodeClientCert.Enabled != nil && *c.NodeClientCert.Enabled {
+		return fmt.Errorf("nodeClientCert.enabled and nodeClientCert.disabled must not both be set")
+	}
+	if (c.Pause.Namespace == "") != (c.Pause.Name == "") {
+		return fmt.Errorf("pause.namespace and pause.name must be set together")
+	}
+	for i, source := range c.NodeServingCert.AdditionalKubeletCAs {
+		if (source.File == "") == (source.ConfigMap == nil) {
+			return fmt.Errorf("nodeServingCert.additionalKubeletCAs[%d] must set exactly one of file and configMap", i)
+		}
+		if source.ConfigMap != nil && (source.ConfigMap.Namespace == "" || source.ConfigMap.Name == "") {
+			return fmt.Errorf("nodeServingCert.additionalKubeletCAs[%d].configMap namespace and name must be set", i)
+		}
+	}
+	if (c.NodeServingCert.NodeInstanceIDAnnotation == "") != (c.NodeServingCert.MachineInstanceIDAnnotation == "") {
+		return fmt.Errorf("nodeServingCert.nodeInstanceIDAnnotation and nodeServingCert.machineInstanceIDAnnotation must be set together")
+	}
+	if maxVersion := c.NodeServingCert.KubeletVersionCheck.MaxVersion; maxVersion != "" {
+		if _, err := version.ParseGeneric(maxVersion); err != nil {
+			return fmt.Errorf("invalid nodeServingCert.kubeletVersionCheck.maxVersion %q: %v", maxVersion, err)
+		}
+	}
+	if size := c.DecisionTrace.Size; size != nil && *size < 0 {
+		return fmt.Errorf("decisionTrace.size must not be negative: %d", *size)
+	}
+	if maxPerMinute := c.Events.MaxPerMinute; maxPerMinute != nil && *maxPerMinute < 0 {
+		return fmt.Errorf("events.maxPerMinute must not be negative: %d", *maxPerMinute)
+	}
+	if burst := c.Events.Burst; burst != nil && *burst < 0 {
+		return fmt.Errorf("events.burst must not be negative: %d", *burst)
+	}
+	if maxPerMinute := c.ReconcileAll.MaxPerMinute; maxPerMinute != nil && *maxPerMinute < 0 {
+		return fmt.Errorf("reconcileAll.maxPerMinute must not be negative: %d", *maxPerMinute)
+	}
+	if c.MachineCache.TTL.Duration < 0 {
+		return fmt.Errorf("machineCache.ttl must not be negative: %s", c.MachineCache.TTL.Duration)
+	}
+	if c.MachineAddressWatch.Interval.Duration < 0 {
+		return fmt.Errorf("machineAddressWatch.interval must not be negative: %s", c.MachineAddressWatch.Interval.Duration)
+	}
+	if maxApprovals := c.NodeServingCert.ApprovalRateLimit.MaxApprovals; maxApprovals != nil && *maxApprovals <= 0 {
+		return fmt.Errorf("nodeServingCert.approvalRateLimit.maxApprovals must be positive: %d", *maxApprovals)
+	}
+	if c.NodeServingCert.ApprovalRateLimit.Window.Duration < 0 {
+		return fmt.Errorf("nodeServingCert.approvalRateLimit.window must not be negative: %s", c.NodeServingCert.ApprovalRateLimit.Window.Duration)
+	}
+	if attempts := c.NodeServingCert.NodeRefRefresh.Attempts; attempts < 0 || attempts > maxNodeRefRefreshAttempts {
+		return fmt.Errorf("nodeServingCert.nodeRefRefresh.attempts must be between 0 and %d: %d", maxNodeRefRefreshAttempts, attempts)
+	}
+	if c.NodeServingCert.NodeRefRefresh.Delay.Duration < 0 {
+		return fmt.Errorf("nodeServingCert.nodeRefRefresh.delay must not be negative: %s", c.NodeServingCert.NodeRefRefresh.Delay.Duration)
+	}
+	if c.NodeServingCert.CurrentCertCacheTTL.Duration < 0 {
+		return fmt.Errorf("nodeServingCert.currentCertCacheTTL must not be negative: %s", c.NodeServingCert.CurrentCertCacheTTL.Duration)
+	}
+	if c.NodeServingCert.CertValidationSkew.Duration < 0 {
+		return fmt.Errorf("nodeServingCert.certValidationSkew must not be negative: %s", c.NodeServingCert.CertValidationSkew.Duration)
+	}
+	for _, addressType := range append(append([]corev1.NodeAddressType{}, c.NodeServingCert.DNSAddressTypes...), c.NodeServingCert.IPAddressTypes...) {
+		if addressType == "" {
+			return fmt.Errorf("nodeServingCert address types must not be empty")
+		}
+	}
+	for _, addressType := range c.NodeServingCert.DNSAddressTypes {
+		if hasAddressType(c.NodeServingCert.IPAddressTypes, addressType) {
+			return fmt.Errorf("nodeServingCert address type %s must not be both a DNS and an IP address type", addressType)
+		}
+	}
+	if port := c.NodeServingCert.DefaultKubeletPort; port != nil && (*port <= 0 || *port > 65535) {
+		return fmt.Errorf("nodeServingCert.defaultKubeletPort must be a valid port: %d", *port)
+	}
+	if maxSANs := c.NodeServingCert.MaxSANsPerCSR; maxSANs != nil && *maxSANs <= 0 {
+		return fmt.Errorf("nodeServingCert.maxSANsPerCSR must be positive: %d", *maxSANs)
+	}
+	if maxExtra := c.NodeServingCert.MaxExtraDNSNames; maxExtra != nil && *maxExtra < 0 {
+		return fmt.Errorf("nodeServingCert.maxExtraDNSNames must not be negative: %d", *maxExtra)
+	}
+	if c.MinRSAKeyBits != nil && *c.MinRSAKeyBits <= 0 {
+		return fmt.Errorf("minRSAKeyBits must be positive: %d", *c.MinRSAKeyBits)
+	}
+	if nodeUser := c.NodeUser; nodeUser != nil && (*nodeUser == "" || strings.HasSuffix(*nodeUser, ":")) {
+		return fmt.Errorf("nodeUser must not be empty nor end with a colon: %q", *nodeUser)
+	}
+	for _, phase := range c.AllowedMachinePhases {
+		if phase == "" {
+			return fmt.Errorf("allowedMachinePhases must not contain empty phases")
+		}
+	}
+	for _, org := range c.NodeServingCert.RequiredOrganizations {
+		if org == "" {
+			return fmt.Errorf("nodeServingCert.requiredOrganizations must not contain empty organizations")
+		}
+	}
+	for _, bootstrapper := range c.NodeClientCert.Bootstrappers {
+		if bootstrapper.Username == "" {
+			return fmt.Errorf("nodeClientCert.bootstrappers username must not be empty")
+		}
+	}
+	for _, namespace := range c.NodeClientCert.MachineConfigOperatorNamespaces {
+		if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+			return fmt.Errorf("invalid nodeClientCert.machineConfigOperatorNamespaces namespace %q: %s", namespace, strings.Join(errs, ", "))
+		}
+	}
+	if name := c.NodeServingCert.KubeletServerName; name != "" && !sets.NewString(kubeletServerNames...).Has(name) {
+		return fmt.Errorf("unknown nodeServingCert.kubeletServerName %q, must be one of %v", name, kubeletServerNames)
+	}
+	for _, sanType := range c.NodeServingCert.RequiredSANTypes {
+		if !sets.NewString(requiredSANTypes...).Has(sanType) {
+			return fmt.Errorf("unknown nodeServingCert.requiredSANTypes type %q, must be one of %v", sanType, requiredSANTypes)
+		}
+	}
+	if source := c.NodeServingCert.SANAddressSources; source != "" {
+		if !sets.NewString(sanAddressSources...).Has(source) {
+			return fmt.Errorf("unknown nodeServingCert.sanAddressSources %q, must be one of %v", source, sanAddressSources)
+		}
+		if c.NodeServingCert.PreferNodeAddresses && source != sanAddressSourceUnion {
+			return fmt.Errorf("nodeServingCert.preferNodeAddresses conflicts with nodeServingCert.sanAddressSources %q", source)
+		}
+	}
+	for _, algorithm := range c.AllowedKeyAlgorithms {
+		if !sets.NewString(keyAlgorithms...).Has(algorithm) {
+			return fmt.Errorf("unknown key algorithm %q in allowedKeyAlgorithms, must be one of %v", algorithm, keyAlgorithms)
+		}
+	}
+	if mode := c.LogRedaction.Mode; mode != "" && !sets.NewString(redactionModes...).Has(mode) {
+		return fmt.Errorf("unknown logRedaction.mode %q, must be one of %v", mode, redactionModes)
+	}
+	if length := c.LogRedaction.TruncateLength; length != nil && *length < 0 {
+		return fmt.Errorf("logRedaction.truncateLength must not be negative: %d", *length)
+	}
+	for _, reason := range c.Quarantine.Reasons {
+		if !sets.NewString(quarantineReasons...).Has(reason) {
+			return fmt.Errorf("unknown quarantine.reasons reason %q, must be one of %v", reason, quarantineReasons)
+		}
+	}
+
+	if initialBackoff, maxBackoff := c.Retries.InitialBackoff.Duration, c.Retries.MaxBackoff.Duration; initialBackoff > 0 && maxBackoff > 0 && initialBackoff > maxBackoff {
+		return fmt.Errorf("retries.initialBackoff %s must not be greater than retries.maxBackoff %s", initialBackoff, maxBackoff)
+	}
+	if trustDomain := c.NodeServingCert.SPIFFE.TrustDomain; trustDomain != "" && !spiffeTrustDomainRegexp.MatchString(trustDomain) {
+		return fmt.Errorf("invalid nodeServingCert.spiffe.trustDomain %q, must only contain lower case letters, digits, dots, dashes and underscores", trustDomain)
+	}
+	if c.NodeServingCert.SerialReplayCheck.Deny && !c.NodeServingCert.SerialReplayCheck.Enabled {
+		return fmt.Errorf("nodeServingCert.serialReplayCheck.deny requires nodeServingCert.serialReplayCheck.enabled")
+	}
+	// Control plane serving CSRs could never be approved.
+	if c.NodeServingCert.ControlPlane.RequireRenewal && c.NodeServingCert.DisableRenewalFastPath {
+		return fmt.Errorf("nodeServingCert.controlPlane.requireRenewal requires the renewal fast path, but nodeServingCert.disableRenewalFastPath is set")
+	}
+	return nil
+}
+
+// clientCertTimeWindow returns the period around the creation of a machine
+// during which client CSRs for its node are approved.
+func (c ClusterMachineApproverConfig) clientCertTimeWindow() (clockSkew, timeWindow time.Duration) {
+	clockSkew, timeWindow = maxMachineClockSkew, maxMachineDelta
+	if c.ClockSkew.Duration > 0 {
+		clockSkew = c.ClockSkew.Duration
+	}
+	if c.ClientCertTimeWindow.Duration > 0 {
+		timeWindow = c.ClientCertTimeWindow.Duration
+	}
+	return clockSkew, timeWindow
+}
+
+// nodeUserPrefix returns the prefix of the user names of nodes, before the
+// node name.
+func (c ClusterMachineApproverConfig) nodeUserPrefix() string {
+	if c.NodeUser != nil {
+		return *c.NodeUser + ":"
+	}
+	return defaultNodeUser + ":"
+}
+
+// machineScoped returns whether CSRs are restricted to the nodes of a subset
+// of the machines by the configuration.
+func (c ClusterMachineApproverConfig) machineScoped() bool {
+	return c.MachineNamespace != "" || c.MachineLabelSelector != nil
+}
+
+// machineSelector returns the selector of the machines CSRs are approved for.
+// No machine is selected when the selector is invalid.
+func (c ClusterMachineApproverConfig) machineSelector() labels.Selector {
+	if c.MachineLabelSelector == nil {
+		return labels.Everything()
+	}
+	selector, err := metav1.LabelSelectorAsSelector(c.MachineLabelSelector)
+	if err != nil {
+		return labels.Nothing()
+	}
+	return selector
+}
+
+// kubeletConnectTimeout returns the timeout for connecting to kubelets.
+func (c ClusterMachineApproverConfig) kubeletConnectTimeout() time.Duration {
+	if c.KubeletConnectTimeout.Duration > 0 {
+		return c.KubeletConnectTimeout.Duration
+	}
+	return defaultKubeletConnectTimeout
+}
+
+// minRSAKeyBits returns the minimum size of the RSA keys of node CSRs.
+func (c ClusterMachineApproverConfig) minRSAKeyBits() int {
+	if c.MinRSAKeyBits != nil {
+		return *c.MinRSAKeyBits
+	}
+	return defaultMinRSAKeyBits
+}
+
+// allowedKeyAlgorithms returns the public key algorithms allowed for node
+// CSRs.
+func (c ClusterMachineApproverConfig) allowedKeyAlgorithms() []string {
+	if len(c.AllowedKeyAlgorithms) > 0 {
+		return c.AllowedKeyAlgorithms
+	}
+	return defaultKeyAlgorithms
+}
+
+// bootstrappers returns the identities allowed to request node client certs.
+func (c NodeClientCert) bootstrappers() []NodeBootstrapper {
+	if len(c.Bootstrappers) == 0 && len(c.MachineConfigOperatorNamespaces) == 0 {
+		return defaultNodeBootstrappers
+	}
+	bootstrappers := append([]NodeBootstrapper{}, c.Bootstrappers...)
+	for _, namespace := range c.MachineConfigOperatorNamespaces {
+		bootstrappers = append(bootstrappers, machineConfigOperatorBootstrapper(namespace))
+	}
+	return bootstrappers
+}
+
+// maxSANsPerCSR returns the maximum number of SANs of serving CSRs.
+func (c NodeServingCert) maxSANsPerCSR() int {
+	if c.MaxSANsPerCSR != nil {
+		return *c.MaxSANsPerCSR
+	}
+	return defaultMaxSANsPerCSR
+}
+
+// currentCertCacheTTL returns how long serving certs retrieved from kubelets
+// are reused.
+func (c NodeServingCert) currentCertCacheTTL() time.Duration {
+	if c.CurrentCertCacheTTL.Duration > 0 {
+		return c.CurrentCertCacheTTL.Duration
+	}
+	return defaultServingCertCacheTTL
+}
+
+// parseCIDRs parses the CIDRs of the networks node client CSRs must originate
+// from.
+func (s SourceNetwork) parseCIDRs() ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, c := range s.CIDRs {
+		_, network, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, fmt.Errorf("invalid nodeClientCert.sourceNetwork.cidrs CIDR %q: %v", c, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// allowedNetworks returns the networks node client CSRs must originate from,
+// as parsed when the config was loaded. Configs not loaded from a file, e.g.
+// in tests, are parsed on each call.
+func (s SourceNetwork) allowedNetworks() ([]*net.IPNet, error) {
+	if s.networks != nil {
+		return s.networks, nil
+	}
+	return s.parseCIDRs()
+}
+
+// nodeNameAllowed returns whether CSRs may be approved for the node, its name
+// matching one of the allowed patterns if any.
+func (c ClusterMachineApproverConfig) nodeNameAllowed(nodeName string) bool {
+	if len(c.NodeNameAllowPatterns) == 0 {
+		return true
+	}
+	for _, pattern := range c.NodeNameAllowPatterns {
+		// Patterns are validated when the config is loaded.
+		if matched, _ := path.Match(pattern, nodeName); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// machinePhaseAllowed returns whether CSRs may be authorized against a machine
+// in the given phase.
+func (c ClusterMachineApproverConfig) machinePhaseAllowed(phase string) bool {
+	if len(c.AllowedMachinePhases) == 0 {
+		return true
+	}
+	for _, allowed := range c.AllowedMachinePhases {
+		if allowed == phase {
+			return true
+		}
+	}
+	return false
+}
+
+// enabled returns whether node client CSRs are approved.
+func (c NodeClientCert) enabled() bool {
+	if c.Enabled != nil {
+		return *c.Enabled
+	}
+	return !c.Disabled
+}
+
+// enabled returns whether node serving CSRs are approved.
+func (c NodeServingCert) enabled() bool {
+	return c.Enabled == nil || *c.Enabled
+}
+
+// enabled returns whether approvals may be paused.
+func (c Pause) enabled() bool {
+	return c.Name != ""
+}
+
+// key returns the key of the pause ConfigMap.
+func (c Pause) key() client.ObjectKey {
+	return client.ObjectKey{Namespace: c.Namespace, Name: c.Name}
+}
+
+// size returns the number of decisions kept.
+func (c DecisionTrace) size() int {
+	if c.Size == nil {
+		return defaultDecisionTraceSize
+	}
+	return *c.Size
+}
+
+// ttl returns how long the machines listed are reused.
+func (c MachineCache) ttl() time.Duration {
+	if c.TTL.Duration > 0 {
+		return c.TTL.Duration
+	}
+	return defaultMachineCacheTTL
+}
+
+// interval returns how often the machines are listed to detect address
+// changes.
+func (c MachineAddressWatch) interval() time.Duration {
+	if c.Interval.Duration > 0 {
+		return c.Interval.Duration
+	}
+	return defaultMachineAddressWatchInterval
+}
+
+// delay returns the time waited before listing the machines again.
+func (c NodeRefRefresh) delay() time.Duration {
+	if c.Delay.Duration > 0 {
+		return c.Delay.Duration
+	}
+	return defaultNodeRefRefreshDelay
+}
+
+// sanAddressSource returns where the addresses serving CSRs may request come
+// from.
+func (c NodeServingCert) sanAddressSource() string {
+	if c.SANAddressSources != "" {
+		return c.SANAddressSources
+	}
+	if c.PreferNodeAddresses {
+		return sanAddressSourceUnion
+	}
+	return sanAddressSourceMachineOnly
+}
+
+// kubeletCAFiles returns the paths of the kubelet CA file and the additional
+// kubelet CA files.
+func (c NodeServingCert) kubeletCAFiles() []string {
+	var paths []string
+	if c.KubeletCAFile != "" {
+		paths = append(paths, c.KubeletCAFile)
+	}
+	for _, source := range c.AdditionalKubeletCAs {
+		if source.File != "" {
+			paths = append(paths, source.File)
+		}
+	}
+	return paths
+}
+
+// objectKey returns the key of the ConfigMap.
+func (c KubeletCAConfigMap) objectKey() client.ObjectKey {
+	return client.ObjectKey{Namespace: c.Namespace, Name: c.Name}
+}
+
+// key returns the key of the CA bundle in the ConfigMap.
+func (c KubeletCAConfigMap) key() string {
+	if c.Key != "" {
+		return c.Key
+	}
+	return defaultKubeletCAConfigMapKey
+}
+
+// instanceIDMatching returns whether machines are matched to nodes by
+// instance ID.
+func (c NodeServingCert) instanceIDMatching() bool {
+	return c.NodeInstanceIDAnnotation != "" && c.MachineInstanceIDAnnotation != ""
+}
+
+// dnsAddressTypes returns the types of the machine addresses DNS names are
+// matched against.
+func (c NodeServingCert) dnsAddressTypes() []corev1.NodeAddressType {
+	if len(c.DNSAddressTypes) > 0 {
+		return c.DNSAddressTypes
+	}
+	return defaultDNSAddressTypes
+}
+
+// ipAddressTypes returns the types of the machine addresses IP addresses are
+// matched against.
+func (c NodeServingCert) ipAddressTypes() []corev1.NodeAddressType {
+	if len(c.IPAddressTypes) > 0 {
+		return c.IPAddressTypes
+	}
+	return defaultIPAddressTypes
+}
+
+// defaultKubeletPort returns the port kubelets are connected to when their
+// node does not report it, or 0 when unset.
+func (c NodeServingCert) defaultKubeletPort() int {
+	if c.DefaultKubeletPort != nil {
+		return *c.DefaultKubeletPort
+	}
+	return 0
+}
+
+// backoff returns the delay before retrying to reconcile a CSR after the given
+// number of consecutive failed attempts.
+func (c Retries) backoff(attempts int) time.Duration {
+	backoff, maxBackoff := defaultRetryInitialBackoff, defaultRetryMaxBackoff
+	if c.InitialBackoff.Duration > 0 {
+		backoff = c.InitialBackoff.Duration
+	}
+	if c.MaxBackoff.Duration > 0 {
+		maxBackoff = c.MaxBackoff.Duration
+	}
+	for i := 1; i < attempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		return maxBackoff
+	}
+	return backoff
+}
+
+// jitter randomly adds up to the requeue jitter fraction of the delay before
+// retrying to it.
+func (c Retries) jitter(backoff time.Duration) time.Duration {
+	if c.RequeueJitter == nil || *c.RequeueJitter <= 0 {
+		return backoff
+	}
+	return wait.Jitter(backoff, *c.RequeueJitter)
+}
+
+// window returns the period over which serving cert approvals are counted.
+func (c ApprovalRateLimit) window() time.Duration {
+	if c.Window.Duration > 0 {
+		return c.Window.Duration
+	}
+	return defaultServingApprovalWindow
 }
@@ -0,0 +1,737 @@
+package controller
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/yaml"
+)
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    ClusterMachineApproverConfig
+		wantErr bool
+	}{
+		{
+			name: "empty",
+			want: ClusterMachineApproverConfig{},
+		},
+		{
+			name:    "time window",
+			content: "clientCertTimeWindow: 6h\nclockSkew: 1m\n",
+			want: ClusterMachineApproverConfig{
+				ClientCertTimeWindow: metav1.Duration{Duration: 6 * time.Hour},
+				ClockSkew:            metav1.Duration{Duration: time.Minute},
+			},
+		},
+		{
+			name:    "machine scope",
+			content: "machineNamespace: openshift-machine-api\nmachineLabelSelector:\n  matchLabels:\n    role: worker\n",
+			want: ClusterMachineApproverConfig{
+				MachineNamespace:     "openshift-machine-api",
+				MachineLabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"role": "worker"}},
+			},
+		},
+		{
+			name:    "invalid machine label selector",
+			content: "machineLabelSelector:\n  matchExpressions:\n  - key: role\n    operator: Bogus\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "negative time window",
+			content: "clientCertTimeWindow: -6h\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "negative clock skew",
+			content: "nodeClientCert:\n  disabled: true\nclockSkew: -1m\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "kubelet connect timeout",
+			content: "kubeletConnectTimeout: 5s\n",
+			want: ClusterMachineApproverConfig{
+				KubeletConnectTimeout: metav1.Duration{Duration: 5 * time.Second},
+			},
+		},
+		{
+			name:    "reconcile timeout",
+			content: "reconcileTimeout: 45s\n",
+			want: ClusterMachineApproverConfig{
+				ReconcileTimeout: metav1.Duration{Duration: 45 * time.Second},
+			},
+		},
+		{
+			name:    "machine lookup grace period",
+			content: "nodeClientCert:\n  machineLookupGracePeriod: 1h\n",
+			want: ClusterMachineApproverConfig{
+				NodeClientCert: NodeClientCert{MachineLookupGracePeriod: metav1.Duration{Duration: time.Hour}},
+			},
+		},
+		{
+			name:    "negative machine lookup grace period",
+			content: "nodeClientCert:\n  machineLookupGracePeriod: -1h\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "negative reconcile timeout",
+			content: "reconcileTimeout: -5s\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "negative kubelet connect timeout",
+			content: "kubeletConnectTimeout: -5s\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "serving cert cache TTL",
+			content: "nodeServingCert:\n  currentCertCacheTTL: 1m\n",
+			want: ClusterMachineApproverConfig{
+				NodeServingCert: NodeServingCert{CurrentCertCacheTTL: metav1.Duration{Duration: time.Minute}},
+			},
+		},
+		{
+			name:    "address types",
+			content: "nodeServingCert:\n  dnsAddressTypes:\n  - InternalDNS\n  - PlatformDNS\n  ipAddressTypes:\n  - PlatformIP\n",
+			want: ClusterMachineApproverConfig{
+				NodeServingCert: NodeServingCert{
+					DNSAddressTypes: []corev1.NodeAddressType{corev1.NodeInternalDNS, "PlatformDNS"},
+					IPAddressTypes:  []corev1.NodeAddressType{"PlatformIP"},
+				},
+			},
+		},
+		{
+			name:    "address type both DNS and IP",
+			content: "nodeServingCert:\n  dnsAddressTypes:\n  - PlatformAddress\n  ipAddressTypes:\n  - PlatformAddress\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "cert validation skew",
+			content: "nodeServingCert:\n  certValidationSkew: 30s\n",
+			want: ClusterMachineApproverConfig{
+				NodeServingCert: NodeServingCert{CertValidationSkew: metav1.Duration{Duration: 30 * time.Second}},
+			},
+		},
+		{
+			name:    "negative cert validation skew",
+			content: "nodeServingCert:\n  certValidationSkew: -30s\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "default kubelet port",
+			content: "nodeServingCert:\n  defaultKubeletPort: 10250\n",
+			want: ClusterMachineApproverConfig{
+				NodeServingCert: NodeServingCert{DefaultKubeletPort: pointer.Int(10250)},
+			},
+		},
+		{
+			name:    "invalid default kubelet port",
+			content: "nodeServingCert:\n  defaultKubeletPort: 70000\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "node user",
+			content: "nodeUser: example:node\n",
+			want: ClusterMachineApproverConfig{
+				NodeUser: pointer.String("example:node"),
+			},
+		},
+		{
+			name:    "empty node user",
+			content: "nodeUser: \"\"\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "node user ending with a colon",
+			content: "nodeUser: \"system:node:\"\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "node name allow patterns",
+			content: "nodeNameAllowPatterns:\n- ip-10-0-*\n- worker-?\n",
+			want: ClusterMachineApproverConfig{
+				NodeNameAllowPatterns: []string{"ip-10-0-*", "worker-?"},
+			},
+		},
+		{
+			name:    "invalid node name allow pattern",
+			content: "nodeNameAllowPatterns:\n- worker-[\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "allowed machine phases",
+			content: "allowedMachinePhases:\n- Provisioned\n- Running\n",
+			want: ClusterMachineApproverConfig{
+				AllowedMachinePhases: []string{"Provisioned", "Running"},
+			},
+		},
+		{
+			name:    "empty allowed machine phase",
+			content: "allowedMachinePhases:\n- Running\n- \"\"\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "required organizations",
+			content: "nodeServingCert:\n  requiredOrganizations:\n  - example.com:kubelets\n",
+			want: ClusterMachineApproverConfig{
+				NodeServingCert: NodeServingCert{RequiredOrganizations: []string{"example.com:kubelets"}},
+			},
+		},
+		{
+			name:    "empty required organization",
+			content: "nodeServingCert:\n  requiredOrganizations:\n  - \"\"\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "requeue jitter",
+			content: "retries:\n  requeueJitter: 0.1\n",
+			want: ClusterMachineApproverConfig{
+				Retries: Retries{RequeueJitter: pointer.Float64(0.1)},
+			},
+		},
+		{
+			name:    "negative requeue jitter",
+			content: "retries:\n  requeueJitter: -0.1\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "machine cache",
+			content: "machineCache:\n  enabled: true\n  ttl: 1m\n",
+			want: ClusterMachineApproverConfig{
+				MachineCache: MachineCache{Enabled: true, TTL: metav1.Duration{Duration: time.Minute}},
+			},
+		},
+		{
+			name:    "negative machine cache TTL",
+			content: "machineCache:\n  ttl: -1m\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "kubelet server name",
+			content: "nodeServingCert:\n  kubeletServerName: NodeName\n",
+			want: ClusterMachineApproverConfig{
+				NodeServingCert: NodeServingCert{KubeletServerName: kubeletServerNameNodeName},
+			},
+		},
+		{
+			name:    "unknown kubelet server name",
+			content: "nodeServingCert:\n  kubeletServerName: Hostname\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "SAN address sources",
+			content: "nodeServingCert:\n  sanAddressSources: NodeOnly\n",
+			want: ClusterMachineApproverConfig{
+				NodeServingCert: NodeServingCert{SANAddressSources: sanAddressSourceNodeOnly},
+			},
+		},
+		{
+			name:    "unknown SAN address sources",
+			content: "nodeServingCert:\n  sanAddressSources: Machine\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "SPIFFE trust domain",
+			content: "nodeServingCert:\n  spiffe:\n    trustDomain: cluster.local\n",
+			want: ClusterMachineApproverConfig{
+				NodeServingCert: NodeServingCert{SPIFFE: SPIFFE{TrustDomain: "cluster.local"}},
+			},
+		},
+		{
+			name:    "invalid SPIFFE trust domain",
+			content: "nodeServingCert:\n  spiffe:\n    trustDomain: spiffe://cluster.local\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "decision trace size",
+			content: "decisionTrace:\n  size: 0\n",
+			want: ClusterMachineApproverConfig{
+				DecisionTrace: DecisionTrace{Size: pointer.Int(0)},
+			},
+		},
+		{
+			name:    "max concurrent kubelet dials",
+			content: "maxConcurrentKubeletDials: 20\n",
+			want:    ClusterMachineApproverConfig{MaxConcurrentKubeletDials: 20},
+		},
+		{
+			name:    "negative max concurrent kubelet dials",
+			content: "maxConcurrentKubeletDials: -1\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "internal DNS first label matching",
+			content: "nodeClientCert:\n  internalDNSFirstLabelMatching: true\n",
+			want: ClusterMachineApproverConfig{
+				NodeClientCert: NodeClientCert{InternalDNSFirstLabelMatching: true},
+			},
+		},
+		{
+			name:    "kubelet CA file",
+			content: "nodeServingCert:\n  kubeletCAFile: /etc/kubelet-ca/ca-bundle.crt\n",
+			want: ClusterMachineApproverConfig{
+				NodeServingCert: NodeServingCert{KubeletCAFile: "/etc/kubelet-ca/ca-bundle.crt"},
+			},
+		},
+		{
+			name:    "flows enabled",
+			content: "nodeClientCert:\n  enabled: false\nnodeServingCert:\n  enabled: true\n",
+			want: ClusterMachineApproverConfig{
+				NodeClientCert:  NodeClientCert{Enabled: pointer.Bool(false)},
+				NodeServingCert: NodeServingCert{Enabled: pointer.Bool(true)},
+			},
+		},
+		{
+			name:    "node client cert both enabled and disabled",
+			content: "nodeClientCert:\n  enabled: true\n  disabled: true\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "pause",
+			content: "pause:\n  namespace: ns\n  name: pause\n",
+			want: ClusterMachineApproverConfig{
+				Pause: Pause{Namespace: "ns", Name: "pause"},
+			},
+		},
+		{
+			name:    "source network",
+			content: "nodeClientCert:\n  sourceNetwork:\n    cidrs:\n    - 10.0.0.0/16\n",
+			want: ClusterMachineApproverConfig{
+				NodeClientCert: NodeClientCert{
+					SourceNetwork: SourceNetwork{
+						CIDRs:    []string{"10.0.0.0/16"},
+						networks: []*net.IPNet{{IP: net.IP{10, 0, 0, 0}, Mask: net.CIDRMask(16, 32)}},
+					},
+				},
+			},
+		},
+		{
+			name:    "invalid source network CIDR",
+			content: "nodeClientCert:\n  sourceNetwork:\n    cidrs:\n    - 10.0.0.0\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "pause without namespace",
+			content: "pause:\n  name: pause\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "instance ID annotations",
+			content: "nodeServingCert:\n  nodeInstanceIDAnnotation: example.com/instance-id\n  machineInstanceIDAnnotation: example.com/machine-instance-id\n",
+			want: ClusterMachineApproverConfig{
+				NodeServingCert: NodeServingCert{
+					NodeInstanceIDAnnotation:    "example.com/instance-id",
+					MachineInstanceIDAnnotation: "example.com/machine-instance-id",
+				},
+			},
+		},
+		{
+			name:    "provider ID matching",
+			content: "nodeServingCert:\n  providerIDMatching: true\n",
+			want: ClusterMachineApproverConfig{
+				NodeServingCert: NodeServingCert{ProviderIDMatching: true},
+			},
+		},
+		{
+			name:    "node instance ID annotation only",
+			content: "nodeServingCert:\n  nodeInstanceIDAnnotation: example.com/instance-id\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "machine address watch",
+			content: "machineAddressWatch:\n  enabled: true\n  interval: 1m\n",
+			want: ClusterMachineApproverConfig{
+				MachineAddressWatch: MachineAddressWatch{Enabled: true, Interval: metav1.Duration{Duration: time.Minute}},
+			},
+		},
+		{
+			name:    "negative machine address watch interval",
+			content: "machineAddressWatch:\n  interval: -1m\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "required SAN types",
+			content: "nodeServingCert:\n  requiredSANTypes:\n  - dns\n  - ip\n",
+			want: ClusterMachineApproverConfig{
+				NodeServingCert: NodeServingCert{RequiredSANTypes: []string{"dns", "ip"}},
+			},
+		},
+		{
+			name:    "unknown required SAN type",
+			content: "nodeServingCert:\n  requiredSANTypes:\n  - uri\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "additional kubelet CAs",
+			content: "nodeServingCert:\n  additionalKubeletCAs:\n  - file: /etc/kubelet-ca/ca.crt\n  - configMap:\n      namespace: kubelet-ca\n      name: additional\n",
+			want: ClusterMachineApproverConfig{
+				NodeServingCert: NodeServingCert{
+					AdditionalKubeletCAs: []KubeletCASource{
+						{File: "/etc/kubelet-ca/ca.crt"},
+						{ConfigMap: &KubeletCAConfigMap{Namespace: "kubelet-ca", Name: "additional"}},
+					},
+				},
+			},
+		},
+		{
+			name:    "additional kubelet CA with both file and ConfigMap",
+			content: "nodeServingCert:\n  additionalKubeletCAs:\n  - file: /etc/kubelet-ca/ca.crt\n    configMap:\n      namespace: kubelet-ca\n      name: additional\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "additional kubelet CA ConfigMap without namespace",
+			content: "nodeServingCert:\n  additionalKubeletCAs:\n  - configMap:\n      name: additional\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "negative decision trace size",
+			content: "decisionTrace:\n  size: -1\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "negative serving cert cache TTL",
+			content: "nodeServingCert:\n  currentCertCacheTTL: -1m\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "node ref refresh",
+			content: "nodeServingCert:\n  nodeRefRefresh:\n    attempts: 2\n    delay: 500ms\n",
+			want: ClusterMachineApproverConfig{
+				NodeServingCert: NodeServingCert{NodeRefRefresh: NodeRefRefresh{Attempts: 2, Delay: metav1.Duration{Duration: 500 * time.Millisecond}}},
+			},
+		},
+		{
+			name:    "too many node ref refresh attempts",
+			content: "nodeServingCert:\n  nodeRefRefresh:\n    attempts: 6\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "negative node ref refresh delay",
+			content: "nodeServingCert:\n  nodeRefRefresh:\n    attempts: 2\n    delay: -1s\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "key strength",
+			content: "minRSAKeyBits: 3072\nallowedKeyAlgorithms:\n- ECDSA-P256\n",
+			want: ClusterMachineApproverConfig{
+				MinRSAKeyBits:        pointer.Int(3072),
+				AllowedKeyAlgorithms: []string{keyAlgorithmECDSAP256},
+			},
+		},
+		{
+			name:    "unknown key algorithm",
+			content: "allowedKeyAlgorithms:\n- DSA\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "machine config operator namespaces",
+			content: "nodeClientCert:\n  machineConfigOperatorNamespaces:\n  - hosted-a\n  - hosted-b\n",
+			want: ClusterMachineApproverConfig{
+				NodeClientCert: NodeClientCert{MachineConfigOperatorNamespaces: []string{"hosted-a", "hosted-b"}},
+			},
+		},
+		{
+			name:    "invalid machine config operator namespace",
+			content: "nodeClientCert:\n  machineConfigOperatorNamespaces:\n  - Hosted_A\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "bootstrappers",
+			content: "nodeClientCert:\n  bootstrappers:\n  - username: system:serviceaccount:hosted:node-bootstrapper\n    groups:\n    - system:authenticated\n",
+			want: ClusterMachineApproverConfig{
+				NodeClientCert: NodeClientCert{
+					Bootstrappers: []NodeBootstrapper{{
+						Username: "system:serviceaccount:hosted:node-bootstrapper",
+						Groups:   []string{"system:authenticated"},
+					}},
+				},
+			},
+		},
+		{
+			name:    "bootstrapper without username",
+			content: "nodeClientCert:\n  bootstrappers:\n  - groups:\n    - system:authenticated\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "negative max extra DNS names",
+			content: "nodeServingCert:\n  maxExtraDNSNames: -1\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "unknown quarantine reason",
+			content: "quarantine:\n  enabled: true\n  reasons:\n  - panda\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "invalid kubelet version check max version",
+			content: "nodeServingCert:\n  kubeletVersionCheck:\n    enabled: true\n    maxVersion: panda\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "negative events per minute",
+			content: "events:\n  maxPerMinute: -1\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "negative events burst",
+			content: "events:\n  burst: -1\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "negative min machine age",
+			content: "nodeClientCert:\n  minMachineAge: -1m\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "unknown log redaction mode",
+			content: "logRedaction:\n  mode: panda\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "negative log redaction truncate length",
+			content: "logRedaction:\n  mode: truncate\n  truncateLength: -1\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "negative reconcile all rate",
+			content: "reconcileAll:\n  enabled: true\n  maxPerMinute: -1\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+		{
+			name:    "invalid",
+			content: "clockSkew: panda\n",
+			want:    ClusterMachineApproverConfig{},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatalf("failed to write config: %v", err)
+			}
+
+			got, err := LoadConfig(path)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadConfig() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigMissingFile(t *testing.T) {
+	if got, err := LoadConfig(""); err != nil || !reflect.DeepEqual(got, ClusterMachineApproverConfig{}) {
+		t.Errorf("LoadConfig() = %+v, %v, want default config", got, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "missing.yaml")
+	if got, err := LoadConfig(path); err == nil || !reflect.DeepEqual(got, ClusterMachineApproverConfig{}) {
+		t.Errorf("LoadConfig() = %+v, %v, want default config and an error", got, err)
+	}
+}
+
+func TestLoadConfigRoundTrip(t *testing.T) {
+	config := ClusterMachineApproverConfig{
+		AuditOnly:            true,
+		ClientCertTimeWindow: metav1.Duration{Duration: 6 * time.Hour},
+		MinRSAKeyBits:        pointer.Int(3072),
+		AllowedKeyAlgorithms: []string{keyAlgorithmECDSAP256},
+		MachineLabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"pool": "infra"}},
+		NodeServingCert: NodeServingCert{
+			MaxSANsPerCSR:       pointer.Int(5),
+			SerialReplayCheck:   SerialReplayCheck{Enabled: true, Deny: true},
+			KubeletServerName:   kubeletServerNameNodeName,
+			CurrentCertCacheTTL: metav1.Duration{Duration: time.Minute},
+		},
+		Retries: Retries{
+			MaxAttempts:    pointer.Int(10),
+			InitialBackoff: metav1.Duration{Duration: 10 * time.Second},
+			MaxBackoff:     metav1.Duration{Duration: time.Minute},
+			RequeueJitter:  pointer.Float64(0.1),
+		},
+	}
+
+	content, err := yaml.Marshal(config)
+	if err != nil {
+		t.Fatalf("failed to marshal config: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, content, 0600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	got, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, config) {
+		t.Errorf("LoadConfig() = %+v, want %+v", got, config)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  ClusterMachineApproverConfig
+		wantErr string
+	}{
+		{
+			name:   "default",
+			config: ClusterMachineApproverConfig{},
+		},
+		{
+			name: "initial backoff greater than max backoff",
+			config: ClusterMachineApproverConfig{
+				Retries: Retries{
+					InitialBackoff: metav1.Duration{Duration: time.Minute},
+					MaxBackoff:     metav1.Duration{Duration: 10 * time.Second},
+				},
+			},
+			wantErr: "retries.initialBackoff 1m0s must not be greater than retries.maxBackoff 10s",
+		},
+		{
+			// The default max backoff is used when unset.
+			name: "initial backoff without max backoff",
+			config: ClusterMachineApproverConfig{
+				Retries: Retries{InitialBackoff: metav1.Duration{Duration: time.Hour}},
+			},
+		},
+		{
+			name:    "invalid node name allow pattern",
+			config:  ClusterMachineApproverConfig{NodeNameAllowPatterns: []string{"ip-10-0-*", "worker-["}},
+			wantErr: `invalid nodeNameAllowPatterns pattern "worker-[": syntax error in pattern`,
+		},
+		{
+			name:    "empty allowed machine phase",
+			config:  ClusterMachineApproverConfig{AllowedMachinePhases: []string{"Running", ""}},
+			wantErr: "allowedMachinePhases must not contain empty phases",
+		},
+		{
+			name: "node addresses preferred with union SAN address sources",
+			config: ClusterMachineApproverConfig{
+				NodeServingCert: NodeServingCert{PreferNodeAddresses: true, SANAddressSources: sanAddressSourceUnion},
+			},
+		},
+		{
+			name: "node addresses preferred with machine SAN address sources",
+			config: ClusterMachineApproverConfig{
+				NodeServingCert: NodeServingCert{PreferNodeAddresses: true, SANAddressSources: sanAddressSourceMachineOnly},
+			},
+			wantErr: `nodeServingCert.preferNodeAddresses conflicts with nodeServingCert.sanAddressSources "MachineOnly"`,
+		},
+		{
+			name: "serial replay denied without check",
+			config: ClusterMachineApproverConfig{
+				NodeServingCert: NodeServingCert{SerialReplayCheck: SerialReplayCheck{Deny: true}},
+			},
+			wantErr: "nodeServingCert.serialReplayCheck.deny requires nodeServingCert.serialReplayCheck.enabled",
+		},
+		{
+			name: "control plane renewal required without renewal fast path",
+			config: ClusterMachineApproverConfig{
+				NodeServingCert: NodeServingCert{
+					DisableRenewalFastPath: true,
+					ControlPlane:           ControlPlaneServingCert{RequireRenewal: true},
+				},
+			},
+			wantErr: "nodeServingCert.controlPlane.requireRenewal requires the renewal fast path, but nodeServingCert.disableRenewalFastPath is set",
+		},
+		{
+			name: "negative connect timeout",
+			config: ClusterMachineApproverConfig{
+				KubeletConnectTimeout: metav1.Duration{Duration: -time.Second},
+			},
+			wantErr: "kubeletConnectTimeout must not be negative: -1s",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.Validate(); errString(err) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClientCertTimeWindow(t *testing.T) {
+	clockSkew, timeWindow := ClusterMachineApproverConfig{}.clientCertTimeWindow()
+	if clockSkew != maxMachineClockSkew || timeWindow != maxMachineDelta {
+		t.Errorf("expected defaults %s, %s, got %s, %s", maxMachineClockSkew, maxMachineDelta, clockSkew, timeWindow)
+	}
+
+	clockSkew, timeWindow = ClusterMachineApproverConfig{
+		ClientCertTimeWindow: metav1.Duration{Duration: 6 * time.Hour},
+		ClockSkew:            metav1.Duration{Duration: time.Minute},
+	}.clientCertTimeWindow()
+	if clockSkew != time.Minute || timeWindow != 6*time.Hour {
+		t.Errorf("expected configured values 1m0s, 6h0m0s, got %s, %s", clockSkew, timeWindow)
+	}
+}
+
+func TestKubeletConnectTimeout(t *testing.T) {
+	if got := (ClusterMachineApproverConfig{}).kubeletConnectTimeout(); got != defaultKubeletConnectTimeout {
+		t.Errorf("expected default %s, got %s", defaultKubeletConnectTimeout, got)
+	}
+	config := ClusterMachineApproverConfig{KubeletConnectTimeout: metav1.Duration{Duration: 5 * time.Second}}
+	if got := config.kubeletConnectTimeout(); got != 5*time.Second {
+		t.Errorf("expected configured timeout 5s, got %s", got)
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
+	"strings"
 	"sync/atomic"
+	"time"
 
 	machinehandlerpkg "github.com/openshift/cluster-machine-approver/pkg/machinehandler"
 	certificatesv1 "k8s.io/api/certificates/v1"
@@ -15,21 +17,29 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	certificatesv1client "k8s.io/client-go/kubernetes/typed/certificates/v1"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 const (
 	configNamespace            = "openshift-config-managed"
 	kubeletCAConfigMap         = "csr-controller-ca"
 	csrConditionApproveMessage = "This CSR was approved by the Node CSR Approver (cluster-machine-approver)"
+
+	// machineAnnotation is set on approved CSRs to the namespace and name of
+	// the machine they were matched to.
+	machineAnnotation = "machineapprover.openshift.io/machine"
 )
 
 // MachineApproverReconciler reconciles a machine-approver  object
@@ -43,35 +53,142 @@ type CertificateApprover struct {
 
 	Config           ClusterMachineApproverConfig
 	APIGroupVersions []schema.GroupVersion
+
+	// Recorder records events on CSRs. No events are recorded when unset.
+	Recorder record.EventRecorder
+
+	// Clock provides the current time. The real clock is used when unset.
+	Clock Clock
+
+	// ServingCertRetriever retrieves the serving certs presented by kubelets
+	// to authorize renewals. Kubelets are connected to when unset.
+	ServingCertRetriever ServingCertRetriever
+
+	// KubeletConnector connects to kubelets to retrieve their serving certs,
+	// when no ServingCertRetriever is set. Kubelets are connected to over the
+	// network when unset.
+	KubeletConnector KubeletConnector
+
+	servingSerials   servingSerialTracker
+	servingApprovals servingApprovalTracker
+	machineInstances machineInstanceTracker
+	events           eventLimiter
+	retries          retryTracker
+	kubeletCAs       kubeletCATracker
+	servingCerts     servingCertCache
+	kubeletDials     kubeletDialLimiter
+	machines         machineCache
+	machineAPI       machineAPIDetector
+	decisions        decisionTrace
+
+	// reconcileAllEvents enqueues the CSRs re-evaluated by the reconcile-all
+	// pass.
+	reconcileAllEvents chan event.GenericEvent
+	// kubeletCAFileEvents enqueues the pending CSRs when the kubelet CA file
+	// changes.
+	kubeletCAFileEvents chan event.GenericEvent
+	// machineAddressEvents enqueues the pending serving CSRs of nodes whose
+	// machine addresses changed.
+	machineAddressEvents chan event.GenericEvent
+}
+
+// Clock provides the current time. Any clock.PassiveClock, including the fake
+// clocks of k8s.io/utils/clock/testing, can be used.
+type Clock interface {
+	Now() time.Time
+}
+
+// clock returns the configured clock, or the real clock when unset.
+func (m *CertificateApprover) clock() Clock {
+	if m.Clock == nil {
+		return clock.RealClock{}
+	}
+	return m.Clock
 }
 
 func (m *CertificateApprover) SetupWithManager(mgr ctrl.Manager, options controller.Options) error {
+	recordFlowsEnabled(m.Config)
+	if !m.Config.NodeClientCert.enabled() {
+		klog.Infof("Approval of node client CSRs is disabled")
+	}
+	if !m.Config.NodeServingCert.enabled() {
+		klog.Infof("Approval of node serving CSRs is disabled")
+	}
+
+	// Runnables run only once elected as leader by default.
+	if m.Config.ReconcileAll.Enabled {
+		m.reconcileAllEvents = make(chan event.GenericEvent)
+		if err := mgr.Add(manager.RunnableFunc(m.reconcileAll)); err != nil {
+			return fmt.Errorf("failed to add reconcile-all pass: %w", err)
+		}
+	} else {
+		// The reconcile-all pass already re-evaluates pending CSRs, at a
+		// limited rate.
+		if err := mgr.Add(manager.RunnableFunc(m.syncPendingCSRs)); err != nil {
+			return fmt.Errorf("failed to add startup sync: %w", err)
+		}
+	}
+	if err := mgr.Add(manager.RunnableFunc(m.checkRenewalCapable)); err != nil {
+		return fmt.Errorf("failed to add renewal self-check: %w", err)
+	}
+	if len(m.Config.NodeServingCert.kubeletCAFiles()) > 0 {
+		m.kubeletCAFileEvents = make(chan event.GenericEvent)
+		if err := mgr.Add(manager.RunnableFunc(m.watchKubeletCAFiles)); err != nil {
+			return fmt.Errorf("failed to add kubelet CA file watcher: %w", err)
+		}
+	}
+	if m.Config.MachineAddressWatch.Enabled {
+		m.machineAddressEvents = make(chan event.GenericEvent)
+		if err := mgr.Add(manager.RunnableFunc(m.watchMachineAddresses)); err != nil {
+			return fmt.Errorf("failed to add machine address watch: %w", err)
+		}
+	}
 	return m.buildWithManager(mgr, options, m)
 }
 
 func (m *CertificateApprover) buildWithManager(mgr ctrl.Manager, options controller.Options, c reconcile.Reconciler) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	blder := ctrl.NewControllerManagedBy(mgr).
 		WithOptions(options).
 		For(&certificatesv1.CertificateSigningRequest{}, builder.WithPredicates(predicate.Funcs{
-			CreateFunc:  func(e event.CreateEvent) bool { return pendingCertFilter(e.Object) },
-			UpdateFunc:  func(e event.UpdateEvent) bool { return pendingCertFilter(e.ObjectNew) },
-			GenericFunc: func(e event.GenericEvent) bool { return pendingCertFilter(e.Object) },
+			CreateFunc:  func(e event.CreateEvent) bool { return m.pendingCertFilter(e.Object) },
+			UpdateFunc:  func(e event.UpdateEvent) bool { return m.pendingCertFilter(e.ObjectNew) },
+			GenericFunc: func(e event.GenericEvent) bool { return m.pendingCertFilter(e.Object) },
 			DeleteFunc:  func(e event.DeleteEvent) bool { return false },
 		})).
 		Watches(
 			&corev1.ConfigMap{},
 			handler.EnqueueRequestsFromMapFunc(m.toCSRs),
 			builder.WithPredicates(predicate.Funcs{
-				CreateFunc:  func(e event.CreateEvent) bool { return caConfigMapFilter(e.Object, nil) },
-				UpdateFunc:  func(e event.UpdateEvent) bool { return caConfigMapFilter(e.ObjectOld, e.ObjectNew) },
-				GenericFunc: func(e event.GenericEvent) bool { return caConfigMapFilter(e.Object, nil) },
-				DeleteFunc:  func(e event.DeleteEvent) bool { return false },
-			})).Complete(c)
+				CreateFunc: func(e event.CreateEvent) bool {
+					return caConfigMapFilter(e.Object, nil) || m.additionalKubeletCAConfigMapFilter(e.Object) || m.pauseConfigMapFilter(e.Object)
+				},
+				UpdateFunc: func(e event.UpdateEvent) bool {
+					return caConfigMapFilter(e.ObjectOld, e.ObjectNew) || m.additionalKubeletCAConfigMapFilter(e.ObjectNew) || m.pauseConfigMapFilter(e.ObjectNew)
+				},
+				GenericFunc: func(e event.GenericEvent) bool {
+					return caConfigMapFilter(e.Object, nil) || m.additionalKubeletCAConfigMapFilter(e.Object) || m.pauseConfigMapFilter(e.Object)
+				},
+				DeleteFunc: func(e event.DeleteEvent) bool {
+					return m.additionalKubeletCAConfigMapFilter(e.Object) || m.pauseConfigMapFilter(e.Object)
+				},
+			}))
+
+	if m.reconcileAllEvents != nil {
+		blder = blder.WatchesRawSource(&source.Channel{Source: m.reconcileAllEvents}, &handler.EnqueueRequestForObject{})
+	}
+	if m.kubeletCAFileEvents != nil {
+		blder = blder.WatchesRawSource(&source.Channel{Source: m.kubeletCAFileEvents}, handler.EnqueueRequestsFromMapFunc(m.toCSRs))
+	}
+	if m.machineAddressEvents != nil {
+		blder = blder.WatchesRawSource(&source.Channel{Source: m.machineAddressEvents}, &handler.EnqueueRequestForObject{})
+	}
+
+	return blder.Complete(c)
 }
 
-func pendingCertFilter(obj runtime.Object) bool {
+func (m *CertificateApprover) pendingCertFilter(obj runtime.Object) bool {
 	cert, ok := obj.(*certificatesv1.CertificateSigningRequest)
-	return ok && !isApproved(*cert) || (isRecentlyApproved(*cert) && !isApprovedByCMA(*cert))
+	return ok && !isApproved(*cert) || (isRecentlyApproved(*cert, m.clock().Now()) && !isApprovedByCMA(*cert))
 }
 
 func (m *CertificateApprover) toCSRs(ctx context.Context, obj client.Object) []reconcile.Request {
@@ -82,9 +199,10 @@ func (m *CertificateApprover) toCSRs(ctx context.Context, obj client.Object) []r
 		klog.Errorf("Unable to list pending CSRs: %v", err)
 		return nil
 	}
+	currentTime := m.clock().Now()
 	for _, csr := range list.Items {
 		// Only reconcile pending or recently approved by another controller
-		if isApproved(csr) && (!isRecentlyApproved(csr) || isApprovedByCMA(csr)) {
+		if isApproved(csr) && (!isRecentlyApproved(csr, currentTime) || isApprovedByCMA(csr)) {
 			continue
 		}
 		requests = append(requests, reconcile.Request{
@@ -122,23 +240,22 @@ func (m *CertificateApprover) Reconcile(ctx context.Context, req ctrl.Request) (
 		klog.Errorf("%v: Failed to list CSRs: %v", req.Name, err)
 		return reconcile.Result{}, fmt.Errorf("Failed to get CSRs: %w", err)
 	}
+	m.retries.prune(csrs.Items)
 
-	machineHandler := &machinehandlerpkg.MachineHandler{
-		Client:    m.MachineClient,
-		Config:    m.MachineRestCfg,
-		Ctx:       ctx,
-		Namespace: m.MachineNamespace,
+	// CSRs already approved or denied are still enqueued so that the count of
+	// pending CSRs is kept up to date, but there is nothing left to decide.
+	for _, csr := range csrs.Items {
+		if csr.Name == req.Name && (isApproved(csr) || isDenied(csr)) {
+			klog.V(2).Infof("%v: CSR is already approved or denied, skipping", req.Name)
+			atomic.StoreUint32(&PendingCSRs, uint32(recentlyPendingNodeCSRs(m.Config.NodeClientCert.bootstrappers(), m.Config.nodeUserPrefix(), csrs.Items, m.clock().Now())))
+			return reconcile.Result{}, nil
+		}
 	}
 
-	var machines []machinehandlerpkg.Machine
-
-	for _, apiGroupVersion := range m.APIGroupVersions {
-		newMachines, err := machineHandler.ListMachines(apiGroupVersion)
-		if err != nil {
-			klog.Errorf("%v: Failed to list machines in API group %v: %v", req.Name, apiGroupVersion, err)
-			return reconcile.Result{}, fmt.Errorf("Failed to list machines: %w", err)
-		}
-		machines = append(machines, newMachines...)
+	machines, err := m.getCachedMachines(ctx)
+	if err != nil {
+		klog.Errorf("%v: %v", req.Name, err)
+		return reconcile.Result{}, err
 	}
 
 	nodes := &corev1.NodeList{}
@@ -147,23 +264,25 @@ func (m *CertificateApprover) Reconcile(ctx context.Context, req ctrl.Request) (
 		return reconcile.Result{}, fmt.Errorf("Failed to get Nodes: %w", err)
 	}
 
-	if offLimits := reconcileLimits(req.Name, machines, nodes, csrs); offLimits {
+	if offLimits := reconcileLimits(m.Config.NodeClientCert.bootstrappers(), m.Config.nodeUserPrefix(), req.Name, machines, nodes, csrs, m.clock().Now()); offLimits {
 		// Stop all reconciliation
+		pendingLimitExceededTotal.Inc()
 		return reconcile.Result{}, nil
 	}
 
 	for _, csr := range csrs.Items {
 		if csr.Name == req.Name {
-			if err := m.reconcileCSR(csr, machines); err != nil {
-				return reconcile.Result{}, fmt.Errorf("could not reconcile CSR: %v", err)
+			if err := m.reconcileCSR(ctx, csr, machines); err != nil {
+				return m.retry(&csr, fmt.Errorf("could not reconcile CSR: %v", err))
 			}
+			m.retries.reset(csr.UID)
 
 			// Reconcile the limits at the end of a reconcile so that the currently
 			// pending CSRs metric has an up to date value if we approved a CSR.
 			// When an error occurs, we requeue and so update the limits on the
 			// next reconcile.
 			// Don't use a cached client here else we may not have up to date CSRs.
-			return reconcile.Result{}, reconcileLimitsUncached(m.NodeRestCfg, csr.Name, machines, nodes)
+			return reconcile.Result{}, reconcileLimitsUncached(m.NodeRestCfg, m.Config.NodeClientCert.bootstrappers(), m.Config.nodeUserPrefix(), csr.Name, machines, nodes, m.clock().Now())
 		}
 	}
 
@@ -172,11 +291,48 @@ func (m *CertificateApprover) Reconcile(ctx context.Context, req ctrl.Request) (
 	return reconcile.Result{}, nil
 }
 
+// machineHandler returns a handler of the machines CSRs are approved for.
+func (m *CertificateApprover) machineHandler(ctx context.Context) *machinehandlerpkg.MachineHandler {
+	namespace := m.MachineNamespace
+	if namespace == "" {
+		namespace = m.Config.MachineNamespace
+	}
+	return &machinehandlerpkg.MachineHandler{
+		Client:        m.MachineClient,
+		Config:        m.MachineRestCfg,
+		Ctx:           ctx,
+		Namespace:     namespace,
+		LabelSelector: m.Config.machineSelector(),
+	}
+}
+
+// listMachines lists the machines in all the API groups of the approver
+// machines are served in.
+func (m *CertificateApprover) listMachines(ctx context.Context) ([]machinehandlerpkg.Machine, error) {
+	apiGroupVersions, err := m.machineAPIGroups(ctx)
+	if err != nil {
+		return nil, err
+	}
+	machineHandler := m.machineHandler(ctx)
+
+	var machines []machinehandlerpkg.Machine
+
+	for _, apiGroupVersion := range apiGroupVersions {
+		newMachines, err := machineHandler.ListMachines(apiGroupVersion)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list machines in API group %v: %w", apiGroupVersion, err)
+		}
+		machines = append(machines, newMachines...)
+	}
+
+	return machines, nil
+}
+
 // reconcileLimits will short circut logic if number of pending CSRs is exceeding limit
-func reconcileLimits(csrName string, machines []machinehandlerpkg.Machine, nodes *corev1.NodeList, csrs *certificatesv1.CertificateSigningRequestList) bool {
+func reconcileLimits(bootstrappers []NodeBootstrapper, nodeUserPrefix string, csrName string, machines []machinehandlerpkg.Machine, nodes *corev1.NodeList, csrs *certificatesv1.CertificateSigningRequestList, currentTime time.Time) bool {
 	maxPending := getMaxPending(machines, nodes)
 	atomic.StoreUint32(&MaxPendingCSRs, uint32(maxPending))
-	pending := recentlyPendingNodeCSRs(csrs.Items)
+	pending := recentlyPendingNodeCSRs(bootstrappers, nodeUserPrefix, csrs.Items, currentTime)
 	atomic.StoreUint32(&PendingCSRs, uint32(pending))
 	if pending > maxPending {
 		klog.Errorf("%v: Pending CSRs: %d; Max pending allowed: %d. Difference between pending CSRs and machines > %v. Ignoring all CSRs as too many recent pending CSRs seen", csrName, pending, maxPending, maxDiffBetweenPendingCSRsAndMachinesCount)
@@ -189,7 +345,7 @@ func reconcileLimits(csrName string, machines []machinehandlerpkg.Machine, nodes
 // reconcileLimitsUncached is used to update the limits using an uncached certificates list.
 // This is used at the end of the approval process to ensure that the limits (and therefore)
 // the metrics are always up to date.
-func reconcileLimitsUncached(cfg *rest.Config, csrName string, machines []machinehandlerpkg.Machine, nodes *corev1.NodeList) error {
+func reconcileLimitsUncached(cfg *rest.Config, bootstrappers []NodeBootstrapper, nodeUserPrefix string, csrName string, machines []machinehandlerpkg.Machine, nodes *corev1.NodeList, currentTime time.Time) error {
 	certClient, err := certificatesv1client.NewForConfig(cfg)
 	if err != nil {
 		return fmt.Errorf("could not initialise certificates client: %v", err)
@@ -200,11 +356,11 @@ func reconcileLimitsUncached(cfg *rest.Config, csrName string, machines []machin
 		return fmt.Errorf("could not list CSRs: %v", err)
 	}
 
-	reconcileLimits(csrName, machines, nodes, certificates)
+	reconcileLimits(bootstrappers, nodeUserPrefix, csrName, machines, nodes, certificates, currentTime)
 	return nil
 }
 
-func (m *CertificateApprover) reconcileCSR(csr certificatesv1.CertificateSigningRequest, machines []machinehandlerpkg.Machine) error {
+func (m *CertificateApprover) reconcileCSR(ctx context.Context, csr certificatesv1.CertificateSigningRequest, machines []machinehandlerpkg.Machine) error {
 	// If a CSR is approved after being added to the queue, but before we reconcile it,
 	// it may have already been approved. If it has already been approved, trying to
 	// approve it again will result in an error and cause a loop.
@@ -213,74 +369,250 @@ func (m *CertificateApprover) reconcileCSR(csr certificatesv1.CertificateSigning
 		klog.Infof("%v: CSR is already approved", csr.Name)
 		return nil
 	}
+	if isDenied(csr) {
+		klog.Infof("%v: CSR is already denied", csr.Name)
+		return nil
+	}
+
+	// Quarantined CSRs are held for manual review.
+	if isQuarantined(csr) {
+		klog.Infof("%v: CSR is quarantined for manual review: %s", csr.Name, csr.Annotations[quarantinedAnnotation])
+		return nil
+	}
+
+	// Bound the time taken to decide, so that a slow kubelet or API server
+	// doesn't hold up the workers.
+	if timeout := m.Config.ReconcileTimeout.Duration; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	redact := newRedactor(m.Config.LogRedaction)
+	klog.V(4).InfoS("Evaluating CSR",
+		"csr", csr.Name,
+		"signerName", csr.Spec.SignerName,
+		"username", csr.Spec.Username,
+		"groups", csr.Spec.Groups,
+		"extra", redact.extra(csr.Spec.Extra),
+		"request", redact.value(string(csr.Spec.Request)),
+	)
 
 	parsedCSR, err := parseCSR(&csr)
 	if err != nil {
 		klog.Errorf("%v: Failed to parse csr: %v", csr.Name, err)
+		m.eventf(&csr, corev1.EventTypeWarning, csrDeniedEventReason, "error parsing request CSR: %v", err)
+		m.denyInvalid(&csr, decisionReasonInvalidRequest, fmt.Errorf("error parsing request CSR: %v", err))
+		m.logDecision(&csr, nil, AuthorizeResult{Reason: decisionReasonInvalidRequest, Err: err}, start)
 		return fmt.Errorf("error parsing request CSR: %v", err)
 	}
 
-	kubeletCA := m.getKubeletCA()
-	if kubeletCA == nil {
+	kubeletCAs := m.getKubeletCAs(ctx)
+	m.recordRenewalCapable(kubeletCAs)
+	if len(kubeletCAs) == 0 {
 		// This is not a fatal error.  The renewal authorization flow
 		// depending on the existing serving cert will be skipped.
 		klog.Errorf("failed to get kubelet CA")
 	}
 
-	if authorize, err := authorizeCSR(m.NodeClient, m.Config, machines, &csr, parsedCSR, kubeletCA); !authorize {
+	result := m.Authorize(ctx, machines, &csr, parsedCSR, kubeletCAs)
+	m.logDecision(&csr, parsedCSR, result, start)
+	machine, authorize, err := result.Machine, result.Authorized, result.Err
+	if !authorize {
 		// Don't deny since it might be someone else's CSR
 		klog.Infof("%s: CSR not authorized", csr.Name)
+		// Declines without an error have already been recorded.
+		if err != nil {
+			m.eventf(&csr, corev1.EventTypeWarning, csrDeniedEventReason, "%v", err)
+		}
+		if m.Config.AuditOnly {
+			m.auditDecision(nil, &csr, parsedCSR, false, err)
+		}
 		return err
 	}
 
+	if m.Config.AuditOnly {
+		// The CSR is left pending for it to be approved by other means.
+		m.auditDecision(machine, &csr, parsedCSR, true, nil)
+		return nil
+	}
+
+	if machine != nil {
+		m.setMachineAnnotation(ctx, &csr, machine)
+	}
 	if err := approve(m.NodeRestCfg, &csr); err != nil {
 		return fmt.Errorf("Unable to approve CSR %s: %w", csr.Name, err)
 	}
 	klog.Infof("CSR %s approved", csr.Name)
+	nodeName := strings.TrimPrefix(parsedCSR.Subject.CommonName, m.Config.nodeUserPrefix())
+	if !isNodeClientCert(m.Config.nodeUserPrefix(), &csr, parsedCSR) {
+		m.servingApproved(nodeName, parsedCSR)
+	}
+	if machine != nil {
+		m.eventf(&csr, corev1.EventTypeNormal, csrApprovedEventReason, "CSR approved for node %s of machine %s", nodeName, machine.Name)
+	} else {
+		m.eventf(&csr, corev1.EventTypeNormal, csrApprovedEventReason, "CSR approved for node %s", nodeName)
+	}
 
 	return nil
 }
 
-// getKubeletCA fetches the kubelet CA from the ConfigMap in the
-// openshift-config-managed namespace.
-func (m *CertificateApprover) getKubeletCA() *x509.CertPool {
-	configMap := &corev1.ConfigMap{}
-	key := client.ObjectKey{
-		Namespace: configNamespace,
-		Name:      kubeletCAConfigMap,
+// auditDecision logs the decision taken on a CSR in audit only mode.
+func (m *CertificateApprover) auditDecision(machine *machinehandlerpkg.Machine, req *certificatesv1.CertificateSigningRequest, csr *x509.CertificateRequest, authorize bool, err error) {
+	kind := csrKindServing
+	if isNodeClientCert(m.Config.nodeUserPrefix(), req, csr) {
+		kind = csrKindClient
 	}
-	if err := m.NodeClient.Get(context.Background(), key, configMap); err != nil {
-		klog.Errorf("failed to get kubelet CA: %v", err)
-		return nil
+	nodeName := strings.TrimPrefix(csr.Subject.CommonName, m.Config.nodeUserPrefix())
+	var machineName string
+	if machine != nil {
+		machineName = machine.Name
 	}
 
-	caBundle, ok := configMap.Data["ca-bundle.crt"]
-	if !ok {
-		klog.Errorf("no ca-bundle.crt in %s", kubeletCAConfigMap)
-		return nil
+	if authorize {
+		klog.Infof("AUDIT: CSR %s (%s) for node %s of machine %q would be approved", req.Name, kind, nodeName, machineName)
+		csrWouldApproveTotal.WithLabelValues(kind).Inc()
+		return
+	}
+	if err != nil {
+		klog.Infof("AUDIT: CSR %s (%s) for node %s of machine %q would not be approved: %v", req.Name, kind, nodeName, machineName, err)
+		return
 	}
+	klog.Infof("AUDIT: CSR %s (%s) for node %s of machine %q would not be approved, see above for the reason", req.Name, kind, nodeName, machineName)
+}
 
-	certPool := x509.NewCertPool()
+// Decisions logged in structured decision logs.
+const (
+	decisionApproved = "approved"
+	decisionDenied   = "denied"
+	decisionErrored  = "errored"
+)
 
-	if ok := certPool.AppendCertsFromPEM([]byte(caBundle)); !ok {
-		klog.Errorf("failed to parse ca-bundle.crt in %s", kubeletCAConfigMap)
+// logDecision records the decision taken on a node CSR evaluated since the
+// given time in the decision trace, and emits its structured decision log
+// line when enabled. The CSR request may be nil when it could not be parsed.
+// CSRs that are not node CSRs are not logged, they may be handled by another
+// approver.
+func (m *CertificateApprover) logDecision(req *certificatesv1.CertificateSigningRequest, csr *x509.CertificateRequest, result AuthorizeResult, start time.Time) {
+	if result.Reason == "" {
+		return
+	}
+
+	var kind, nodeName string
+	if csr != nil {
+		kind = csrKindServing
+		if isNodeClientCert(m.Config.nodeUserPrefix(), req, csr) {
+			kind = csrKindClient
+		}
+		nodeName = strings.TrimPrefix(csr.Subject.CommonName, m.Config.nodeUserPrefix())
+	}
+	decision := decisionDenied
+	switch {
+	case result.Err != nil:
+		decision = decisionErrored
+	case result.Authorized:
+		decision = decisionApproved
+	}
+	var machineName string
+	if result.Machine != nil {
+		machineName = result.Machine.Namespace + "/" + result.Machine.Name
+	}
+
+	m.decisions.add(m.Config.DecisionTrace.size(), tracedDecision{
+		CSR:            req.Name,
+		Type:           kind,
+		NodeName:       nodeName,
+		Decision:       decision,
+		Reason:         result.Reason,
+		MatchedMachine: machineName,
+		Timestamp:      m.clock().Now(),
+	})
+
+	if !m.Config.StructuredDecisionLog {
+		return
+	}
+	klog.InfoS("CSR decision",
+		"csr", req.Name,
+		"type", kind,
+		"username", req.Spec.Username,
+		"nodeName", nodeName,
+		"decision", decision,
+		"reason", result.Reason,
+		"matchedMachine", machineName,
+		"durationMs", time.Since(start).Milliseconds(),
+	)
+}
+
+// setMachineAnnotation annotates a CSR about to be approved with the
+// namespace and name of the machine it was matched to, for traceability.
+func (m *CertificateApprover) setMachineAnnotation(ctx context.Context, req *certificatesv1.CertificateSigningRequest, machine *machinehandlerpkg.Machine) {
+	value := machine.Namespace + "/" + machine.Name
+	if req.Annotations[machineAnnotation] == value {
+		return
+	}
+
+	patch := client.MergeFrom(req.DeepCopy())
+	if req.Annotations == nil {
+		req.Annotations = map[string]string{}
+	}
+	req.Annotations[machineAnnotation] = value
+
+	if err := m.NodeClient.Patch(ctx, req, patch); err != nil {
+		klog.Errorf("%v: Failed to set machine annotation %q: %v", req.Name, value, err)
+	}
+}
+
+// getKubeletCAs fetches the kubelet CA from the kubelet CA file when
+// configured, or from the ConfigMap in the openshift-config-managed namespace.
+// The CA it replaced, if it rotated while the controller is running, is
+// returned after it.
+func (m *CertificateApprover) getKubeletCAs(ctx context.Context) []*x509.CertPool {
+	bundles := m.kubeletCABundles(ctx)
+	if len(bundles) == 0 {
+		kubeletCACerts.Set(0)
 		return nil
 	}
 
-	return certPool
+	certPool, count := parseCABundles(bundles)
+	kubeletCACerts.Set(float64(count))
+	if count == 0 {
+		klog.Errorf("failed to parse kubelet CA bundle")
+		return nil
+	}
+
+	return m.kubeletCAs.observe(strings.Join(bundles, "\n"), certPool)
 }
 
 func approve(rest *rest.Config, csr *certificatesv1.CertificateSigningRequest) error {
-	needsupdate := false
 	now := metav1.Now()
-	condition := certificatesv1.CertificateSigningRequestCondition{
+	return updateApproval(rest, csr, certificatesv1.CertificateSigningRequestCondition{
 		Type:               certificatesv1.CertificateApproved,
 		Reason:             "NodeCSRApprove",
 		Message:            csrConditionApproveMessage,
 		LastUpdateTime:     now,
 		LastTransitionTime: now,
 		Status:             "True",
-	}
+	})
+}
+
+// deny sets the Denied condition on the CSR, with the given reason and message.
+func deny(rest *rest.Config, csr *certificatesv1.CertificateSigningRequest, reason, message string) error {
+	now := metav1.Now()
+	return updateApproval(rest, csr, certificatesv1.CertificateSigningRequestCondition{
+		Type:               certificatesv1.CertificateDenied,
+		Reason:             reason,
+		Message:            message,
+		LastUpdateTime:     now,
+		LastTransitionTime: now,
+		Status:             "True",
+	})
+}
+
+// updateApproval sets the condition on the CSR through its approval
+// subresource, unless the CSR already has it.
+func updateApproval(rest *rest.Config, csr *certificatesv1.CertificateSigningRequest, condition certificatesv1.CertificateSigningRequestCondition) error {
+	needsupdate := false
 
 	// Check if the new condition already exists, and change it only if there is a status
 	// transition (otherwise we should preserve the current last transition time).
@@ -0,0 +1,584 @@
+package controller
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"net"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr/funcr"
+	configv1 "github.com/openshift/api/config/v1"
+	machinehandlerpkg "github.com/openshift/cluster-machine-approver/pkg/machinehandler"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
+	testingclock "k8s.io/utils/clock/testing"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestReconcileCSRAuditOnly(t *testing.T) {
+	machines := []machinehandlerpkg.Machine{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "panda-machine",
+			CreationTimestamp: creationTimestamp(-5 * time.Minute),
+		},
+		Status: machinehandlerpkg.MachineStatus{
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalDNS, Address: "panda"},
+			},
+		},
+	}}
+	csr := certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "panda-csr",
+			CreationTimestamp: creationTimestamp(-time.Minute),
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Request: []byte(clientGood),
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageKeyEncipherment,
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageClientAuth,
+			},
+			Username: nodeBootstrapperUsername,
+			Groups:   nodeBootstrapperGroups.List(),
+		},
+	}
+
+	// No rest config is set, approving the CSR would fail.
+	approver := &CertificateApprover{
+		NodeClient: fake.NewFakeClient(),
+		Config:     ClusterMachineApproverConfig{AuditOnly: true},
+	}
+
+	before := counterValue(t, csrWouldApproveTotal.WithLabelValues(csrKindClient))
+
+	if err := approver.reconcileCSR(context.Background(), csr, machines); err != nil {
+		t.Fatalf("reconcileCSR() error = %v", err)
+	}
+
+	if after := counterValue(t, csrWouldApproveTotal.WithLabelValues(csrKindClient)); after != before+1 {
+		t.Errorf("expected would approve counter to be incremented from %v, got %v", before, after)
+	}
+}
+
+func TestReconcileCSRDeadline(t *testing.T) {
+	machines := []machinehandlerpkg.Machine{{
+		ObjectMeta: metav1.ObjectMeta{Name: "panda-machine"},
+		Status: machinehandlerpkg.MachineStatus{
+			NodeRef: &corev1.ObjectReference{Name: "panda"},
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalDNS, Address: "panda"},
+				{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+			},
+		},
+	}}
+	csr := certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "panda-serving-csr"},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Request: []byte(createCSR("system:node:panda", defaultOrgs, []net.IP{net.ParseIP("10.0.0.1")}, []string{"panda"})),
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageServerAuth,
+			},
+			SignerName: certificatesv1.KubeletServingSignerName,
+			Username:   "system:node:panda",
+			Groups:     []string{"system:authenticated", "system:nodes"},
+		},
+	}
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "panda"},
+		Status: corev1.NodeStatus{
+			Addresses: []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: "10.0.0.1"}},
+			DaemonEndpoints: corev1.NodeDaemonEndpoints{
+				KubeletEndpoint: corev1.DaemonEndpoint{Port: 10250},
+			},
+		},
+	}
+	caConfigMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: configNamespace, Name: kubeletCAConfigMap},
+		Data:       map[string]string{"ca-bundle.crt": rootCertGood},
+	}
+	network := &configv1.Network{ObjectMeta: metav1.ObjectMeta{Name: "cluster"}}
+
+	tests := []struct {
+		name       string
+		connector  KubeletConnector
+		wantReason string
+		wantErr    bool
+	}{
+		{
+			name: "kubelet refusing connections",
+			connector: func(ctx context.Context, addr string, tlsConfig *tls.Config) (tls.ConnectionState, error) {
+				return tls.ConnectionState{}, &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+			},
+			wantReason: decisionReasonMachine,
+		},
+		{
+			name: "kubelet slower than the deadline",
+			connector: func(ctx context.Context, addr string, tlsConfig *tls.Config) (tls.ConnectionState, error) {
+				<-ctx.Done()
+				return tls.ConnectionState{}, ctx.Err()
+			},
+			wantReason: decisionReasonDeadlineExceeded,
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			approver := &CertificateApprover{
+				NodeClient:       fake.NewFakeClient(node, caConfigMap, network),
+				Clock:            testingclock.NewFakePassiveClock(baseTime),
+				KubeletConnector: tt.connector,
+				Config: ClusterMachineApproverConfig{
+					AuditOnly:        true,
+					ReconcileTimeout: metav1.Duration{Duration: 100 * time.Millisecond},
+				},
+			}
+
+			// The slow kubelet would otherwise be waited for until the kubelet
+			// connect timeout.
+			err := approver.reconcileCSR(context.Background(), csr, machines)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("reconcileCSR() error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("reconcileCSR() error = %v, want deadline exceeded", err)
+			}
+			if decisions := approver.decisions.list(); len(decisions) != 1 || decisions[0].Reason != tt.wantReason {
+				t.Errorf("decisions = %+v, want reason %s", decisions, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestReconcileDecidedCSRs(t *testing.T) {
+	server := newMachineDiscoveryServer()
+	defer server.Close()
+
+	tests := []struct {
+		name          string
+		conditions    []certificatesv1.CertificateSigningRequestCondition
+		wantEvaluated bool
+	}{
+		{
+			name:       "approved",
+			conditions: []certificatesv1.CertificateSigningRequestCondition{{Type: certificatesv1.CertificateApproved}},
+		},
+		{
+			name:       "denied",
+			conditions: []certificatesv1.CertificateSigningRequestCondition{{Type: certificatesv1.CertificateDenied}},
+		},
+		{
+			name:          "pending",
+			wantEvaluated: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			csr := &certificatesv1.CertificateSigningRequest{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "panda-csr",
+					CreationTimestamp: metav1.NewTime(baseTime),
+				},
+				Spec: certificatesv1.CertificateSigningRequestSpec{
+					Request: []byte(clientGood),
+					Usages: []certificatesv1.KeyUsage{
+						certificatesv1.UsageKeyEncipherment,
+						certificatesv1.UsageDigitalSignature,
+						certificatesv1.UsageClientAuth,
+					},
+					SignerName: certificatesv1.KubeAPIServerClientKubeletSignerName,
+					Username:   nodeBootstrapperUsername,
+					Groups:     nodeBootstrapperGroups.List(),
+				},
+				Status: certificatesv1.CertificateSigningRequestStatus{Conditions: tt.conditions},
+			}
+
+			var machineLists int
+			machineClient := fake.NewClientBuilder().WithInterceptorFuncs(interceptor.Funcs{
+				List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+					machineLists++
+					return c.List(ctx, list, opts...)
+				},
+			}).Build()
+			nodeClient := fake.NewClientBuilder().WithObjects(csr).Build()
+			approver := &CertificateApprover{
+				MachineClient:    machineClient,
+				MachineRestCfg:   &rest.Config{Host: server.URL},
+				APIGroupVersions: []schema.GroupVersion{{Group: "machine.openshift.io"}},
+				NodeClient:       nodeClient,
+				Clock:            testingclock.NewFakePassiveClock(baseTime),
+			}
+
+			// Without machines, pending CSRs are declined with a reason and
+			// retried later.
+			result, err := approver.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKey{Name: csr.Name}})
+			if err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+			if evaluated := result.RequeueAfter > 0; evaluated != tt.wantEvaluated {
+				t.Errorf("Reconcile() = %+v, want CSR evaluated %v", result, tt.wantEvaluated)
+			}
+			if evaluated := machineLists > 0; evaluated != tt.wantEvaluated {
+				t.Errorf("machines listed %d times, want CSR evaluated %v", machineLists, tt.wantEvaluated)
+			}
+			if evaluated := len(approver.decisions.list()) > 0; evaluated != tt.wantEvaluated {
+				t.Errorf("decisions = %v, want CSR evaluated %v", approver.decisions.list(), tt.wantEvaluated)
+			}
+
+			got := &certificatesv1.CertificateSigningRequest{}
+			if err := nodeClient.Get(context.Background(), client.ObjectKey{Name: csr.Name}, got); err != nil {
+				t.Fatalf("failed to get CSR: %v", err)
+			}
+			if _, evaluated := got.Annotations[denialReasonAnnotation]; evaluated != tt.wantEvaluated {
+				t.Errorf("denial reason annotation = %q, want CSR evaluated %v", got.Annotations[denialReasonAnnotation], tt.wantEvaluated)
+			}
+		})
+	}
+}
+
+func TestReconcileSeparateMachineCluster(t *testing.T) {
+	server := newMachineDiscoveryServer()
+	defer server.Close()
+
+	// With hosted control planes, machines live in the management cluster
+	// while CSRs and nodes live in the guest cluster.
+	machine := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "machine.openshift.io/v1beta1",
+			"kind":       "Machine",
+			"metadata": map[string]interface{}{
+				"name":              "panda-machine",
+				"namespace":         "clusters-panda",
+				"creationTimestamp": baseTime.Add(-5 * time.Minute).Format(time.RFC3339),
+			},
+			"status": map[string]interface{}{
+				"addresses": []interface{}{
+					map[string]interface{}{"type": "InternalDNS", "address": "panda"},
+				},
+			},
+		},
+	}
+	csr := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "panda-csr",
+			CreationTimestamp: metav1.NewTime(baseTime),
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Request: []byte(clientGood),
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageKeyEncipherment,
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageClientAuth,
+			},
+			SignerName: certificatesv1.KubeAPIServerClientKubeletSignerName,
+			Username:   nodeBootstrapperUsername,
+			Groups:     nodeBootstrapperGroups.List(),
+		},
+	}
+
+	// Each client records the kinds of the objects read from its cluster.
+	recordingClient := func(kinds *[]string, objects ...client.Object) client.WithWatch {
+		return fake.NewClientBuilder().WithObjects(objects...).WithInterceptorFuncs(interceptor.Funcs{
+			Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+				*kinds = append(*kinds, reflect.TypeOf(obj).Elem().Name())
+				return c.Get(ctx, key, obj, opts...)
+			},
+			List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+				*kinds = append(*kinds, reflect.TypeOf(list).Elem().Name())
+				return c.List(ctx, list, opts...)
+			},
+		}).Build()
+	}
+	var machineKinds, nodeKinds []string
+	machineClient := recordingClient(&machineKinds, machine)
+	nodeClient := recordingClient(&nodeKinds, csr)
+
+	// The CSR would be approved in the guest cluster, for which no rest config
+	// is set.
+	approver := &CertificateApprover{
+		MachineClient:    machineClient,
+		MachineRestCfg:   &rest.Config{Host: server.URL},
+		APIGroupVersions: []schema.GroupVersion{{Group: "machine.openshift.io"}},
+		NodeClient:       nodeClient,
+		Clock:            testingclock.NewFakePassiveClock(baseTime),
+		Config:           ClusterMachineApproverConfig{AuditOnly: true},
+	}
+
+	machines, err := approver.getCachedMachines(context.Background())
+	if err != nil {
+		t.Fatalf("getCachedMachines() error = %v", err)
+	}
+	if err := approver.reconcileCSR(context.Background(), *csr, machines); err != nil {
+		t.Fatalf("reconcileCSR() error = %v", err)
+	}
+
+	decisions := approver.decisions.list()
+	if len(decisions) != 1 || decisions[0].Decision != decisionApproved || decisions[0].MatchedMachine != "clusters-panda/panda-machine" {
+		t.Errorf("decisions = %+v, want CSR approved for the machine of the management cluster", decisions)
+	}
+
+	for _, kind := range machineKinds {
+		if kind != "UnstructuredList" {
+			t.Errorf("%s read from the management cluster, want only machines", kind)
+		}
+	}
+	if len(machineKinds) == 0 {
+		t.Errorf("no machines listed from the management cluster")
+	}
+	for _, kind := range nodeKinds {
+		if kind == "Unstructured" || kind == "UnstructuredList" {
+			t.Errorf("machines read from the guest cluster")
+		}
+	}
+	if len(nodeKinds) == 0 {
+		t.Errorf("no node looked up in the guest cluster")
+	}
+}
+
+func TestReconcileCSRStructuredDecisionLog(t *testing.T) {
+	machines := []machinehandlerpkg.Machine{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "panda-machine",
+			Namespace:         "openshift-machine-api",
+			CreationTimestamp: creationTimestamp(-5 * time.Minute),
+		},
+		Status: machinehandlerpkg.MachineStatus{
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalDNS, Address: "panda"},
+			},
+		},
+	}}
+	csr := certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "panda-csr",
+			CreationTimestamp: creationTimestamp(-time.Minute),
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Request: []byte(clientGood),
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageKeyEncipherment,
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageClientAuth,
+			},
+			Username: nodeBootstrapperUsername,
+			Groups:   nodeBootstrapperGroups.List(),
+		},
+	}
+
+	tests := []struct {
+		name    string
+		enabled bool
+		objects []client.Object
+		want    map[string]interface{}
+	}{
+		{
+			name:    "approved",
+			enabled: true,
+			want: map[string]interface{}{
+				"csr":            "panda-csr",
+				"type":           csrKindClient,
+				"username":       nodeBootstrapperUsername,
+				"nodeName":       "panda",
+				"decision":       decisionApproved,
+				"reason":         decisionReasonMachine,
+				"matchedMachine": "openshift-machine-api/panda-machine",
+			},
+		},
+		{
+			name:    "denied",
+			enabled: true,
+			objects: []client.Object{&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "panda"}}},
+			want: map[string]interface{}{
+				"csr":            "panda-csr",
+				"type":           csrKindClient,
+				"username":       nodeBootstrapperUsername,
+				"nodeName":       "panda",
+				"decision":       decisionDenied,
+				"reason":         decisionReasonNodeExists,
+				"matchedMachine": "",
+			},
+		},
+		{
+			name: "disabled",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var decisions []map[string]interface{}
+			klog.SetLogger(funcr.NewJSON(func(obj string) {
+				line := map[string]interface{}{}
+				if err := json.Unmarshal([]byte(obj), &line); err != nil {
+					t.Errorf("failed to parse log line %s: %v", obj, err)
+				}
+				if line["msg"] == "CSR decision" {
+					decisions = append(decisions, line)
+				}
+			}, funcr.Options{}))
+			defer klog.ClearLogger()
+
+			// Decisions are only logged in audit only mode, no rest config is
+			// set to approve the CSR.
+			approver := &CertificateApprover{
+				NodeClient: fake.NewClientBuilder().WithObjects(tt.objects...).Build(),
+				Config: ClusterMachineApproverConfig{
+					AuditOnly:             true,
+					StructuredDecisionLog: tt.enabled,
+				},
+			}
+			approver.reconcileCSR(context.Background(), csr, machines)
+
+			if tt.want == nil {
+				if len(decisions) != 0 {
+					t.Errorf("expected no decision to be logged, got %v", decisions)
+				}
+				return
+			}
+			if len(decisions) != 1 {
+				t.Fatalf("expected one decision to be logged, got %v", decisions)
+			}
+			for key, want := range tt.want {
+				if got := decisions[0][key]; got != want {
+					t.Errorf("decision %s = %v, want %v", key, got, want)
+				}
+			}
+			if _, ok := decisions[0]["durationMs"].(float64); !ok {
+				t.Errorf("decision durationMs = %v, want a number", decisions[0]["durationMs"])
+			}
+		})
+	}
+}
+
+func TestListMachinesScope(t *testing.T) {
+	machine := func(name, namespace, role string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{
+			Object: map[string]interface{}{
+				"apiVersion": "machine.openshift.io/v1beta1",
+				"kind":       "Machine",
+				"metadata": map[string]interface{}{
+					"name":              name,
+					"namespace":         namespace,
+					"labels":            map[string]interface{}{"role": role},
+					"creationTimestamp": creationTimestamp(-5 * time.Minute).UTC().Format(time.RFC3339),
+				},
+				"status": map[string]interface{}{
+					"addresses": []interface{}{
+						map[string]interface{}{"type": "InternalDNS", "address": name},
+					},
+				},
+			},
+		}
+	}
+	server := newMachineDiscoveryServer()
+	defer server.Close()
+	machineClient := fake.NewClientBuilder().WithObjects(
+		machine("panda", "openshift-machine-api", "worker"),
+		machine("bamboo", "tenant", "worker"),
+		machine("koala", "openshift-machine-api", "infra"),
+	).Build()
+
+	tests := []struct {
+		name             string
+		namespace        string
+		config           ClusterMachineApproverConfig
+		wantMachineNames []string
+	}{
+		{
+			name:             "all machines",
+			wantMachineNames: []string{"bamboo", "koala", "panda"},
+		},
+		{
+			name:             "configured namespace",
+			config:           ClusterMachineApproverConfig{MachineNamespace: "openshift-machine-api"},
+			wantMachineNames: []string{"koala", "panda"},
+		},
+		{
+			name:             "namespace flag preferred",
+			namespace:        "tenant",
+			config:           ClusterMachineApproverConfig{MachineNamespace: "openshift-machine-api"},
+			wantMachineNames: []string{"bamboo"},
+		},
+		{
+			name: "configured label selector",
+			config: ClusterMachineApproverConfig{
+				MachineLabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"role": "worker"}},
+			},
+			wantMachineNames: []string{"bamboo", "panda"},
+		},
+		{
+			name: "configured namespace and label selector",
+			config: ClusterMachineApproverConfig{
+				MachineNamespace:     "tenant",
+				MachineLabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"role": "worker"}},
+			},
+			wantMachineNames: []string{"bamboo"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			approver := &CertificateApprover{
+				MachineClient:    machineClient,
+				MachineRestCfg:   &rest.Config{Host: server.URL},
+				MachineNamespace: tt.namespace,
+				APIGroupVersions: []schema.GroupVersion{{Group: "machine.openshift.io"}},
+				NodeClient:       fake.NewFakeClient(),
+				Config:           tt.config,
+				Clock:            testingclock.NewFakePassiveClock(baseTime),
+			}
+
+			machines, err := approver.listMachines(context.Background())
+			if err != nil {
+				t.Fatalf("listMachines() error = %v", err)
+			}
+			machineNames := []string{}
+			for _, machine := range machines {
+				machineNames = append(machineNames, machine.Name)
+			}
+			sort.Strings(machineNames)
+			if !reflect.DeepEqual(machineNames, tt.wantMachineNames) {
+				t.Fatalf("listMachines() = %v, want %v", machineNames, tt.wantMachineNames)
+			}
+
+			// The client CSR of the panda node is only approved when its
+			// machine is in scope.
+			req := &certificatesv1.CertificateSigningRequest{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "panda-csr",
+					CreationTimestamp: creationTimestamp(-time.Minute),
+				},
+				Spec: certificatesv1.CertificateSigningRequestSpec{
+					Request: []byte(clientGood),
+					Usages: []certificatesv1.KeyUsage{
+						certificatesv1.UsageKeyEncipherment,
+						certificatesv1.UsageDigitalSignature,
+						certificatesv1.UsageClientAuth,
+					},
+					Username: nodeBootstrapperUsername,
+					Groups:   nodeBootstrapperGroups.List(),
+				},
+			}
+			wantAuthorize := sets.NewString(machineNames...).Has("panda")
+			if _, authorize, _ := approver.authorizeCSR(context.Background(), machines, req, parseCR(t, clientGood), nil); authorize != wantAuthorize {
+				t.Errorf("authorizeCSR() = %v, want %v", authorize, wantAuthorize)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"fmt"
 	"net"
 	"net/url"
@@ -19,16 +20,17 @@ import (
 	certificatesv1 "k8s.io/api/certificates/v1"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimachineryvalidation "k8s.io/apimachinery/pkg/api/validation"
 	kerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/version"
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 const (
-	nodeUser       = "system:node"
-	nodeGroup      = "system:nodes"
-	nodeUserPrefix = nodeUser + ":"
+	defaultNodeUser = "system:node"
+	nodeGroup       = "system:nodes"
 
 	maxPendingDelta                           = time.Hour
 	maxApprovedDelta                          = 30 * time.Second
@@ -36,11 +38,44 @@ const (
 
 	nodeBootstrapperUsername = "system:serviceaccount:openshift-machine-config-operator:node-bootstrapper"
 
+	defaultSourceIPExtraKey = "source-ip"
+
+	// Defaults of the period around the creation of a machine during which
+	// client CSRs for its node are approved.
 	maxMachineClockSkew = 10 * time.Second
 	maxMachineDelta     = 2 * time.Hour
 
+	// defaultKubeletConnectTimeout bounds connecting to kubelets to retrieve
+	// their serving cert, including the TLS handshake.
+	defaultKubeletConnectTimeout = 30 * time.Second
+
+	// Names the serving cert presented by kubelets may be verified against.
+	kubeletServerNameAddress  = "Address"
+	kubeletServerNameNodeName = "NodeName"
+	kubeletServerNameNone     = "None"
+
+	// Sources of the addresses the names requested by serving CSRs are
+	// matched against.
+	sanAddressSourceMachineOnly = "MachineOnly"
+	sanAddressSourceNodeOnly    = "NodeOnly"
+	sanAddressSourceUnion       = "Union"
+
+	// Types of SANs serving CSRs may be required to request.
+	requiredSANTypeDNS = "dns"
+	requiredSANTypeIP  = "ip"
+
+	// defaultMaxSANsPerCSR limits the number of SANs of serving CSRs, as node
+	// serving certs only carry a handful of names.
+	defaultMaxSANsPerCSR = 10
+
 	networkTypeOpenShiftSDN = "OpenShiftSDN"
 	networkClusterName      = "cluster"
+
+	infrastructureClusterName = "cluster"
+
+	machineRoleLabel      = "machine.openshift.io/cluster-api-machine-role"
+	machineRoleMaster     = "master"
+	capiControlPlaneLabel = "cluster.x-k8s.io/control-plane"
 )
 
 var nodeBootstrapperGroups = sets.NewString(
@@ -49,12 +84,45 @@ var nodeBootstrapperGroups = sets.NewString(
 	"system:authenticated",
 )
 
-var now = time.Now
+// Default types of the machine addresses the names requested by serving CSRs
+// are matched against.
+var (
+	defaultDNSAddressTypes = []corev1.NodeAddressType{corev1.NodeInternalDNS, corev1.NodeExternalDNS, corev1.NodeHostName}
+	defaultIPAddressTypes  = []corev1.NodeAddressType{corev1.NodeInternalIP, corev1.NodeExternalIP}
+)
+
+var defaultNodeBootstrappers = []NodeBootstrapper{{
+	Username: nodeBootstrapperUsername,
+	Groups:   nodeBootstrapperGroups.List(),
+}}
+
+// machineConfigOperatorBootstrapper returns the node-bootstrapper service
+// account of the machine config operator running in the given namespace.
+func machineConfigOperatorBootstrapper(namespace string) NodeBootstrapper {
+	return NodeBootstrapper{
+		Username: fmt.Sprintf("system:serviceaccount:%s:node-bootstrapper", namespace),
+		Groups: sets.NewString(
+			"system:serviceaccounts:"+namespace,
+			"system:serviceaccounts",
+			"system:authenticated",
+		).List(),
+	}
+}
+
+// errNoKubeletPort is returned when a node does not report the port of its
+// kubelet, e.g. early in its life, and no default port is configured.
+var errNoKubeletPort = errors.New("no kubelet port")
+
+// errNoMachineAddresses is returned when the machine of a node has no
+// addresses the SANs of its serving CSRs can be matched against yet, e.g.
+// before the cloud provider populated them.
+var errNoMachineAddresses = errors.New("machine has no addresses yet")
 
 var MaxPendingCSRs uint32
 var PendingCSRs uint32
 
-func validateCSRContents(req *certificatesv1.CertificateSigningRequest, csr *x509.CertificateRequest) (string, error) {
+func validateCSRContents(config ClusterMachineApproverConfig, req *certificatesv1.CertificateSigningRequest, csr *x509.CertificateRequest) (string, error) {
+	nodeUserPrefix := config.nodeUserPrefix()
 	if !strings.HasPrefix(req.Spec.Username, nodeUserPrefix) {
 		klog.Infof("%v: CSR does not appear to be a node serving cert", req.Name)
 		return "", nil
@@ -105,6 +173,14 @@ func validateCSRContents(req *certificatesv1.CertificateSigningRequest, csr *x50
 		return "", fmt.Errorf("%q is missing usages", usageSet)
 	}
 
+	// Having the required usages doesn't prevent others, e.g. client auth, from
+	// taking the place of key encipherment.
+	if config.NodeServingCert.StrictUsages {
+		if unexpected := usageSet.Difference(sets.NewString(validationUsageSetLegacy...)); unexpected.Len() > 0 {
+			return "", fmt.Errorf("unexpected usages %q", unexpected.List())
+		}
+	}
+
 	// Check subject: O = system:nodes, CN = system:node:ip-10-0-152-205.ec2.internal
 	if csr.Subject.CommonName != req.Spec.Username {
 		return "", fmt.Errorf("Mismatched CommonName %s != %s", csr.Subject.CommonName, req.Spec.Username)
@@ -121,11 +197,84 @@ func validateCSRContents(req *certificatesv1.CertificateSigningRequest, csr *x50
 		return "", fmt.Errorf("Organization %v doesn't include %s", csr.Subject.Organization, nodeGroup)
 	}
 
+	// Additional organizations may be required by policy.
+	organizations := sets.NewString(csr.Subject.Organization...)
+	for _, org := range config.NodeServingCert.RequiredOrganizations {
+		if !organizations.Has(org) {
+			return "", fmt.Errorf("Organization %v doesn't include required %s", csr.Subject.Organization, org)
+		}
+	}
+
+	if err := validateServingURIs(config.NodeServingCert.SPIFFE, nodeAsking, csr.URIs); err != nil {
+		return "", err
+	}
+	if len(csr.EmailAddresses) > 0 {
+		return "", fmt.Errorf("Email SANs are not allowed: %v", csr.EmailAddresses)
+	}
+
+	for _, sanType := range config.NodeServingCert.RequiredSANTypes {
+		switch {
+		case sanType == requiredSANTypeDNS && len(csr.DNSNames) == 0:
+			return "", fmt.Errorf("CSR requests no DNS name SAN, required by nodeServingCert.requiredSANTypes")
+		case sanType == requiredSANTypeIP && len(csr.IPAddresses) == 0:
+			return "", fmt.Errorf("CSR requests no IP address SAN, required by nodeServingCert.requiredSANTypes")
+		}
+	}
+
+	// Kubelets are never reached on these, even when a machine erroneously
+	// reports one as its address.
+	for _, ip := range csr.IPAddresses {
+		switch {
+		case ip.IsLoopback():
+			return "", fmt.Errorf("loopback IP SAN is not allowed: %s", ip)
+		case ip.IsUnspecified():
+			return "", fmt.Errorf("unspecified IP SAN is not allowed: %s", ip)
+		case ip.IsLinkLocalUnicast():
+			return "", fmt.Errorf("link-local IP SAN is not allowed: %s", ip)
+		}
+	}
+
 	return nodeAsking, nil
 }
 
-// authorizeCSR authorizes the CertificateSigningRequest req for a node's client or server certificate.
-// csr should be the parsed CSR from req.Spec.Request.
+// AuthorizeResult is the outcome of the evaluation of a node CSR.
+type AuthorizeResult struct {
+	// Authorized is whether the CSR may be approved.
+	Authorized bool
+	// Reason is the authorization method used when authorized, or the reason
+	// why the CSR is not authorized. It is empty for CSRs that are not node
+	// CSRs.
+	Reason string
+	// Machine is the machine the CSR was matched to when authorized, if any.
+	Machine *machinehandlerpkg.Machine
+	// Err is set when the CSR is not authorized due to a possibly transient
+	// error, in which case it should be evaluated again later.
+	Err error
+}
+
+// ServingCertRetriever retrieves the serving cert currently presented by the
+// kubelet of a node, verified against the given CAs.
+type ServingCertRetriever interface {
+	ServingCert(ctx context.Context, nodeName string, cas []*x509.CertPool) (*x509.Certificate, error)
+}
+
+// authorizeCSR authorizes the CertificateSigningRequest req for a node's
+// client or server certificate, see Authorize.
+func (m *CertificateApprover) authorizeCSR(
+	ctx context.Context,
+	machines []machinehandlerpkg.Machine,
+	req *certificatesv1.CertificateSigningRequest,
+	csr *x509.CertificateRequest,
+	cas []*x509.CertPool,
+) (*machinehandlerpkg.Machine, bool, error) {
+	result := m.Authorize(ctx, machines, req, csr, cas)
+	return result.Machine, result.Authorized, result.Err
+}
+
+// Authorize authorizes the CertificateSigningRequest req for a node's client or server certificate.
+// csr should be the parsed CSR from req.Spec.Request. The CSR is only evaluated, not approved.
+// Nodes are looked up with the NodeClient, and the serving certs presented by kubelets are
+// retrieved with the ServingCertRetriever, both of which may be fakes.
 //
 // For client certificates, when the flow is not globally disabled:
 // The only information contained in the CSR is the future name of the node.  Thus we perform a best effort check:
@@ -137,39 +286,105 @@ func validateCSRContents(req *certificatesv1.CertificateSigningRequest, csr *x50
 // 5. CSR creation timestamp is very close to machine creation timestamp
 // 6. CSR is meant for node client auth based on usage, CN, etc
 //
-// For server certificates:
+// For server certificates, when the flow is not disabled:
 // Names contained in the CSR are checked against addresses in the corresponding node's machine status.
-func authorizeCSR(
-	c client.Client,
-	config ClusterMachineApproverConfig,
+//
+// No node CSR is authorized while approvals are paused by the pause ConfigMap.
+func (m *CertificateApprover) Authorize(
+	ctx context.Context,
 	machines []machinehandlerpkg.Machine,
 	req *certificatesv1.CertificateSigningRequest,
 	csr *x509.CertificateRequest,
-	ca *x509.CertPool,
-) (bool, error) {
+	cas []*x509.CertPool,
+) AuthorizeResult {
 	if req == nil || csr == nil {
-		klog.Errorf("authorizeCSR invalid request")
-		return false, nil
+		klog.Errorf("Authorize invalid request")
+		return AuthorizeResult{}
+	}
+
+	// The content of a CSR whose signature doesn't match its public key can't
+	// be trusted.
+	if err := csr.CheckSignature(); err != nil {
+		klog.Errorf("%v: CSR signature does not match its public key, cannot approve: %v", req.Name, err)
+		m.eventf(req, corev1.EventTypeWarning, csrDeniedEventReason, "CSR signature does not match its public key: %v", err)
+		csrInvalidSignatureTotal.Inc()
+		m.denyInvalid(req, denyReasonInvalidSignature, fmt.Errorf("CSR signature does not match its public key: %v", err))
+		return AuthorizeResult{Reason: denyReasonInvalidSignature}
 	}
 
-	if isNodeClientCert(req, csr) {
-		if config.NodeClientCert.Disabled {
-			klog.Errorf("%v: CSR rejected as the flow is disabled", req.Name)
-			return false, fmt.Errorf("CSR %s for node client cert rejected as the flow is disabled", req.Name)
+	if isNodeClientCert(m.Config.nodeUserPrefix(), req, csr) {
+		if result, paused := m.declineIfPaused(ctx, req, csrKindClient); paused {
+			return result
+		}
+		if !m.Config.NodeClientCert.enabled() {
+			klog.Errorf("%v: Node client CSR rejected as the flow is disabled", req.Name)
+			return m.decide(ctx, req, csrKindClient, decisionReasonFlowDisabled, nil, false, withKind(ErrFlowDisabled, fmt.Errorf("CSR %s for node client cert rejected as the flow is disabled", req.Name)))
 		}
-		return authorizeNodeClientCSR(c, machines, req, csr)
+		return m.authorizeNodeClientCSR(ctx, machines, req, csr)
 	}
 
 	klog.Infof("%v: CSR does not appear to be client csr", req.Name)
 	// node serving cert validation after this point
 
-	nodeAsking, err := validateCSRContents(req, csr)
+	nodeAsking, err := validateCSRContents(m.Config, req, csr)
 	if nodeAsking == "" || err != nil {
 		if err != nil {
-			//TODO: set annotation/emit event here.
 			klog.Errorf("%v: Unrecoverable serving cert error, cannot approve: %v", req.Name, err)
+			m.eventf(req, corev1.EventTypeWarning, csrDeniedEventReason, "%v", err)
+			m.denyInvalid(req, decisionReasonInvalidRequest, err)
+			return m.decide(ctx, req, csrKindServing, decisionReasonInvalidRequest, nil, false, nil)
+		}
+		// Not a node CSR, it may be handled by another approver.
+		return AuthorizeResult{}
+	}
+
+	if result, paused := m.declineIfPaused(ctx, req, csrKindServing); paused {
+		return result
+	}
+	if !m.Config.NodeServingCert.enabled() {
+		klog.Errorf("%v: Node serving CSR rejected as the flow is disabled", req.Name)
+		return m.decide(ctx, req, csrKindServing, decisionReasonFlowDisabled, nil, false, withKind(ErrFlowDisabled, fmt.Errorf("CSR %s for node serving cert rejected as the flow is disabled", req.Name)))
+	}
+
+	if !m.Config.nodeNameAllowed(nodeAsking) {
+		klog.Errorf("%v: node name %s does not match any allowed pattern, cannot approve", req.Name, nodeAsking)
+		m.eventf(req, corev1.EventTypeWarning, csrDeniedEventReason, "node name %s does not match any allowed pattern", nodeAsking)
+		return m.decide(ctx, req, csrKindServing, decisionReasonNodeNameNotAllowed, nil, false, nil)
+	}
+
+	if sans, maxSANs := len(csr.DNSNames)+len(csr.IPAddresses)+len(csr.URIs)+len(csr.EmailAddresses), m.Config.NodeServingCert.maxSANsPerCSR(); sans > maxSANs {
+		klog.Errorf("%v: CSR requests %d SANs, above the maximum of %d, cannot approve", req.Name, sans, maxSANs)
+		m.eventf(req, corev1.EventTypeWarning, csrDeniedEventReason, "CSR requests %d SANs, above the maximum of %d", sans, maxSANs)
+		m.denyInvalid(req, decisionReasonTooManySANs, fmt.Errorf("CSR requests %d SANs, above the maximum of %d", sans, maxSANs))
+		return m.decide(ctx, req, csrKindServing, decisionReasonTooManySANs, nil, false, nil)
+	}
+
+	if err := validatePublicKey(m.Config, csr); err != nil {
+		klog.Errorf("%v: Weak public key, cannot approve: %v", req.Name, err)
+		m.eventf(req, corev1.EventTypeWarning, csrDeniedEventReason, "%v", err)
+		m.denyInvalid(req, decisionReasonWeakKey, err)
+		return m.decide(ctx, req, csrKindServing, decisionReasonWeakKey, nil, false, nil)
+	}
+
+	if m.Config.NodeServingCert.KubeletVersionCheck.Enabled {
+		if err := validateKubeletVersion(ctx, m.NodeClient, m.Config.NodeServingCert.KubeletVersionCheck, machines, nodeAsking); err != nil && !m.overrideSoftFailure(req, overrideCheckKubeletVersion, err) {
+			klog.Errorf("%v: Kubelet version check failed, cannot approve: %v", req.Name, err)
+			// Return error so we requeue, in case the node is rolled back.
+			return m.decide(ctx, req, csrKindServing, decisionReasonKubeletVersion, nil, false, err)
+		}
+	}
+
+	if m.Config.NodeServingCert.NodeHostnameCheck {
+		matches, err := matchesNodeHostname(ctx, m.NodeClient, nodeAsking, csr)
+		if err != nil {
+			klog.Errorf("%v: Failed to check node hostname: %v", req.Name, err)
+			return m.decide(ctx, req, csrKindServing, decisionReasonNodeLookupFailed, nil, false, withKind(ErrTransientAPI, err))
+		}
+		if !matches {
+			klog.Errorf("%v: DNS name %s does not match the hostname reported by node %s, cannot approve", req.Name, csr.DNSNames[0], nodeAsking)
+			m.eventf(req, corev1.EventTypeWarning, csrDeniedEventReason, "DNS name %s does not match the hostname reported by node %s", csr.DNSNames[0], nodeAsking)
+			return m.decide(ctx, req, csrKindServing, decisionReasonNodeHostnameMismatch, nil, false, nil)
 		}
-		return false, nil
 	}
 
 	var approvalErrors []error
@@ -179,106 +394,421 @@ func authorizeCSR(
 	// the presented cert against the current Kubelet CA, will result in
 	// fallback to the original flow relying on the machine-api.
 	//
-	// This is only supported if we were given a CA to verify against.
+	// This is only supported if we were given a CA to verify against, and
+	// may be disabled when kubelets can't be reached.
 	var servingCert *x509.Certificate
-	if ca != nil {
+	var fallbackCause string
+	if m.Config.NodeServingCert.DisableRenewalFastPath {
+		klog.V(2).Infof("%v: Renewal using the current serving cert is disabled, not retrieving it", req.Name)
+		fallbackCause = renewalFallbackDisabled
+	} else if len(cas) > 0 {
 		var err error
-		servingCert, err = getServingCert(c, nodeAsking, ca)
-		if err != nil {
+		servingCert, err = m.servingCertRetriever().ServingCert(ctx, nodeAsking, cas)
+		if errors.Is(err, errNoKubeletPort) {
+			klog.Infof("%v: Node %s does not report its kubelet port yet, can't retrieve its current serving cert", req.Name, nodeAsking)
+			fallbackCause = renewalFallbackDialFailed
+		} else if err != nil {
 			klog.Infof("Failed to retrieve current serving cert: %v", err)
+			fallbackCause = renewalFallbackDialFailed
+		} else if servingCert == nil {
+			fallbackCause = renewalFallbackCertNotFound
+		}
+	} else {
+		fallbackCause = renewalFallbackNoCA
+	}
+
+	// Past the reconcile deadline, e.g. when the kubelet was slow to answer,
+	// the fallback would fail on every lookup.
+	if result, exceeded := m.declineIfDeadlineExceeded(ctx, req, csrKindServing); exceeded {
+		return result
+	}
+
+	// A kubelet presenting a serving cert that has already been superseded by a
+	// newer one may be replaying a stale cert to justify the renewal.
+	if servingCert != nil && m.Config.NodeServingCert.SerialReplayCheck.Enabled {
+		if err := m.observeServingSerial(ctx, nodeAsking, servingCert); err != nil {
+			klog.Warningf("%v: Possible replay of a stale serving cert: %v", req.Name, err)
+			if m.Config.NodeServingCert.SerialReplayCheck.Deny {
+				if m.quarantine(ctx, req, quarantineReasonStaleServingCert, err) {
+					return m.decide(ctx, req, csrKindServing, quarantineReasonStaleServingCert, nil, false, nil)
+				}
+				approvalErrors = append(approvalErrors, err)
+				servingCert = nil
+				fallbackCause = renewalFallbackStaleCert
+			}
 		}
 	}
 
-	x509VerificationOpts := x509.VerifyOptions{Roots: ca}
+	x509VerificationOpts := x509.VerifyOptions{CurrentTime: certValidationTime(servingCert, m.clock().Now(), m.Config.NodeServingCert.CertValidationSkew.Duration)}
 	if servingCert != nil {
 		klog.Infof("Found existing serving cert for %s", nodeAsking)
 
-		if err := authorizeServingRenewal(nodeAsking, csr, servingCert, x509VerificationOpts); err != nil {
+		// Names added by the renewal are matched against the same addresses
+		// as in the machine-api flow. Without the node, they are only matched
+		// against those of the machine, if any.
+		var sanNode *corev1.Node
+		if m.Config.NodeServingCert.sanAddressSource() != sanAddressSourceMachineOnly {
+			sanNode = &corev1.Node{}
+			if err := m.NodeClient.Get(ctx, client.ObjectKey{Name: nodeAsking}, sanNode); err != nil {
+				klog.Infof("%v: Failed to get node %s to match the names added by the renewal: %v", req.Name, nodeAsking, err)
+				sanNode = nil
+			}
+		}
+
+		// Nodes may legitimately switch to another key algorithm, but it
+		// should be noticed.
+		keyAlgorithmErr := verifyKeyAlgorithmContinuity(csr, servingCert)
+		if keyAlgorithmErr != nil {
+			klog.Warningf("%v: Renewing serving cert of node %s: %v", req.Name, nodeAsking, keyAlgorithmErr)
+		}
+
+		if err := authorizeServingRenewal(m.Config.nodeUserPrefix(), nodeAsking, csr, servingCert, nodeRefMachine(machines, nodeAsking), newServingSANOptions(m.Config.NodeServingCert, sanNode), cas, x509VerificationOpts); err != nil {
 			approvalErrors = append(approvalErrors, err)
 			klog.Infof("Could not use current serving cert for renewal: %v", err)
 			klog.Infof("Current SAN Values: %v, CSR SAN Values: %v",
 				certSANs(servingCert), csrSANs(csr))
+			fallbackCause = renewalFallbackRenewalInvalid
+		} else if keyAlgorithmErr != nil && m.Config.NodeServingCert.EnforceKeyAlgorithmContinuity {
+			approvalErrors = append(approvalErrors, keyAlgorithmErr)
+			klog.Infof("Could not use current serving cert for renewal: %v", keyAlgorithmErr)
+			fallbackCause = renewalFallbackRenewalInvalid
+		} else if m.Config.machineScoped() && nodeRefMachine(machines, nodeAsking) == nil {
+			// Nodes of machines out of scope may be handled by another
+			// approver.
+			err := fmt.Errorf("node %s has no machine in scope", nodeAsking)
+			approvalErrors = append(approvalErrors, err)
+			klog.Infof("Could not use current serving cert for renewal: %v", err)
+			fallbackCause = renewalFallbackRenewalInvalid
 		} else {
 			// No error, the renewal is authorized.
-			return true, nil
+			return m.authorizeServingApproval(ctx, req, nodeAsking, decisionReasonRenewal, nodeRefMachine(machines, nodeAsking))
+		}
+	}
+	m.recordRenewalFallback(req, nodeAsking, fallbackCause, approvalErrors)
+
+	// The node linker may not have set the node ref of the machine yet.
+	machines = m.refreshMachinesForNodeRef(ctx, machines, nodeAsking)
+
+	if m.Config.NodeServingCert.ControlPlane.RequireRenewal {
+		if machine, err := machinehandlerpkg.FindMatchingMachineFromNodeRef(machines, nodeAsking); err == nil && isControlPlaneMachine(machine) {
+			klog.Infof("%v: Control plane serving CSRs may only be approved by renewal, not falling back to machine-api authorization", req.Name)
+			approvalErrors = append(approvalErrors, fmt.Errorf("control plane serving cert for node %s can only be renewed", nodeAsking))
+			return m.decide(ctx, req, csrKindServing, decisionReasonRenewalRequired, nil, false, fmt.Errorf("could not authorize CSR: exhausted all authorization methods: %w", kerrors.NewAggregate(approvalErrors)))
+		}
+	}
+
+	// A node whose machine is being deleted is about to be drained and
+	// removed, it doesn't need a new serving cert.
+	if m.Config.NodeServingCert.RejectTerminatingMachineCSRs {
+		if machine := nodeRefMachine(machines, nodeAsking); machine != nil && machine.DeletionTimestamp != nil {
+			klog.Errorf("%v: machine %s of node %s is being deleted, cannot approve", req.Name, machine.Name, nodeAsking)
+			m.eventf(req, corev1.EventTypeWarning, csrDeniedEventReason, "machine %s of node %s is being deleted", machine.Name, nodeAsking)
+			return m.decide(ctx, req, csrKindServing, decisionReasonMachineTerminating, nil, false, nil)
+		}
+	}
+
+	if machine := nodeRefMachine(machines, nodeAsking); machine != nil && !m.Config.machinePhaseAllowed(machine.Status.Phase) {
+		return m.declineMachinePhase(ctx, req, csrKindServing, machine)
+	}
+
+	// Some platforms report node IPs in the machine provider status only.
+	var useProviderInterfaces bool
+	if platforms := m.Config.NodeServingCert.ProviderNetworkInterfaces.Platforms; len(platforms) > 0 {
+		platform, err := getPlatformType(ctx, m.NodeClient)
+		if err != nil {
+			klog.Infof("Could not determine platform: %v", err)
+			return m.decide(ctx, req, csrKindServing, decisionReasonPlatformLookupFailed, nil, false, withKind(ErrTransientAPI, fmt.Errorf("could not determine platform: %v", err)))
+		}
+		for _, p := range platforms {
+			if p == platform {
+				useProviderInterfaces = true
+				break
+			}
+		}
+	}
+
+	// On some platforms the node addresses, populated by the cloud provider,
+	// are more up to date than the machine addresses.
+	// The node is also needed to match its machine by instance ID or provider
+	// ID.
+	var node *corev1.Node
+	if m.Config.NodeServingCert.sanAddressSource() != sanAddressSourceMachineOnly || m.Config.NodeServingCert.instanceIDMatching() || m.Config.NodeServingCert.ProviderIDMatching {
+		node = &corev1.Node{}
+		if err := m.NodeClient.Get(ctx, client.ObjectKey{Name: nodeAsking}, node); err != nil {
+			klog.Errorf("%v: Failed to get node %s: %v", req.Name, nodeAsking, err)
+			return m.decide(ctx, req, csrKindServing, decisionReasonNodeLookupFailed, nil, false, withKind(ErrTransientAPI, fmt.Errorf("failed to get node %s: %v", nodeAsking, err)))
 		}
 	}
 
 	// Fall back to the original machine-api based authorization scheme.
 	klog.Infof("Falling back to machine-api authorization for %s", nodeAsking)
-	if err := authorizeServingCertWithMachine(machines, req, nodeAsking, csr); err != nil {
+	if targetMachine, err := authorizeServingCertWithMachine(m.Config, machines, req, nodeAsking, csr, useProviderInterfaces, node); err != nil {
+		var suspicious *suspiciousCSRError
+		if errors.As(err, &suspicious) && m.quarantine(ctx, req, suspicious.reason, suspicious.err) {
+			return m.decide(ctx, req, csrKindServing, suspicious.reason, nil, false, nil)
+		}
+		if errors.Is(err, machinehandlerpkg.ErrAmbiguousMachine) {
+			// Requeue rather than approving against the addresses of a
+			// possibly stale machine.
+			klog.Errorf("%v: %v, cannot approve", req.Name, err)
+			return m.decide(ctx, req, csrKindServing, decisionReasonAmbiguousMachine, nil, false, err)
+		}
+		if errors.Is(err, errNoMachineAddresses) {
+			klog.Infof("%v: %v, requeuing", req.Name, err)
+			return m.decide(ctx, req, csrKindServing, decisionReasonNoMachineAddresses, nil, false, err)
+		}
 		approvalErrors = append(approvalErrors, err)
 		klog.Infof("Could not use Machine for serving cert authorization: %v", err)
 	} else {
 		// No error means the machine was able to authorize the cert
-		return true, nil
+		return m.authorizeServingApproval(ctx, req, nodeAsking, decisionReasonMachine, targetMachine)
 	}
 
-	egressEnabled, err := needsEgressCheck(c)
+	egressEnabled, err := needsEgressCheck(ctx, m.NodeClient)
 	if err != nil {
 		klog.Infof("Could not determine if egress enabled: %v", err)
-		return false, fmt.Errorf("could not determine if egress enabled: %v", err)
+		return m.decide(ctx, req, csrKindServing, decisionReasonEgressLookupFailed, nil, false, withKind(ErrTransientAPI, fmt.Errorf("could not determine if egress enabled: %v", err)))
 	}
 
 	if servingCert != nil && egressEnabled {
 		klog.Infof("Falling back to serving cert renewal with Egress IP checks")
-		if err := authorizeServingRenewalWithEgressIPs(c, nodeAsking, csr, servingCert, x509VerificationOpts); err != nil {
+		if err := authorizeServingRenewalWithEgressIPs(ctx, m.NodeClient, m.Config.nodeUserPrefix(), nodeAsking, csr, servingCert, cas, x509VerificationOpts); err != nil {
 			approvalErrors = append(approvalErrors, err)
 			klog.Infof("Could not use current serving cert and egress IPs for renewal: %v", err)
 		} else {
 			// No error means the machine was able to authorize the cert
-			return true, nil
+			return m.authorizeServingApproval(ctx, req, nodeAsking, decisionReasonEgressIPRenewal, nodeRefMachine(machines, nodeAsking))
 		}
 	}
 
-	return false, fmt.Errorf("could not authorize CSR: exhausted all authorization methods: %v", kerrors.NewAggregate(approvalErrors))
+	return m.decide(ctx, req, csrKindServing, decisionReasonAuthorizationExhausted, nil, false, fmt.Errorf("could not authorize CSR: exhausted all authorization methods: %w", kerrors.NewAggregate(approvalErrors)))
 }
 
-func authorizeNodeClientCSR(c client.Client, machines []machinehandlerpkg.Machine, req *certificatesv1.CertificateSigningRequest, csr *x509.CertificateRequest) (bool, error) {
-	if !isReqFromNodeBootstrapper(req) {
+func (m *CertificateApprover) authorizeNodeClientCSR(ctx context.Context, machines []machinehandlerpkg.Machine, req *certificatesv1.CertificateSigningRequest, csr *x509.CertificateRequest) AuthorizeResult {
+	if !isReqFromNodeBootstrapper(m.Config.NodeClientCert.bootstrappers(), req) {
 		klog.Infof("%v: CSR does not appear to be a valid node bootstrapper client cert request", req.Name)
-		return false, nil
+		klog.V(4).InfoS("Unexpected node client CSR requestor",
+			"csr", req.Name,
+			"username", req.Spec.Username,
+			"groups", req.Spec.Groups,
+			"extra", newRedactor(m.Config.LogRedaction).extra(req.Spec.Extra),
+		)
+		return m.decide(ctx, req, csrKindClient, decisionReasonNotNodeBootstrapper, nil, false, nil)
+	}
+
+	if err := validateSourceNetwork(m.Config.NodeClientCert.SourceNetwork, req); err != nil {
+		if m.quarantine(ctx, req, quarantineReasonSourceNetwork, err) {
+			return m.decide(ctx, req, csrKindClient, decisionReasonSourceNetwork, nil, false, nil)
+		}
+		klog.Errorf("%v: %v, cannot approve", req.Name, err)
+		m.eventf(req, corev1.EventTypeWarning, csrDeniedEventReason, "%v", err)
+		return m.decide(ctx, req, csrKindClient, decisionReasonSourceNetwork, nil, false, nil)
 	}
 
-	nodeName := strings.TrimPrefix(csr.Subject.CommonName, nodeUserPrefix)
+	nodeName := strings.TrimPrefix(csr.Subject.CommonName, m.Config.nodeUserPrefix())
 	if len(nodeName) == 0 {
-		//TODO: set annotation/emit event here.
 		klog.Errorf("%v: CSR does not appear to be a valid node bootstrapper client cert request", req.Name)
-		return false, nil
+		m.eventf(req, corev1.EventTypeWarning, csrDeniedEventReason, "CSR does not appear to be a valid node bootstrapper client cert request")
+		m.denyInvalid(req, decisionReasonInvalidCommonName, fmt.Errorf("CSR does not appear to be a valid node bootstrapper client cert request"))
+		return m.decide(ctx, req, csrKindClient, decisionReasonInvalidCommonName, nil, false, nil)
+	}
+	// The node name is looked up, it must be a valid object name.
+	if errs := apimachineryvalidation.NameIsDNSSubdomain(nodeName, false); len(errs) > 0 {
+		err := fmt.Errorf("node name %q is not a valid DNS-1123 subdomain: %s", nodeName, strings.Join(errs, ", "))
+		klog.Errorf("%v: %v, cannot approve", req.Name, err)
+		m.eventf(req, corev1.EventTypeWarning, csrDeniedEventReason, "%v", err)
+		m.denyInvalid(req, decisionReasonInvalidCommonName, err)
+		return m.decide(ctx, req, csrKindClient, decisionReasonInvalidCommonName, nil, false, nil)
+	}
+
+	if !m.Config.nodeNameAllowed(nodeName) {
+		klog.Errorf("%v: node name %s does not match any allowed pattern, cannot approve", req.Name, nodeName)
+		m.eventf(req, corev1.EventTypeWarning, csrDeniedEventReason, "node name %s does not match any allowed pattern", nodeName)
+		return m.decide(ctx, req, csrKindClient, decisionReasonNodeNameNotAllowed, nil, false, nil)
+	}
+
+	if err := validatePublicKey(m.Config, csr); err != nil {
+		klog.Errorf("%v: Weak public key, cannot approve: %v", req.Name, err)
+		m.eventf(req, corev1.EventTypeWarning, csrDeniedEventReason, "%v", err)
+		m.denyInvalid(req, decisionReasonWeakKey, err)
+		return m.decide(ctx, req, csrKindClient, decisionReasonWeakKey, nil, false, nil)
 	}
 
-	if err := c.Get(context.Background(), client.ObjectKey{Name: nodeName}, &corev1.Node{}); err != nil && !apierrors.IsNotFound(err) {
+	node := &corev1.Node{}
+	if err := m.NodeClient.Get(ctx, client.ObjectKey{Name: nodeName}, node); err != nil && !apierrors.IsNotFound(err) {
 		// possible transient API error, requeue
 		klog.Errorf("%v: unable to get node %s error: %v", req.Name, nodeName, err)
-		return false, fmt.Errorf("failed get existing nodes %s", nodeName)
+		return m.decide(ctx, req, csrKindClient, decisionReasonNodeLookupFailed, nil, false, withKind(ErrTransientAPI, fmt.Errorf("failed get existing nodes %s", nodeName)))
 	} else if err == nil {
-		//TODO: set annotation/emit event here.
-		klog.Errorf("%v: node %s already exists, cannot approve", req.Name, nodeName)
-		return false, nil
+		return m.declineExistingNode(ctx, req, machines, node)
 	}
 
 	nodeMachine, err := machinehandlerpkg.FindMatchingMachineFromInternalDNS(machines, nodeName)
+	if err != nil && !errors.Is(err, machinehandlerpkg.ErrAmbiguousMachine) && m.Config.NodeClientCert.InternalDNSFirstLabelMatching {
+		nodeMachine, err = machinehandlerpkg.FindMatchingMachineFromInternalDNSFirstLabel(machines, nodeName)
+	}
+	if err != nil && !errors.Is(err, machinehandlerpkg.ErrAmbiguousMachine) && m.Config.NodeClientCert.ProviderIDMatching.NodeNameAnnotation != "" {
+		nodeMachine, err = findMatchingMachineFromProviderID(m.Config.NodeClientCert.ProviderIDMatching, machines, nodeName)
+	}
+	if errors.Is(err, machinehandlerpkg.ErrAmbiguousMachine) {
+		klog.Errorf("%v: %v, cannot approve", req.Name, err)
+		return m.decide(ctx, req, csrKindClient, decisionReasonAmbiguousMachine, nil, false, err)
+	}
 	if err != nil {
-		//TODO: set annotation/emit event here.
 		klog.Errorf("%v: failed to find machine for node %s, cannot approve", req.Name, nodeName)
-		return false, fmt.Errorf("failed to find machine for node %s", nodeName)
+		if grace := m.Config.NodeClientCert.MachineLookupGracePeriod.Duration; grace > 0 && m.clock().Now().Sub(req.CreationTimestamp.Time) > grace {
+			return m.declineMachineLookupExpired(ctx, req, nodeName, grace)
+		}
+		return m.decide(ctx, req, csrKindClient, decisionReasonMachineNotFound, nil, false, withKind(ErrNoMatchingMachine, fmt.Errorf("failed to find machine for node %s", nodeName)))
 	}
 
 	if nodeMachine.Status.NodeRef != nil {
-		//TODO: set annotation/emit event here.
 		klog.Errorf("%v: machine for node %v already has node ref, cannot approve", nodeMachine.Status.NodeRef)
-		return false, nil
+		m.eventf(req, corev1.EventTypeWarning, csrDeniedEventReason, "machine %s for node %s already has node ref %s", nodeMachine.Name, nodeName, nodeMachine.Status.NodeRef.Name)
+		return m.decide(ctx, req, csrKindClient, decisionReasonNodeRefExists, nil, false, nil)
+	}
+
+	if !m.Config.machinePhaseAllowed(nodeMachine.Status.Phase) {
+		return m.declineMachinePhase(ctx, req, csrKindClient, nodeMachine)
+	}
+
+	// A CSR arriving right after the machine was created may have been
+	// pre-staged, hold it until the machine is old enough.
+	if minAge := m.Config.NodeClientCert.MinMachineAge.Duration; minAge > 0 {
+		if age := m.clock().Now().Sub(nodeMachine.CreationTimestamp.Time); age < minAge {
+			klog.Infof("%v: machine %s created %s ago, below minimum age %s, requeuing", req.Name, nodeMachine.Name, age, minAge)
+			return m.decide(ctx, req, csrKindClient, decisionReasonMachineTooRecent, nil, false, withKind(ErrMachineNotReady, fmt.Errorf("machine %s created %s ago, below minimum age %s", nodeMachine.Name, age, minAge)))
+		}
 	}
 
-	start := nodeMachine.ObjectMeta.CreationTimestamp.Add(-maxMachineClockSkew)
-	end := nodeMachine.ObjectMeta.CreationTimestamp.Add(maxMachineDelta)
+	// The provider instance may have been replaced after the CSR was created,
+	// in which case the CSR was requested by an instance that no longer exists.
+	if m.Config.NodeClientCert.RejectReplacedInstances {
+		if replacedAt := m.machineInstances.observe(nodeMachine, m.clock().Now()); !replacedAt.IsZero() && req.CreationTimestamp.Time.Before(replacedAt) {
+			if m.quarantine(ctx, req, quarantineReasonInstanceReplaced, fmt.Errorf("instance for machine %s was replaced at %s", nodeMachine.Name, replacedAt)) {
+				return m.decide(ctx, req, csrKindClient, quarantineReasonInstanceReplaced, nil, false, nil)
+			}
+			klog.Errorf("%v: instance for machine %s was replaced at %s, after CSR creation at %s, cannot approve", req.Name, nodeMachine.Name, replacedAt, req.CreationTimestamp.Time)
+			m.eventf(req, corev1.EventTypeWarning, csrDeniedEventReason, "instance for machine %s was replaced at %s, after CSR creation at %s", nodeMachine.Name, replacedAt, req.CreationTimestamp.Time)
+			return m.decide(ctx, req, csrKindClient, quarantineReasonInstanceReplaced, nil, false, nil)
+		}
+	}
+
+	clockSkew, timeWindow := m.Config.clientCertTimeWindow()
+	start := nodeMachine.ObjectMeta.CreationTimestamp.Add(-clockSkew)
+	end := nodeMachine.ObjectMeta.CreationTimestamp.Add(timeWindow)
 	if !inTimeSpan(start, end, req.CreationTimestamp.Time) {
-		//TODO: set annotation/emit event here.
-		klog.Errorf("%v: CSR creation time %s not in range (%s, %s)", req.Name, req.CreationTimestamp.Time, start, end)
-		return false, nil
+		// Tell clocks skewed between the node and the cluster apart from
+		// machines taking too long to boot.
+		offset := req.CreationTimestamp.Time.Sub(nodeMachine.ObjectMeta.CreationTimestamp.Time)
+		reason := decisionReasonCSRPastTimeWindow
+		err := fmt.Errorf("CSR created %s after machine %s, beyond the time window of %s", offset, nodeMachine.Name, timeWindow)
+		if !req.CreationTimestamp.Time.After(start) {
+			reason = decisionReasonCSRPredatesMachine
+			err = fmt.Errorf("CSR created %s before machine %s, beyond the clock skew of %s", -offset, nodeMachine.Name, clockSkew)
+		}
+		klog.Errorf("%v: %v", req.Name, err)
+		m.eventf(req, corev1.EventTypeWarning, csrDeniedEventReason, "%v", err)
+		return m.decide(ctx, req, csrKindClient, reason, nil, false, nil)
+	}
+
+	machineToApprovalSeconds.Observe(m.clock().Now().Sub(nodeMachine.CreationTimestamp.Time).Seconds())
+	return m.decide(ctx, req, csrKindClient, decisionReasonMachine, nodeMachine, true, nil) // approve node client cert
+}
+
+// declineExistingNode declines a client CSR requested for a node that already
+// exists. A node already linked to its machine, with the same provider ID, is
+// likely re-requesting a client cert, e.g. after its kubelet lost it, and only
+// needs to renew it. Any other existing node is an unexpected collision with
+// the node name, worth a warning.
+func (m *CertificateApprover) declineExistingNode(ctx context.Context, req *certificatesv1.CertificateSigningRequest, machines []machinehandlerpkg.Machine, node *corev1.Node) AuthorizeResult {
+	machine, err := machinehandlerpkg.FindMatchingMachineFromNodeRef(machines, node.Name)
+	if err != nil {
+		klog.Errorf("%v: node %s already exists, cannot approve", req.Name, node.Name)
+		m.eventf(req, corev1.EventTypeWarning, csrDeniedEventReason, "node %s already exists", node.Name)
+		return m.decide(ctx, req, csrKindClient, decisionReasonNodeExists, nil, false, nil)
+	}
+
+	if machine.Spec.ProviderID != nil && node.Spec.ProviderID != "" && *machine.Spec.ProviderID != node.Spec.ProviderID {
+		klog.Errorf("%v: node %s already exists with provider ID %s, not the one of its machine %s, cannot approve", req.Name, node.Name, node.Spec.ProviderID, machine.Name)
+		m.eventf(req, corev1.EventTypeWarning, csrDeniedEventReason, "node %s already exists with provider ID %s, not the one of its machine %s", node.Name, node.Spec.ProviderID, machine.Name)
+		return m.decide(ctx, req, csrKindClient, decisionReasonNodeExists, nil, false, nil)
+	}
+
+	klog.Infof("%v: node %s already exists for machine %s, not approving the client CSR re-requested for it", req.Name, node.Name, machine.Name)
+	m.eventf(req, corev1.EventTypeNormal, csrDeniedEventReason, "node %s already exists for machine %s, its client cert must be renewed instead", node.Name, machine.Name)
+	return m.decide(ctx, req, csrKindClient, decisionReasonNodeReRequested, nil, false, nil)
+}
+
+// declineMachineLookupExpired declines a client CSR no machine matched within
+// the machine lookup grace period after its creation. The CSR is no longer
+// requeued, the machine of its node is unlikely to ever appear.
+func (m *CertificateApprover) declineMachineLookupExpired(ctx context.Context, req *certificatesv1.CertificateSigningRequest, nodeName string, grace time.Duration) AuthorizeResult {
+	err := withKind(ErrNoMatchingMachine, fmt.Errorf("no machine found for node %s within %s of CSR creation", nodeName, grace))
+	klog.Errorf("%v: %v, giving up", req.Name, err)
+	m.eventf(req, corev1.EventTypeWarning, csrDeniedEventReason, "%v", err)
+	m.denyInvalid(req, decisionReasonMachineLookupExpired, err)
+	return m.decide(ctx, req, csrKindClient, decisionReasonMachineLookupExpired, nil, false, nil)
+}
+
+// declineMachinePhase declines a CSR authorized against a machine in a phase
+// that is not allowed. The CSR is requeued, as the machine may still progress
+// to an allowed phase, e.g. from Provisioning to Provisioned.
+func (m *CertificateApprover) declineMachinePhase(ctx context.Context, req *certificatesv1.CertificateSigningRequest, kind string, machine *machinehandlerpkg.Machine) AuthorizeResult {
+	err := withKind(ErrMachineNotReady, fmt.Errorf("machine %s is in phase %q, not one of the allowed phases %v", machine.Name, machine.Status.Phase, m.Config.AllowedMachinePhases))
+	klog.Errorf("%v: %v, requeuing", req.Name, err)
+	m.eventf(req, corev1.EventTypeWarning, csrDeniedEventReason, "%v", err)
+	return m.decide(ctx, req, kind, decisionReasonMachinePhase, nil, false, err)
+}
+
+// declineIfDeadlineExceeded declines a CSR whose reconcile deadline has been
+// exceeded. The CSR is requeued, to be evaluated again from the start.
+func (m *CertificateApprover) declineIfDeadlineExceeded(ctx context.Context, req *certificatesv1.CertificateSigningRequest, kind string) (AuthorizeResult, bool) {
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return AuthorizeResult{}, false
+	}
+
+	err := fmt.Errorf("reconcile deadline of %s exceeded: %w", m.Config.ReconcileTimeout.Duration, ctx.Err())
+	klog.Warningf("%v: %v, requeuing", req.Name, err)
+	return m.decide(ctx, req, kind, decisionReasonDeadlineExceeded, nil, false, err), true
+}
+
+// validateSourceNetwork checks that the source IP address recorded in the extra
+// user info of the CSR is within one of the configured networks.
+// When no networks are configured, or the CSR carries no source IP address,
+// the check is skipped.
+func validateSourceNetwork(sourceNetwork SourceNetwork, req *certificatesv1.CertificateSigningRequest) error {
+	if len(sourceNetwork.CIDRs) == 0 {
+		return nil
+	}
+
+	extraKey := sourceNetwork.ExtraKey
+	if extraKey == "" {
+		extraKey = defaultSourceIPExtraKey
 	}
 
-	return true, nil // approve node client cert
+	sourceIPs := req.Spec.Extra[extraKey]
+	if len(sourceIPs) == 0 {
+		klog.Infof("%v: CSR has no source IP in %q, skipping source network check", req.Name, extraKey)
+		return nil
+	}
+
+	cidrs, err := sourceNetwork.allowedNetworks()
+	if err != nil {
+		return err
+	}
+
+	for _, sourceIP := range sourceIPs {
+		ip := net.ParseIP(sourceIP)
+		if ip == nil {
+			return fmt.Errorf("could not parse source IP %q", sourceIP)
+		}
+		if !ipInSet(cidrs, nil, ip) {
+			return fmt.Errorf("source IP %s not in allowed networks %v", sourceIP, sourceNetwork.CIDRs)
+		}
+	}
+
+	return nil
 }
 
 // authorizeServingRenewal will authorize the renewal of a kubelet's serving
@@ -286,25 +816,78 @@ func authorizeNodeClientCSR(c client.Client, machines []machinehandlerpkg.Machin
 //
 // The current certificate must be signed by the current CA and not expired.
 // The common name on the current certificate must match the expected value.
-// All Subject Alternate Name values must match between CSR and current cert.
-func authorizeServingRenewal(nodeName string, csr *x509.CertificateRequest, currentCert *x509.Certificate, options x509.VerifyOptions) error {
-	if err := verifyCertificateCommonName(nodeName, csr, currentCert, options); err != nil {
+// The CSR must request all the DNS names and IP addresses of the current cert,
+// so that names pinning the node to its machine can't be swapped for others.
+// Additional DNS names and IP addresses, e.g. for a new NIC, must be addresses
+// of the machine of the node, selected and matched by sanOpts as in the
+// machine-api flow. All other Subject Alternate Name values must
+// match between CSR and current cert.
+func authorizeServingRenewal(nodeUserPrefix, nodeName string, csr *x509.CertificateRequest, currentCert *x509.Certificate, machine *machinehandlerpkg.Machine, sanOpts servingSANOptions, roots []*x509.CertPool, options x509.VerifyOptions) error {
+	if err := verifyCertificateCommonName(nodeUserPrefix, nodeName, csr, currentCert, roots, options); err != nil {
 		return err
 	}
 
-	// Check that all Subject Alternate Name values are equal.
-	match := equalStrings(currentCert.DNSNames, csr.DNSNames) &&
+	// Check that no Subject Alternate Name value is dropped, and that URIs and
+	// email addresses are equal.
+	match := subsetStrings(lowerDNSNames(currentCert.DNSNames), lowerDNSNames(csr.DNSNames)) &&
 		equalStrings(currentCert.EmailAddresses, csr.EmailAddresses) &&
-		equalIPAddresses(currentCert.IPAddresses, csr.IPAddresses) &&
+		subsetIPAddresses(nil, csr.IPAddresses, currentCert.IPAddresses) &&
 		equalURLs(currentCert.URIs, csr.URIs)
 
 	if !match {
 		return fmt.Errorf("CSR Subject Alternate Name values do not match current certificate")
 	}
 
+	added := addedSANs(csr, currentCert)
+	if len(added) == 0 {
+		return nil
+	}
+	if machine == nil {
+		return fmt.Errorf("CSR Subject Alternate Names %v are not in current certificate and node %s has no machine", added, nodeName)
+	}
+	for _, san := range added {
+		if !machineHasAddress(machine, san, sanOpts) {
+			return fmt.Errorf("CSR Subject Alternate Name %s is not in current certificate nor in machine %s addresses", san, machine.Name)
+		}
+	}
+
 	return nil
 }
 
+// addedSANs returns the DNS names and IP addresses requested by the CSR that
+// are not in the current cert.
+func addedSANs(csr *x509.CertificateRequest, currentCert *x509.Certificate) []string {
+	var added []string
+	currentDNSNames := sets.NewString(lowerDNSNames(currentCert.DNSNames)...)
+	for _, dnsName := range csr.DNSNames {
+		if !currentDNSNames.Has(strings.ToLower(dnsName)) {
+			added = append(added, dnsName)
+		}
+	}
+	for _, ip := range csr.IPAddresses {
+		if !subsetIPAddresses(nil, currentCert.IPAddresses, []net.IP{ip}) {
+			added = append(added, ip.String())
+		}
+	}
+	return added
+}
+
+// machineHasAddress tests whether the DNS name or IP address is one of the
+// addresses the names requested by serving CSRs are matched against, as for
+// the SANs of the machine-api flow.
+func machineHasAddress(machine *machinehandlerpkg.Machine, san string, opts servingSANOptions) bool {
+	ip := net.ParseIP(san)
+	for _, addr := range servingSANAddresses(machine, opts) {
+		if ip != nil && hasAddressType(opts.ipAddressTypes, addr.Type) && ipMatchesAddress(ip, addr.Address) {
+			return true
+		}
+		if ip == nil && hasAddressType(opts.dnsAddressTypes, addr.Type) && machinehandlerpkg.EqualDNSNames(san, addr.Address) {
+			return true
+		}
+	}
+	return false
+}
+
 // authorizeServingRenewal will authorize the renewal of a kubelet's serving
 // certificate.
 //
@@ -318,14 +901,14 @@ func authorizeServingRenewal(nodeName string, csr *x509.CertificateRequest, curr
 //
 // TODO: Once CCMs are GA, we should be able to exclude the egress networks via the CCM configuration.
 // Investigate that this is the case and remove this fallback if appropriate.
-func authorizeServingRenewalWithEgressIPs(c client.Client, nodeName string, csr *x509.CertificateRequest, currentCert *x509.Certificate, options x509.VerifyOptions) error {
-	if err := verifyCertificateCommonName(nodeName, csr, currentCert, options); err != nil {
+func authorizeServingRenewalWithEgressIPs(ctx context.Context, c client.Client, nodeUserPrefix, nodeName string, csr *x509.CertificateRequest, currentCert *x509.Certificate, roots []*x509.CertPool, options x509.VerifyOptions) error {
+	if err := verifyCertificateCommonName(nodeUserPrefix, nodeName, csr, currentCert, roots, options); err != nil {
 		return err
 	}
 
 	// Check that all Subject Alternate Name values except IP addresses are equal.
 	// IP addresses will be verified separately.
-	match := equalStrings(currentCert.DNSNames, csr.DNSNames) &&
+	match := equalStrings(lowerDNSNames(currentCert.DNSNames), lowerDNSNames(csr.DNSNames)) &&
 		equalStrings(currentCert.EmailAddresses, csr.EmailAddresses) &&
 		equalURLs(currentCert.URIs, csr.URIs)
 
@@ -334,7 +917,7 @@ func authorizeServingRenewalWithEgressIPs(c client.Client, nodeName string, csr
 	}
 
 	hostSubnet := &networkv1.HostSubnet{}
-	if err := c.Get(context.Background(), client.ObjectKey{Name: nodeName}, hostSubnet); err != nil {
+	if err := c.Get(ctx, client.ObjectKey{Name: nodeName}, hostSubnet); err != nil {
 		return fmt.Errorf("could not fetch hostsubnet: %v", err)
 	}
 
@@ -359,44 +942,229 @@ func authorizeServingRenewalWithEgressIPs(c client.Client, nodeName string, csr
 	return nil
 }
 
-func authorizeServingCertWithMachine(machines []machinehandlerpkg.Machine, req *certificatesv1.CertificateSigningRequest, nodeAsking string, csr *x509.CertificateRequest) error {
+// recordRenewalFallback counts serving CSRs falling back to the machine-api
+// flow, by cause. An event is recorded when the renewal was attempted, as a
+// failing renewal path goes unnoticed otherwise.
+func (m *CertificateApprover) recordRenewalFallback(req *certificatesv1.CertificateSigningRequest, nodeName, cause string, approvalErrors []error) {
+	renewalFallbackTotal.WithLabelValues(cause).Inc()
+
+	switch cause {
+	case renewalFallbackNoCA, renewalFallbackDisabled:
+		return
+	case renewalFallbackDialFailed, renewalFallbackCertNotFound:
+		m.eventf(req, corev1.EventTypeNormal, csrRenewalFallbackEventReason, "could not retrieve the current serving cert of node %s, falling back to machine-api authorization", nodeName)
+	default:
+		m.eventf(req, corev1.EventTypeNormal, csrRenewalFallbackEventReason, "could not renew the current serving cert of node %s, falling back to machine-api authorization: %v", nodeName, kerrors.NewAggregate(approvalErrors))
+	}
+}
+
+// nodeRefMachine returns the machine linked to the node by its node ref, or
+// nil if there is none.
+func nodeRefMachine(machines []machinehandlerpkg.Machine, nodeName string) *machinehandlerpkg.Machine {
+	machine, err := machinehandlerpkg.FindMatchingMachineFromNodeRef(machines, nodeName)
+	if err != nil {
+		return nil
+	}
+	return machine
+}
+
+// authorizeServingCertWithMachine checks the names requested by a serving CSR
+// against the addresses of the machine of the node. With useProviderInterfaces,
+// IP addresses listed in the network interfaces of the machine provider status
+// are also allowed. The node, when retrieved, provides its addresses, instance
+// ID and provider ID.
+func authorizeServingCertWithMachine(config ClusterMachineApproverConfig, machines []machinehandlerpkg.Machine, req *certificatesv1.CertificateSigningRequest, nodeAsking string, csr *x509.CertificateRequest, useProviderInterfaces bool, node *corev1.Node) (*machinehandlerpkg.Machine, error) {
 	// Check that we have a registered node with the request name
 	targetMachine, err := machinehandlerpkg.FindMatchingMachineFromNodeRef(machines, nodeAsking)
+	if errors.Is(err, machinehandlerpkg.ErrAmbiguousMachine) {
+		return nil, err
+	}
+	if err != nil && node != nil && config.NodeServingCert.instanceIDMatching() {
+		targetMachine, err = findMatchingMachineFromInstanceID(config.NodeServingCert, machines, node)
+		if errors.Is(err, machinehandlerpkg.ErrAmbiguousMachine) {
+			return nil, err
+		}
+	}
+	if err != nil && node != nil && config.NodeServingCert.ProviderIDMatching {
+		targetMachine, err = findMatchingMachineFromNodeProviderID(machines, node)
+		if errors.Is(err, machinehandlerpkg.ErrAmbiguousMachine) {
+			return nil, err
+		}
+	}
 	if err != nil {
 		klog.Errorf("%v: Serving Cert: No target machine for node %q", req.Name, nodeAsking)
-		//TODO: set annotation/emit event here.
 		// Return error so we requeue in case we're racing with node linker.
-		return fmt.Errorf("Unable to find machine for node")
+		return nil, withKind(ErrNoMatchingMachine, fmt.Errorf("Unable to find machine for node"))
 	}
 
+	// Control plane machines may legitimately serve additional names, such as API VIPs.
+	var extraAllowedSANs []string
+	if isControlPlaneMachine(targetMachine) {
+		extraAllowedSANs = config.NodeServingCert.ControlPlane.ExtraAllowedSANs
+	}
+
+	// A CSR asking for many more DNS names than the machine has is suspicious,
+	// even when each name individually matches one of the machine addresses.
+	if maxExtra := config.NodeServingCert.MaxExtraDNSNames; maxExtra != nil {
+		requested, available := countDNSNames(csr.DNSNames), countMachineDNSAddresses(targetMachine, config.NodeServingCert.dnsAddressTypes())+countDNSNames(extraAllowedSANs)
+		if requested-available > *maxExtra {
+			klog.Errorf("%v: CSR requests %d DNS names but machine only has %d DNS addresses (max extra allowed: %d)", req.Name, requested, available, *maxExtra)
+			return nil, &suspiciousCSRError{
+				reason: quarantineReasonExcessDNSNames,
+				err:    fmt.Errorf("CSR requests %d DNS names but machine only has %d DNS addresses (max extra allowed: %d)", requested, available, *maxExtra),
+			}
+		}
+	}
+
+	opts := newServingSANOptions(config.NodeServingCert, node)
+	opts.extraAllowedSANs = extraAllowedSANs
+	opts.allowShortNames = config.NodeServingCert.AllowShortNameSANs
+	opts.requireFullCoverage = config.NodeServingCert.RequireFullSANCoverage
+	opts.useProviderInterfaces = useProviderInterfaces
+	// Every SAN would be declined as not being a machine address, wait for
+	// the addresses to be populated instead.
+	if len(servingSANAddresses(targetMachine, opts)) == 0 {
+		return nil, withKind(ErrMachineNotReady, fmt.Errorf("%w: machine %s of node %s", errNoMachineAddresses, targetMachine.Name, nodeAsking))
+	}
+	if err := validateServingSANs(csr, targetMachine, opts); err != nil {
+		// return error so we requeue, in case machine network is out of date
+		// for some reason
+		klog.Errorf("%v: %v", req.Name, err)
+		return nil, err
+	}
+
+	return targetMachine, nil
+}
+
+// findMatchingMachineFromInstanceID finds the machine of a node whose node ref
+// is not set yet by the instance ID both are annotated with. Machines already
+// linked to another node are not matched.
+func findMatchingMachineFromInstanceID(config NodeServingCert, machines []machinehandlerpkg.Machine, node *corev1.Node) (*machinehandlerpkg.Machine, error) {
+	instanceID := node.Annotations[config.NodeInstanceIDAnnotation]
+	machine, err := machinehandlerpkg.FindMatchingMachineFromInstanceID(machines, node.Name, config.MachineInstanceIDAnnotation, instanceID)
+	if err != nil {
+		return nil, err
+	}
+	if machine.Status.NodeRef != nil {
+		return nil, fmt.Errorf("machine %s with instance ID %s of node %s already has node ref %s", machine.Name, instanceID, node.Name, machine.Status.NodeRef.Name)
+	}
+	klog.Infof("Matched machine %s to node %s by instance ID %s", machine.Name, node.Name, instanceID)
+	return machine, nil
+}
+
+// findMatchingMachineFromProviderID finds the machine of a node which does not
+// exist yet by the provider ID resolved for the node name.
+func findMatchingMachineFromProviderID(config ProviderIDMatching, machines []machinehandlerpkg.Machine, nodeName string) (*machinehandlerpkg.Machine, error) {
+	providerID, err := machinehandlerpkg.AnnotationProviderIDResolver(config.NodeNameAnnotation)(machines, nodeName)
+	if err != nil {
+		return nil, err
+	}
+
+	machine, err := machinehandlerpkg.FindMatchingMachineFromProviderID(machines, nodeName, providerID)
+	if err != nil {
+		return nil, err
+	}
+	klog.Infof("Matched machine %s for node %s by provider ID %s", machine.Name, nodeName, providerID)
+	return machine, nil
+}
+
+// findMatchingMachineFromNodeProviderID finds the machine of a node whose node
+// ref is not set yet by the provider ID of the node. Machines already linked to
+// another node are not matched.
+func findMatchingMachineFromNodeProviderID(machines []machinehandlerpkg.Machine, node *corev1.Node) (*machinehandlerpkg.Machine, error) {
+	machine, err := machinehandlerpkg.FindMatchingMachineFromProviderID(machines, node.Name, node.Spec.ProviderID)
+	if err != nil {
+		return nil, err
+	}
+	if machine.Status.NodeRef != nil {
+		return nil, fmt.Errorf("machine %s with provider ID %s of node %s already has node ref %s", machine.Name, node.Spec.ProviderID, node.Name, machine.Status.NodeRef.Name)
+	}
+	klog.Infof("Matched machine %s to node %s by provider ID %s", machine.Name, node.Name, node.Spec.ProviderID)
+	return machine, nil
+}
+
+// servingSANOptions allows serving CSRs to request names that are not machine
+// addresses.
+type servingSANOptions struct {
+	// dnsAddressTypes and ipAddressTypes are the types of the machine
+	// addresses DNS names and IP addresses are matched against.
+	dnsAddressTypes  []corev1.NodeAddressType
+	ipAddressTypes   []corev1.NodeAddressType
+	extraAllowedSANs []string
+	allowShortNames  bool
+	// requireFullCoverage requires every address matched against to be
+	// requested.
+	requireFullCoverage   bool
+	useProviderInterfaces bool
+	// addressSource selects whether the addresses of the machine, of its
+	// node, or both are matched against. Defaults to the machine ones.
+	addressSource string
+	nodeAddresses []corev1.NodeAddress
+}
+
+// newServingSANOptions returns the options selecting the addresses the names
+// requested by serving CSRs are matched against, those of the given node being
+// used depending on the address source.
+func newServingSANOptions(config NodeServingCert, node *corev1.Node) servingSANOptions {
+	opts := servingSANOptions{
+		dnsAddressTypes: config.dnsAddressTypes(),
+		ipAddressTypes:  config.ipAddressTypes(),
+		addressSource:   config.sanAddressSource(),
+	}
+	if node != nil {
+		opts.nodeAddresses = node.Status.Addresses
+	}
+	return opts
+}
+
+// servingSANAddresses returns the addresses the names requested by a serving
+// CSR are matched against, from the machine, its node or both depending on the
+// address source.
+func servingSANAddresses(machine *machinehandlerpkg.Machine, opts servingSANOptions) []corev1.NodeAddress {
+	switch opts.addressSource {
+	case sanAddressSourceNodeOnly:
+		return opts.nodeAddresses
+	case sanAddressSourceUnion:
+		return append(append([]corev1.NodeAddress{}, machine.Status.Addresses...), opts.nodeAddresses...)
+	default:
+		return machine.Status.Addresses
+	}
+}
+
+// validateServingSANs checks that all the names requested by a serving CSR
+// correspond to addresses assigned to the machine, or to its node depending
+// on the address source.
+func validateServingSANs(csr *x509.CertificateRequest, machine *machinehandlerpkg.Machine, opts servingSANOptions) error {
 	// SAN checks for both DNS and IPs, e.g.,
 	// DNS:ip-10-0-152-205, DNS:ip-10-0-152-205.ec2.internal, IP Address:10.0.152.205, IP Address:10.0.152.205
-	// All names in the request must correspond to addresses assigned to a single machine.
+	addresses := servingSANAddresses(machine, opts)
 	for _, san := range csr.DNSNames {
 		if len(san) == 0 {
 			continue
 		}
 		var attemptedAddresses []string
 		var foundSan bool
-		for _, addr := range targetMachine.Status.Addresses {
-			switch addr.Type {
-			case corev1.NodeInternalDNS, corev1.NodeExternalDNS, corev1.NodeHostName:
-				if strings.EqualFold(san, addr.Address) {
+		for _, addr := range addresses {
+			if hasAddressType(opts.dnsAddressTypes, addr.Type) {
+				if machinehandlerpkg.EqualDNSNames(san, addr.Address) {
 					foundSan = true
 					break
 				} else {
 					attemptedAddresses = append(attemptedAddresses, addr.Address)
 				}
-			default:
 			}
 		}
+		if !foundSan && extraSANAllowed(opts.extraAllowedSANs, san) {
+			continue
+		}
+		// Some platforms only record the FQDN of the machine, and Windows
+		// nodes may only use their short name.
+		if !foundSan && (opts.allowShortNames || machinehandlerpkg.IsWindowsMachine(*machine)) && shortNameInAddresses(addresses, opts.dnsAddressTypes, san) {
+			continue
+		}
 		// The CSR requested a DNS name that did not belong to the machine
 		if !foundSan {
-			//TODO: set annotation/emit event here.
-			// return error so we requeue, in case machine network is out of date
-			// for some reason
-			klog.Errorf("%v: DNS name '%s' not in machine names: %s", req.Name, san, strings.Join(attemptedAddresses, " "))
-			return fmt.Errorf("DNS name '%s' not in machine names: %s", san, strings.Join(attemptedAddresses, " "))
+			return withKind(ErrSANMismatch, fmt.Errorf("DNS name '%s' not in machine names: %s", san, strings.Join(attemptedAddresses, " ")))
 		}
 	}
 
@@ -406,45 +1174,281 @@ func authorizeServingCertWithMachine(machines []machinehandlerpkg.Machine, req *
 		}
 		var attemptedAddresses []string
 		var foundSan bool
-		for _, addr := range targetMachine.Status.Addresses {
-			switch corev1.NodeAddressType(addr.Type) {
-			case corev1.NodeInternalIP, corev1.NodeExternalIP:
-				if san.String() == addr.Address {
+		for _, addr := range addresses {
+			if hasAddressType(opts.ipAddressTypes, addr.Type) {
+				if ipMatchesAddress(san, addr.Address) {
 					foundSan = true
 					break
 				} else {
 					attemptedAddresses = append(attemptedAddresses, addr.Address)
 				}
-			default:
 			}
 		}
+		if !foundSan && extraSANAllowed(opts.extraAllowedSANs, san.String()) {
+			continue
+		}
+		if !foundSan && opts.useProviderInterfaces && ipInProviderInterfaces(machine, san) {
+			continue
+		}
 		// The CSR requested an IP name that did not belong to the machine
 		if !foundSan {
-			//TODO: set annotation/emit event here.
-			// return error so we requeue, in case machine network is out of date
-			// for some reason
-			klog.Errorf("%v: IP address '%s' not in machine addresses: %s", req.Name, san, strings.Join(attemptedAddresses, " "))
-			return fmt.Errorf("IP address '%s' not in machine addresses: %s", san, strings.Join(attemptedAddresses, " "))
+			return withKind(ErrSANMismatch, fmt.Errorf("IP address '%s' not in machine addresses: %s", san, strings.Join(attemptedAddresses, " ")))
 		}
 	}
 
+	if opts.requireFullCoverage {
+		return validateServingSANCoverage(csr, machine, opts)
+	}
+	return nil
+}
+
+// validateServingSANCoverage checks that every DNS and IP address of the
+// machine, or of its node depending on the address source, is requested by a
+// serving CSR.
+func validateServingSANCoverage(csr *x509.CertificateRequest, machine *machinehandlerpkg.Machine, opts servingSANOptions) error {
+	for _, addr := range servingSANAddresses(machine, opts) {
+		var covered bool
+		switch {
+		case hasAddressType(opts.dnsAddressTypes, addr.Type):
+			for _, san := range csr.DNSNames {
+				if machinehandlerpkg.EqualDNSNames(san, addr.Address) {
+					covered = true
+					break
+				}
+			}
+		case hasAddressType(opts.ipAddressTypes, addr.Type):
+			for _, san := range csr.IPAddresses {
+				if ipMatchesAddress(san, addr.Address) {
+					covered = true
+					break
+				}
+			}
+		default:
+			continue
+		}
+		if !covered {
+			return withKind(ErrSANMismatch, fmt.Errorf("machine address '%s' of type %s not in CSR SANs", addr.Address, addr.Type))
+		}
+	}
 	return nil
 }
 
-func verifyCertificateCommonName(nodeName string, csr *x509.CertificateRequest, currentCert *x509.Certificate, options x509.VerifyOptions) error {
-	// options.Roots should contain root certificates
-	if csr == nil || currentCert == nil || options.Roots == nil {
+// validateKubeletVersion checks that the kubelet version reported by the node
+// is not ahead of the version expected for it: the version of its machine when
+// set, or the configured maximum version otherwise. Nodes for which no
+// expected version is known are not checked.
+func validateKubeletVersion(ctx context.Context, c client.Client, check KubeletVersionCheck, machines []machinehandlerpkg.Machine, nodeName string) error {
+	expected := check.MaxVersion
+	if machine, err := machinehandlerpkg.FindMatchingMachineFromNodeRef(machines, nodeName); err == nil && machine.Spec.Version != nil && *machine.Spec.Version != "" {
+		expected = *machine.Spec.Version
+	}
+	if expected == "" {
+		return nil
+	}
+
+	expectedVersion, err := version.ParseGeneric(expected)
+	if err != nil {
+		return fmt.Errorf("failed to parse expected kubelet version: %v", err)
+	}
+
+	node := &corev1.Node{}
+	if err := c.Get(ctx, client.ObjectKey{Name: nodeName}, node); err != nil {
+		return fmt.Errorf("failed to get node %s: %v", nodeName, err)
+	}
+
+	kubeletVersion, err := version.ParseGeneric(node.Status.NodeInfo.KubeletVersion)
+	if err != nil {
+		return fmt.Errorf("failed to parse kubelet version of node %s: %v", nodeName, err)
+	}
+
+	if expectedVersion.LessThan(kubeletVersion) {
+		return fmt.Errorf("kubelet version %s of node %s is ahead of expected version %s", node.Status.NodeInfo.KubeletVersion, nodeName, expected)
+	}
+
+	return nil
+}
+
+// matchesNodeHostname returns true if the primary DNS name of the serving CSR
+// matches the hostname reported by the node. CSRs without DNS names, and nodes
+// not reporting a hostname, are not checked.
+func matchesNodeHostname(ctx context.Context, c client.Client, nodeName string, csr *x509.CertificateRequest) (bool, error) {
+	if len(csr.DNSNames) == 0 {
+		return true, nil
+	}
+
+	node := &corev1.Node{}
+	if err := c.Get(ctx, client.ObjectKey{Name: nodeName}, node); err != nil {
+		return false, fmt.Errorf("failed to get node %s: %v", nodeName, err)
+	}
+
+	var hostnames []string
+	for _, address := range node.Status.Addresses {
+		if address.Type == corev1.NodeHostName {
+			hostnames = append(hostnames, address.Address)
+		}
+	}
+	if len(hostnames) == 0 {
+		klog.Infof("Node %s does not report a hostname, skipping hostname check", nodeName)
+		return true, nil
+	}
+
+	for _, hostname := range hostnames {
+		if strings.EqualFold(hostname, csr.DNSNames[0]) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// shortNameInAddresses returns true if the DNS name is a single label
+// matching the hostname label of one of the DNS addresses.
+func shortNameInAddresses(addresses []corev1.NodeAddress, dnsAddressTypes []corev1.NodeAddressType, san string) bool {
+	for _, addr := range addresses {
+		if hasAddressType(dnsAddressTypes, addr.Type) && machinehandlerpkg.IsHostnameLabel(addr.Address, san) {
+			return true
+		}
+	}
+	return false
+}
+
+// ipMatchesAddress compares an IP SAN with an address from the machine status,
+// which may use a different textual form, such as an expanded or upper case
+// IPv6 address, an IPv4-mapped IPv6 address, or a zone suffix.
+func ipMatchesAddress(ip net.IP, address string) bool {
+	if i := strings.IndexByte(address, '%'); i >= 0 {
+		address = address[:i]
+	}
+	addressIP := net.ParseIP(strings.TrimSpace(address))
+	return addressIP != nil && addressIP.Equal(ip)
+}
+
+// providerInterfaceIPs returns the IP addresses listed in the network
+// interfaces of the machine provider status. Entries not matching the expected
+// layout are ignored.
+func providerInterfaceIPs(machine *machinehandlerpkg.Machine) []net.IP {
+	interfaces, ok := machine.Status.ProviderStatus["networkInterfaces"].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	var ips []net.IP
+	for _, i := range interfaces {
+		iface, ok := i.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		addresses, ok := iface["ipAddresses"].([]interface{})
+		if !ok {
+			continue
+		}
+		for _, a := range addresses {
+			if address, ok := a.(string); ok {
+				if ip := net.ParseIP(address); ip != nil {
+					ips = append(ips, ip)
+				}
+			}
+		}
+	}
+	return ips
+}
+
+func ipInProviderInterfaces(machine *machinehandlerpkg.Machine, ip net.IP) bool {
+	for _, providerIP := range providerInterfaceIPs(machine) {
+		if providerIP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// getPlatformType returns the platform of the cluster.
+func getPlatformType(ctx context.Context, c client.Client) (configv1.PlatformType, error) {
+	infra := &configv1.Infrastructure{}
+	if err := c.Get(ctx, client.ObjectKey{Name: infrastructureClusterName}, infra); err != nil {
+		return "", fmt.Errorf("could not fetch cluster infrastructure: %v", err)
+	}
+
+	if infra.Status.PlatformStatus != nil {
+		return infra.Status.PlatformStatus.Type, nil
+	}
+	return infra.Status.Platform, nil
+}
+
+// isControlPlaneMachine returns true if the machine is labelled as part of the
+// control plane by either the Machine API or the Cluster API.
+func isControlPlaneMachine(machine *machinehandlerpkg.Machine) bool {
+	if machine.Labels[machineRoleLabel] == machineRoleMaster {
+		return true
+	}
+	_, ok := machine.Labels[capiControlPlaneLabel]
+	return ok
+}
+
+// extraSANAllowed returns true if the SAN is in the list of extra allowed SANs.
+// IP addresses are compared in their canonical form.
+func extraSANAllowed(extraAllowedSANs []string, san string) bool {
+	sanIP := net.ParseIP(san)
+	for _, allowed := range extraAllowedSANs {
+		if allowedIP := net.ParseIP(allowed); allowedIP != nil && sanIP != nil {
+			if allowedIP.Equal(sanIP) {
+				return true
+			}
+			continue
+		}
+		if strings.EqualFold(allowed, san) {
+			return true
+		}
+	}
+	return false
+}
+
+// countDNSNames returns the number of non-empty DNS names.
+func countDNSNames(dnsNames []string) int {
+	var count int
+	for _, name := range dnsNames {
+		if len(name) > 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// countMachineDNSAddresses returns the number of addresses on the machine that
+// a DNS name in a serving CSR can be matched against.
+func countMachineDNSAddresses(machine *machinehandlerpkg.Machine, dnsAddressTypes []corev1.NodeAddressType) int {
+	var count int
+	for _, addr := range machine.Status.Addresses {
+		if hasAddressType(dnsAddressTypes, addr.Type) {
+			count++
+		}
+	}
+	return count
+}
+
+// hasAddressType tests whether the address type is one of the given types.
+func hasAddressType(types []corev1.NodeAddressType, addressType corev1.NodeAddressType) bool {
+	for _, t := range types {
+		if t == addressType {
+			return true
+		}
+	}
+	return false
+}
+
+func verifyCertificateCommonName(nodeUserPrefix, nodeName string, csr *x509.CertificateRequest, currentCert *x509.Certificate, roots []*x509.CertPool, options x509.VerifyOptions) error {
+	// roots should contain root certificates
+	if csr == nil || currentCert == nil || len(roots) == 0 {
 		return fmt.Errorf("CSR, serving cert, or CA not provided")
 	}
 
-	// Check that the serving cert is signed by the given CA, is not expired,
-	// and is otherwise valid.
-	if _, err := currentCert.Verify(options); err != nil {
+	// Check that the serving cert is signed by one of the given CAs, is not
+	// expired, and is otherwise valid.
+	if _, err := verifyWithAnyRoots(currentCert, roots, options); err != nil {
 		return err
 	}
 
 	// Check that the CN is correct on the current cert.
-	if currentCert.Subject.CommonName != fmt.Sprintf("%s:%s", nodeUser, nodeName) {
+	if currentCert.Subject.CommonName != nodeUserPrefix+nodeName {
 		return fmt.Errorf("current serving cert has bad common name")
 	}
 
@@ -456,8 +1460,48 @@ func verifyCertificateCommonName(nodeName string, csr *x509.CertificateRequest,
 	return nil
 }
 
-func isReqFromNodeBootstrapper(req *certificatesv1.CertificateSigningRequest) bool {
-	return req.Spec.Username == nodeBootstrapperUsername && nodeBootstrapperGroups.Equal(sets.NewString(req.Spec.Groups...))
+// certValidationTime returns the time the serving cert presented by a kubelet
+// is verified at. A cert only becoming valid within the skew, e.g. issued
+// while the clock of the node is ahead, is verified at the start of its
+// validity. Expired certs are not tolerated.
+func certValidationTime(cert *x509.Certificate, now time.Time, skew time.Duration) time.Time {
+	if cert == nil || skew <= 0 {
+		return now
+	}
+	if now.Before(cert.NotBefore) && cert.NotBefore.Sub(now) <= skew {
+		return cert.NotBefore
+	}
+	return now
+}
+
+// verifyWithAnyRoots verifies the certificate against each of the root CA
+// pools in turn, e.g. the current and previous kubelet CAs during a rotation,
+// and returns the chains verified against the first pool it is signed by.
+func verifyWithAnyRoots(cert *x509.Certificate, roots []*x509.CertPool, options x509.VerifyOptions) ([][]*x509.Certificate, error) {
+	var errs []error
+	for _, pool := range roots {
+		options.Roots = pool
+		chains, err := cert.Verify(options)
+		if err == nil {
+			return chains, nil
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) == 0 {
+		return nil, fmt.Errorf("no CA provided")
+	}
+	return nil, kerrors.NewAggregate(errs)
+}
+
+// isReqFromNodeBootstrapper returns true if the CSR was requested by one of
+// the bootstrappers, with exactly its groups.
+func isReqFromNodeBootstrapper(bootstrappers []NodeBootstrapper, req *certificatesv1.CertificateSigningRequest) bool {
+	for _, bootstrapper := range bootstrappers {
+		if req.Spec.Username == bootstrapper.Username && sets.NewString(bootstrapper.Groups...).Equal(sets.NewString(req.Spec.Groups...)) {
+			return true
+		}
+	}
+	return false
 }
 
 func inTimeSpan(start, end, check time.Time) bool {
@@ -473,9 +1517,17 @@ func isApproved(csr certificatesv1.CertificateSigningRequest) bool {
 	return false
 }
 
-func isRecentlyApproved(csr certificatesv1.CertificateSigningRequest) bool {
+func isDenied(csr certificatesv1.CertificateSigningRequest) bool {
+	for _, condition := range csr.Status.Conditions {
+		if condition.Type == certificatesv1.CertificateDenied {
+			return true
+		}
+	}
+	return false
+}
+
+func isRecentlyApproved(csr certificatesv1.CertificateSigningRequest, currentTime time.Time) bool {
 	// assumes we are scheduled on the master meaning our clock is the same
-	currentTime := now()
 	start := currentTime.Add(-maxApprovedDelta)
 	end := currentTime.Add(maxMachineClockSkew)
 
@@ -496,9 +1548,8 @@ func isApprovedByCMA(csr certificatesv1.CertificateSigningRequest) bool {
 	return false
 }
 
-func recentlyPendingNodeCSRs(csrs []certificatesv1.CertificateSigningRequest) int {
+func recentlyPendingNodeCSRs(bootstrappers []NodeBootstrapper, nodeUserPrefix string, csrs []certificatesv1.CertificateSigningRequest, currentTime time.Time) int {
 	// assumes we are scheduled on the master meaning our clock is the same
-	currentTime := now()
 	start := currentTime.Add(-maxPendingDelta)
 	end := currentTime.Add(maxMachineClockSkew)
 
@@ -510,7 +1561,13 @@ func recentlyPendingNodeCSRs(csrs []certificatesv1.CertificateSigningRequest) in
 			continue
 		}
 
-		if (isReqFromNodeBootstrapper(&csr) || isRequestFromNodeUser(csr)) && !isApproved(csr) {
+		// quarantined CSRs are left for manual review and must not prevent
+		// other CSRs from being approved
+		if isQuarantined(csr) {
+			continue
+		}
+
+		if (isReqFromNodeBootstrapper(bootstrappers, &csr) || isRequestFromNodeUser(nodeUserPrefix, csr)) && !isApproved(csr) {
 			pending++
 		}
 	}
@@ -518,69 +1575,202 @@ func recentlyPendingNodeCSRs(csrs []certificatesv1.CertificateSigningRequest) in
 	return pending
 }
 
-func isRequestFromNodeUser(csr certificatesv1.CertificateSigningRequest) bool {
+var kubeletServerNames = []string{
+	kubeletServerNameAddress,
+	kubeletServerNameNodeName,
+	kubeletServerNameNone,
+}
+
+var sanAddressSources = []string{
+	sanAddressSourceMachineOnly,
+	sanAddressSourceNodeOnly,
+	sanAddressSourceUnion,
+}
+
+var requiredSANTypes = []string{
+	requiredSANTypeDNS,
+	requiredSANTypeIP,
+}
+
+func isRequestFromNodeUser(nodeUserPrefix string, csr certificatesv1.CertificateSigningRequest) bool {
 	return strings.HasPrefix(csr.Spec.Username, nodeUserPrefix)
 }
 
 // getServingCert fetches the node by the given name and attempts to connect to
-// its kubelet on the first advertised address.
+// its kubelet on each of its advertised addresses in turn, with the given
+// connector or over the network when nil, until a connection succeeds.
 //
-// If successful, and the returned TLS certificate is validated against the
-// given CA, the node's serving certificate as presented over the established
-// connection is returned.
-func getServingCert(c client.Client, nodeName string, ca *x509.CertPool) (*x509.Certificate, error) {
-	if ca == nil {
+// The node's serving certificate is returned as presented over the first
+// connection whose TLS certificate is validated against one of the given CAs
+// for the name selected by serverName. The errors of all the attempts are
+// returned when none succeeds. With verifyStaple, a certificate presented with
+// an OCSP staple is only returned if the staple reports it as good.
+func getServingCert(ctx context.Context, c client.Client, connect KubeletConnector, nodeName string, cas []*x509.CertPool, verifyStaple bool, serverName string, defaultPort int, dialTimeout time.Duration, currentTime time.Time) (*x509.Certificate, error) {
+	if len(cas) == 0 {
 		return nil, fmt.Errorf("no CA found: will not retrieve serving cert")
 	}
 
 	node := &corev1.Node{}
-	if err := c.Get(context.Background(), client.ObjectKey{Name: nodeName}, node); err != nil {
+	if err := c.Get(ctx, client.ObjectKey{Name: nodeName}, node); err != nil {
 		return nil, err
 	}
 
-	host, err := nodeInternalIP(node)
+	hosts, err := nodeKubeletIPs(node)
 	if err != nil {
+		kubeletDialFailuresTotal.WithLabelValues(dialFailureNoAddress).Inc()
 		return nil, err
 	}
 
-	port := strconv.Itoa(int(node.Status.DaemonEndpoints.KubeletEndpoint.Port))
+	// Nodes only report the kubelet port once the kubelet has started.
+	kubeletPort := int(node.Status.DaemonEndpoints.KubeletEndpoint.Port)
+	if kubeletPort == 0 {
+		if defaultPort == 0 {
+			kubeletDialFailuresTotal.WithLabelValues(dialFailureNoPort).Inc()
+			return nil, fmt.Errorf("%w reported by node %s", errNoKubeletPort, nodeName)
+		}
+		kubeletPort = defaultPort
+	}
+	port := strconv.Itoa(kubeletPort)
+	if connect == nil {
+		connect = dialKubelet
+	}
+
+	// The kubelet may not be reachable on all addresses, e.g. on the
+	// provisioning network of multi-NIC hosts, try them in turn.
+	var state *tls.ConnectionState
+	var verifiedChains [][]*x509.Certificate
+	var dialErrors []error
+	for _, host := range hosts {
+		kubelet := net.JoinHostPort(host, port)
+		// crypto/tls only verifies against a single CA pool, the presented
+		// cert is verified against each of the CAs below instead.
+		tlsConfig := &tls.Config{
+			ServerName:         host,
+			InsecureSkipVerify: true,
+		}
+
+		klog.Infof("retrieving serving cert from %s (%s)", nodeName, kubelet)
 
-	kubelet := net.JoinHostPort(host, port)
-	dialer := &net.Dialer{Timeout: 30 * time.Second}
-	tlsConfig := &tls.Config{
-		RootCAs:    ca,
-		ServerName: host,
+		// The dial is aborted, including the TLS handshake, when the reconcile
+		// is cancelled.
+		start := time.Now()
+		connState, err := connectKubelet(ctx, connect, kubelet, tlsConfig, dialTimeout)
+		if err == nil {
+			verifiedChains, err = verifyKubeletServingCert(connState, cas, kubeletVerifiedName(serverName, nodeName, host))
+		}
+		recordKubeletDial(start, err)
+		if err == nil {
+			state = &connState
+			break
+		}
+		klog.Infof("Failed to retrieve serving cert from %s (%s): %v", nodeName, kubelet, err)
+		dialErrors = append(dialErrors, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if state == nil {
+		return nil, kerrors.NewAggregate(dialErrors)
 	}
 
-	klog.Infof("retrieving serving cert from %s (%s)", nodeName, kubelet)
+	cert := state.PeerCertificates[0]
 
-	conn, err := tls.DialWithDialer(dialer, "tcp", kubelet, tlsConfig)
-	if err != nil {
-		return nil, err
+	if verifyStaple {
+		// The last certificate of the verified chain is the root, which issued
+		// the serving cert itself when there are no intermediates.
+		chain := verifiedChains[0]
+		issuer := chain[len(chain)-1]
+		if len(chain) > 1 {
+			issuer = chain[1]
+		}
+		if err := verifyOCSPStaple(cert, issuer, state.OCSPResponse, currentTime); err != nil {
+			return nil, fmt.Errorf("serving cert of node %s can't be trusted: %v", nodeName, err)
+		}
 	}
 
+	return cert, nil
+}
+
+// KubeletConnector connects to the kubelet listening on the given address with
+// the given TLS config, and returns the state of the connection once the TLS
+// handshake completed. The serving cert presented is verified by the caller.
+type KubeletConnector func(ctx context.Context, addr string, tlsConfig *tls.Config) (tls.ConnectionState, error)
+
+// dialKubelet is the KubeletConnector connecting to kubelets over the network.
+func dialKubelet(ctx context.Context, addr string, tlsConfig *tls.Config) (tls.ConnectionState, error) {
+	dialer := &tls.Dialer{Config: tlsConfig}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return tls.ConnectionState{}, err
+	}
 	defer conn.Close()
+	return conn.(*tls.Conn).ConnectionState(), nil
+}
 
-	cert := conn.ConnectionState().PeerCertificates[0]
+// connectKubelet connects to the kubelet with the given connector, giving up
+// after the timeout, if any.
+func connectKubelet(ctx context.Context, connect KubeletConnector, addr string, tlsConfig *tls.Config, timeout time.Duration) (tls.ConnectionState, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return connect(ctx, addr, tlsConfig)
+}
 
-	return cert, nil
+// verifyKubeletServingCert verifies the serving cert presented by a kubelet,
+// for the given name unless empty, against any of the CAs, and returns its
+// verified chains.
+func verifyKubeletServingCert(state tls.ConnectionState, cas []*x509.CertPool, name string) ([][]*x509.Certificate, error) {
+	if len(state.PeerCertificates) == 0 {
+		return nil, fmt.Errorf("no serving cert presented")
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	return verifyWithAnyRoots(state.PeerCertificates[0], cas, x509.VerifyOptions{
+		DNSName:       name,
+		Intermediates: intermediates,
+	})
+}
+
+// kubeletVerifiedName returns the name the serving cert presented by the
+// kubelet of the node, reached on the given host, is verified against. The
+// name is not verified when empty.
+func kubeletVerifiedName(serverName, nodeName, host string) string {
+	switch serverName {
+	case kubeletServerNameNodeName:
+		return nodeName
+	case kubeletServerNameNone:
+		return ""
+	}
+	return host
 }
 
-// nodeInternalIP returns the first internal IP for the node.
-func nodeInternalIP(node *corev1.Node) (string, error) {
+// nodeKubeletIPs returns the IPs the kubelet of the node may be reached on,
+// internal IPs first.
+func nodeKubeletIPs(node *corev1.Node) ([]string, error) {
+	var internal, external []string
 	for _, address := range node.Status.Addresses {
-		if address.Type == corev1.NodeInternalIP {
-			return address.Address, nil
+		switch address.Type {
+		case corev1.NodeInternalIP:
+			internal = append(internal, address.Address)
+		case corev1.NodeExternalIP:
+			external = append(external, address.Address)
 		}
 	}
 
-	return "", fmt.Errorf("node %s has no internal addresses", node.Name)
+	if len(internal)+len(external) == 0 {
+		return nil, fmt.Errorf("node %s has no internal or external addresses", node.Name)
+	}
+	return append(internal, external...), nil
 }
 
 // needsEgressCheck determines whether or not egress IP checks should be enabled.
-func needsEgressCheck(c client.Client) (bool, error) {
+func needsEgressCheck(ctx context.Context, c client.Client) (bool, error) {
 	network := &configv1.Network{}
-	if err := c.Get(context.Background(), client.ObjectKey{Name: networkClusterName}, network); err != nil {
+	if err := c.Get(ctx, client.ObjectKey{Name: networkClusterName}, network); err != nil {
 		return false, fmt.Errorf("could not fetch cluster network: %v", err)
 	}
 
@@ -621,24 +1811,19 @@ func equalURLs(a, b []*url.URL) bool {
 	return reflect.DeepEqual(aStrings, bStrings)
 }
 
-// equalIPAddresses tests whether the string representations of two slices of IP
-// Addresses are equal.
-func equalIPAddresses(a, b []net.IP) bool {
-	var aStrings, bStrings []string
-
-	if len(a) != len(b) {
-		return false
-	}
-
-	for i := range a {
-		aStrings = append(aStrings, a[i].String())
-		bStrings = append(bStrings, b[i].String())
+// lowerDNSNames returns the DNS names in lower case, as DNS names are
+// case-insensitive.
+func lowerDNSNames(dnsNames []string) []string {
+	lower := make([]string, 0, len(dnsNames))
+	for _, dnsName := range dnsNames {
+		lower = append(lower, strings.ToLower(dnsName))
 	}
+	return lower
+}
 
-	sort.Strings(aStrings)
-	sort.Strings(bStrings)
-
-	return reflect.DeepEqual(aStrings, bStrings)
+// subsetStrings tests whether the set sub is contained within the set super.
+func subsetStrings(sub, super []string) bool {
+	return sets.NewString(super...).HasAll(sub...)
 }
 
 // subsetIPAddresses tests whether the set sub is contained within the set super.
@@ -2,6 +2,7 @@ package controller
 
 import (
 	"bytes"
+	"context"
 	"crypto"
 	"crypto/ecdsa"
 	"crypto/elliptic"
@@ -11,11 +12,14 @@ import (
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"math/big"
 	"net"
 	"net/url"
 	"reflect"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -27,8 +31,12 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	testingclock "k8s.io/utils/clock/testing"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
 
 	machinehandlerpkg "github.com/openshift/cluster-machine-approver/pkg/machinehandler"
 )
@@ -73,7 +81,6 @@ var defaultIPs []net.IP
 var defaultDNSNames []string
 
 func init() {
-	now = testingclock.NewFakePassiveClock(baseTime).Now
 	networkv1.AddToScheme(scheme.Scheme)
 	configv1.AddToScheme(scheme.Scheme)
 
@@ -83,8 +90,10 @@ func init() {
 		panic(err)
 	}
 
-	// Sign a serving cert based on the previous CA cert
-	serverCert, serverKey, err := generateCertKeyPair(time.Hour, rootCert, rootKey, "system:node:test", "node1", "node1.local")
+	// Sign a serving cert based on the previous CA cert. Loopback IP SANs are
+	// not allowed in serving CSRs, the fake kubelet presenting it is reached
+	// through localhost instead.
+	serverCert, serverKey, err := generateCertKeyPair(time.Hour, rootCert, rootKey, "system:node:test", "node1", "node1.local", "localhost")
 	if err != nil {
 		panic(err)
 	}
@@ -97,15 +106,15 @@ func init() {
 	serverKeyGood = string(serverKey)
 
 	defaultOrgs = []string{"system:nodes"}
-	defaultIPs = []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("10.0.0.1")}
-	defaultDNSNames = []string{"node1", "node1.local"}
+	defaultIPs = []net.IP{net.ParseIP("10.0.0.1")}
+	defaultDNSNames = []string{"node1", "node1.local", "localhost"}
 
 	goodCSR = createCSR("system:node:test", defaultOrgs, defaultIPs, defaultDNSNames)
 	goodCSRECDSA = createCSRECDSA("system:node:test", defaultOrgs, defaultIPs, defaultDNSNames)
 	extraAddr = createCSR(
 		"system:node:test",
 		defaultOrgs,
-		[]net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("10.0.0.1"), net.ParseIP("99.0.1.1")},
+		[]net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("99.0.1.1")},
 		defaultDNSNames)
 	otherName = createCSR("system:node:foobar", defaultOrgs, defaultIPs, defaultDNSNames)
 	noNamePrefix = createCSR("test", defaultOrgs, defaultIPs, defaultDNSNames)
@@ -146,7 +155,7 @@ func generateCertKeyPair(duration time.Duration, parentCertPEM, parentKeyPEM []b
 		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment | x509.KeyUsageCertSign,
 		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
 		DNSNames:              otherNames,
-		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("10.0.0.1")},
+		IPAddresses:           []net.IP{net.ParseIP("10.0.0.1")},
 		IsCA:                  parentCertPEM == nil,
 		BasicConstraintsValid: true, // Required, else IsCA is ignored
 	}
@@ -229,7 +238,7 @@ func createCSRECDSA(commonName string, organizations []string, ipAddressess []ne
 
 func Test_authorizeCSR(t *testing.T) {
 	defaultPort := int32(25435)
-	defaultAddr := "127.0.0.1"
+	defaultAddr := "localhost"
 	defaultNode := func() *corev1.Node {
 		return &corev1.Node{
 			Status: corev1.NodeStatus{
@@ -291,6 +300,10 @@ func Test_authorizeCSR(t *testing.T) {
 					Type:    corev1.NodeExternalDNS,
 					Address: "node1",
 				},
+				{
+					Type:    corev1.NodeInternalDNS,
+					Address: "localhost",
+				},
 			}
 		}
 		var nodeRef *corev1.ObjectReference
@@ -307,6 +320,25 @@ func Test_authorizeCSR(t *testing.T) {
 		}
 	}
 
+	asControlPlane := func(machine machinehandlerpkg.Machine) machinehandlerpkg.Machine {
+		machine.Labels = map[string]string{"machine.openshift.io/cluster-api-machine-role": "master"}
+		return machine
+	}
+
+	controlPlaneCSR := createCSR(
+		"system:node:test",
+		defaultOrgs,
+		[]net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.100")},
+		[]string{"node1", "node1.local", "localhost", "api-int.example.com"})
+
+	controlPlaneConfig := ClusterMachineApproverConfig{
+		NodeServingCert: NodeServingCert{
+			ControlPlane: ControlPlaneServingCert{
+				ExtraAllowedSANs: []string{"api-int.example.com", "10.0.0.100"},
+			},
+		},
+	}
+
 	type args struct {
 		config        ClusterMachineApproverConfig
 		machines      []machinehandlerpkg.Machine
@@ -752,6 +784,7 @@ func Test_authorizeCSR(t *testing.T) {
 						{corev1.NodeExternalIP, "10.0.0.2"},
 						{corev1.NodeExternalDNS, "node1"},
 						{corev1.NodeExternalDNS, "node1.local"},
+						{Type: corev1.NodeInternalDNS, Address: "localhost"},
 					}...),
 				},
 				req: &certificatesv1.CertificateSigningRequest{
@@ -803,6 +836,168 @@ func Test_authorizeCSR(t *testing.T) {
 			wantErr:   "could not authorize CSR: exhausted all authorization methods: DNS name 'node1' not in machine names: node1.local node2",
 			authorize: false,
 		},
+		{
+			name: "csr-too-many-dns-names",
+			args: args{
+				config: ClusterMachineApproverConfig{
+					NodeServingCert: NodeServingCert{
+						MaxExtraDNSNames: pointer.Int(1),
+					},
+				},
+				machines: []machinehandlerpkg.Machine{makeMachine("test")},
+				req: &certificatesv1.CertificateSigningRequest{
+					Spec: certificatesv1.CertificateSigningRequestSpec{
+						Usages: []certificatesv1.KeyUsage{
+							certificatesv1.UsageDigitalSignature,
+							certificatesv1.UsageKeyEncipherment,
+							certificatesv1.UsageServerAuth,
+						},
+						Username: "system:node:test",
+						Groups: []string{
+							"system:authenticated",
+							"system:nodes",
+						},
+					},
+				},
+				csr: createCSR("system:node:test", defaultOrgs, defaultIPs, []string{"node1", "node1.local", "localhost", "node1", "node1.local"}),
+			},
+			wantErr:   "could not authorize CSR: exhausted all authorization methods: CSR requests 5 DNS names but machine only has 3 DNS addresses (max extra allowed: 1)",
+			authorize: false,
+		},
+		{
+			name: "csr-extra-dns-names-within-limit",
+			args: args{
+				config: ClusterMachineApproverConfig{
+					NodeServingCert: NodeServingCert{
+						MaxExtraDNSNames: pointer.Int(2),
+					},
+				},
+				machines: []machinehandlerpkg.Machine{makeMachine("test")},
+				req: &certificatesv1.CertificateSigningRequest{
+					Spec: certificatesv1.CertificateSigningRequestSpec{
+						Usages: []certificatesv1.KeyUsage{
+							certificatesv1.UsageDigitalSignature,
+							certificatesv1.UsageKeyEncipherment,
+							certificatesv1.UsageServerAuth,
+						},
+						Username: "system:node:test",
+						Groups: []string{
+							"system:authenticated",
+							"system:nodes",
+						},
+					},
+				},
+				csr: createCSR("system:node:test", defaultOrgs, defaultIPs, []string{"node1", "node1.local", "node1", "node1.local"}),
+			},
+			authorize: true,
+		},
+		{
+			name: "control plane csr with VIP",
+			args: args{
+				config:   controlPlaneConfig,
+				machines: []machinehandlerpkg.Machine{asControlPlane(makeMachine("test"))},
+				req: &certificatesv1.CertificateSigningRequest{
+					Spec: certificatesv1.CertificateSigningRequestSpec{
+						Usages: []certificatesv1.KeyUsage{
+							certificatesv1.UsageDigitalSignature,
+							certificatesv1.UsageKeyEncipherment,
+							certificatesv1.UsageServerAuth,
+						},
+						Username: "system:node:test",
+						Groups: []string{
+							"system:authenticated",
+							"system:nodes",
+						},
+					},
+				},
+				csr: controlPlaneCSR,
+			},
+			wantErr:   "",
+			authorize: true,
+		},
+		{
+			name: "worker csr with VIP",
+			args: args{
+				config:   controlPlaneConfig,
+				machines: []machinehandlerpkg.Machine{makeMachine("test")},
+				req: &certificatesv1.CertificateSigningRequest{
+					Spec: certificatesv1.CertificateSigningRequestSpec{
+						Usages: []certificatesv1.KeyUsage{
+							certificatesv1.UsageDigitalSignature,
+							certificatesv1.UsageKeyEncipherment,
+							certificatesv1.UsageServerAuth,
+						},
+						Username: "system:node:test",
+						Groups: []string{
+							"system:authenticated",
+							"system:nodes",
+						},
+					},
+				},
+				csr: controlPlaneCSR,
+			},
+			wantErr:   "could not authorize CSR: exhausted all authorization methods: DNS name 'api-int.example.com' not in machine names: node1.local node1 localhost",
+			authorize: false,
+		},
+		{
+			name: "control plane csr requires renewal",
+			args: args{
+				config: ClusterMachineApproverConfig{
+					NodeServingCert: NodeServingCert{
+						ControlPlane: ControlPlaneServingCert{
+							RequireRenewal: true,
+						},
+					},
+				},
+				machines: []machinehandlerpkg.Machine{asControlPlane(makeMachine("test"))},
+				req: &certificatesv1.CertificateSigningRequest{
+					Spec: certificatesv1.CertificateSigningRequestSpec{
+						Usages: []certificatesv1.KeyUsage{
+							certificatesv1.UsageDigitalSignature,
+							certificatesv1.UsageKeyEncipherment,
+							certificatesv1.UsageServerAuth,
+						},
+						Username: "system:node:test",
+						Groups: []string{
+							"system:authenticated",
+							"system:nodes",
+						},
+					},
+				},
+				csr: goodCSR,
+			},
+			wantErr:   "could not authorize CSR: exhausted all authorization methods: control plane serving cert for node test can only be renewed",
+			authorize: false,
+		},
+		{
+			name: "worker csr does not require renewal",
+			args: args{
+				config: ClusterMachineApproverConfig{
+					NodeServingCert: NodeServingCert{
+						ControlPlane: ControlPlaneServingCert{
+							RequireRenewal: true,
+						},
+					},
+				},
+				machines: []machinehandlerpkg.Machine{makeMachine("test")},
+				req: &certificatesv1.CertificateSigningRequest{
+					Spec: certificatesv1.CertificateSigningRequestSpec{
+						Usages: []certificatesv1.KeyUsage{
+							certificatesv1.UsageDigitalSignature,
+							certificatesv1.UsageKeyEncipherment,
+							certificatesv1.UsageServerAuth,
+						},
+						Username: "system:node:test",
+						Groups: []string{
+							"system:authenticated",
+							"system:nodes",
+						},
+					},
+				},
+				csr: goodCSR,
+			},
+			authorize: true,
+		},
 		{
 			name: "client good",
 			args: args{
@@ -830,6 +1025,108 @@ func Test_authorizeCSR(t *testing.T) {
 			wantErr:   "",
 			authorize: true,
 		},
+		{
+			name: "client good from within source network",
+			args: args{
+				config: ClusterMachineApproverConfig{
+					NodeClientCert: NodeClientCert{
+						SourceNetwork: SourceNetwork{
+							CIDRs: []string{"10.0.0.0/16"},
+						},
+					},
+				},
+				machines: []machinehandlerpkg.Machine{
+					makeMachine("", corev1.NodeAddress{Type: corev1.NodeInternalDNS, Address: "panda"}),
+				},
+				req: &certificatesv1.CertificateSigningRequest{
+					Spec: certificatesv1.CertificateSigningRequestSpec{
+						Usages: []certificatesv1.KeyUsage{
+							certificatesv1.UsageKeyEncipherment,
+							certificatesv1.UsageDigitalSignature,
+							certificatesv1.UsageClientAuth,
+						},
+						Username: "system:serviceaccount:openshift-machine-config-operator:node-bootstrapper",
+						Groups: []string{
+							"system:authenticated",
+							"system:serviceaccounts:openshift-machine-config-operator",
+							"system:serviceaccounts",
+						},
+						Extra: map[string]certificatesv1.ExtraValue{
+							"source-ip": {"10.0.3.4"},
+						},
+					},
+				},
+				csr: clientGood,
+			},
+			authorize: true,
+		},
+		{
+			name: "client good but outside source network",
+			args: args{
+				config: ClusterMachineApproverConfig{
+					NodeClientCert: NodeClientCert{
+						SourceNetwork: SourceNetwork{
+							CIDRs: []string{"10.0.0.0/16"},
+						},
+					},
+				},
+				machines: []machinehandlerpkg.Machine{
+					makeMachine("", corev1.NodeAddress{Type: corev1.NodeInternalDNS, Address: "panda"}),
+				},
+				req: &certificatesv1.CertificateSigningRequest{
+					Spec: certificatesv1.CertificateSigningRequestSpec{
+						Usages: []certificatesv1.KeyUsage{
+							certificatesv1.UsageKeyEncipherment,
+							certificatesv1.UsageDigitalSignature,
+							certificatesv1.UsageClientAuth,
+						},
+						Username: "system:serviceaccount:openshift-machine-config-operator:node-bootstrapper",
+						Groups: []string{
+							"system:authenticated",
+							"system:serviceaccounts:openshift-machine-config-operator",
+							"system:serviceaccounts",
+						},
+						Extra: map[string]certificatesv1.ExtraValue{
+							"source-ip": {"192.168.0.1"},
+						},
+					},
+				},
+				csr: clientGood,
+			},
+			authorize: false,
+		},
+		{
+			name: "client good without source IP",
+			args: args{
+				config: ClusterMachineApproverConfig{
+					NodeClientCert: NodeClientCert{
+						SourceNetwork: SourceNetwork{
+							CIDRs: []string{"10.0.0.0/16"},
+						},
+					},
+				},
+				machines: []machinehandlerpkg.Machine{
+					makeMachine("", corev1.NodeAddress{Type: corev1.NodeInternalDNS, Address: "panda"}),
+				},
+				req: &certificatesv1.CertificateSigningRequest{
+					Spec: certificatesv1.CertificateSigningRequestSpec{
+						Usages: []certificatesv1.KeyUsage{
+							certificatesv1.UsageKeyEncipherment,
+							certificatesv1.UsageDigitalSignature,
+							certificatesv1.UsageClientAuth,
+						},
+						Username: "system:serviceaccount:openshift-machine-config-operator:node-bootstrapper",
+						Groups: []string{
+							"system:authenticated",
+							"system:serviceaccounts:openshift-machine-config-operator",
+							"system:serviceaccounts",
+						},
+					},
+				},
+				csr: clientGood,
+			},
+			authorize: true,
+		},
 		{
 			name: "client extra O",
 			args: args{
@@ -1399,9 +1696,11 @@ func Test_authorizeCSR(t *testing.T) {
 			authorize: true,
 		},
 		{
-			name: "successfull fallback to fresh approval",
+			name: "renew flow of node with machine in scope",
 			args: args{
+				config:   ClusterMachineApproverConfig{MachineNamespace: "openshift-machine-api"},
 				machines: []machinehandlerpkg.Machine{makeMachine("test")},
+				node:     withName("test", defaultNode()),
 				req: &certificatesv1.CertificateSigningRequest{
 					ObjectMeta: metav1.ObjectMeta{
 						Name:              "renew",
@@ -1426,9 +1725,11 @@ func Test_authorizeCSR(t *testing.T) {
 			authorize: true,
 		},
 		{
-			name: "successfull fallback to fresh approval from incorrect server cert",
+			name: "renew flow of node without machine in scope",
 			args: args{
-				node: withPort(defaultPort+1, withName("test", defaultNode())),
+				config:   ClusterMachineApproverConfig{MachineNamespace: "openshift-machine-api"},
+				machines: nil,
+				node:     withName("test", defaultNode()),
 				req: &certificatesv1.CertificateSigningRequest{
 					ObjectMeta: metav1.ObjectMeta{
 						Name:              "renew",
@@ -1447,22 +1748,25 @@ func Test_authorizeCSR(t *testing.T) {
 						},
 					},
 				},
-				csr:           goodCSR,
-				ca:            []*x509.Certificate{parseCert(t, differentCert)},
-				kubeletServer: fakeResponder(t, fmt.Sprintf("%s:%v", defaultAddr, defaultPort+1), differentCert, differentKey),
+				csr: goodCSR,
+				ca:  []*x509.Certificate{parseCert(t, rootCertGood)},
 			},
-			wantErr:   "could not authorize CSR: exhausted all authorization methods: [current serving cert has bad common name, Unable to find machine for node]",
+			wantErr:   "could not authorize CSR: exhausted all authorization methods: [node test has no machine in scope, Unable to find machine for node]",
 			authorize: false,
 		},
 		{
-			name: "CSR extra address not in egress IPs",
+			name: "successfull fallback to fresh approval",
 			args: args{
-				node: withName("test", defaultNode()),
+				machines: []machinehandlerpkg.Machine{makeMachine("test")},
 				req: &certificatesv1.CertificateSigningRequest{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:              "renew",
+						CreationTimestamp: creationTimestamp(10 * time.Minute),
+					},
 					Spec: certificatesv1.CertificateSigningRequestSpec{
 						Usages: []certificatesv1.KeyUsage{
-							certificatesv1.UsageDigitalSignature,
 							certificatesv1.UsageKeyEncipherment,
+							certificatesv1.UsageDigitalSignature,
 							certificatesv1.UsageServerAuth,
 						},
 						Username: "system:node:test",
@@ -1472,132 +1776,4112 @@ func Test_authorizeCSR(t *testing.T) {
 						},
 					},
 				},
-				csr:         extraAddr,
-				networkType: "OpenShiftSDN",
-				hostSubnet:  hostSubnet("test"),
-				ca:          []*x509.Certificate{parseCert(t, rootCertGood)},
+				csr: goodCSR,
+				ca:  []*x509.Certificate{parseCert(t, rootCertGood)},
+			},
+			authorize: true,
+		},
+		{
+			name: "successfull fallback to fresh approval from incorrect server cert",
+			args: args{
+				node: withPort(defaultPort+1, withName("test", defaultNode())),
+				req: &certificatesv1.CertificateSigningRequest{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:              "renew",
+						CreationTimestamp: creationTimestamp(10 * time.Minute),
+					},
+					Spec: certificatesv1.CertificateSigningRequestSpec{
+						Usages: []certificatesv1.KeyUsage{
+							certificatesv1.UsageKeyEncipherment,
+							certificatesv1.UsageDigitalSignature,
+							certificatesv1.UsageServerAuth,
+						},
+						Username: "system:node:test",
+						Groups: []string{
+							"system:authenticated",
+							"system:nodes",
+						},
+					},
+				},
+				csr:           goodCSR,
+				ca:            []*x509.Certificate{parseCert(t, differentCert)},
+				kubeletServer: fakeResponder(t, fmt.Sprintf("%s:%v", defaultAddr, defaultPort+1), differentCert, differentKey),
+			},
+			wantErr:   "could not authorize CSR: exhausted all authorization methods: [current serving cert has bad common name, Unable to find machine for node]",
+			authorize: false,
+		},
+		{
+			name: "CSR extra address not in egress IPs",
+			args: args{
+				node: withName("test", defaultNode()),
+				req: &certificatesv1.CertificateSigningRequest{
+					Spec: certificatesv1.CertificateSigningRequestSpec{
+						Usages: []certificatesv1.KeyUsage{
+							certificatesv1.UsageDigitalSignature,
+							certificatesv1.UsageKeyEncipherment,
+							certificatesv1.UsageServerAuth,
+						},
+						Username: "system:node:test",
+						Groups: []string{
+							"system:authenticated",
+							"system:nodes",
+						},
+					},
+				},
+				csr:         extraAddr,
+				networkType: "OpenShiftSDN",
+				hostSubnet:  hostSubnet("test"),
+				ca:          []*x509.Certificate{parseCert(t, rootCertGood)},
+			},
+			wantErr:   "could not authorize CSR: exhausted all authorization methods: [CSR Subject Alternate Names [99.0.1.1] are not in current certificate and node test has no machine, Unable to find machine for node, CSR Subject Alternate Names includes unknown IP addresses]",
+			authorize: false,
+		},
+		{
+			name: "CSR extra address in egress IPs",
+			args: args{
+				node: withName("test", defaultNode()),
+				req: &certificatesv1.CertificateSigningRequest{
+					Spec: certificatesv1.CertificateSigningRequestSpec{
+						Usages: []certificatesv1.KeyUsage{
+							certificatesv1.UsageDigitalSignature,
+							certificatesv1.UsageKeyEncipherment,
+							certificatesv1.UsageServerAuth,
+						},
+						Username: "system:node:test",
+						Groups: []string{
+							"system:authenticated",
+							"system:nodes",
+						},
+					},
+				},
+				csr:         extraAddr,
+				networkType: "OpenShiftSDN",
+				hostSubnet:  withEgressIPs(hostSubnet("test"), "99.0.1.1"),
+				ca:          []*x509.Certificate{parseCert(t, rootCertGood)},
+			},
+			authorize: true,
+		},
+		{
+			name: "CSR extra address in egress CIDRs",
+			args: args{
+				node: withName("test", defaultNode()),
+				req: &certificatesv1.CertificateSigningRequest{
+					Spec: certificatesv1.CertificateSigningRequestSpec{
+						Usages: []certificatesv1.KeyUsage{
+							certificatesv1.UsageDigitalSignature,
+							certificatesv1.UsageKeyEncipherment,
+							certificatesv1.UsageServerAuth,
+						},
+						Username: "system:node:test",
+						Groups: []string{
+							"system:authenticated",
+							"system:nodes",
+						},
+					},
+				},
+				csr:         extraAddr,
+				networkType: "OpenShiftSDN",
+				hostSubnet:  withEgressCIDRs(hostSubnet("test"), "99.0.1.0/24"),
+				ca:          []*x509.Certificate{parseCert(t, rootCertGood)},
+			},
+			authorize: true,
+		},
+	}
+
+	server := fakeResponder(t, fmt.Sprintf("%s:%v", defaultAddr, defaultPort), serverCertGood, serverKeyGood)
+	defer server.Close()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kubeletServer := server
+			if tt.args.kubeletServer != nil {
+				kubeletServer = tt.args.kubeletServer
+				defer kubeletServer.Close()
+			}
+
+			network := &configv1.Network{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "cluster",
+				},
+				Status: configv1.NetworkStatus{
+					NetworkType: tt.args.networkType,
+				},
+			}
+
+			objs := []runtime.Object{network}
+			if tt.args.node != nil {
+				objs = append(objs, tt.args.node)
+			}
+			if tt.args.hostSubnet != nil {
+				objs = append(objs, tt.args.hostSubnet)
+			}
+			cl := fake.NewFakeClient(objs...)
+			tt.args.req.Spec.Request = []byte(tt.args.csr)
+			parsedCSR, err := parseCSR(tt.args.req)
+			if err != nil {
+				if errString(err) != tt.wantErr {
+					t.Errorf("parseCSR() error = %v, wantErr %s", err, tt.wantErr)
+				}
+				return
+			}
+
+			var ca []*x509.CertPool
+			if len(tt.args.ca) > 0 {
+				// Start renewal flow
+				pool := x509.NewCertPool()
+				for _, cert := range tt.args.ca {
+					pool.AddCert(cert)
+				}
+				ca = []*x509.CertPool{pool}
+				go respond(kubeletServer)
+			}
+			approver := &CertificateApprover{NodeClient: cl, Config: tt.args.config}
+			if _, authorize, err := approver.authorizeCSR(context.Background(), tt.args.machines, tt.args.req, parsedCSR, ca); authorize != tt.authorize || errString(err) != tt.wantErr {
+				t.Errorf("authorizeCSR() error = %v, wantErr %s", err, tt.wantErr)
+			}
+		})
+
+		t.Run("Invalid call", func(t *testing.T) {
+			approver := &CertificateApprover{Config: tt.args.config}
+			if _, authorize, err := approver.authorizeCSR(context.Background(), tt.args.machines, nil, nil, nil); authorize != false {
+				t.Errorf("authorizeCSR() error = %v, wantErr %s", err, "Invalid request")
+			}
+		})
+	}
+}
+
+func TestValidateSourceNetwork(t *testing.T) {
+	withSourceIP := func(key, ip string) *certificatesv1.CertificateSigningRequest {
+		return &certificatesv1.CertificateSigningRequest{
+			Spec: certificatesv1.CertificateSigningRequestSpec{
+				Extra: map[string]certificatesv1.ExtraValue{
+					key: {ip},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name          string
+		sourceNetwork SourceNetwork
+		req           *certificatesv1.CertificateSigningRequest
+		wantErr       string
+	}{
+		{
+			name: "check disabled",
+			req:  withSourceIP("source-ip", "192.168.0.1"),
+		},
+		{
+			name:          "IPv4 in network",
+			sourceNetwork: SourceNetwork{CIDRs: []string{"10.0.0.0/16"}},
+			req:           withSourceIP("source-ip", "10.0.1.1"),
+		},
+		{
+			name:          "IPv6 in network",
+			sourceNetwork: SourceNetwork{CIDRs: []string{"10.0.0.0/16", "fd00::/64"}},
+			req:           withSourceIP("source-ip", "fd00::1"),
+		},
+		{
+			name:          "not in network",
+			sourceNetwork: SourceNetwork{CIDRs: []string{"10.0.0.0/16"}},
+			req:           withSourceIP("source-ip", "10.1.0.1"),
+			wantErr:       "source IP 10.1.0.1 not in allowed networks [10.0.0.0/16]",
+		},
+		{
+			name:          "custom extra key",
+			sourceNetwork: SourceNetwork{CIDRs: []string{"10.0.0.0/16"}, ExtraKey: "example.com/client-ip"},
+			req:           withSourceIP("example.com/client-ip", "10.1.0.1"),
+			wantErr:       "source IP 10.1.0.1 not in allowed networks [10.0.0.0/16]",
+		},
+		{
+			name:          "no source IP",
+			sourceNetwork: SourceNetwork{CIDRs: []string{"10.0.0.0/16"}},
+			req:           withSourceIP("example.com/client-ip", "10.1.0.1"),
+		},
+		{
+			name:          "invalid source IP",
+			sourceNetwork: SourceNetwork{CIDRs: []string{"10.0.0.0/16"}},
+			req:           withSourceIP("source-ip", "panda"),
+			wantErr:       "could not parse source IP \"panda\"",
+		},
+		{
+			name:          "invalid CIDR",
+			sourceNetwork: SourceNetwork{CIDRs: []string{"10.0.0.0"}},
+			req:           withSourceIP("source-ip", "10.0.0.1"),
+			wantErr:       `invalid nodeClientCert.sourceNetwork.cidrs CIDR "10.0.0.0": invalid CIDR address: 10.0.0.0`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateSourceNetwork(tt.sourceNetwork, tt.req); errString(err) != tt.wantErr {
+				t.Errorf("got: %v, want: %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthorizeNodeClientCSRReplacedInstance(t *testing.T) {
+	machine := func(providerID string) machinehandlerpkg.Machine {
+		return machinehandlerpkg.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "panda-machine",
+				UID:               "panda-uid",
+				CreationTimestamp: creationTimestamp(-5 * time.Minute),
+			},
+			Spec: machinehandlerpkg.MachineSpec{
+				ProviderID: pointer.String(providerID),
+			},
+			Status: machinehandlerpkg.MachineStatus{
+				Addresses: []corev1.NodeAddress{
+					{Type: corev1.NodeInternalDNS, Address: "panda"},
+				},
+			},
+		}
+	}
+	clientReq := func(created time.Duration) *certificatesv1.CertificateSigningRequest {
+		return &certificatesv1.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "panda-csr",
+				CreationTimestamp: creationTimestamp(created),
+			},
+			Spec: certificatesv1.CertificateSigningRequestSpec{
+				Usages: []certificatesv1.KeyUsage{
+					certificatesv1.UsageKeyEncipherment,
+					certificatesv1.UsageDigitalSignature,
+					certificatesv1.UsageClientAuth,
+				},
+				Username: "system:serviceaccount:openshift-machine-config-operator:node-bootstrapper",
+				Groups: []string{
+					"system:authenticated",
+					"system:serviceaccounts:openshift-machine-config-operator",
+					"system:serviceaccounts",
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		disabled  bool
+		observed  []string
+		current   string
+		created   time.Duration
+		authorize bool
+	}{
+		{
+			name:      "instance never replaced",
+			observed:  []string{"aws:///i-1"},
+			current:   "aws:///i-1",
+			created:   -time.Minute,
+			authorize: true,
+		},
+		{
+			name:      "instance replaced after CSR creation",
+			observed:  []string{"aws:///i-1"},
+			current:   "aws:///i-2",
+			created:   -time.Minute,
+			authorize: false,
+		},
+		{
+			name:      "instance replaced before CSR creation",
+			observed:  []string{"aws:///i-1"},
+			current:   "aws:///i-2",
+			created:   time.Minute,
+			authorize: true,
+		},
+		{
+			name:      "instance replaced after CSR creation but check disabled",
+			disabled:  true,
+			observed:  []string{"aws:///i-1"},
+			current:   "aws:///i-2",
+			created:   -time.Minute,
+			authorize: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			approver := &CertificateApprover{
+				NodeClient: fake.NewFakeClient(),
+				Config: ClusterMachineApproverConfig{
+					NodeClientCert: NodeClientCert{RejectReplacedInstances: !tt.disabled},
+				},
+				Clock: testingclock.NewFakePassiveClock(baseTime),
+			}
+			for _, providerID := range tt.observed {
+				m := machine(providerID)
+				approver.machineInstances.observe(&m, baseTime)
+			}
+
+			req := clientReq(tt.created)
+			req.Spec.Request = []byte(clientGood)
+			_, authorize, err := approver.authorizeCSR(context.Background(), []machinehandlerpkg.Machine{machine(tt.current)}, req, parseCR(t, clientGood), nil)
+			if authorize != tt.authorize || err != nil {
+				t.Errorf("authorizeCSR() = %v, error = %v, want %v", authorize, err, tt.authorize)
+			}
+		})
+	}
+}
+
+func TestAuthorizeNodeClientCSRInvalidNodeName(t *testing.T) {
+	tests := []struct {
+		name       string
+		nodeName   string
+		wantReason string
+	}{
+		{
+			name:       "valid node name",
+			nodeName:   "panda.example.com",
+			wantReason: decisionReasonMachineNotFound,
+		},
+		{
+			name:       "uppercase characters",
+			nodeName:   "Panda",
+			wantReason: decisionReasonInvalidCommonName,
+		},
+		{
+			name:       "invalid characters",
+			nodeName:   "panda_1/../kube-system",
+			wantReason: decisionReasonInvalidCommonName,
+		},
+		{
+			name:       "leading dash",
+			nodeName:   "-panda",
+			wantReason: decisionReasonInvalidCommonName,
+		},
+		{
+			name:       "excessive length",
+			nodeName:   strings.Repeat("panda.", 50) + "example.com",
+			wantReason: decisionReasonInvalidCommonName,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			csr := createCSR("system:node:"+tt.nodeName, []string{"system:nodes"}, nil, nil)
+			req := &certificatesv1.CertificateSigningRequest{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "panda-csr",
+					CreationTimestamp: creationTimestamp(-time.Minute),
+				},
+				Spec: certificatesv1.CertificateSigningRequestSpec{
+					Request: []byte(csr),
+					Usages: []certificatesv1.KeyUsage{
+						certificatesv1.UsageKeyEncipherment,
+						certificatesv1.UsageDigitalSignature,
+						certificatesv1.UsageClientAuth,
+					},
+					Username: nodeBootstrapperUsername,
+					Groups:   nodeBootstrapperGroups.List(),
+				},
+			}
+
+			nodeLookups := 0
+			approver := &CertificateApprover{
+				NodeClient: fake.NewClientBuilder().WithInterceptorFuncs(interceptor.Funcs{
+					Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+						if _, ok := obj.(*corev1.Node); ok {
+							nodeLookups++
+						}
+						return c.Get(ctx, key, obj, opts...)
+					},
+				}).Build(),
+			}
+
+			got := approver.Authorize(context.Background(), nil, req, parseCR(t, csr), nil)
+			if got.Authorized || got.Reason != tt.wantReason {
+				t.Errorf("Authorize() = %+v, want reason %s", got, tt.wantReason)
+			}
+			// Invalid node names are never looked up.
+			wantLookups := 0
+			if tt.wantReason != decisionReasonInvalidCommonName {
+				wantLookups = 1
+			}
+			if nodeLookups != wantLookups {
+				t.Errorf("node looked up %d times, want %d", nodeLookups, wantLookups)
+			}
+		})
+	}
+}
+
+func TestAuthorizeNodeClientCSRExistingNode(t *testing.T) {
+	machine := func(nodeRef string, providerID *string) machinehandlerpkg.Machine {
+		m := machinehandlerpkg.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "panda-machine",
+				CreationTimestamp: creationTimestamp(-4 * time.Minute),
+			},
+			Spec: machinehandlerpkg.MachineSpec{
+				ProviderID: providerID,
+			},
+			Status: machinehandlerpkg.MachineStatus{
+				Addresses: []corev1.NodeAddress{
+					{Type: corev1.NodeInternalDNS, Address: "panda"},
+				},
+			},
+		}
+		if nodeRef != "" {
+			m.Status.NodeRef = &corev1.ObjectReference{Name: nodeRef}
+		}
+		return m
+	}
+
+	tests := []struct {
+		name           string
+		machines       []machinehandlerpkg.Machine
+		nodeProviderID string
+		wantReason     string
+		wantEvent      string
+	}{
+		{
+			name:       "node of its machine",
+			machines:   []machinehandlerpkg.Machine{machine("panda", pointer.String("aws:///us-east-1a/i-panda"))},
+			wantReason: decisionReasonNodeReRequested,
+			wantEvent:  "Normal CSRDenied node panda already exists for machine panda-machine, its client cert must be renewed instead",
+		},
+		{
+			name:           "node of its machine with the same provider ID",
+			machines:       []machinehandlerpkg.Machine{machine("panda", pointer.String("aws:///us-east-1a/i-panda"))},
+			nodeProviderID: "aws:///us-east-1a/i-panda",
+			wantReason:     decisionReasonNodeReRequested,
+			wantEvent:      "Normal CSRDenied node panda already exists for machine panda-machine, its client cert must be renewed instead",
+		},
+		{
+			name:           "node of its machine with another provider ID",
+			machines:       []machinehandlerpkg.Machine{machine("panda", pointer.String("aws:///us-east-1a/i-panda"))},
+			nodeProviderID: "aws:///us-east-1a/i-intruder",
+			wantReason:     decisionReasonNodeExists,
+			wantEvent:      "Warning CSRDenied node panda already exists with provider ID aws:///us-east-1a/i-intruder, not the one of its machine panda-machine",
+		},
+		{
+			name:       "machine without node ref",
+			machines:   []machinehandlerpkg.Machine{machine("", nil)},
+			wantReason: decisionReasonNodeExists,
+			wantEvent:  "Warning CSRDenied node panda already exists",
+		},
+		{
+			name:       "machine of another node",
+			machines:   []machinehandlerpkg.Machine{machine("bamboo", nil)},
+			wantReason: decisionReasonNodeExists,
+			wantEvent:  "Warning CSRDenied node panda already exists",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &certificatesv1.CertificateSigningRequest{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "panda-csr",
+					CreationTimestamp: creationTimestamp(-time.Minute),
+				},
+				Spec: certificatesv1.CertificateSigningRequestSpec{
+					Request: []byte(clientGood),
+					Usages: []certificatesv1.KeyUsage{
+						certificatesv1.UsageKeyEncipherment,
+						certificatesv1.UsageDigitalSignature,
+						certificatesv1.UsageClientAuth,
+					},
+					Username: nodeBootstrapperUsername,
+					Groups:   nodeBootstrapperGroups.List(),
+				},
+			}
+			node := &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "panda"},
+				Spec:       corev1.NodeSpec{ProviderID: tt.nodeProviderID},
+			}
+			recorder := record.NewFakeRecorder(10)
+			approver := &CertificateApprover{
+				NodeClient: fake.NewClientBuilder().WithObjects(node).Build(),
+				Recorder:   recorder,
+			}
+
+			got := approver.Authorize(context.Background(), tt.machines, req, parseCR(t, clientGood), nil)
+			if got.Authorized || got.Reason != tt.wantReason || got.Err != nil {
+				t.Errorf("Authorize() = %+v, want reason %s", got, tt.wantReason)
+			}
+
+			select {
+			case event := <-recorder.Events:
+				if event != tt.wantEvent {
+					t.Errorf("got event %q, want %q", event, tt.wantEvent)
+				}
+			default:
+				t.Errorf("expected event %q", tt.wantEvent)
+			}
+		})
+	}
+}
+
+func TestAuthorizeNodeClientCSRMachineToApproval(t *testing.T) {
+	machines := []machinehandlerpkg.Machine{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "panda-machine",
+			CreationTimestamp: creationTimestamp(-4 * time.Minute),
+		},
+		Status: machinehandlerpkg.MachineStatus{
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalDNS, Address: "panda"},
+			},
+		},
+	}}
+	req := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "panda-csr",
+			CreationTimestamp: creationTimestamp(-time.Minute),
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Request: []byte(clientGood),
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageKeyEncipherment,
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageClientAuth,
+			},
+			Username: nodeBootstrapperUsername,
+			Groups:   nodeBootstrapperGroups.List(),
+		},
+	}
+
+	tests := []struct {
+		name        string
+		machines    []machinehandlerpkg.Machine
+		wantObserve bool
+	}{
+		{
+			name:        "approved",
+			machines:    machines,
+			wantObserve: true,
+		},
+		{
+			name: "not approved",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			approver := &CertificateApprover{
+				NodeClient: fake.NewFakeClient(),
+				Clock:      testingclock.NewFakePassiveClock(baseTime),
+			}
+
+			countBefore, sumBefore := histogramValue(t, machineToApprovalSeconds)
+
+			_, authorize, _ := approver.authorizeCSR(context.Background(), tt.machines, req.DeepCopy(), parseCR(t, clientGood), nil)
+			if authorize != tt.wantObserve {
+				t.Fatalf("authorizeCSR() = %v, want %v", authorize, tt.wantObserve)
+			}
+
+			wantCount, wantSum := countBefore, sumBefore
+			if tt.wantObserve {
+				// The machine was created 4 minutes before the approval.
+				wantCount++
+				wantSum += 240
+			}
+			if count, sum := histogramValue(t, machineToApprovalSeconds); count != wantCount || sum != wantSum {
+				t.Errorf("machine to approval histogram = %d samples summing to %v, want %d summing to %v", count, sum, wantCount, wantSum)
+			}
+		})
+	}
+}
+
+func TestAuthorizeNodeClientCSRMinMachineAge(t *testing.T) {
+	machines := []machinehandlerpkg.Machine{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "panda-machine",
+			CreationTimestamp: creationTimestamp(-5 * time.Minute),
+		},
+		Status: machinehandlerpkg.MachineStatus{
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalDNS, Address: "panda"},
+			},
+		},
+	}}
+	req := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "panda-csr",
+			CreationTimestamp: creationTimestamp(-time.Minute),
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Request: []byte(clientGood),
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageKeyEncipherment,
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageClientAuth,
+			},
+			Username: nodeBootstrapperUsername,
+			Groups:   nodeBootstrapperGroups.List(),
+		},
+	}
+
+	tests := []struct {
+		name      string
+		minAge    time.Duration
+		authorize bool
+		wantErr   string
+	}{
+		{
+			name:      "no minimum age",
+			authorize: true,
+		},
+		{
+			name:      "machine older than minimum age",
+			minAge:    time.Minute,
+			authorize: true,
+		},
+		{
+			name:      "machine younger than minimum age",
+			minAge:    10 * time.Minute,
+			authorize: false,
+			wantErr:   "machine panda-machine created 5m0s ago, below minimum age 10m0s",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			approver := &CertificateApprover{
+				NodeClient: fake.NewFakeClient(),
+				Config: ClusterMachineApproverConfig{
+					NodeClientCert: NodeClientCert{MinMachineAge: metav1.Duration{Duration: tt.minAge}},
+				},
+				Clock: testingclock.NewFakePassiveClock(baseTime),
+			}
+			_, authorize, err := approver.authorizeCSR(context.Background(), machines, req, parseCR(t, clientGood), nil)
+			if authorize != tt.authorize || errString(err) != tt.wantErr {
+				t.Errorf("authorizeCSR() = %v, error = %v, want %v, error %s", authorize, err, tt.authorize, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthorizeNodeClientCSRTimeWindow(t *testing.T) {
+	machines := []machinehandlerpkg.Machine{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "panda-machine",
+			CreationTimestamp: creationTimestamp(-3 * time.Hour),
+		},
+		Status: machinehandlerpkg.MachineStatus{
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalDNS, Address: "panda"},
+			},
+		},
+	}}
+	req := func(created time.Duration) *certificatesv1.CertificateSigningRequest {
+		return &certificatesv1.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "panda-csr",
+				CreationTimestamp: creationTimestamp(created),
+			},
+			Spec: certificatesv1.CertificateSigningRequestSpec{
+				Request: []byte(clientGood),
+				Usages: []certificatesv1.KeyUsage{
+					certificatesv1.UsageKeyEncipherment,
+					certificatesv1.UsageDigitalSignature,
+					certificatesv1.UsageClientAuth,
+				},
+				Username: nodeBootstrapperUsername,
+				Groups:   nodeBootstrapperGroups.List(),
+			},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		config    ClusterMachineApproverConfig
+		created   time.Duration
+		want      AuthorizeResult
+		wantEvent string
+	}{
+		{
+			name:      "after default time window",
+			created:   -time.Minute,
+			want:      AuthorizeResult{Reason: decisionReasonCSRPastTimeWindow},
+			wantEvent: "Warning CSRDenied CSR created 2h59m0s after machine panda-machine, beyond the time window of 2h0m0s",
+		},
+		{
+			name:    "within configured time window",
+			config:  ClusterMachineApproverConfig{ClientCertTimeWindow: metav1.Duration{Duration: 4 * time.Hour}},
+			created: -time.Minute,
+			want:    AuthorizeResult{Authorized: true, Reason: decisionReasonMachine},
+		},
+		{
+			name:      "after configured time window",
+			config:    ClusterMachineApproverConfig{ClientCertTimeWindow: metav1.Duration{Duration: time.Hour}},
+			created:   -time.Hour,
+			want:      AuthorizeResult{Reason: decisionReasonCSRPastTimeWindow},
+			wantEvent: "Warning CSRDenied CSR created 2h0m0s after machine panda-machine, beyond the time window of 1h0m0s",
+		},
+		{
+			name:      "before machine creation beyond default clock skew",
+			created:   -3*time.Hour - time.Minute,
+			want:      AuthorizeResult{Reason: decisionReasonCSRPredatesMachine},
+			wantEvent: "Warning CSRDenied CSR created 1m0s before machine panda-machine, beyond the clock skew of 10s",
+		},
+		{
+			name:    "before machine creation within configured clock skew",
+			config:  ClusterMachineApproverConfig{ClockSkew: metav1.Duration{Duration: 5 * time.Minute}},
+			created: -3*time.Hour - time.Minute,
+			want:    AuthorizeResult{Authorized: true, Reason: decisionReasonMachine},
+		},
+		{
+			name:      "before machine creation beyond configured clock skew",
+			config:    ClusterMachineApproverConfig{ClockSkew: metav1.Duration{Duration: 5 * time.Minute}},
+			created:   -3*time.Hour - 10*time.Minute,
+			want:      AuthorizeResult{Reason: decisionReasonCSRPredatesMachine},
+			wantEvent: "Warning CSRDenied CSR created 10m0s before machine panda-machine, beyond the clock skew of 5m0s",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			approver := &CertificateApprover{
+				NodeClient: fake.NewFakeClient(),
+				Recorder:   recorder,
+				Config:     tt.config,
+			}
+			if got := approver.Authorize(context.Background(), machines, req(tt.created), parseCR(t, clientGood), nil); got.Authorized != tt.want.Authorized || got.Reason != tt.want.Reason || got.Err != nil {
+				t.Errorf("Authorize() = %+v, want %+v", got, tt.want)
+			}
+
+			select {
+			case event := <-recorder.Events:
+				if event != tt.wantEvent {
+					t.Errorf("got event %q, want %q", event, tt.wantEvent)
+				}
+			default:
+				if tt.wantEvent != "" {
+					t.Errorf("expected event %q", tt.wantEvent)
+				}
+			}
+		})
+	}
+}
+
+func TestAuthorizeNodeClientCSRMachineLookupGracePeriod(t *testing.T) {
+	// No machine has the node name "panda" as internal DNS address.
+	machines := []machinehandlerpkg.Machine{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "bamboo-machine",
+			CreationTimestamp: creationTimestamp(-time.Hour),
+		},
+		Status: machinehandlerpkg.MachineStatus{
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalDNS, Address: "bamboo"},
+			},
+		},
+	}}
+	req := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "panda-csr",
+			CreationTimestamp: creationTimestamp(-10 * time.Minute),
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Request: []byte(clientGood),
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageKeyEncipherment,
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageClientAuth,
+			},
+			Username: nodeBootstrapperUsername,
+			Groups:   nodeBootstrapperGroups.List(),
+		},
+	}
+
+	tests := []struct {
+		name        string
+		gracePeriod time.Duration
+		wantReason  string
+		wantErr     string
+		wantEvent   string
+	}{
+		{
+			name:       "no grace period",
+			wantReason: decisionReasonMachineNotFound,
+			wantErr:    "failed to find machine for node panda",
+		},
+		{
+			name:        "within grace period",
+			gracePeriod: time.Hour,
+			wantReason:  decisionReasonMachineNotFound,
+			wantErr:     "failed to find machine for node panda",
+		},
+		{
+			name:        "past grace period",
+			gracePeriod: 5 * time.Minute,
+			wantReason:  decisionReasonMachineLookupExpired,
+			wantEvent:   "Warning CSRDenied no machine found for node panda within 5m0s of CSR creation",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			approver := &CertificateApprover{
+				NodeClient: fake.NewFakeClient(),
+				Recorder:   recorder,
+				Clock:      testingclock.NewFakePassiveClock(baseTime),
+				Config: ClusterMachineApproverConfig{
+					NodeClientCert: NodeClientCert{MachineLookupGracePeriod: metav1.Duration{Duration: tt.gracePeriod}},
+				},
+			}
+
+			// CSRs declined with an error are requeued, the others are not.
+			got := approver.Authorize(context.Background(), machines, req.DeepCopy(), parseCR(t, clientGood), nil)
+			if got.Authorized || got.Reason != tt.wantReason || errString(got.Err) != tt.wantErr {
+				t.Errorf("Authorize() = %+v, want reason %s, error %s", got, tt.wantReason, tt.wantErr)
+			}
+			if tt.wantErr != "" && !errors.Is(got.Err, ErrNoMatchingMachine) {
+				t.Errorf("Authorize() error = %v, want no matching machine", got.Err)
+			}
+
+			select {
+			case event := <-recorder.Events:
+				if event != tt.wantEvent {
+					t.Errorf("got event %q, want %q", event, tt.wantEvent)
+				}
+			default:
+				if tt.wantEvent != "" {
+					t.Errorf("expected event %q", tt.wantEvent)
+				}
+			}
+		})
+	}
+}
+
+func TestAuthorizeNodeClientCSRProviderIDMatching(t *testing.T) {
+	// The node name "panda" is not an internal DNS address of the machine.
+	machines := []machinehandlerpkg.Machine{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "panda-machine",
+			Annotations:       map[string]string{"example.com/node-name": "panda"},
+			CreationTimestamp: creationTimestamp(-5 * time.Minute),
+		},
+		Spec: machinehandlerpkg.MachineSpec{ProviderID: pointer.String("baremetal:///panda-host")},
+		Status: machinehandlerpkg.MachineStatus{
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalDNS, Address: "panda-host.example.com"},
+			},
+		},
+	}}
+	req := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "panda-csr",
+			CreationTimestamp: creationTimestamp(-time.Minute),
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Request: []byte(clientGood),
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageKeyEncipherment,
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageClientAuth,
+			},
+			Username: nodeBootstrapperUsername,
+			Groups:   nodeBootstrapperGroups.List(),
+		},
+	}
+
+	duplicate := machines[0]
+	duplicate.Name = "panda-machine-copy"
+
+	tests := []struct {
+		name      string
+		machines  []machinehandlerpkg.Machine
+		config    ProviderIDMatching
+		authorize bool
+		wantErr   string
+	}{
+		{
+			name:      "disabled",
+			authorize: false,
+			wantErr:   "failed to find machine for node panda",
+		},
+		{
+			name:      "matched by provider ID",
+			config:    ProviderIDMatching{NodeNameAnnotation: "example.com/node-name"},
+			authorize: true,
+		},
+		{
+			name:      "several machines annotated with node name",
+			machines:  append(machines, duplicate),
+			config:    ProviderIDMatching{NodeNameAnnotation: "example.com/node-name"},
+			authorize: false,
+			wantErr:   "more than one machine matches node panda: panda-machine and panda-machine-copy",
+		},
+		{
+			name:      "no machine annotated with node name",
+			config:    ProviderIDMatching{NodeNameAnnotation: "example.com/other"},
+			authorize: false,
+			wantErr:   "failed to find machine for node panda",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			approver := &CertificateApprover{
+				NodeClient: fake.NewFakeClient(),
+				Config: ClusterMachineApproverConfig{
+					NodeClientCert: NodeClientCert{ProviderIDMatching: tt.config},
+				},
+			}
+			if tt.machines == nil {
+				tt.machines = machines
+			}
+			_, authorize, err := approver.authorizeCSR(context.Background(), tt.machines, req, parseCR(t, clientGood), nil)
+			if authorize != tt.authorize || errString(err) != tt.wantErr {
+				t.Errorf("authorizeCSR() = %v, error = %v, want %v, error %s", authorize, err, tt.authorize, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthorizeNodeClientCSRInternalDNSFirstLabelMatching(t *testing.T) {
+	// On GCP, the node "panda" is named after the instance, while the internal
+	// DNS name of the machine is qualified by the project.
+	machine := func(name, internalDNS string) machinehandlerpkg.Machine {
+		return machinehandlerpkg.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				CreationTimestamp: creationTimestamp(-5 * time.Minute),
+			},
+			Status: machinehandlerpkg.MachineStatus{
+				Addresses: []corev1.NodeAddress{
+					{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+					{Type: corev1.NodeInternalDNS, Address: internalDNS},
+				},
+			},
+		}
+	}
+	req := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "panda-csr",
+			CreationTimestamp: creationTimestamp(-time.Minute),
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Request: []byte(clientGood),
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageKeyEncipherment,
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageClientAuth,
+			},
+			Username: nodeBootstrapperUsername,
+			Groups:   nodeBootstrapperGroups.List(),
+		},
+	}
+
+	tests := []struct {
+		name        string
+		machines    []machinehandlerpkg.Machine
+		enabled     bool
+		want        AuthorizeResult
+		wantErr     string
+		wantMachine string
+	}{
+		{
+			name:     "disabled",
+			machines: []machinehandlerpkg.Machine{machine("panda-machine", "panda.c.my-project.internal")},
+			want:     AuthorizeResult{Reason: decisionReasonMachineNotFound},
+			wantErr:  "failed to find machine for node panda",
+		},
+		{
+			name:        "matched by first label",
+			machines:    []machinehandlerpkg.Machine{machine("panda-machine", "panda.c.my-project.internal")},
+			enabled:     true,
+			want:        AuthorizeResult{Authorized: true, Reason: decisionReasonMachine},
+			wantMachine: "panda-machine",
+		},
+		{
+			name: "exact match preferred",
+			machines: []machinehandlerpkg.Machine{
+				machine("panda-machine", "panda.c.my-project.internal"),
+				machine("panda-exact", "panda"),
+			},
+			enabled:     true,
+			want:        AuthorizeResult{Authorized: true, Reason: decisionReasonMachine},
+			wantMachine: "panda-exact",
+		},
+		{
+			name: "first label of machines in different projects",
+			machines: []machinehandlerpkg.Machine{
+				machine("panda-machine", "panda.c.my-project.internal"),
+				machine("panda-other", "panda.c.other-project.internal"),
+			},
+			enabled: true,
+			want:    AuthorizeResult{Reason: decisionReasonAmbiguousMachine},
+			wantErr: "more than one machine matches node panda: panda-machine and panda-other",
+		},
+		{
+			name:     "other instance",
+			machines: []machinehandlerpkg.Machine{machine("bamboo-machine", "bamboo.c.my-project.internal")},
+			enabled:  true,
+			want:     AuthorizeResult{Reason: decisionReasonMachineNotFound},
+			wantErr:  "failed to find machine for node panda",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			approver := &CertificateApprover{
+				NodeClient: fake.NewFakeClient(),
+				Config: ClusterMachineApproverConfig{
+					NodeClientCert: NodeClientCert{InternalDNSFirstLabelMatching: tt.enabled},
+				},
+			}
+
+			got := approver.Authorize(context.Background(), tt.machines, req.DeepCopy(), parseCR(t, clientGood), nil)
+			if got.Authorized != tt.want.Authorized || got.Reason != tt.want.Reason || errString(got.Err) != tt.wantErr {
+				t.Errorf("Authorize() = %+v, want %+v, wantErr %s", got, tt.want, tt.wantErr)
+			}
+			var gotMachine string
+			if got.Machine != nil {
+				gotMachine = got.Machine.Name
+			}
+			if gotMachine != tt.wantMachine {
+				t.Errorf("Authorize() machine = %q, want %q", gotMachine, tt.wantMachine)
+			}
+		})
+	}
+}
+
+func TestAuthorizeCSRInvalidSignature(t *testing.T) {
+	// Flipping the last byte of the signature keeps the CSR well formed.
+	tamper := func(csrPEM string) string {
+		block, _ := pem.Decode([]byte(csrPEM))
+		der := append([]byte{}, block.Bytes...)
+		der[len(der)-1] ^= 0xff
+		return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}))
+	}
+	machines := []machinehandlerpkg.Machine{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "panda-machine",
+			CreationTimestamp: creationTimestamp(-5 * time.Minute),
+		},
+		Status: machinehandlerpkg.MachineStatus{
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalDNS, Address: "panda"},
+			},
+		},
+	}}
+
+	tests := []struct {
+		name      string
+		csr       string
+		authorize bool
+	}{
+		{
+			name:      "valid signature",
+			csr:       clientGood,
+			authorize: true,
+		},
+		{
+			name:      "signature not matching the public key",
+			csr:       tamper(clientGood),
+			authorize: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &certificatesv1.CertificateSigningRequest{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "panda-csr",
+					CreationTimestamp: creationTimestamp(-time.Minute),
+				},
+				Spec: certificatesv1.CertificateSigningRequestSpec{
+					Request: []byte(tt.csr),
+					Usages: []certificatesv1.KeyUsage{
+						certificatesv1.UsageKeyEncipherment,
+						certificatesv1.UsageDigitalSignature,
+						certificatesv1.UsageClientAuth,
+					},
+					Username: nodeBootstrapperUsername,
+					Groups:   nodeBootstrapperGroups.List(),
+				},
+			}
+			approver := &CertificateApprover{NodeClient: fake.NewFakeClient()}
+
+			before := counterValue(t, csrInvalidSignatureTotal)
+			_, authorize, err := approver.authorizeCSR(context.Background(), machines, req, parseCR(t, tt.csr), nil)
+			if authorize != tt.authorize || err != nil {
+				t.Errorf("authorizeCSR() = %v, error = %v, want %v", authorize, err, tt.authorize)
+			}
+
+			wantCount := 0.0
+			if !tt.authorize {
+				wantCount = 1
+			}
+			if count := counterValue(t, csrInvalidSignatureTotal) - before; count != wantCount {
+				t.Errorf("counted %v CSRs with an invalid signature, want %v", count, wantCount)
+			}
+		})
+	}
+}
+
+func TestServingSerialTracker(t *testing.T) {
+	certWithSerial := func(serial int64) *x509.Certificate {
+		return &x509.Certificate{SerialNumber: big.NewInt(serial)}
+	}
+
+	tracker := &servingSerialTracker{}
+	steps := []struct {
+		node    string
+		serial  int64
+		wantErr string
+	}{
+		{node: "test", serial: 1},
+		{node: "test", serial: 1},
+		{node: "test", serial: 2},
+		{node: "other", serial: 1},
+		{node: "test", serial: 1, wantErr: "serving cert serial 1 for node test has been superseded by 2"},
+		{node: "test", serial: 3},
+		{node: "test", serial: 2, wantErr: "serving cert serial 2 for node test has been superseded by 3"},
+	}
+
+	for i, step := range steps {
+		if _, err := tracker.observe(step.node, certWithSerial(step.serial)); errString(err) != step.wantErr {
+			t.Errorf("step %d: got: %v, want: %s", i, err, step.wantErr)
+		}
+	}
+}
+
+func TestObserveServingSerialPersisted(t *testing.T) {
+	certWithSerial := func(serial int64) *x509.Certificate {
+		return &x509.Certificate{SerialNumber: big.NewInt(serial)}
+	}
+	cl := fake.NewClientBuilder().WithObjects(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "test"}}).Build()
+
+	approver := &CertificateApprover{NodeClient: cl}
+	for _, serial := range []int64{1, 2} {
+		if err := approver.observeServingSerial(context.Background(), "test", certWithSerial(serial)); err != nil {
+			t.Fatalf("observeServingSerial(%d) error = %v", serial, err)
+		}
+	}
+
+	node := &corev1.Node{}
+	if err := cl.Get(context.Background(), client.ObjectKey{Name: "test"}, node); err != nil {
+		t.Fatalf("failed to get node: %v", err)
+	}
+	if want := `{"current":"2","superseded":["1"]}`; node.Annotations[servingSerialsAnnotation] != want {
+		t.Errorf("serials annotation = %q, want %q", node.Annotations[servingSerialsAnnotation], want)
+	}
+
+	// A restarted controller, or a new leader, still detects the replay.
+	restarted := &CertificateApprover{NodeClient: cl}
+	if err := restarted.observeServingSerial(context.Background(), "test", certWithSerial(1)); errString(err) != "serving cert serial 1 for node test has been superseded by 2" {
+		t.Errorf("observeServingSerial() error = %v, want replay detected", err)
+	}
+	if err := restarted.observeServingSerial(context.Background(), "test", certWithSerial(2)); err != nil {
+		t.Errorf("observeServingSerial() error = %v, want current serial accepted", err)
+	}
+}
+
+func TestAuthorizeCSRServingSerialReplay(t *testing.T) {
+	defaultPort := int32(25635)
+	defaultAddr := "localhost"
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+		},
+		Status: corev1.NodeStatus{
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalIP, Address: defaultAddr},
+			},
+			DaemonEndpoints: corev1.NodeDaemonEndpoints{
+				KubeletEndpoint: corev1.DaemonEndpoint{
+					Port: defaultPort,
+				},
+			},
+		},
+	}
+	network := &configv1.Network{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "cluster",
+		},
+	}
+	req := &certificatesv1.CertificateSigningRequest{
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageKeyEncipherment,
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageServerAuth,
+			},
+			Username: "system:node:test",
+			Groups: []string{
+				"system:authenticated",
+				"system:nodes",
+			},
+			Request: []byte(goodCSR),
+		},
+	}
+
+	server := fakeResponder(t, fmt.Sprintf("%s:%v", defaultAddr, defaultPort), serverCertGood, serverKeyGood)
+	defer server.Close()
+
+	ca := x509.NewCertPool()
+	ca.AddCert(parseCert(t, rootCertGood))
+
+	tests := []struct {
+		name      string
+		check     SerialReplayCheck
+		wantErr   string
+		authorize bool
+	}{
+		{
+			name:      "replay check disabled",
+			authorize: true,
+		},
+		{
+			name:      "replay detected but only warned about",
+			check:     SerialReplayCheck{Enabled: true},
+			authorize: true,
+		},
+		{
+			name:      "replay detected and denied",
+			check:     SerialReplayCheck{Enabled: true, Deny: true},
+			wantErr:   fmt.Sprintf("could not authorize CSR: exhausted all authorization methods: [serving cert serial %s for node test has been superseded by 1, Unable to find machine for node]", parseCert(t, serverCertGood).SerialNumber),
+			authorize: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			approver := &CertificateApprover{
+				NodeClient: fake.NewFakeClient(node, network),
+				Config: ClusterMachineApproverConfig{
+					NodeServingCert: NodeServingCert{SerialReplayCheck: tt.check},
+				},
+			}
+			// Simulate the kubelet having already renewed to a newer cert.
+			approver.servingSerials.observe("test", parseCert(t, serverCertGood))
+			approver.servingSerials.observe("test", &x509.Certificate{SerialNumber: big.NewInt(1)})
+
+			go respond(server)
+			_, authorize, err := approver.authorizeCSR(context.Background(), nil, req, parseCR(t, goodCSR), []*x509.CertPool{ca})
+			if authorize != tt.authorize || errString(err) != tt.wantErr {
+				t.Errorf("authorizeCSR() = %v, error = %v, want %v, wantErr %s", authorize, err, tt.authorize, tt.wantErr)
+			}
+		})
+	}
+}
+
+// fakeServingCertRetriever returns the same serving cert, or error, for any
+// node, and counts the retrievals.
+type fakeServingCertRetriever struct {
+	cert       *x509.Certificate
+	err        error
+	retrievals int
+}
+
+func (r *fakeServingCertRetriever) ServingCert(ctx context.Context, nodeName string, cas []*x509.CertPool) (*x509.Certificate, error) {
+	r.retrievals++
+	return r.cert, r.err
+}
+
+func TestAuthorize(t *testing.T) {
+	machine := machinehandlerpkg.Machine{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-machine"},
+		Status: machinehandlerpkg.MachineStatus{
+			NodeRef: &corev1.ObjectReference{Name: "test"},
+		},
+	}
+	req := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-csr"},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageServerAuth,
+			},
+			Username: "system:node:test",
+			Groups: []string{
+				"system:authenticated",
+				"system:nodes",
+			},
+			Request: []byte(goodCSR),
+		},
+	}
+	network := &configv1.Network{ObjectMeta: metav1.ObjectMeta{Name: "cluster"}}
+	ca := x509.NewCertPool()
+	ca.AddCert(parseCert(t, rootCertGood))
+
+	tests := []struct {
+		name        string
+		retriever   *fakeServingCertRetriever
+		machines    []machinehandlerpkg.Machine
+		want        AuthorizeResult
+		wantErr     string
+		wantMachine string
+	}{
+		{
+			name:        "renewal of the current serving cert",
+			retriever:   &fakeServingCertRetriever{cert: parseCert(t, serverCertGood)},
+			machines:    []machinehandlerpkg.Machine{machine},
+			want:        AuthorizeResult{Authorized: true, Reason: decisionReasonRenewal},
+			wantMachine: "test-machine",
+		},
+		{
+			name:      "kubelet unreachable and no machine",
+			retriever: &fakeServingCertRetriever{err: errors.New("connection refused")},
+			want:      AuthorizeResult{Reason: decisionReasonAuthorizationExhausted},
+			wantErr:   "could not authorize CSR: exhausted all authorization methods: Unable to find machine for node",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			approver := &CertificateApprover{
+				NodeClient:           fake.NewFakeClient(network),
+				Clock:                testingclock.NewFakePassiveClock(presetTimeCorrect),
+				ServingCertRetriever: tt.retriever,
+			}
+
+			got := approver.Authorize(context.Background(), tt.machines, req.DeepCopy(), parseCR(t, goodCSR), []*x509.CertPool{ca})
+			if got.Authorized != tt.want.Authorized || got.Reason != tt.want.Reason || errString(got.Err) != tt.wantErr {
+				t.Errorf("Authorize() = %+v, want %+v, wantErr %s", got, tt.want, tt.wantErr)
+			}
+			var gotMachine string
+			if got.Machine != nil {
+				gotMachine = got.Machine.Name
+			}
+			if gotMachine != tt.wantMachine {
+				t.Errorf("Authorize() machine = %q, want %q", gotMachine, tt.wantMachine)
+			}
+			if tt.retriever.retrievals != 1 {
+				t.Errorf("serving cert retrieved %d times, want 1", tt.retriever.retrievals)
+			}
+		})
+	}
+}
+
+func TestAuthorizeFlowsEnabled(t *testing.T) {
+	clientMachine := machinehandlerpkg.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "panda-machine",
+			CreationTimestamp: creationTimestamp(-5 * time.Minute),
+		},
+		Status: machinehandlerpkg.MachineStatus{
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalDNS, Address: "panda"},
+			},
+		},
+	}
+	servingMachine := machinehandlerpkg.Machine{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-machine"},
+		Status: machinehandlerpkg.MachineStatus{
+			NodeRef: &corev1.ObjectReference{Name: "test"},
+		},
+	}
+	clientReq := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "client-csr",
+			CreationTimestamp: creationTimestamp(-time.Minute),
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Request: []byte(clientGood),
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageKeyEncipherment,
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageClientAuth,
+			},
+			Username: nodeBootstrapperUsername,
+			Groups:   nodeBootstrapperGroups.List(),
+		},
+	}
+	servingReq := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "serving-csr"},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Request: []byte(goodCSR),
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageServerAuth,
+			},
+			Username: "system:node:test",
+			Groups: []string{
+				"system:authenticated",
+				"system:nodes",
+			},
+		},
+	}
+	network := &configv1.Network{ObjectMeta: metav1.ObjectMeta{Name: "cluster"}}
+	ca := x509.NewCertPool()
+	ca.AddCert(parseCert(t, rootCertGood))
+
+	tests := []struct {
+		name           string
+		config         ClusterMachineApproverConfig
+		clientEnabled  bool
+		servingEnabled bool
+	}{
+		{
+			name:           "both enabled by default",
+			clientEnabled:  true,
+			servingEnabled: true,
+		},
+		{
+			name:           "serving only",
+			config:         ClusterMachineApproverConfig{NodeClientCert: NodeClientCert{Enabled: pointer.Bool(false)}},
+			servingEnabled: true,
+		},
+		{
+			name:           "serving only with deprecated disabled",
+			config:         ClusterMachineApproverConfig{NodeClientCert: NodeClientCert{Disabled: true}},
+			servingEnabled: true,
+		},
+		{
+			name:          "client only",
+			config:        ClusterMachineApproverConfig{NodeServingCert: NodeServingCert{Enabled: pointer.Bool(false)}},
+			clientEnabled: true,
+		},
+		{
+			name: "both disabled",
+			config: ClusterMachineApproverConfig{
+				NodeClientCert:  NodeClientCert{Enabled: pointer.Bool(false)},
+				NodeServingCert: NodeServingCert{Enabled: pointer.Bool(false)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			approver := &CertificateApprover{
+				NodeClient:           fake.NewFakeClient(network),
+				Clock:                testingclock.NewFakePassiveClock(presetTimeCorrect),
+				ServingCertRetriever: &fakeServingCertRetriever{cert: parseCert(t, serverCertGood)},
+				Config:               tt.config,
+			}
+
+			recordFlowsEnabled(tt.config)
+			for kind, enabled := range map[string]bool{csrKindClient: tt.clientEnabled, csrKindServing: tt.servingEnabled} {
+				want := 0.0
+				if enabled {
+					want = 1
+				}
+				if got := gaugeValue(t, csrFlowEnabled.WithLabelValues(kind)); got != want {
+					t.Errorf("%s flow enabled gauge = %v, want %v", kind, got, want)
+				}
+			}
+
+			for _, c := range []struct {
+				kind     string
+				enabled  bool
+				machines []machinehandlerpkg.Machine
+				req      *certificatesv1.CertificateSigningRequest
+				csr      string
+			}{
+				{csrKindClient, tt.clientEnabled, []machinehandlerpkg.Machine{clientMachine}, clientReq, clientGood},
+				{csrKindServing, tt.servingEnabled, []machinehandlerpkg.Machine{servingMachine}, servingReq, goodCSR},
+			} {
+				disabledBefore := counterValue(t, csrErroredTotal.WithLabelValues(c.kind, decisionReasonFlowDisabled))
+
+				got := approver.Authorize(context.Background(), c.machines, c.req.DeepCopy(), parseCR(t, c.csr), []*x509.CertPool{ca})
+				if got.Authorized != c.enabled {
+					t.Errorf("%s CSR authorized = %v, want %v: %+v", c.kind, got.Authorized, c.enabled, got)
+				}
+				if disabled := got.Reason == decisionReasonFlowDisabled; disabled == c.enabled || (got.Err != nil) == c.enabled {
+					t.Errorf("%s CSR reason = %q, error = %v", c.kind, got.Reason, got.Err)
+				}
+
+				wantDisabled := disabledBefore
+				if !c.enabled {
+					wantDisabled++
+				}
+				if disabled := counterValue(t, csrErroredTotal.WithLabelValues(c.kind, decisionReasonFlowDisabled)); disabled != wantDisabled {
+					t.Errorf("%s flow disabled counter = %v, want %v", c.kind, disabled, wantDisabled)
+				}
+			}
+		})
+	}
+}
+
+func TestAuthorizeRenewalFallback(t *testing.T) {
+	machine := machinehandlerpkg.Machine{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-machine"},
+		Status: machinehandlerpkg.MachineStatus{
+			NodeRef: &corev1.ObjectReference{Name: "test"},
+		},
+	}
+	req := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-csr"},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageServerAuth,
+			},
+			Username: "system:node:test",
+			Groups: []string{
+				"system:authenticated",
+				"system:nodes",
+			},
+			Request: []byte(goodCSR),
+		},
+	}
+	network := &configv1.Network{ObjectMeta: metav1.ObjectMeta{Name: "cluster"}}
+	ca := x509.NewCertPool()
+	ca.AddCert(parseCert(t, rootCertGood))
+
+	tests := []struct {
+		name      string
+		retriever *fakeServingCertRetriever
+		cas       []*x509.CertPool
+		disabled  bool
+		wantCause string
+		wantEvent string
+	}{
+		{
+			name:      "renewal of the current serving cert",
+			retriever: &fakeServingCertRetriever{cert: parseCert(t, serverCertGood)},
+			cas:       []*x509.CertPool{ca},
+		},
+		{
+			name:      "no CA",
+			retriever: &fakeServingCertRetriever{cert: parseCert(t, serverCertGood)},
+			wantCause: renewalFallbackNoCA,
+		},
+		{
+			name:      "renewal disabled",
+			retriever: &fakeServingCertRetriever{cert: parseCert(t, serverCertGood)},
+			cas:       []*x509.CertPool{ca},
+			disabled:  true,
+			wantCause: renewalFallbackDisabled,
+		},
+		{
+			name:      "kubelet unreachable",
+			retriever: &fakeServingCertRetriever{err: errors.New("connection refused")},
+			cas:       []*x509.CertPool{ca},
+			wantCause: renewalFallbackDialFailed,
+			wantEvent: "Normal CSRRenewalFallback could not retrieve the current serving cert of node test, falling back to machine-api authorization",
+		},
+		{
+			name:      "serving cert not found",
+			retriever: &fakeServingCertRetriever{},
+			cas:       []*x509.CertPool{ca},
+			wantCause: renewalFallbackCertNotFound,
+			wantEvent: "Normal CSRRenewalFallback could not retrieve the current serving cert of node test, falling back to machine-api authorization",
+		},
+		{
+			name:      "serving cert not trusted",
+			retriever: &fakeServingCertRetriever{cert: parseCert(t, serverCertGood)},
+			cas:       []*x509.CertPool{x509.NewCertPool()},
+			wantCause: renewalFallbackRenewalInvalid,
+			wantEvent: "Normal CSRRenewalFallback could not renew the current serving cert of node test, falling back to machine-api authorization: x509: certificate signed by unknown authority",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			approver := &CertificateApprover{
+				NodeClient:           fake.NewFakeClient(network),
+				Clock:                testingclock.NewFakePassiveClock(presetTimeCorrect),
+				Recorder:             recorder,
+				ServingCertRetriever: tt.retriever,
+				Config: ClusterMachineApproverConfig{
+					NodeServingCert: NodeServingCert{DisableRenewalFastPath: tt.disabled},
+				},
+			}
+
+			var before float64
+			if tt.wantCause != "" {
+				before = counterValue(t, renewalFallbackTotal.WithLabelValues(tt.wantCause))
+			}
+			approver.Authorize(context.Background(), []machinehandlerpkg.Machine{machine}, req.DeepCopy(), parseCR(t, goodCSR), tt.cas)
+			if tt.wantCause != "" {
+				if after := counterValue(t, renewalFallbackTotal.WithLabelValues(tt.wantCause)); after != before+1 {
+					t.Errorf("expected renewal fallback counter to be incremented from %v, got %v", before, after)
+				}
+			}
+
+			select {
+			case event := <-recorder.Events:
+				if event != tt.wantEvent {
+					t.Errorf("got event %q, want %q", event, tt.wantEvent)
+				}
+			default:
+				if tt.wantEvent != "" {
+					t.Errorf("expected event %q", tt.wantEvent)
+				}
+			}
+		})
+	}
+}
+
+func TestAuthorizeKeyAlgorithmContinuity(t *testing.T) {
+	// The machine has none of the addresses requested, so that the CSR is only
+	// authorized by renewal.
+	machine := machinehandlerpkg.Machine{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-machine"},
+		Status: machinehandlerpkg.MachineStatus{
+			NodeRef: &corev1.ObjectReference{Name: "test"},
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalIP, Address: "192.0.2.1"},
+			},
+		},
+	}
+	network := &configv1.Network{ObjectMeta: metav1.ObjectMeta{Name: "cluster"}}
+	ca := x509.NewCertPool()
+	ca.AddCert(parseCert(t, rootCertGood))
+
+	// The current serving cert has an RSA key.
+	tests := []struct {
+		name      string
+		csr       string
+		enforce   bool
+		want      AuthorizeResult
+		wantEvent string
+	}{
+		{
+			name:    "RSA renewal",
+			csr:     goodCSR,
+			enforce: true,
+			want:    AuthorizeResult{Authorized: true, Reason: decisionReasonRenewal},
+		},
+		{
+			name: "ECDSA renewal",
+			csr:  goodCSRECDSA,
+			want: AuthorizeResult{Authorized: true, Reason: decisionReasonRenewal},
+		},
+		{
+			name:      "ECDSA renewal with key algorithm continuity",
+			csr:       goodCSRECDSA,
+			enforce:   true,
+			want:      AuthorizeResult{Reason: decisionReasonAuthorizationExhausted},
+			wantEvent: "Normal CSRRenewalFallback could not renew the current serving cert of node test, falling back to machine-api authorization: CSR public key algorithm ECDSA-P256 does not match RSA of the current cert",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &certificatesv1.CertificateSigningRequest{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-csr"},
+				Spec: certificatesv1.CertificateSigningRequestSpec{
+					Usages: []certificatesv1.KeyUsage{
+						certificatesv1.UsageDigitalSignature,
+						certificatesv1.UsageServerAuth,
+					},
+					Username: "system:node:test",
+					Groups: []string{
+						"system:authenticated",
+						"system:nodes",
+					},
+					Request: []byte(tt.csr),
+				},
+			}
+			recorder := record.NewFakeRecorder(10)
+			approver := &CertificateApprover{
+				NodeClient:           fake.NewFakeClient(network),
+				Clock:                testingclock.NewFakePassiveClock(presetTimeCorrect),
+				Recorder:             recorder,
+				ServingCertRetriever: &fakeServingCertRetriever{cert: parseCert(t, serverCertGood)},
+				Config: ClusterMachineApproverConfig{
+					NodeServingCert: NodeServingCert{EnforceKeyAlgorithmContinuity: tt.enforce},
+				},
+			}
+
+			got := approver.Authorize(context.Background(), []machinehandlerpkg.Machine{machine}, req, parseCR(t, tt.csr), []*x509.CertPool{ca})
+			if got.Authorized != tt.want.Authorized || got.Reason != tt.want.Reason {
+				t.Errorf("Authorize() = %+v, want %+v", got, tt.want)
+			}
+
+			select {
+			case event := <-recorder.Events:
+				if event != tt.wantEvent {
+					t.Errorf("got event %q, want %q", event, tt.wantEvent)
+				}
+			default:
+				if tt.wantEvent != "" {
+					t.Errorf("expected event %q", tt.wantEvent)
+				}
+			}
+		})
+	}
+}
+
+func TestAuthorizeRenewalWithKubeletConnector(t *testing.T) {
+	machine := machinehandlerpkg.Machine{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-machine"},
+		Status: machinehandlerpkg.MachineStatus{
+			NodeRef: &corev1.ObjectReference{Name: "test"},
+		},
+	}
+	req := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-csr"},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageServerAuth,
+			},
+			Username: "system:node:test",
+			Groups: []string{
+				"system:authenticated",
+				"system:nodes",
+			},
+			Request: []byte(goodCSR),
+		},
+	}
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "test"},
+		Status: corev1.NodeStatus{
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeExternalIP, Address: "localhost"},
+				{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+			},
+			DaemonEndpoints: corev1.NodeDaemonEndpoints{
+				KubeletEndpoint: corev1.DaemonEndpoint{Port: 10250},
+			},
+		},
+	}
+	network := &configv1.Network{ObjectMeta: metav1.ObjectMeta{Name: "cluster"}}
+	ca := x509.NewCertPool()
+	ca.AddCert(parseCert(t, rootCertGood))
+
+	// The kubelet presents its current serving cert on the given address only.
+	presenting := func(addr string, certs ...*x509.Certificate) KubeletConnector {
+		return func(ctx context.Context, kubelet string, tlsConfig *tls.Config) (tls.ConnectionState, error) {
+			if kubelet != addr {
+				return tls.ConnectionState{}, &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+			}
+			return tls.ConnectionState{PeerCertificates: certs}, nil
+		}
+	}
+
+	tests := []struct {
+		name          string
+		connector     KubeletConnector
+		cas           []*x509.CertPool
+		wantAuthorize bool
+		wantDialed    []string
+	}{
+		{
+			name:          "current serving cert presented",
+			connector:     presenting("10.0.0.1:10250", parseCert(t, serverCertGood)),
+			cas:           []*x509.CertPool{ca},
+			wantAuthorize: true,
+			wantDialed:    []string{"10.0.0.1:10250"},
+		},
+		{
+			name:          "kubelet reachable on external address only",
+			connector:     presenting("localhost:10250", parseCert(t, serverCertGood)),
+			cas:           []*x509.CertPool{ca},
+			wantAuthorize: true,
+			wantDialed:    []string{"10.0.0.1:10250", "localhost:10250"},
+		},
+		{
+			name:       "serving cert not trusted",
+			connector:  presenting("10.0.0.1:10250", parseCert(t, serverCertGood)),
+			cas:        []*x509.CertPool{x509.NewCertPool()},
+			wantDialed: []string{"10.0.0.1:10250", "localhost:10250"},
+		},
+		{
+			name:       "no serving cert presented",
+			connector:  presenting("10.0.0.1:10250"),
+			cas:        []*x509.CertPool{ca},
+			wantDialed: []string{"10.0.0.1:10250", "localhost:10250"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var dialed []string
+			approver := &CertificateApprover{
+				NodeClient: fake.NewFakeClient(network, node),
+				Clock:      testingclock.NewFakePassiveClock(presetTimeCorrect),
+				KubeletConnector: func(ctx context.Context, addr string, tlsConfig *tls.Config) (tls.ConnectionState, error) {
+					dialed = append(dialed, addr)
+					return tt.connector(ctx, addr, tlsConfig)
+				},
+				// Verify the serving cert presented for any name, the test
+				// cert isn't issued for the addresses of the node.
+				Config: ClusterMachineApproverConfig{
+					NodeServingCert: NodeServingCert{KubeletServerName: kubeletServerNameNone},
+				},
+			}
+
+			got := approver.Authorize(context.Background(), []machinehandlerpkg.Machine{machine}, req.DeepCopy(), parseCR(t, goodCSR), tt.cas)
+			if got.Authorized != tt.wantAuthorize {
+				t.Errorf("Authorize() = %+v, want authorized %v", got, tt.wantAuthorize)
+			}
+			if tt.wantAuthorize && got.Reason != decisionReasonRenewal {
+				t.Errorf("Authorize() reason = %s, want %s", got.Reason, decisionReasonRenewal)
+			}
+			if !reflect.DeepEqual(dialed, tt.wantDialed) {
+				t.Errorf("dialed %v, want %v", dialed, tt.wantDialed)
+			}
+		})
+	}
+}
+
+func TestAuthorizeCertValidationSkew(t *testing.T) {
+	// The machine has none of the addresses requested, so that the CSR is only
+	// authorized by renewal.
+	machine := machinehandlerpkg.Machine{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-machine"},
+		Status: machinehandlerpkg.MachineStatus{
+			NodeRef: &corev1.ObjectReference{Name: "test"},
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalIP, Address: "192.0.2.1"},
+			},
+		},
+	}
+	req := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-csr"},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageServerAuth,
+			},
+			Username: "system:node:test",
+			Groups: []string{
+				"system:authenticated",
+				"system:nodes",
+			},
+			Request: []byte(goodCSR),
+		},
+	}
+	network := &configv1.Network{ObjectMeta: metav1.ObjectMeta{Name: "cluster"}}
+	ca := x509.NewCertPool()
+	ca.AddCert(parseCert(t, rootCertGood))
+	servingCert := parseCert(t, serverCertGood)
+
+	tests := []struct {
+		name        string
+		currentTime time.Time
+		skew        time.Duration
+		want        AuthorizeResult
+	}{
+		{
+			name:        "serving cert valid",
+			currentTime: presetTimeCorrect,
+			want:        AuthorizeResult{Authorized: true, Reason: decisionReasonRenewal},
+		},
+		{
+			name:        "serving cert not yet valid",
+			currentTime: servingCert.NotBefore.Add(-5 * time.Second),
+			want:        AuthorizeResult{Reason: decisionReasonAuthorizationExhausted},
+		},
+		{
+			name:        "serving cert not yet valid within skew",
+			currentTime: servingCert.NotBefore.Add(-5 * time.Second),
+			skew:        10 * time.Second,
+			want:        AuthorizeResult{Authorized: true, Reason: decisionReasonRenewal},
+		},
+		{
+			name:        "serving cert not yet valid beyond skew",
+			currentTime: servingCert.NotBefore.Add(-5 * time.Second),
+			skew:        2 * time.Second,
+			want:        AuthorizeResult{Reason: decisionReasonAuthorizationExhausted},
+		},
+		{
+			// Only certs not yet valid are tolerated.
+			name:        "serving cert expired within skew",
+			currentTime: servingCert.NotAfter.Add(5 * time.Second),
+			skew:        10 * time.Second,
+			want:        AuthorizeResult{Reason: decisionReasonAuthorizationExhausted},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			approver := &CertificateApprover{
+				NodeClient:           fake.NewFakeClient(network),
+				Clock:                testingclock.NewFakePassiveClock(tt.currentTime),
+				ServingCertRetriever: &fakeServingCertRetriever{cert: servingCert},
+				Config: ClusterMachineApproverConfig{
+					NodeServingCert: NodeServingCert{CertValidationSkew: metav1.Duration{Duration: tt.skew}},
+				},
+			}
+
+			got := approver.Authorize(context.Background(), []machinehandlerpkg.Machine{machine}, req.DeepCopy(), parseCR(t, goodCSR), []*x509.CertPool{ca})
+			if got.Authorized != tt.want.Authorized || got.Reason != tt.want.Reason {
+				t.Errorf("Authorize() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthorizeCSRMaxSANs(t *testing.T) {
+	var dnsNames []string
+	var addresses []corev1.NodeAddress
+	for i := 0; i < 12; i++ {
+		name := fmt.Sprintf("panda-%d", i)
+		dnsNames = append(dnsNames, name)
+		addresses = append(addresses, corev1.NodeAddress{Type: corev1.NodeInternalDNS, Address: name})
+	}
+	machines := []machinehandlerpkg.Machine{{
+		ObjectMeta: metav1.ObjectMeta{Name: "panda-machine"},
+		Status: machinehandlerpkg.MachineStatus{
+			NodeRef:   &corev1.ObjectReference{Name: "panda"},
+			Addresses: addresses,
+		},
+	}}
+
+	tests := []struct {
+		name      string
+		config    NodeServingCert
+		dnsNames  []string
+		authorize bool
+	}{
+		{
+			name:      "within default limit",
+			dnsNames:  dnsNames[:10],
+			authorize: true,
+		},
+		{
+			name:      "above default limit",
+			dnsNames:  dnsNames[:11],
+			authorize: false,
+		},
+		{
+			name:      "within configured limit",
+			config:    NodeServingCert{MaxSANsPerCSR: pointer.Int(12)},
+			dnsNames:  dnsNames,
+			authorize: true,
+		},
+		{
+			name:      "above configured limit",
+			config:    NodeServingCert{MaxSANsPerCSR: pointer.Int(2)},
+			dnsNames:  dnsNames[:3],
+			authorize: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			csr := createCSR("system:node:panda", defaultOrgs, nil, tt.dnsNames)
+			req := &certificatesv1.CertificateSigningRequest{
+				ObjectMeta: metav1.ObjectMeta{Name: "panda-csr"},
+				Spec: certificatesv1.CertificateSigningRequestSpec{
+					Usages: []certificatesv1.KeyUsage{
+						certificatesv1.UsageKeyEncipherment,
+						certificatesv1.UsageDigitalSignature,
+						certificatesv1.UsageServerAuth,
+					},
+					Username: "system:node:panda",
+					Groups: []string{
+						"system:authenticated",
+						"system:nodes",
+					},
+					Request: []byte(csr),
+				},
+			}
+			approver := &CertificateApprover{
+				NodeClient: fake.NewFakeClient(),
+				Config:     ClusterMachineApproverConfig{NodeServingCert: tt.config},
+			}
+
+			_, authorize, err := approver.authorizeCSR(context.Background(), machines, req, parseCR(t, csr), nil)
+			if authorize != tt.authorize || err != nil {
+				t.Errorf("authorizeCSR() = %v, error = %v, want %v", authorize, err, tt.authorize)
+			}
+		})
+	}
+}
+
+func TestAuthorizeCSRServingOtherSANs(t *testing.T) {
+	createCSRWithSANs := func(uris []*url.URL, emailAddresses []string) string {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("failed to generate key: %v", err)
+		}
+		template := x509.CertificateRequest{
+			Subject: pkix.Name{
+				Organization: defaultOrgs,
+				CommonName:   "system:node:panda",
+			},
+			DNSNames:       []string{"panda"},
+			URIs:           uris,
+			EmailAddresses: emailAddresses,
+		}
+		csrBytes, err := x509.CreateCertificateRequest(rand.Reader, &template, key)
+		if err != nil {
+			t.Fatalf("failed to create CSR: %v", err)
+		}
+		return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrBytes}))
+	}
+	machines := []machinehandlerpkg.Machine{{
+		ObjectMeta: metav1.ObjectMeta{Name: "panda-machine"},
+		Status: machinehandlerpkg.MachineStatus{
+			NodeRef: &corev1.ObjectReference{Name: "panda"},
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalDNS, Address: "panda"},
+			},
+		},
+	}}
+
+	spiffe := NodeServingCert{SPIFFE: SPIFFE{TrustDomain: "cluster"}}
+
+	tests := []struct {
+		name      string
+		config    NodeServingCert
+		csr       string
+		authorize bool
+	}{
+		{
+			name:      "DNS SAN only",
+			csr:       createCSRWithSANs(nil, nil),
+			authorize: true,
+		},
+		{
+			name:      "URI SAN",
+			csr:       createCSRWithSANs([]*url.URL{{Scheme: "spiffe", Host: "cluster.local", Path: "/ns/default/sa/panda"}}, nil),
+			authorize: false,
+		},
+		{
+			name:      "SPIFFE ID of node",
+			config:    spiffe,
+			csr:       createCSRWithSANs([]*url.URL{{Scheme: "spiffe", Host: "cluster", Path: "/node/panda"}}, nil),
+			authorize: true,
+		},
+		{
+			name:      "SPIFFE ID of node without trust domain",
+			csr:       createCSRWithSANs([]*url.URL{{Scheme: "spiffe", Host: "cluster", Path: "/node/panda"}}, nil),
+			authorize: false,
+		},
+		{
+			name:      "SPIFFE ID of other node",
+			config:    spiffe,
+			csr:       createCSRWithSANs([]*url.URL{{Scheme: "spiffe", Host: "cluster", Path: "/node/koala"}}, nil),
+			authorize: false,
+		},
+		{
+			name:      "SPIFFE ID in other trust domain",
+			config:    spiffe,
+			csr:       createCSRWithSANs([]*url.URL{{Scheme: "spiffe", Host: "cluster.local", Path: "/node/panda"}}, nil),
+			authorize: false,
+		},
+		{
+			name:      "email SAN",
+			csr:       createCSRWithSANs(nil, []string{"panda@example.com"}),
+			authorize: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &certificatesv1.CertificateSigningRequest{
+				ObjectMeta: metav1.ObjectMeta{Name: "panda-csr"},
+				Spec: certificatesv1.CertificateSigningRequestSpec{
+					Usages: []certificatesv1.KeyUsage{
+						certificatesv1.UsageDigitalSignature,
+						certificatesv1.UsageServerAuth,
+					},
+					Username: "system:node:panda",
+					Groups: []string{
+						"system:authenticated",
+						"system:nodes",
+					},
+					Request: []byte(tt.csr),
+				},
+			}
+			approver := &CertificateApprover{
+				NodeClient: fake.NewFakeClient(),
+				Config:     ClusterMachineApproverConfig{NodeServingCert: tt.config},
+			}
+
+			_, authorize, err := approver.authorizeCSR(context.Background(), machines, req, parseCR(t, tt.csr), nil)
+			if authorize != tt.authorize || err != nil {
+				t.Errorf("authorizeCSR() = %v, error = %v, want %v", authorize, err, tt.authorize)
+			}
+		})
+	}
+}
+
+func TestAuthorizeCSRServingForbiddenIPs(t *testing.T) {
+	tests := []struct {
+		name    string
+		ip      string
+		wantErr string
+	}{
+		{
+			name: "routable IP",
+			ip:   "10.0.0.1",
+		},
+		{
+			name:    "IPv4 loopback",
+			ip:      "127.0.0.1",
+			wantErr: "loopback IP SAN is not allowed: 127.0.0.1",
+		},
+		{
+			name:    "IPv6 loopback",
+			ip:      "::1",
+			wantErr: "loopback IP SAN is not allowed: ::1",
+		},
+		{
+			name:    "IPv4 unspecified",
+			ip:      "0.0.0.0",
+			wantErr: "unspecified IP SAN is not allowed: 0.0.0.0",
+		},
+		{
+			name:    "IPv6 unspecified",
+			ip:      "::",
+			wantErr: "unspecified IP SAN is not allowed: ::",
+		},
+		{
+			name:    "IPv4 link-local",
+			ip:      "169.254.169.254",
+			wantErr: "link-local IP SAN is not allowed: 169.254.169.254",
+		},
+		{
+			name:    "IPv6 link-local",
+			ip:      "fe80::1",
+			wantErr: "link-local IP SAN is not allowed: fe80::1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The machine reports the address, the CSR is still rejected.
+			machines := []machinehandlerpkg.Machine{{
+				ObjectMeta: metav1.ObjectMeta{Name: "panda-machine"},
+				Status: machinehandlerpkg.MachineStatus{
+					NodeRef: &corev1.ObjectReference{Name: "panda"},
+					Addresses: []corev1.NodeAddress{
+						{Type: corev1.NodeInternalDNS, Address: "panda"},
+						{Type: corev1.NodeInternalIP, Address: tt.ip},
+					},
+				},
+			}}
+			csr := createCSR("system:node:panda", defaultOrgs, []net.IP{net.ParseIP(tt.ip)}, []string{"panda"})
+			req := &certificatesv1.CertificateSigningRequest{
+				ObjectMeta: metav1.ObjectMeta{Name: "panda-csr"},
+				Spec: certificatesv1.CertificateSigningRequestSpec{
+					Usages: []certificatesv1.KeyUsage{
+						certificatesv1.UsageDigitalSignature,
+						certificatesv1.UsageServerAuth,
+					},
+					Username: "system:node:panda",
+					Groups: []string{
+						"system:authenticated",
+						"system:nodes",
+					},
+					Request: []byte(csr),
+				},
+			}
+
+			if _, err := validateCSRContents(ClusterMachineApproverConfig{}, req, parseCR(t, csr)); errString(err) != tt.wantErr {
+				t.Errorf("validateCSRContents() error = %v, wantErr %s", err, tt.wantErr)
+			}
+
+			approver := &CertificateApprover{NodeClient: fake.NewFakeClient()}
+			_, authorize, err := approver.authorizeCSR(context.Background(), machines, req, parseCR(t, csr), nil)
+			if want := tt.wantErr == ""; authorize != want || err != nil {
+				t.Errorf("authorizeCSR() = %v, error = %v, want %v", authorize, err, want)
+			}
+		})
+	}
+}
+
+func TestAuthorizeCSRDisableRenewalFastPath(t *testing.T) {
+	// The listener counts the connections to the kubelet, and closes them
+	// without completing the TLS handshake.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	var dials int32
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&dials, 1)
+			conn.Close()
+		}
+	}()
+
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "panda"},
+		Status: corev1.NodeStatus{
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalIP, Address: "127.0.0.1"},
+			},
+			DaemonEndpoints: corev1.NodeDaemonEndpoints{
+				KubeletEndpoint: corev1.DaemonEndpoint{
+					Port: int32(listener.Addr().(*net.TCPAddr).Port),
+				},
+			},
+		},
+	}
+	machines := []machinehandlerpkg.Machine{{
+		ObjectMeta: metav1.ObjectMeta{Name: "panda-machine"},
+		Status: machinehandlerpkg.MachineStatus{
+			NodeRef: &corev1.ObjectReference{Name: "panda"},
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalDNS, Address: "panda"},
+				{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+			},
+		},
+	}}
+	csr := createCSR("system:node:panda", defaultOrgs, []net.IP{net.ParseIP("10.0.0.1")}, []string{"panda"})
+	req := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "panda-csr"},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageServerAuth,
+			},
+			Username: "system:node:panda",
+			Groups: []string{
+				"system:authenticated",
+				"system:nodes",
+			},
+			Request: []byte(csr),
+		},
+	}
+	certPool := x509.NewCertPool()
+	certPool.AddCert(parseCert(t, rootCertGood))
+
+	tests := []struct {
+		name      string
+		disabled  bool
+		wantDials int32
+	}{
+		{
+			name:      "fast path enabled",
+			wantDials: 1,
+		},
+		{
+			name:      "fast path disabled",
+			disabled:  true,
+			wantDials: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&dials, 0)
+			approver := &CertificateApprover{
+				NodeClient: fake.NewFakeClient(node),
+				Config: ClusterMachineApproverConfig{
+					NodeServingCert: NodeServingCert{DisableRenewalFastPath: tt.disabled},
+				},
+			}
+
+			// Either way, the CSR is authorized through the machine.
+			_, authorize, err := approver.authorizeCSR(context.Background(), machines, req, parseCR(t, csr), []*x509.CertPool{certPool})
+			if !authorize || err != nil {
+				t.Errorf("authorizeCSR() = %v, error = %v, want true", authorize, err)
+			}
+			if got := atomic.LoadInt32(&dials); got != tt.wantDials {
+				t.Errorf("kubelet dialed %d times, want %d", got, tt.wantDials)
+			}
+		})
+	}
+}
+
+func TestValidateCSRContentsRequiredOrganizations(t *testing.T) {
+	tests := []struct {
+		name          string
+		organizations []string
+		required      []string
+		wantErr       string
+	}{
+		{
+			name:          "no required organization",
+			organizations: defaultOrgs,
+		},
+		{
+			name:          "required organization present",
+			organizations: []string{"system:nodes", "example.com:kubelets"},
+			required:      []string{"example.com:kubelets"},
+		},
+		{
+			name:          "required organization missing",
+			organizations: []string{"system:nodes", "example.com:other"},
+			required:      []string{"example.com:kubelets"},
+			wantErr:       "Organization [system:nodes example.com:other] doesn't include required example.com:kubelets",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			csr := createCSR("system:node:panda", tt.organizations, []net.IP{net.ParseIP("10.0.0.1")}, []string{"panda"})
+			req := &certificatesv1.CertificateSigningRequest{
+				ObjectMeta: metav1.ObjectMeta{Name: "panda-csr"},
+				Spec: certificatesv1.CertificateSigningRequestSpec{
+					Usages: []certificatesv1.KeyUsage{
+						certificatesv1.UsageDigitalSignature,
+						certificatesv1.UsageServerAuth,
+					},
+					Username: "system:node:panda",
+					Groups: []string{
+						"system:authenticated",
+						"system:nodes",
+					},
+					Request: []byte(csr),
+				},
+			}
+
+			nodeAsking, err := validateCSRContents(ClusterMachineApproverConfig{NodeServingCert: NodeServingCert{RequiredOrganizations: tt.required}}, req, parseCR(t, csr))
+			if errString(err) != tt.wantErr {
+				t.Errorf("validateCSRContents() error = %v, wantErr %s", err, tt.wantErr)
+			}
+			if err == nil && nodeAsking != "panda" {
+				t.Errorf("validateCSRContents() = %s, want panda", nodeAsking)
+			}
+		})
+	}
+}
+
+func TestValidateCSRContentsStrictUsages(t *testing.T) {
+	tests := []struct {
+		name    string
+		strict  bool
+		usages  []certificatesv1.KeyUsage
+		wantErr string
+	}{
+		{
+			name:   "required usages",
+			strict: true,
+			usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageServerAuth,
+			},
+		},
+		{
+			name:   "legacy usages",
+			strict: true,
+			usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageKeyEncipherment,
+				certificatesv1.UsageServerAuth,
+			},
+		},
+		{
+			name: "client auth sneaked in",
+			usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageServerAuth,
+				certificatesv1.UsageClientAuth,
+			},
+		},
+		{
+			name:   "client auth sneaked in with strict usages",
+			strict: true,
+			usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageServerAuth,
+				certificatesv1.UsageClientAuth,
+			},
+			wantErr: `unexpected usages ["client auth"]`,
+		},
+		{
+			name:   "client auth substituted for server auth with strict usages",
+			strict: true,
+			usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageKeyEncipherment,
+				certificatesv1.UsageClientAuth,
+			},
+			wantErr: `map["client auth":{} "digital signature":{} "key encipherment":{}] is missing usages`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			csr := createCSR("system:node:panda", defaultOrgs, []net.IP{net.ParseIP("10.0.0.1")}, []string{"panda"})
+			req := &certificatesv1.CertificateSigningRequest{
+				ObjectMeta: metav1.ObjectMeta{Name: "panda-csr"},
+				Spec: certificatesv1.CertificateSigningRequestSpec{
+					Usages:   tt.usages,
+					Username: "system:node:panda",
+					Groups: []string{
+						"system:authenticated",
+						"system:nodes",
+					},
+					Request: []byte(csr),
+				},
+			}
+
+			if _, err := validateCSRContents(ClusterMachineApproverConfig{NodeServingCert: NodeServingCert{StrictUsages: tt.strict}}, req, parseCR(t, csr)); errString(err) != tt.wantErr {
+				t.Errorf("validateCSRContents() error = %v, wantErr %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateCSRContentsRequiredSANTypes(t *testing.T) {
+	tests := []struct {
+		name     string
+		required []string
+		ips      []net.IP
+		dnsNames []string
+		wantErr  string
+	}{
+		{
+			name: "IP only, nothing required",
+			ips:  []net.IP{net.ParseIP("10.0.0.1")},
+		},
+		{
+			name:     "IP only, DNS required",
+			required: []string{requiredSANTypeDNS},
+			ips:      []net.IP{net.ParseIP("10.0.0.1")},
+			wantErr:  "CSR requests no DNS name SAN, required by nodeServingCert.requiredSANTypes",
+		},
+		{
+			name:     "DNS only, IP required",
+			required: []string{requiredSANTypeIP},
+			dnsNames: []string{"panda"},
+			wantErr:  "CSR requests no IP address SAN, required by nodeServingCert.requiredSANTypes",
+		},
+		{
+			name:     "DNS only, DNS required",
+			required: []string{requiredSANTypeDNS},
+			dnsNames: []string{"panda"},
+		},
+		{
+			name:     "both, both required",
+			required: []string{requiredSANTypeDNS, requiredSANTypeIP},
+			ips:      []net.IP{net.ParseIP("10.0.0.1")},
+			dnsNames: []string{"panda"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			csr := createCSR("system:node:panda", defaultOrgs, tt.ips, tt.dnsNames)
+			req := &certificatesv1.CertificateSigningRequest{
+				ObjectMeta: metav1.ObjectMeta{Name: "panda-csr"},
+				Spec: certificatesv1.CertificateSigningRequestSpec{
+					Usages: []certificatesv1.KeyUsage{
+						certificatesv1.UsageDigitalSignature,
+						certificatesv1.UsageServerAuth,
+					},
+					Username: "system:node:panda",
+					Groups: []string{
+						"system:authenticated",
+						"system:nodes",
+					},
+					Request: []byte(csr),
+				},
+			}
+
+			if _, err := validateCSRContents(ClusterMachineApproverConfig{NodeServingCert: NodeServingCert{RequiredSANTypes: tt.required}}, req, parseCR(t, csr)); errString(err) != tt.wantErr {
+				t.Errorf("validateCSRContents() error = %v, wantErr %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthorizeCSRNodeUser(t *testing.T) {
+	machines := []machinehandlerpkg.Machine{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "panda-machine",
+			CreationTimestamp: metav1.NewTime(baseTime),
+		},
+		Status: machinehandlerpkg.MachineStatus{
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalDNS, Address: "panda"},
+				{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+			},
+		},
+	}}
+	servingMachines := []machinehandlerpkg.Machine{machines[0]}
+	servingMachines[0].Status.NodeRef = &corev1.ObjectReference{Name: "panda"}
+
+	clientReq := func(commonName string) (*certificatesv1.CertificateSigningRequest, string) {
+		csr := createCSR(commonName, defaultOrgs, nil, nil)
+		return &certificatesv1.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "panda-client-csr",
+				CreationTimestamp: metav1.NewTime(baseTime.Add(time.Minute)),
+			},
+			Spec: certificatesv1.CertificateSigningRequestSpec{
+				Usages: []certificatesv1.KeyUsage{
+					certificatesv1.UsageDigitalSignature,
+					certificatesv1.UsageClientAuth,
+				},
+				Username: nodeBootstrapperUsername,
+				Groups:   nodeBootstrapperGroups.List(),
+				Request:  []byte(csr),
+			},
+		}, csr
+	}
+	servingReq := func(nodeUser string) (*certificatesv1.CertificateSigningRequest, string) {
+		csr := createCSR(nodeUser+":panda", defaultOrgs, []net.IP{net.ParseIP("10.0.0.1")}, []string{"panda"})
+		return &certificatesv1.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{Name: "panda-serving-csr"},
+			Spec: certificatesv1.CertificateSigningRequestSpec{
+				Usages: []certificatesv1.KeyUsage{
+					certificatesv1.UsageDigitalSignature,
+					certificatesv1.UsageServerAuth,
+				},
+				Username: nodeUser + ":panda",
+				Groups: []string{
+					"system:authenticated",
+					"system:nodes",
+				},
+				Request: []byte(csr),
+			},
+		}, csr
+	}
+
+	tests := []struct {
+		name       string
+		nodeUser   *string
+		csrUser    string
+		wantClient AuthorizeResult
+		wantServer AuthorizeResult
+	}{
+		{
+			name:       "default node user",
+			csrUser:    "system:node",
+			wantClient: AuthorizeResult{Authorized: true, Reason: decisionReasonMachine},
+			wantServer: AuthorizeResult{Authorized: true, Reason: decisionReasonMachine},
+		},
+		{
+			name:       "custom node user",
+			nodeUser:   pointer.String("example:node"),
+			csrUser:    "example:node",
+			wantClient: AuthorizeResult{Authorized: true, Reason: decisionReasonMachine},
+			wantServer: AuthorizeResult{Authorized: true, Reason: decisionReasonMachine},
+		},
+		{
+			// The CSRs are not node CSRs, they may be handled by another
+			// approver.
+			name:     "default node user with custom node user configured",
+			nodeUser: pointer.String("example:node"),
+			csrUser:  "system:node",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			approver := &CertificateApprover{
+				NodeClient: fake.NewFakeClient(),
+				Clock:      testingclock.NewFakePassiveClock(baseTime.Add(time.Minute)),
+				Config:     ClusterMachineApproverConfig{NodeUser: tt.nodeUser},
+			}
+
+			req, csr := clientReq(tt.csrUser + ":panda")
+			if got := approver.Authorize(context.Background(), machines, req, parseCR(t, csr), nil); got.Authorized != tt.wantClient.Authorized || got.Reason != tt.wantClient.Reason || got.Err != nil {
+				t.Errorf("Authorize() client CSR = %+v, want %+v", got, tt.wantClient)
+			}
+
+			req, csr = servingReq(tt.csrUser)
+			if got := approver.Authorize(context.Background(), servingMachines, req, parseCR(t, csr), nil); got.Authorized != tt.wantServer.Authorized || got.Reason != tt.wantServer.Reason || got.Err != nil {
+				t.Errorf("Authorize() serving CSR = %+v, want %+v", got, tt.wantServer)
+			}
+		})
+	}
+}
+
+func TestAuthorizeCSRNodeNameAllowPatterns(t *testing.T) {
+	machines := []machinehandlerpkg.Machine{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "panda-machine",
+			CreationTimestamp: metav1.NewTime(baseTime),
+		},
+		Status: machinehandlerpkg.MachineStatus{
+			NodeRef: &corev1.ObjectReference{Name: "panda"},
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalDNS, Address: "panda"},
+				{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+			},
+		},
+	}}
+	clientMachines := []machinehandlerpkg.Machine{machines[0]}
+	clientMachines[0].Status.NodeRef = nil
+
+	clientCSR := createCSR("system:node:panda", defaultOrgs, nil, nil)
+	clientReq := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "panda-client-csr",
+			CreationTimestamp: metav1.NewTime(baseTime.Add(time.Minute)),
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageClientAuth,
+			},
+			Username: nodeBootstrapperUsername,
+			Groups:   nodeBootstrapperGroups.List(),
+			Request:  []byte(clientCSR),
+		},
+	}
+	servingCSR := createCSR("system:node:panda", defaultOrgs, []net.IP{net.ParseIP("10.0.0.1")}, []string{"panda"})
+	servingReq := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "panda-serving-csr"},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageServerAuth,
+			},
+			Username: "system:node:panda",
+			Groups: []string{
+				"system:authenticated",
+				"system:nodes",
+			},
+			Request: []byte(servingCSR),
+		},
+	}
+
+	tests := []struct {
+		name     string
+		patterns []string
+		want     AuthorizeResult
+	}{
+		{
+			name: "no patterns",
+			want: AuthorizeResult{Authorized: true, Reason: decisionReasonMachine},
+		},
+		{
+			name:     "matching pattern",
+			patterns: []string{"worker-*", "pan*"},
+			want:     AuthorizeResult{Authorized: true, Reason: decisionReasonMachine},
+		},
+		{
+			name:     "exact pattern",
+			patterns: []string{"panda"},
+			want:     AuthorizeResult{Authorized: true, Reason: decisionReasonMachine},
+		},
+		{
+			name:     "no matching pattern",
+			patterns: []string{"worker-*", "panda-?"},
+			want:     AuthorizeResult{Reason: decisionReasonNodeNameNotAllowed},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			approver := &CertificateApprover{
+				NodeClient: fake.NewFakeClient(),
+				Clock:      testingclock.NewFakePassiveClock(baseTime.Add(time.Minute)),
+				Config:     ClusterMachineApproverConfig{NodeNameAllowPatterns: tt.patterns},
+			}
+
+			if got := approver.Authorize(context.Background(), clientMachines, clientReq, parseCR(t, clientCSR), nil); got.Authorized != tt.want.Authorized || got.Reason != tt.want.Reason || got.Err != nil {
+				t.Errorf("Authorize() client CSR = %+v, want %+v", got, tt.want)
+			}
+			if got := approver.Authorize(context.Background(), machines, servingReq, parseCR(t, servingCSR), nil); got.Authorized != tt.want.Authorized || got.Reason != tt.want.Reason || got.Err != nil {
+				t.Errorf("Authorize() serving CSR = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthorizeCSRRejectTerminatingMachine(t *testing.T) {
+	machine := func(deletionTimestamp *metav1.Time) []machinehandlerpkg.Machine {
+		return []machinehandlerpkg.Machine{{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "panda-machine",
+				DeletionTimestamp: deletionTimestamp,
+			},
+			Status: machinehandlerpkg.MachineStatus{
+				NodeRef: &corev1.ObjectReference{Name: "panda"},
+				Addresses: []corev1.NodeAddress{
+					{Type: corev1.NodeInternalDNS, Address: "panda"},
+					{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+				},
+			},
+		}}
+	}
+	csr := createCSR("system:node:panda", defaultOrgs, []net.IP{net.ParseIP("10.0.0.1")}, []string{"panda"})
+	req := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "panda-serving-csr"},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageServerAuth,
+			},
+			Username: "system:node:panda",
+			Groups: []string{
+				"system:authenticated",
+				"system:nodes",
+			},
+			Request: []byte(csr),
+		},
+	}
+	deleted := metav1.NewTime(baseTime)
+
+	tests := []struct {
+		name      string
+		machines  []machinehandlerpkg.Machine
+		reject    bool
+		want      AuthorizeResult
+		wantEvent string
+	}{
+		{
+			name:     "machine running",
+			machines: machine(nil),
+			reject:   true,
+			want:     AuthorizeResult{Authorized: true, Reason: decisionReasonMachine},
+		},
+		{
+			name:     "machine terminating",
+			machines: machine(&deleted),
+			want:     AuthorizeResult{Authorized: true, Reason: decisionReasonMachine},
+		},
+		{
+			name:      "machine terminating rejected",
+			machines:  machine(&deleted),
+			reject:    true,
+			want:      AuthorizeResult{Reason: decisionReasonMachineTerminating},
+			wantEvent: "Warning CSRDenied machine panda-machine of node panda is being deleted",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			approver := &CertificateApprover{
+				NodeClient: fake.NewFakeClient(),
+				Recorder:   recorder,
+				Config: ClusterMachineApproverConfig{
+					NodeServingCert: NodeServingCert{RejectTerminatingMachineCSRs: tt.reject},
+				},
+			}
+
+			if got := approver.Authorize(context.Background(), tt.machines, req, parseCR(t, csr), nil); got.Authorized != tt.want.Authorized || got.Reason != tt.want.Reason || got.Err != nil {
+				t.Errorf("Authorize() = %+v, want %+v", got, tt.want)
+			}
+
+			select {
+			case event := <-recorder.Events:
+				if event != tt.wantEvent {
+					t.Errorf("got event %q, want %q", event, tt.wantEvent)
+				}
+			default:
+				if tt.wantEvent != "" {
+					t.Errorf("expected event %q", tt.wantEvent)
+				}
+			}
+		})
+	}
+}
+
+func TestAuthorizeServingCSRMachineWithoutAddresses(t *testing.T) {
+	addresses := []corev1.NodeAddress{
+		{Type: corev1.NodeInternalDNS, Address: "panda"},
+		{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+	}
+	machine := func(addresses []corev1.NodeAddress) []machinehandlerpkg.Machine {
+		return []machinehandlerpkg.Machine{{
+			ObjectMeta: metav1.ObjectMeta{Name: "panda-machine"},
+			Status: machinehandlerpkg.MachineStatus{
+				NodeRef:   &corev1.ObjectReference{Name: "panda"},
+				Addresses: addresses,
+			},
+		}}
+	}
+	csr := createCSR("system:node:panda", defaultOrgs, []net.IP{net.ParseIP("10.0.0.1")}, []string{"panda"})
+	req := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "panda-serving-csr"},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageServerAuth,
+			},
+			Username: "system:node:panda",
+			Groups: []string{
+				"system:authenticated",
+				"system:nodes",
+			},
+			Request: []byte(csr),
+		},
+	}
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "panda"},
+		Status:     corev1.NodeStatus{Addresses: addresses},
+	}
+
+	tests := []struct {
+		name              string
+		machines          []machinehandlerpkg.Machine
+		sanAddressSources string
+		wantReason        string
+		wantErr           string
+	}{
+		{
+			name:       "machine with addresses",
+			machines:   machine(addresses),
+			wantReason: decisionReasonMachine,
+		},
+		{
+			name:       "machine without addresses",
+			machines:   machine(nil),
+			wantReason: decisionReasonNoMachineAddresses,
+			wantErr:    "machine has no addresses yet: machine panda-machine of node panda",
+		},
+		{
+			name:              "machine without addresses matched against node addresses",
+			machines:          machine(nil),
+			sanAddressSources: sanAddressSourceNodeOnly,
+			wantReason:        decisionReasonMachine,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			approver := &CertificateApprover{
+				NodeClient: fake.NewClientBuilder().WithObjects(node).Build(),
+				Config: ClusterMachineApproverConfig{
+					NodeServingCert: NodeServingCert{SANAddressSources: tt.sanAddressSources},
+				},
+			}
+
+			got := approver.Authorize(context.Background(), tt.machines, req, parseCR(t, csr), nil)
+			if got.Authorized != (tt.wantErr == "") || got.Reason != tt.wantReason || errString(got.Err) != tt.wantErr {
+				t.Errorf("Authorize() = %+v, want reason %s and error %q", got, tt.wantReason, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthorizeServingCSRInstanceID(t *testing.T) {
+	const annotation = "example.com/instance-id"
+	machine := func(instanceID string, nodeRef *corev1.ObjectReference) []machinehandlerpkg.Machine {
+		return []machinehandlerpkg.Machine{{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "panda-machine",
+				Annotations: map[string]string{annotation: instanceID},
+			},
+			Status: machinehandlerpkg.MachineStatus{
+				NodeRef: nodeRef,
+				Addresses: []corev1.NodeAddress{
+					{Type: corev1.NodeInternalDNS, Address: "panda"},
+					{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+				},
+			},
+		}}
+	}
+	csr := createCSR("system:node:panda", defaultOrgs, []net.IP{net.ParseIP("10.0.0.1")}, []string{"panda"})
+	req := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "panda-serving-csr"},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageServerAuth,
+			},
+			Username: "system:node:panda",
+			Groups: []string{
+				"system:authenticated",
+				"system:nodes",
+			},
+			Request: []byte(csr),
+		},
+	}
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "panda",
+			Annotations: map[string]string{annotation: "i-0123"},
+		},
+	}
+	network := &configv1.Network{ObjectMeta: metav1.ObjectMeta{Name: "cluster"}}
+
+	tests := []struct {
+		name       string
+		machines   []machinehandlerpkg.Machine
+		matching   bool
+		wantReason string
+	}{
+		{
+			name:       "matching instance ID",
+			machines:   machine("i-0123", nil),
+			matching:   true,
+			wantReason: decisionReasonMachine,
+		},
+		{
+			name:       "matching instance ID without instance ID matching",
+			machines:   machine("i-0123", nil),
+			wantReason: decisionReasonAuthorizationExhausted,
+		},
+		{
+			name:       "other instance ID",
+			machines:   machine("i-4567", nil),
+			matching:   true,
+			wantReason: decisionReasonAuthorizationExhausted,
+		},
+		{
+			name:       "machine of another node",
+			machines:   machine("i-0123", &corev1.ObjectReference{Name: "bamboo"}),
+			matching:   true,
+			wantReason: decisionReasonAuthorizationExhausted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			approver := &CertificateApprover{
+				NodeClient: fake.NewClientBuilder().WithObjects(node, network).Build(),
+			}
+			if tt.matching {
+				approver.Config.NodeServingCert = NodeServingCert{
+					NodeInstanceIDAnnotation:    annotation,
+					MachineInstanceIDAnnotation: annotation,
+				}
+			}
+
+			got := approver.Authorize(context.Background(), tt.machines, req, parseCR(t, csr), nil)
+			if got.Authorized != (tt.wantReason == decisionReasonMachine) || got.Reason != tt.wantReason {
+				t.Errorf("Authorize() = %+v, want reason %s", got, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestAuthorizeServingCSRProviderID(t *testing.T) {
+	machine := func(name, providerID string, nodeRef *corev1.ObjectReference) machinehandlerpkg.Machine {
+		return machinehandlerpkg.Machine{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       machinehandlerpkg.MachineSpec{ProviderID: pointer.String(providerID)},
+			Status: machinehandlerpkg.MachineStatus{
+				NodeRef: nodeRef,
+				Addresses: []corev1.NodeAddress{
+					{Type: corev1.NodeInternalDNS, Address: "panda"},
+					{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+				},
+			},
+		}
+	}
+	csr := createCSR("system:node:panda", defaultOrgs, []net.IP{net.ParseIP("10.0.0.1")}, []string{"panda"})
+	req := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "panda-serving-csr"},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageServerAuth,
+			},
+			Username: "system:node:panda",
+			Groups: []string{
+				"system:authenticated",
+				"system:nodes",
+			},
+			Request: []byte(csr),
+		},
+	}
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "panda"},
+		Spec:       corev1.NodeSpec{ProviderID: "baremetal:///panda-host"},
+	}
+	network := &configv1.Network{ObjectMeta: metav1.ObjectMeta{Name: "cluster"}}
+
+	tests := []struct {
+		name       string
+		machines   []machinehandlerpkg.Machine
+		matching   bool
+		wantReason string
+	}{
+		{
+			name:       "matching provider ID",
+			machines:   []machinehandlerpkg.Machine{machine("panda-machine", "baremetal:///panda-host", nil)},
+			matching:   true,
+			wantReason: decisionReasonMachine,
+		},
+		{
+			name:       "matching provider ID without provider ID matching",
+			machines:   []machinehandlerpkg.Machine{machine("panda-machine", "baremetal:///panda-host", nil)},
+			wantReason: decisionReasonAuthorizationExhausted,
+		},
+		{
+			name:       "other provider ID",
+			machines:   []machinehandlerpkg.Machine{machine("panda-machine", "baremetal:///bamboo-host", nil)},
+			matching:   true,
+			wantReason: decisionReasonAuthorizationExhausted,
+		},
+		{
+			name:       "machine of another node",
+			machines:   []machinehandlerpkg.Machine{machine("panda-machine", "baremetal:///panda-host", &corev1.ObjectReference{Name: "bamboo"})},
+			matching:   true,
+			wantReason: decisionReasonAuthorizationExhausted,
+		},
+		{
+			name: "several machines with the provider ID",
+			machines: []machinehandlerpkg.Machine{
+				machine("panda-machine", "baremetal:///panda-host", nil),
+				machine("panda-machine-copy", "baremetal:///panda-host", nil),
+			},
+			matching:   true,
+			wantReason: decisionReasonAmbiguousMachine,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			approver := &CertificateApprover{
+				NodeClient: fake.NewClientBuilder().WithObjects(node, network).Build(),
+			}
+			approver.Config.NodeServingCert.ProviderIDMatching = tt.matching
+
+			got := approver.Authorize(context.Background(), tt.machines, req, parseCR(t, csr), nil)
+			if got.Authorized != (tt.wantReason == decisionReasonMachine) || got.Reason != tt.wantReason {
+				t.Errorf("Authorize() = %+v, want reason %s", got, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestAuthorizeCSRAllowedMachinePhases(t *testing.T) {
+	clientMachine := func(phase string) []machinehandlerpkg.Machine {
+		return []machinehandlerpkg.Machine{{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "panda-machine",
+				CreationTimestamp: creationTimestamp(-5 * time.Minute),
+			},
+			Status: machinehandlerpkg.MachineStatus{
+				Addresses: []corev1.NodeAddress{
+					{Type: corev1.NodeInternalDNS, Address: "panda"},
+				},
+				Phase: phase,
+			},
+		}}
+	}
+	servingMachine := func(phase string) []machinehandlerpkg.Machine {
+		machines := clientMachine(phase)
+		machines[0].Status.NodeRef = &corev1.ObjectReference{Name: "panda"}
+		machines[0].Status.Addresses = append(machines[0].Status.Addresses, corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "10.0.0.1"})
+		return machines
+	}
+	servingCSR := createCSR("system:node:panda", defaultOrgs, []net.IP{net.ParseIP("10.0.0.1")}, []string{"panda"})
+	clientReq := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "panda-client-csr",
+			CreationTimestamp: creationTimestamp(-time.Minute),
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageKeyEncipherment,
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageClientAuth,
+			},
+			Username: nodeBootstrapperUsername,
+			Groups:   nodeBootstrapperGroups.List(),
+			Request:  []byte(clientGood),
+		},
+	}
+	servingReq := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "panda-serving-csr"},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageServerAuth,
+			},
+			Username: "system:node:panda",
+			Groups: []string{
+				"system:authenticated",
+				"system:nodes",
+			},
+			Request: []byte(servingCSR),
+		},
+	}
+
+	tests := []struct {
+		name      string
+		allowed   []string
+		machines  []machinehandlerpkg.Machine
+		req       *certificatesv1.CertificateSigningRequest
+		csr       string
+		want      AuthorizeResult
+		wantErr   string
+		wantEvent string
+	}{
+		{
+			name:     "client CSR of failed machine with any phase allowed",
+			machines: clientMachine("Failed"),
+			req:      clientReq,
+			csr:      clientGood,
+			want:     AuthorizeResult{Authorized: true, Reason: decisionReasonMachine},
+		},
+		{
+			name:     "client CSR of provisioned machine",
+			allowed:  []string{"Provisioned", "Running"},
+			machines: clientMachine("Provisioned"),
+			req:      clientReq,
+			csr:      clientGood,
+			want:     AuthorizeResult{Authorized: true, Reason: decisionReasonMachine},
+		},
+		{
+			name:      "client CSR of failed machine",
+			allowed:   []string{"Provisioned", "Running"},
+			machines:  clientMachine("Failed"),
+			req:       clientReq,
+			csr:       clientGood,
+			want:      AuthorizeResult{Reason: decisionReasonMachinePhase},
+			wantErr:   `machine panda-machine is in phase "Failed", not one of the allowed phases [Provisioned Running]`,
+			wantEvent: `Warning CSRDenied machine panda-machine is in phase "Failed", not one of the allowed phases [Provisioned Running]`,
+		},
+		{
+			name:     "serving CSR of running machine",
+			allowed:  []string{"Provisioned", "Running"},
+			machines: servingMachine("Running"),
+			req:      servingReq,
+			csr:      servingCSR,
+			want:     AuthorizeResult{Authorized: true, Reason: decisionReasonMachine},
+		},
+		{
+			name:      "serving CSR of failed machine",
+			allowed:   []string{"Provisioned", "Running"},
+			machines:  servingMachine("Failed"),
+			req:       servingReq,
+			csr:       servingCSR,
+			want:      AuthorizeResult{Reason: decisionReasonMachinePhase},
+			wantErr:   `machine panda-machine is in phase "Failed", not one of the allowed phases [Provisioned Running]`,
+			wantEvent: `Warning CSRDenied machine panda-machine is in phase "Failed", not one of the allowed phases [Provisioned Running]`,
+		},
+		{
+			name:      "serving CSR of machine without phase",
+			allowed:   []string{"Running"},
+			machines:  servingMachine(""),
+			req:       servingReq,
+			csr:       servingCSR,
+			want:      AuthorizeResult{Reason: decisionReasonMachinePhase},
+			wantErr:   `machine panda-machine is in phase "", not one of the allowed phases [Running]`,
+			wantEvent: `Warning CSRDenied machine panda-machine is in phase "", not one of the allowed phases [Running]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			approver := &CertificateApprover{
+				NodeClient: fake.NewFakeClient(),
+				Recorder:   recorder,
+				Config:     ClusterMachineApproverConfig{AllowedMachinePhases: tt.allowed},
+			}
+
+			got := approver.Authorize(context.Background(), tt.machines, tt.req.DeepCopy(), parseCR(t, tt.csr), nil)
+			if got.Authorized != tt.want.Authorized || got.Reason != tt.want.Reason || errString(got.Err) != tt.wantErr {
+				t.Errorf("Authorize() = %+v, want %+v, wantErr %s", got, tt.want, tt.wantErr)
+			}
+
+			select {
+			case event := <-recorder.Events:
+				if event != tt.wantEvent {
+					t.Errorf("got event %q, want %q", event, tt.wantEvent)
+				}
+			default:
+				if tt.wantEvent != "" {
+					t.Errorf("expected event %q", tt.wantEvent)
+				}
+			}
+		})
+	}
+}
+
+func TestAuthorizeCSRWindowsMachine(t *testing.T) {
+	machine := func(windows, nodeRef bool) []machinehandlerpkg.Machine {
+		machine := machinehandlerpkg.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "win-panda-machine",
+				CreationTimestamp: creationTimestamp(-5 * time.Minute),
+			},
+			Status: machinehandlerpkg.MachineStatus{
+				Addresses: []corev1.NodeAddress{
+					{Type: corev1.NodeInternalDNS, Address: "win-panda.ec2.internal"},
+					{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+				},
+			},
+		}
+		if nodeRef {
+			machine.Status.NodeRef = &corev1.ObjectReference{Name: "WIN-PANDA"}
+		}
+		if windows {
+			machine.Labels = map[string]string{machinehandlerpkg.OSIDLabel: machinehandlerpkg.OSIDWindows}
+		}
+		return []machinehandlerpkg.Machine{machine}
+	}
+	// Node names are lowercase, the hostname is only uppercase in serving SANs.
+	clientCSR := createCSR("system:node:win-panda", []string{"system:nodes"}, nil, nil)
+	servingCSR := createCSR("system:node:WIN-PANDA", defaultOrgs, []net.IP{net.ParseIP("10.0.0.1")}, []string{"WIN-PANDA", "win-panda.ec2.internal"})
+	clientReq := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "win-panda-client-csr",
+			CreationTimestamp: creationTimestamp(-time.Minute),
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageKeyEncipherment,
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageClientAuth,
+			},
+			Username: nodeBootstrapperUsername,
+			Groups:   nodeBootstrapperGroups.List(),
+			Request:  []byte(clientCSR),
+		},
+	}
+	servingReq := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "win-panda-serving-csr"},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageServerAuth,
+			},
+			Username: "system:node:WIN-PANDA",
+			Groups: []string{
+				"system:authenticated",
+				"system:nodes",
+			},
+			Request: []byte(servingCSR),
+		},
+	}
+
+	tests := []struct {
+		name    string
+		windows bool
+		req     *certificatesv1.CertificateSigningRequest
+		csr     string
+		want    AuthorizeResult
+		wantErr string
+	}{
+		{
+			name:    "client CSR of Windows node with short name",
+			windows: true,
+			req:     clientReq,
+			csr:     clientCSR,
+			want:    AuthorizeResult{Authorized: true, Reason: decisionReasonMachine},
+		},
+		{
+			name:    "client CSR of Linux node with short name",
+			req:     clientReq,
+			csr:     clientCSR,
+			want:    AuthorizeResult{Reason: decisionReasonMachineNotFound},
+			wantErr: "failed to find machine for node win-panda",
+		},
+		{
+			name:    "serving CSR of Windows node with short uppercase name",
+			windows: true,
+			req:     servingReq,
+			csr:     servingCSR,
+			want:    AuthorizeResult{Authorized: true, Reason: decisionReasonMachine},
+		},
+		{
+			name:    "serving CSR of Linux node with short uppercase name",
+			req:     servingReq,
+			csr:     servingCSR,
+			want:    AuthorizeResult{Reason: decisionReasonAuthorizationExhausted},
+			wantErr: "could not authorize CSR: exhausted all authorization methods: DNS name 'WIN-PANDA' not in machine names: win-panda.ec2.internal",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			approver := &CertificateApprover{
+				NodeClient: fake.NewFakeClient(&configv1.Network{ObjectMeta: metav1.ObjectMeta{Name: "cluster"}}),
+				Recorder:   record.NewFakeRecorder(10),
+			}
+
+			got := approver.Authorize(context.Background(), machine(tt.windows, tt.req == servingReq), tt.req.DeepCopy(), parseCR(t, tt.csr), nil)
+			if got.Authorized != tt.want.Authorized || got.Reason != tt.want.Reason || errString(got.Err) != tt.wantErr {
+				t.Errorf("Authorize() = %+v, want %+v, wantErr %s", got, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateKubeletVersion(t *testing.T) {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "panda"},
+		Status: corev1.NodeStatus{
+			NodeInfo: corev1.NodeSystemInfo{KubeletVersion: "v1.28.3+a1b2c3d"},
+		},
+	}
+	machineWithVersion := func(v string) []machinehandlerpkg.Machine {
+		return []machinehandlerpkg.Machine{{
+			Spec: machinehandlerpkg.MachineSpec{Version: pointer.String(v)},
+			Status: machinehandlerpkg.MachineStatus{
+				NodeRef: &corev1.ObjectReference{Name: "panda"},
+			},
+		}}
+	}
+
+	tests := []struct {
+		name     string
+		check    KubeletVersionCheck
+		machines []machinehandlerpkg.Machine
+		wantErr  string
+	}{
+		{
+			name: "no expected version",
+		},
+		{
+			name:  "kubelet at max version",
+			check: KubeletVersionCheck{MaxVersion: "v1.28.3"},
+		},
+		{
+			name:  "kubelet behind max version",
+			check: KubeletVersionCheck{MaxVersion: "1.29"},
+		},
+		{
+			name:    "kubelet ahead of max version",
+			check:   KubeletVersionCheck{MaxVersion: "v1.28.2"},
+			wantErr: "kubelet version v1.28.3+a1b2c3d of node panda is ahead of expected version v1.28.2",
+		},
+		{
+			name:     "machine version takes precedence",
+			check:    KubeletVersionCheck{MaxVersion: "v1.28.2"},
+			machines: machineWithVersion("v1.28.3"),
+		},
+		{
+			name:     "kubelet ahead of machine version",
+			check:    KubeletVersionCheck{MaxVersion: "v1.29.0"},
+			machines: machineWithVersion("v1.27.8"),
+			wantErr:  "kubelet version v1.28.3+a1b2c3d of node panda is ahead of expected version v1.27.8",
+		},
+		{
+			name:    "invalid max version",
+			check:   KubeletVersionCheck{MaxVersion: "latest"},
+			wantErr: "failed to parse expected kubelet version: could not parse \"latest\" as version",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cl := fake.NewClientBuilder().WithObjects(node).Build()
+			if err := validateKubeletVersion(context.Background(), cl, tt.check, tt.machines, "panda"); errString(err) != tt.wantErr {
+				t.Errorf("got: %v, want: %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthorizeServingCertWithProviderNetworkInterfaces(t *testing.T) {
+	machine := machinehandlerpkg.Machine{
+		Status: machinehandlerpkg.MachineStatus{
+			NodeRef: &corev1.ObjectReference{Name: "panda"},
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalDNS, Address: "panda"},
+				{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+			},
+			ProviderStatus: map[string]interface{}{
+				"networkInterfaces": []interface{}{
+					map[string]interface{}{"ipAddresses": []interface{}{"10.0.1.1", "fd00::1"}},
+					map[string]interface{}{"ipAddresses": "10.0.2.1"},
+					"panda",
+				},
+			},
+		},
+	}
+	req := &certificatesv1.CertificateSigningRequest{ObjectMeta: metav1.ObjectMeta{Name: "panda-csr"}}
+
+	tests := []struct {
+		name                  string
+		ips                   []net.IP
+		useProviderInterfaces bool
+		wantErr               string
+	}{
+		{
+			name: "IP in machine addresses",
+			ips:  []net.IP{net.ParseIP("10.0.0.1")},
+		},
+		{
+			name:    "IP in provider interfaces not used",
+			ips:     []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("10.0.1.1")},
+			wantErr: "IP address '10.0.1.1' not in machine addresses: 10.0.0.1",
+		},
+		{
+			name:                  "IP in provider interfaces",
+			ips:                   []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("10.0.1.1"), net.ParseIP("fd00::1")},
+			useProviderInterfaces: true,
+		},
+		{
+			name:                  "IP in malformed provider interface",
+			ips:                   []net.IP{net.ParseIP("10.0.2.1")},
+			useProviderInterfaces: true,
+			wantErr:               "IP address '10.0.2.1' not in machine addresses: 10.0.0.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			csr := parseCR(t, createCSR("system:node:panda", defaultOrgs, tt.ips, []string{"panda"}))
+			_, err := authorizeServingCertWithMachine(ClusterMachineApproverConfig{}, []machinehandlerpkg.Machine{machine}, req, "panda", csr, tt.useProviderInterfaces, nil)
+			if errString(err) != tt.wantErr {
+				t.Errorf("got: %v, want: %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIPMatchesAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		ip      string
+		address string
+		want    bool
+	}{
+		{
+			name:    "identical IPv4",
+			ip:      "10.0.0.1",
+			address: "10.0.0.1",
+			want:    true,
+		},
+		{
+			name:    "different IPv4",
+			ip:      "10.0.0.1",
+			address: "10.0.0.2",
+			want:    false,
+		},
+		{
+			name:    "expanded upper case IPv6",
+			ip:      "fe80::1",
+			address: "FE80:0:0:0:0:0:0:1",
+			want:    true,
+		},
+		{
+			name:    "IPv6 with leading zeros",
+			ip:      "fd00::1",
+			address: "fd00:0000:0000:0000:0000:0000:0000:0001",
+			want:    true,
+		},
+		{
+			name:    "IPv6 with zone",
+			ip:      "fe80::1",
+			address: "fe80::1%eth0",
+			want:    true,
+		},
+		{
+			name:    "IPv4-mapped IPv6 address",
+			ip:      "10.0.0.1",
+			address: "::ffff:10.0.0.1",
+			want:    true,
+		},
+		{
+			name:    "IPv4 and IPv6 address",
+			ip:      "10.0.0.1",
+			address: "fd00::10.0.0.1",
+			want:    false,
+		},
+		{
+			name:    "not an IP address",
+			ip:      "10.0.0.1",
+			address: "panda",
+			want:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ipMatchesAddress(net.ParseIP(tt.ip), tt.address); got != tt.want {
+				t.Errorf("ipMatchesAddress(%s, %s) = %v, want %v", tt.ip, tt.address, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthorizeServingCertWithMachineDualStack(t *testing.T) {
+	machine := machinehandlerpkg.Machine{
+		Status: machinehandlerpkg.MachineStatus{
+			NodeRef: &corev1.ObjectReference{Name: "panda"},
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalDNS, Address: "panda"},
+				{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+				{Type: corev1.NodeInternalIP, Address: "FD00:0:0:0:0:0:0:1"},
+				{Type: corev1.NodeExternalIP, Address: "::ffff:192.168.0.1"},
+			},
+		},
+	}
+	req := &certificatesv1.CertificateSigningRequest{ObjectMeta: metav1.ObjectMeta{Name: "panda-csr"}}
+
+	tests := []struct {
+		name    string
+		ips     []net.IP
+		wantErr string
+	}{
+		{
+			name: "IPv4 and IPv6",
+			ips:  []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("fd00::1")},
+		},
+		{
+			name: "IPv6 only",
+			ips:  []net.IP{net.ParseIP("fd00::1")},
+		},
+		{
+			name: "IPv4-mapped machine address",
+			ips:  []net.IP{net.ParseIP("192.168.0.1")},
+		},
+		{
+			name:    "unknown IPv6",
+			ips:     []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("fd00::2")},
+			wantErr: "IP address 'fd00::2' not in machine addresses: 10.0.0.1 FD00:0:0:0:0:0:0:1 ::ffff:192.168.0.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			csr := parseCR(t, createCSR("system:node:panda", defaultOrgs, tt.ips, []string{"panda"}))
+			_, err := authorizeServingCertWithMachine(ClusterMachineApproverConfig{}, []machinehandlerpkg.Machine{machine}, req, "panda", csr, false, nil)
+			if errString(err) != tt.wantErr {
+				t.Errorf("got: %v, want: %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthorizeServingCertWithMachineShortNames(t *testing.T) {
+	machine := machinehandlerpkg.Machine{
+		Status: machinehandlerpkg.MachineStatus{
+			NodeRef: &corev1.ObjectReference{Name: "ip-10-0-152-205"},
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalDNS, Address: "ip-10-0-152-205.ec2.internal"},
+				{Type: corev1.NodeInternalIP, Address: "10.0.152.205"},
+			},
+		},
+	}
+	req := &certificatesv1.CertificateSigningRequest{ObjectMeta: metav1.ObjectMeta{Name: "panda-csr"}}
+
+	tests := []struct {
+		name               string
+		dnsNames           []string
+		allowShortNameSANs bool
+		wantErr            string
+	}{
+		{
+			name:     "FQDN only",
+			dnsNames: []string{"ip-10-0-152-205.ec2.internal"},
+		},
+		{
+			name:     "short name not allowed",
+			dnsNames: []string{"ip-10-0-152-205", "ip-10-0-152-205.ec2.internal"},
+			wantErr:  "DNS name 'ip-10-0-152-205' not in machine names: ip-10-0-152-205.ec2.internal",
+		},
+		{
+			name:               "short name allowed",
+			dnsNames:           []string{"ip-10-0-152-205", "ip-10-0-152-205.ec2.internal"},
+			allowShortNameSANs: true,
+		},
+		{
+			name:               "other short name",
+			dnsNames:           []string{"ip-10-0-152-206"},
+			allowShortNameSANs: true,
+			wantErr:            "DNS name 'ip-10-0-152-206' not in machine names: ip-10-0-152-205.ec2.internal",
+		},
+		{
+			name:               "partial domain",
+			dnsNames:           []string{"ip-10-0-152-205.ec2"},
+			allowShortNameSANs: true,
+			wantErr:            "DNS name 'ip-10-0-152-205.ec2' not in machine names: ip-10-0-152-205.ec2.internal",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := ClusterMachineApproverConfig{
+				NodeServingCert: NodeServingCert{AllowShortNameSANs: tt.allowShortNameSANs},
+			}
+			csr := parseCR(t, createCSR("system:node:ip-10-0-152-205", defaultOrgs, []net.IP{net.ParseIP("10.0.152.205")}, tt.dnsNames))
+			_, err := authorizeServingCertWithMachine(config, []machinehandlerpkg.Machine{machine}, req, "ip-10-0-152-205", csr, false, nil)
+			if errString(err) != tt.wantErr {
+				t.Errorf("got: %v, want: %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthorizeServingCertWithMachineFullSANCoverage(t *testing.T) {
+	machine := machinehandlerpkg.Machine{
+		Status: machinehandlerpkg.MachineStatus{
+			NodeRef: &corev1.ObjectReference{Name: "ip-10-0-152-205"},
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalDNS, Address: "ip-10-0-152-205.ec2.internal"},
+				{Type: corev1.NodeInternalIP, Address: "10.0.152.205"},
+				{Type: corev1.NodeExternalIP, Address: "203.0.113.5"},
+				// Not matched against any SAN by default.
+				{Type: corev1.NodeExternalDNS + "Unused", Address: "unused.example.com"},
+			},
+		},
+	}
+	node := &corev1.Node{
+		Status: corev1.NodeStatus{
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalDNS, Address: "ip-10-0-152-205.ec2.internal"},
+				{Type: corev1.NodeInternalIP, Address: "10.0.152.205"},
+				{Type: corev1.NodeExternalIP, Address: "198.51.100.7"},
+			},
+		},
+	}
+	req := &certificatesv1.CertificateSigningRequest{ObjectMeta: metav1.ObjectMeta{Name: "panda-csr"}}
+
+	tests := []struct {
+		name                   string
+		dnsNames               []string
+		ips                    []net.IP
+		requireFullSANCoverage bool
+		sanAddressSources      string
+		wantErr                string
+	}{
+		{
+			name:                   "all addresses",
+			dnsNames:               []string{"ip-10-0-152-205.ec2.internal"},
+			ips:                    []net.IP{net.ParseIP("10.0.152.205"), net.ParseIP("203.0.113.5")},
+			requireFullSANCoverage: true,
+		},
+		{
+			name:     "missing IP address allowed",
+			dnsNames: []string{"ip-10-0-152-205.ec2.internal"},
+			ips:      []net.IP{net.ParseIP("10.0.152.205")},
+		},
+		{
+			name:                   "missing IP address",
+			dnsNames:               []string{"ip-10-0-152-205.ec2.internal"},
+			ips:                    []net.IP{net.ParseIP("10.0.152.205")},
+			requireFullSANCoverage: true,
+			wantErr:                "machine address '203.0.113.5' of type ExternalIP not in CSR SANs",
+		},
+		{
+			name:                   "missing DNS name",
+			ips:                    []net.IP{net.ParseIP("10.0.152.205"), net.ParseIP("203.0.113.5")},
+			requireFullSANCoverage: true,
+			wantErr:                "machine address 'ip-10-0-152-205.ec2.internal' of type InternalDNS not in CSR SANs",
+		},
+		{
+			name:                   "missing node address",
+			dnsNames:               []string{"ip-10-0-152-205.ec2.internal"},
+			ips:                    []net.IP{net.ParseIP("10.0.152.205"), net.ParseIP("203.0.113.5")},
+			requireFullSANCoverage: true,
+			sanAddressSources:      sanAddressSourceUnion,
+			wantErr:                "machine address '198.51.100.7' of type ExternalIP not in CSR SANs",
+		},
+		{
+			name:                   "all node addresses",
+			dnsNames:               []string{"ip-10-0-152-205.ec2.internal"},
+			ips:                    []net.IP{net.ParseIP("10.0.152.205"), net.ParseIP("198.51.100.7")},
+			requireFullSANCoverage: true,
+			sanAddressSources:      sanAddressSourceNodeOnly,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := ClusterMachineApproverConfig{
+				NodeServingCert: NodeServingCert{
+					RequireFullSANCoverage: tt.requireFullSANCoverage,
+					SANAddressSources:      tt.sanAddressSources,
+				},
+			}
+			csr := parseCR(t, createCSR("system:node:ip-10-0-152-205", defaultOrgs, tt.ips, tt.dnsNames))
+			_, err := authorizeServingCertWithMachine(config, []machinehandlerpkg.Machine{machine}, req, "ip-10-0-152-205", csr, false, node)
+			if errString(err) != tt.wantErr {
+				t.Errorf("got: %v, want: %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthorizeServingCertWithMachineTrailingDots(t *testing.T) {
+	req := &certificatesv1.CertificateSigningRequest{ObjectMeta: metav1.ObjectMeta{Name: "panda-csr"}}
+
+	tests := []struct {
+		name           string
+		machineAddress string
+		dnsNames       []string
+		wantErr        string
+	}{
+		{
+			name:           "no trailing dots",
+			machineAddress: "ip-10-0-152-205.ec2.internal",
+			dnsNames:       []string{"ip-10-0-152-205.ec2.internal"},
+		},
+		{
+			name:           "trailing dot in SAN",
+			machineAddress: "ip-10-0-152-205.ec2.internal",
+			dnsNames:       []string{"ip-10-0-152-205.ec2.internal."},
+		},
+		{
+			name:           "trailing dot in machine address",
+			machineAddress: "ip-10-0-152-205.ec2.internal.",
+			dnsNames:       []string{"ip-10-0-152-205.ec2.internal"},
+		},
+		{
+			name:           "trailing dots in both",
+			machineAddress: "ip-10-0-152-205.ec2.internal.",
+			dnsNames:       []string{"IP-10-0-152-205.EC2.Internal."},
+		},
+		{
+			name:           "other name with trailing dot",
+			machineAddress: "ip-10-0-152-205.ec2.internal",
+			dnsNames:       []string{"ip-10-0-152-206.ec2.internal."},
+			wantErr:        "DNS name 'ip-10-0-152-206.ec2.internal.' not in machine names: ip-10-0-152-205.ec2.internal",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			machine := machinehandlerpkg.Machine{
+				Status: machinehandlerpkg.MachineStatus{
+					NodeRef: &corev1.ObjectReference{Name: "ip-10-0-152-205"},
+					Addresses: []corev1.NodeAddress{
+						{Type: corev1.NodeInternalDNS, Address: tt.machineAddress},
+						{Type: corev1.NodeInternalIP, Address: "10.0.152.205"},
+					},
+				},
+			}
+			csr := parseCR(t, createCSR("system:node:ip-10-0-152-205", defaultOrgs, []net.IP{net.ParseIP("10.0.152.205")}, tt.dnsNames))
+			_, err := authorizeServingCertWithMachine(ClusterMachineApproverConfig{}, []machinehandlerpkg.Machine{machine}, req, "ip-10-0-152-205", csr, false, nil)
+			if errString(err) != tt.wantErr {
+				t.Errorf("got: %v, want: %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthorizeServingCertWithMachineAddressTypes(t *testing.T) {
+	machine := machinehandlerpkg.Machine{
+		Status: machinehandlerpkg.MachineStatus{
+			NodeRef: &corev1.ObjectReference{Name: "panda"},
+			Addresses: []corev1.NodeAddress{
+				{Type: "PlatformDNS", Address: "panda.example.com"},
+				{Type: "PlatformIP", Address: "10.0.0.1"},
+			},
+		},
+	}
+	req := &certificatesv1.CertificateSigningRequest{ObjectMeta: metav1.ObjectMeta{Name: "panda-csr"}}
+
+	tests := []struct {
+		name     string
+		config   NodeServingCert
+		dnsNames []string
+		ips      []net.IP
+		wantErr  string
+	}{
+		{
+			name:     "default DNS address types",
+			dnsNames: []string{"panda.example.com"},
+			wantErr:  "DNS name 'panda.example.com' not in machine names: ",
+		},
+		{
+			name:    "default IP address types",
+			ips:     []net.IP{net.ParseIP("10.0.0.1")},
+			wantErr: "IP address '10.0.0.1' not in machine addresses: ",
+		},
+		{
+			name:     "custom address types",
+			config:   NodeServingCert{DNSAddressTypes: []corev1.NodeAddressType{"PlatformDNS"}, IPAddressTypes: []corev1.NodeAddressType{"PlatformIP"}},
+			dnsNames: []string{"panda.example.com"},
+			ips:      []net.IP{net.ParseIP("10.0.0.1")},
+		},
+		{
+			name:     "custom DNS address type only",
+			config:   NodeServingCert{DNSAddressTypes: []corev1.NodeAddressType{"PlatformDNS"}},
+			dnsNames: []string{"panda.example.com"},
+			ips:      []net.IP{net.ParseIP("10.0.0.1")},
+			wantErr:  "IP address '10.0.0.1' not in machine addresses: ",
+		},
+		{
+			// The IP address types are not matched against DNS names.
+			name:     "DNS name of an IP address type",
+			config:   NodeServingCert{IPAddressTypes: []corev1.NodeAddressType{"PlatformDNS"}},
+			dnsNames: []string{"panda.example.com"},
+			wantErr:  "DNS name 'panda.example.com' not in machine names: ",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := ClusterMachineApproverConfig{NodeServingCert: tt.config}
+			csr := parseCR(t, createCSR("system:node:panda", defaultOrgs, tt.ips, tt.dnsNames))
+			_, err := authorizeServingCertWithMachine(config, []machinehandlerpkg.Machine{machine}, req, "panda", csr, false, nil)
+			if errString(err) != tt.wantErr {
+				t.Errorf("got: %v, want: %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthorizeServingCertWithNodeAddresses(t *testing.T) {
+	machines := []machinehandlerpkg.Machine{{
+		ObjectMeta: metav1.ObjectMeta{Name: "panda-machine"},
+		Status: machinehandlerpkg.MachineStatus{
+			NodeRef: &corev1.ObjectReference{Name: "panda"},
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalDNS, Address: "panda"},
+				{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+			},
+		},
+	}}
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "panda"},
+		Status: corev1.NodeStatus{
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+				{Type: corev1.NodeInternalIP, Address: "10.0.0.2"},
+				{Type: corev1.NodeHostName, Address: "panda.example.com"},
 			},
-			wantErr:   "could not authorize CSR: exhausted all authorization methods: [CSR Subject Alternate Name values do not match current certificate, Unable to find machine for node, CSR Subject Alternate Names includes unknown IP addresses]",
+		},
+	}
+
+	network := &configv1.Network{ObjectMeta: metav1.ObjectMeta{Name: "cluster"}}
+
+	tests := []struct {
+		name                string
+		objects             []client.Object
+		preferNodeAddresses bool
+		sanAddressSources   string
+		ips                 []net.IP
+		dnsNames            []string
+		authorize           bool
+		wantErr             string
+	}{
+		{
+			name:      "machine addresses only",
+			objects:   []client.Object{node},
+			ips:       []net.IP{net.ParseIP("10.0.0.1")},
+			dnsNames:  []string{"panda"},
+			authorize: true,
+		},
+		{
+			name:      "IP address missing from machine",
+			objects:   []client.Object{node},
+			ips:       []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.2")},
+			dnsNames:  []string{"panda"},
 			authorize: false,
+			wantErr:   "could not authorize CSR: exhausted all authorization methods: IP address '10.0.0.2' not in machine addresses: 10.0.0.1",
+		},
+		{
+			name:                "IP address missing from machine on node",
+			objects:             []client.Object{node},
+			preferNodeAddresses: true,
+			ips:                 []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.2")},
+			dnsNames:            []string{"panda"},
+			authorize:           true,
+		},
+		{
+			name:                "DNS name missing from machine on node",
+			objects:             []client.Object{node},
+			preferNodeAddresses: true,
+			dnsNames:            []string{"panda", "panda.example.com"},
+			authorize:           true,
+		},
+		{
+			name:                "IP address on neither",
+			objects:             []client.Object{node},
+			preferNodeAddresses: true,
+			ips:                 []net.IP{net.ParseIP("10.0.0.3")},
+			dnsNames:            []string{"panda"},
+			authorize:           false,
+			wantErr:             "could not authorize CSR: exhausted all authorization methods: IP address '10.0.0.3' not in machine addresses: 10.0.0.1 10.0.0.1 10.0.0.2",
+		},
+		{
+			name:              "IP address missing from machine with machine only sources",
+			objects:           []client.Object{node},
+			sanAddressSources: sanAddressSourceMachineOnly,
+			ips:               []net.IP{net.ParseIP("10.0.0.2")},
+			dnsNames:          []string{"panda"},
+			authorize:         false,
+			wantErr:           "could not authorize CSR: exhausted all authorization methods: IP address '10.0.0.2' not in machine addresses: 10.0.0.1",
+		},
+		{
+			name:              "node addresses with node only sources",
+			objects:           []client.Object{node},
+			sanAddressSources: sanAddressSourceNodeOnly,
+			ips:               []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.2")},
+			dnsNames:          []string{"panda.example.com"},
+			authorize:         true,
+		},
+		{
+			name:              "DNS name missing from node with node only sources",
+			objects:           []client.Object{node},
+			sanAddressSources: sanAddressSourceNodeOnly,
+			ips:               []net.IP{net.ParseIP("10.0.0.1")},
+			dnsNames:          []string{"panda"},
+			authorize:         false,
+			wantErr:           "could not authorize CSR: exhausted all authorization methods: DNS name 'panda' not in machine names: panda.example.com",
 		},
 		{
-			name: "CSR extra address in egress IPs",
-			args: args{
-				node: withName("test", defaultNode()),
-				req: &certificatesv1.CertificateSigningRequest{
-					Spec: certificatesv1.CertificateSigningRequestSpec{
-						Usages: []certificatesv1.KeyUsage{
-							certificatesv1.UsageDigitalSignature,
-							certificatesv1.UsageKeyEncipherment,
-							certificatesv1.UsageServerAuth,
-						},
-						Username: "system:node:test",
-						Groups: []string{
-							"system:authenticated",
-							"system:nodes",
-						},
-					},
-				},
-				csr:         extraAddr,
-				networkType: "OpenShiftSDN",
-				hostSubnet:  withEgressIPs(hostSubnet("test"), "99.0.1.1"),
-				ca:          []*x509.Certificate{parseCert(t, rootCertGood)},
-			},
-			authorize: true,
+			name:              "machine and node addresses with union sources",
+			objects:           []client.Object{node},
+			sanAddressSources: sanAddressSourceUnion,
+			ips:               []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.2")},
+			dnsNames:          []string{"panda", "panda.example.com"},
+			authorize:         true,
 		},
 		{
-			name: "CSR extra address in egress CIDRs",
-			args: args{
-				node: withName("test", defaultNode()),
-				req: &certificatesv1.CertificateSigningRequest{
-					Spec: certificatesv1.CertificateSigningRequestSpec{
-						Usages: []certificatesv1.KeyUsage{
-							certificatesv1.UsageDigitalSignature,
-							certificatesv1.UsageKeyEncipherment,
-							certificatesv1.UsageServerAuth,
-						},
-						Username: "system:node:test",
-						Groups: []string{
-							"system:authenticated",
-							"system:nodes",
-						},
-					},
-				},
-				csr:         extraAddr,
-				networkType: "OpenShiftSDN",
-				hostSubnet:  withEgressCIDRs(hostSubnet("test"), "99.0.1.0/24"),
-				ca:          []*x509.Certificate{parseCert(t, rootCertGood)},
-			},
-			authorize: true,
+			name:              "IP address on neither with union sources",
+			objects:           []client.Object{node},
+			sanAddressSources: sanAddressSourceUnion,
+			ips:               []net.IP{net.ParseIP("10.0.0.3")},
+			dnsNames:          []string{"panda"},
+			authorize:         false,
+			wantErr:           "could not authorize CSR: exhausted all authorization methods: IP address '10.0.0.3' not in machine addresses: 10.0.0.1 10.0.0.1 10.0.0.2",
+		},
+		{
+			name:              "node not found with node only sources",
+			sanAddressSources: sanAddressSourceNodeOnly,
+			ips:               []net.IP{net.ParseIP("10.0.0.1")},
+			dnsNames:          []string{"panda"},
+			authorize:         false,
+			wantErr:           "failed to get node panda: nodes \"panda\" not found",
+		},
+		{
+			name:                "node not found",
+			preferNodeAddresses: true,
+			ips:                 []net.IP{net.ParseIP("10.0.0.1")},
+			dnsNames:            []string{"panda"},
+			authorize:           false,
+			wantErr:             "failed to get node panda: nodes \"panda\" not found",
 		},
 	}
 
-	server := fakeResponder(t, fmt.Sprintf("%s:%v", defaultAddr, defaultPort), serverCertGood, serverKeyGood)
-	defer server.Close()
-
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			kubeletServer := server
-			if tt.args.kubeletServer != nil {
-				kubeletServer = tt.args.kubeletServer
-				defer kubeletServer.Close()
-			}
-
-			network := &configv1.Network{
-				ObjectMeta: metav1.ObjectMeta{
-					Name: "cluster",
+			csr := createCSR("system:node:panda", defaultOrgs, tt.ips, tt.dnsNames)
+			req := &certificatesv1.CertificateSigningRequest{
+				ObjectMeta: metav1.ObjectMeta{Name: "panda-csr"},
+				Spec: certificatesv1.CertificateSigningRequestSpec{
+					Usages: []certificatesv1.KeyUsage{
+						certificatesv1.UsageDigitalSignature,
+						certificatesv1.UsageServerAuth,
+					},
+					Username: "system:node:panda",
+					Groups: []string{
+						"system:authenticated",
+						"system:nodes",
+					},
+					Request: []byte(csr),
 				},
-				Status: configv1.NetworkStatus{
-					NetworkType: tt.args.networkType,
+			}
+			approver := &CertificateApprover{
+				NodeClient: fake.NewClientBuilder().WithObjects(append(tt.objects, network)...).Build(),
+				Config: ClusterMachineApproverConfig{
+					NodeServingCert: NodeServingCert{
+						PreferNodeAddresses: tt.preferNodeAddresses,
+						SANAddressSources:   tt.sanAddressSources,
+					},
 				},
 			}
 
-			objs := []runtime.Object{network}
-			if tt.args.node != nil {
-				objs = append(objs, tt.args.node)
-			}
-			if tt.args.hostSubnet != nil {
-				objs = append(objs, tt.args.hostSubnet)
-			}
-			cl := fake.NewFakeClient(objs...)
-			tt.args.req.Spec.Request = []byte(tt.args.csr)
-			parsedCSR, err := parseCSR(tt.args.req)
-			if err != nil {
-				if errString(err) != tt.wantErr {
-					t.Errorf("parseCSR() error = %v, wantErr %s", err, tt.wantErr)
-				}
-				return
+			_, authorize, err := approver.authorizeCSR(context.Background(), machines, req, parseCR(t, csr), nil)
+			if authorize != tt.authorize || errString(err) != tt.wantErr {
+				t.Errorf("authorizeCSR() = %v, error = %v, want %v, wantErr %s", authorize, err, tt.authorize, tt.wantErr)
 			}
+		})
+	}
+}
 
-			var ca *x509.CertPool
-			if len(tt.args.ca) > 0 {
-				// Start renewal flow
-				ca = x509.NewCertPool()
-				for _, cert := range tt.args.ca {
-					ca.AddCert(cert)
-				}
-				go respond(kubeletServer)
+func TestMatchesNodeHostname(t *testing.T) {
+	nodeWithAddresses := func(addresses ...corev1.NodeAddress) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "panda"},
+			Status:     corev1.NodeStatus{Addresses: addresses},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		node     *corev1.Node
+		dnsNames []string
+		want     bool
+		wantErr  string
+	}{
+		{
+			name:     "matching hostname",
+			node:     nodeWithAddresses(corev1.NodeAddress{Type: corev1.NodeHostName, Address: "panda.example.com"}),
+			dnsNames: []string{"panda.example.com", "panda"},
+			want:     true,
+		},
+		{
+			name:     "matching hostname with different case",
+			node:     nodeWithAddresses(corev1.NodeAddress{Type: corev1.NodeHostName, Address: "Panda.example.com"}),
+			dnsNames: []string{"panda.example.com"},
+			want:     true,
+		},
+		{
+			name:     "hostname only matches another DNS name",
+			node:     nodeWithAddresses(corev1.NodeAddress{Type: corev1.NodeHostName, Address: "panda"}),
+			dnsNames: []string{"bamboo.example.com", "panda"},
+			want:     false,
+		},
+		{
+			name:     "node without hostname",
+			node:     nodeWithAddresses(corev1.NodeAddress{Type: corev1.NodeInternalDNS, Address: "bamboo"}),
+			dnsNames: []string{"panda"},
+			want:     true,
+		},
+		{
+			name: "no DNS names",
+			want: true,
+		},
+		{
+			name:     "node not found",
+			dnsNames: []string{"panda"},
+			want:     false,
+			wantErr:  "failed to get node panda: nodes \"panda\" not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builder := fake.NewClientBuilder()
+			if tt.node != nil {
+				builder = builder.WithObjects(tt.node)
 			}
-			if authorize, err := authorizeCSR(cl, tt.args.config, tt.args.machines, tt.args.req, parsedCSR, ca); authorize != tt.authorize || errString(err) != tt.wantErr {
-				t.Errorf("authorizeCSR() error = %v, wantErr %s", err, tt.wantErr)
+			got, err := matchesNodeHostname(context.Background(), builder.Build(), "panda", &x509.CertificateRequest{DNSNames: tt.dnsNames})
+			if got != tt.want || errString(err) != tt.wantErr {
+				t.Errorf("matchesNodeHostname() = %v, %v, want %v, %s", got, err, tt.want, tt.wantErr)
 			}
 		})
+	}
+}
 
-		t.Run("Invalid call", func(t *testing.T) {
-			if authorize, err := authorizeCSR(nil, tt.args.config, tt.args.machines, nil, nil, nil); authorize != false {
-				t.Errorf("authorizeCSR() error = %v, wantErr %s", err, "Invalid request")
+func TestGetPlatformType(t *testing.T) {
+	tests := []struct {
+		name    string
+		infra   *configv1.Infrastructure
+		want    configv1.PlatformType
+		wantErr string
+	}{
+		{
+			name: "platform status",
+			infra: &configv1.Infrastructure{
+				ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+				Status: configv1.InfrastructureStatus{
+					PlatformStatus: &configv1.PlatformStatus{Type: configv1.PowerVSPlatformType},
+				},
+			},
+			want: configv1.PowerVSPlatformType,
+		},
+		{
+			name: "deprecated platform",
+			infra: &configv1.Infrastructure{
+				ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+				Status:     configv1.InfrastructureStatus{Platform: configv1.AWSPlatformType},
+			},
+			want: configv1.AWSPlatformType,
+		},
+		{
+			name:    "no infrastructure",
+			wantErr: "could not fetch cluster infrastructure: infrastructures.config.openshift.io \"cluster\" not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects := []runtime.Object{}
+			if tt.infra != nil {
+				objects = append(objects, tt.infra)
+			}
+			platform, err := getPlatformType(context.Background(), fake.NewFakeClient(objects...))
+			if errString(err) != tt.wantErr {
+				t.Errorf("got: %v, want: %s", err, tt.wantErr)
+			}
+			if platform != tt.want {
+				t.Errorf("got platform %q, want %q", platform, tt.want)
 			}
 		})
 	}
 }
 
 func TestAuthorizeServingRenewal(t *testing.T) {
+	machineWithAddresses := func(addresses ...corev1.NodeAddress) *machinehandlerpkg.Machine {
+		return &machinehandlerpkg.Machine{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-machine"},
+			Status: machinehandlerpkg.MachineStatus{
+				NodeRef:   &corev1.ObjectReference{Name: "test"},
+				Addresses: addresses,
+			},
+		}
+	}
+
 	tests := []struct {
 		name        string
+		nodeUser    *string
 		nodeName    string
 		csr         *x509.CertificateRequest
 		currentCert *x509.Certificate
+		machine     *machinehandlerpkg.Machine
+		servingCert NodeServingCert
+		node        *corev1.Node
 		ca          []*x509.Certificate
+		previousCA  []*x509.Certificate
 		time        time.Time
 		wantErr     string
 	}{
@@ -1614,6 +5898,27 @@ func TestAuthorizeServingRenewal(t *testing.T) {
 			ca:          []*x509.Certificate{parseCert(t, rootCertGood)},
 			time:        presetTimeCorrect,
 		},
+		{
+			// The kubelet CA has rotated, but the current cert was signed by
+			// the previous CA.
+			name:        "signed by previous CA",
+			nodeName:    "test",
+			csr:         parseCR(t, goodCSR),
+			currentCert: parseCert(t, serverCertGood),
+			ca:          []*x509.Certificate{parseCert(t, differentCert)},
+			previousCA:  []*x509.Certificate{parseCert(t, rootCertGood)},
+			time:        presetTimeCorrect,
+		},
+		{
+			name:        "signed by unknown CA after rotation",
+			nodeName:    "test",
+			csr:         parseCR(t, goodCSR),
+			currentCert: parseCert(t, serverCertGood),
+			ca:          []*x509.Certificate{parseCert(t, differentCert)},
+			previousCA:  []*x509.Certificate{},
+			time:        presetTimeCorrect,
+			wantErr:     "x509: certificate signed by unknown authority",
+		},
 		{
 			name:        "reject expired",
 			nodeName:    "test",
@@ -1624,14 +5929,126 @@ func TestAuthorizeServingRenewal(t *testing.T) {
 			wantErr:     fmt.Sprintf("x509: certificate has expired or is not yet valid: current time %s is before %s", presetTimeExpired.Format(time.RFC3339), presetTimeCorrect.Format(time.RFC3339)),
 		},
 		{
-			name:        "SAN list differs",
+			name:        "added SAN without machine",
+			nodeName:    "test",
+			csr:         parseCR(t, extraAddr),
+			currentCert: parseCert(t, serverCertGood),
+			ca:          []*x509.Certificate{parseCert(t, rootCertGood)},
+			time:        presetTimeCorrect,
+			wantErr:     "CSR Subject Alternate Names [99.0.1.1] are not in current certificate and node test has no machine",
+		},
+		{
+			// The node gained a NIC.
+			name:        "added SAN in machine addresses",
+			nodeName:    "test",
+			csr:         parseCR(t, extraAddr),
+			currentCert: parseCert(t, serverCertGood),
+			machine: machineWithAddresses(
+				corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+				corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "99.0.1.1"},
+			),
+			ca:   []*x509.Certificate{parseCert(t, rootCertGood)},
+			time: presetTimeCorrect,
+		},
+		{
+			name:        "added DNS name in machine addresses",
+			nodeName:    "test",
+			csr:         parseCR(t, createCSR("system:node:test", defaultOrgs, defaultIPs, append([]string{"node1.example.com"}, defaultDNSNames...))),
+			currentCert: parseCert(t, serverCertGood),
+			machine: machineWithAddresses(
+				corev1.NodeAddress{Type: corev1.NodeExternalDNS, Address: "node1.example.com"},
+			),
+			ca:   []*x509.Certificate{parseCert(t, rootCertGood)},
+			time: presetTimeCorrect,
+		},
+		{
+			// Names are matched as in the machine-api flow, ignoring the
+			// trailing dot of fully qualified names.
+			name:        "added fully qualified DNS name in machine addresses",
+			nodeName:    "test",
+			csr:         parseCR(t, createCSR("system:node:test", defaultOrgs, defaultIPs, append([]string{"node1.example.com."}, defaultDNSNames...))),
+			currentCert: parseCert(t, serverCertGood),
+			machine: machineWithAddresses(
+				corev1.NodeAddress{Type: corev1.NodeInternalDNS, Address: "Node1.example.com"},
+			),
+			ca:   []*x509.Certificate{parseCert(t, rootCertGood)},
+			time: presetTimeCorrect,
+		},
+		{
+			name:        "added DNS name in address of type not configured",
+			nodeName:    "test",
+			csr:         parseCR(t, createCSR("system:node:test", defaultOrgs, defaultIPs, append([]string{"node1.example.com"}, defaultDNSNames...))),
+			currentCert: parseCert(t, serverCertGood),
+			machine: machineWithAddresses(
+				corev1.NodeAddress{Type: corev1.NodeExternalDNS, Address: "node1.example.com"},
+			),
+			servingCert: NodeServingCert{DNSAddressTypes: []corev1.NodeAddressType{corev1.NodeInternalDNS}},
+			ca:          []*x509.Certificate{parseCert(t, rootCertGood)},
+			time:        presetTimeCorrect,
+			wantErr:     "CSR Subject Alternate Name node1.example.com is not in current certificate nor in machine test-machine addresses",
+		},
+		{
+			name:        "added SAN in node addresses",
+			nodeName:    "test",
+			csr:         parseCR(t, extraAddr),
+			currentCert: parseCert(t, serverCertGood),
+			machine: machineWithAddresses(
+				corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+			),
+			servingCert: NodeServingCert{SANAddressSources: sanAddressSourceUnion},
+			node: &corev1.Node{
+				Status: corev1.NodeStatus{
+					Addresses: []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: "99.0.1.1"}},
+				},
+			},
+			ca:   []*x509.Certificate{parseCert(t, rootCertGood)},
+			time: presetTimeCorrect,
+		},
+		{
+			name:        "added SAN not in machine addresses",
 			nodeName:    "test",
 			csr:         parseCR(t, extraAddr),
 			currentCert: parseCert(t, serverCertGood),
+			machine: machineWithAddresses(
+				corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+				corev1.NodeAddress{Type: corev1.NodeInternalDNS, Address: "99.0.1.1"},
+			),
+			ca:      []*x509.Certificate{parseCert(t, rootCertGood)},
+			time:    presetTimeCorrect,
+			wantErr: "CSR Subject Alternate Name 99.0.1.1 is not in current certificate nor in machine test-machine addresses",
+		},
+		{
+			// DNS names are case-insensitive.
+			name:        "DNS names in different case",
+			nodeName:    "test",
+			csr:         parseCR(t, createCSR("system:node:test", defaultOrgs, defaultIPs, []string{"NODE1", "Node1.Local", "localhost"})),
+			currentCert: parseCert(t, serverCertGood),
+			ca:          []*x509.Certificate{parseCert(t, rootCertGood)},
+			time:        presetTimeCorrect,
+		},
+		{
+			name:        "removed SAN",
+			nodeName:    "test",
+			csr:         parseCR(t, createCSR("system:node:test", defaultOrgs, defaultIPs, []string{"node1", "node1.local"})),
+			currentCert: parseCert(t, serverCertGood),
 			ca:          []*x509.Certificate{parseCert(t, rootCertGood)},
 			time:        presetTimeCorrect,
 			wantErr:     "CSR Subject Alternate Name values do not match current certificate",
 		},
+		{
+			// The replacement is a machine address, but the SAN pinning the
+			// node to its machine can't be dropped.
+			name:        "replaced SAN",
+			nodeName:    "test",
+			csr:         parseCR(t, createCSR("system:node:test", defaultOrgs, []net.IP{net.ParseIP("99.0.1.1")}, defaultDNSNames)),
+			currentCert: parseCert(t, serverCertGood),
+			machine: machineWithAddresses(
+				corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "99.0.1.1"},
+			),
+			ca:      []*x509.Certificate{parseCert(t, rootCertGood)},
+			time:    presetTimeCorrect,
+			wantErr: "CSR Subject Alternate Name values do not match current certificate",
+		},
 		{
 			name:        "No certificate match",
 			nodeName:    "test",
@@ -1650,6 +6067,16 @@ func TestAuthorizeServingRenewal(t *testing.T) {
 			time:        presetTimeCorrect,
 			wantErr:     "current serving cert and CSR common name mismatch",
 		},
+		{
+			name:        "custom node user",
+			nodeUser:    pointer.String("example:node"),
+			nodeName:    "test",
+			csr:         parseCR(t, goodCSR),
+			currentCert: parseCert(t, serverCertGood),
+			ca:          []*x509.Certificate{parseCert(t, rootCertGood)},
+			time:        presetTimeCorrect,
+			wantErr:     "current serving cert has bad common name",
+		},
 		{
 			name:        "Unexpected CN",
 			nodeName:    "panda",
@@ -1667,11 +6094,23 @@ func TestAuthorizeServingRenewal(t *testing.T) {
 			for _, cert := range tt.ca {
 				certPool.AddCert(cert)
 			}
+			roots := []*x509.CertPool{certPool}
+			if len(tt.previousCA) > 0 {
+				previousPool := x509.NewCertPool()
+				for _, cert := range tt.previousCA {
+					previousPool.AddCert(cert)
+				}
+				roots = append(roots, previousPool)
+			}
 			err := authorizeServingRenewal(
+				ClusterMachineApproverConfig{NodeUser: tt.nodeUser}.nodeUserPrefix(),
 				tt.nodeName,
 				tt.csr,
 				tt.currentCert,
-				x509.VerifyOptions{Roots: certPool, CurrentTime: tt.time},
+				tt.machine,
+				newServingSANOptions(tt.servingCert, tt.node),
+				roots,
+				x509.VerifyOptions{CurrentTime: tt.time},
 			)
 
 			if errString(err) != tt.wantErr {
@@ -1806,11 +6245,14 @@ func TestAuthorizeServingRenewalWithEgressIPs(t *testing.T) {
 			cl := fake.NewFakeClient(objs...)
 
 			err := authorizeServingRenewalWithEgressIPs(
+				context.Background(),
 				cl,
+				"system:node:",
 				tt.nodeName,
 				tt.csr,
 				tt.currentCert,
-				x509.VerifyOptions{Roots: certPool, CurrentTime: tt.time},
+				[]*x509.CertPool{certPool},
+				x509.VerifyOptions{CurrentTime: tt.time},
 			)
 
 			if errString(err) != tt.wantErr {
@@ -1822,7 +6264,7 @@ func TestAuthorizeServingRenewalWithEgressIPs(t *testing.T) {
 
 func TestGetServingCert(t *testing.T) {
 	defaultPort := int32(25535)
-	defaultAddr := "127.0.0.1"
+	defaultAddr := "localhost"
 	defaultNode := &corev1.Node{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: "test",
@@ -1845,38 +6287,130 @@ func TestGetServingCert(t *testing.T) {
 	uninitialized := defaultNode.DeepCopy()
 	uninitialized.Status = corev1.NodeStatus{}
 
+	// The kubelet is only reachable on the second address.
+	unreachableFirstAddr := defaultNode.DeepCopy()
+	unreachableFirstAddr.Status.Addresses = []corev1.NodeAddress{
+		{Type: corev1.NodeInternalIP, Address: "127.0.0.2"},
+		{Type: corev1.NodeInternalIP, Address: defaultAddr},
+	}
+
+	reachableExternalAddr := defaultNode.DeepCopy()
+	reachableExternalAddr.Status.Addresses = []corev1.NodeAddress{
+		{Type: corev1.NodeExternalIP, Address: defaultAddr},
+		{Type: corev1.NodeInternalIP, Address: "127.0.0.2"},
+	}
+
+	unreachableAddrs := defaultNode.DeepCopy()
+	unreachableAddrs.Status.Addresses = []corev1.NodeAddress{
+		{Type: corev1.NodeInternalIP, Address: "127.0.0.2"},
+		{Type: corev1.NodeExternalIP, Address: "127.0.0.3"},
+	}
+
+	// The serving cert is issued for the node name, but not for the IP the
+	// kubelet is reached on.
+	ipAddr := defaultNode.DeepCopy()
+	ipAddr.Name = "node1"
+	ipAddr.Status.Addresses = []corev1.NodeAddress{
+		{Type: corev1.NodeInternalIP, Address: "127.0.0.1"},
+	}
+
 	tests := []struct {
-		name      string
-		nodeName  string
-		node      *corev1.Node
-		rootCerts []*x509.Certificate
-		wantErr   string
+		name         string
+		nodeName     string
+		node         *corev1.Node
+		rootCerts    []*x509.Certificate
+		verifyStaple bool
+		serverName   string
+		wantErr      string
 	}{
 		{
 			name:      "all good",
 			nodeName:  "test",
-			node:      defaultNode,
+			node:      defaultNode,
+			rootCerts: []*x509.Certificate{parseCert(t, rootCertGood)},
+		},
+		{
+			name:         "all good without OCSP staple",
+			nodeName:     "test",
+			node:         defaultNode,
+			rootCerts:    []*x509.Certificate{parseCert(t, rootCertGood)},
+			verifyStaple: true,
+		},
+		{
+			name:      "unknown certificate",
+			nodeName:  "test",
+			node:      defaultNode,
+			rootCerts: []*x509.Certificate{parseCert(t, differentCert)},
+			wantErr:   "x509: certificate signed by unknown authority",
+		},
+		{
+			name:      "node not found",
+			nodeName:  "test",
+			rootCerts: []*x509.Certificate{parseCert(t, rootCertGood)},
+			wantErr:   "nodes \"test\" not found",
+		},
+		{
+			name:      "wrong address",
+			nodeName:  "test",
+			node:      wrongAddr,
+			rootCerts: []*x509.Certificate{parseCert(t, rootCertGood)},
+			wantErr:   "dial tcp 127.0.0.1:25544: connect: connection refused",
+		},
+		{
+			name:      "unreachable first address",
+			nodeName:  "test",
+			node:      unreachableFirstAddr,
 			rootCerts: []*x509.Certificate{parseCert(t, rootCertGood)},
 		},
 		{
-			name:      "unknown certificate",
+			name:      "reachable external address",
 			nodeName:  "test",
-			node:      defaultNode,
-			rootCerts: []*x509.Certificate{parseCert(t, differentCert)},
-			wantErr:   "tls: failed to verify certificate: x509: certificate signed by unknown authority",
+			node:      reachableExternalAddr,
+			rootCerts: []*x509.Certificate{parseCert(t, rootCertGood)},
 		},
 		{
-			name:      "node not found",
+			name:      "all addresses unreachable",
 			nodeName:  "test",
+			node:      unreachableAddrs,
 			rootCerts: []*x509.Certificate{parseCert(t, rootCertGood)},
-			wantErr:   "nodes \"test\" not found",
+			wantErr:   "[dial tcp 127.0.0.2:25535: connect: connection refused, dial tcp 127.0.0.3:25535: connect: connection refused]",
 		},
 		{
-			name:      "wrong address",
-			nodeName:  "test",
-			node:      wrongAddr,
+			name:      "cert not issued for the address",
+			nodeName:  "node1",
+			node:      ipAddr,
 			rootCerts: []*x509.Certificate{parseCert(t, rootCertGood)},
-			wantErr:   "dial tcp 127.0.0.1:25544: connect: connection refused",
+			wantErr:   "x509: certificate is valid for 10.0.0.1, not 127.0.0.1",
+		},
+		{
+			name:       "cert verified against the node name",
+			nodeName:   "node1",
+			node:       ipAddr,
+			rootCerts:  []*x509.Certificate{parseCert(t, rootCertGood)},
+			serverName: kubeletServerNameNodeName,
+		},
+		{
+			name:       "cert not issued for the node name",
+			nodeName:   "test",
+			node:       defaultNode,
+			rootCerts:  []*x509.Certificate{parseCert(t, rootCertGood)},
+			serverName: kubeletServerNameNodeName,
+			wantErr:    "x509: certificate is valid for node1, node1.local, localhost, not test",
+		},
+		{
+			name:       "cert not verified against any name",
+			nodeName:   "node1",
+			node:       ipAddr,
+			rootCerts:  []*x509.Certificate{parseCert(t, rootCertGood)},
+			serverName: kubeletServerNameNone,
+		},
+		{
+			name:       "unknown certificate not verified against any name",
+			nodeName:   "node1",
+			node:       ipAddr,
+			rootCerts:  []*x509.Certificate{parseCert(t, differentCert)},
+			serverName: kubeletServerNameNone,
+			wantErr:    "x509: certificate signed by unknown authority",
 		},
 		{
 			name:     "no pool provided",
@@ -1889,7 +6423,7 @@ func TestGetServingCert(t *testing.T) {
 			nodeName:  "test",
 			node:      uninitialized,
 			rootCerts: []*x509.Certificate{parseCert(t, rootCertGood)},
-			wantErr:   "node test has no internal addresses",
+			wantErr:   "node test has no internal or external addresses",
 		},
 	}
 
@@ -1898,12 +6432,13 @@ func TestGetServingCert(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			var certPool *x509.CertPool
+			var certPools []*x509.CertPool
 			if len(tt.rootCerts) > 0 {
-				certPool = x509.NewCertPool()
+				certPool := x509.NewCertPool()
 				for _, cert := range tt.rootCerts {
 					certPool.AddCert(cert)
 				}
+				certPools = []*x509.CertPool{certPool}
 			}
 
 			objects := []runtime.Object{}
@@ -1913,7 +6448,7 @@ func TestGetServingCert(t *testing.T) {
 			cl := fake.NewFakeClient(objects...)
 
 			go respond(server)
-			serverCert, err := getServingCert(cl, tt.nodeName, certPool)
+			serverCert, err := getServingCert(context.Background(), cl, nil, tt.nodeName, certPools, tt.verifyStaple, tt.serverName, 0, defaultKubeletConnectTimeout, baseTime)
 			if errString(err) != tt.wantErr {
 				t.Fatalf("got: %v, want: %s", err, tt.wantErr)
 			}
@@ -1924,6 +6459,148 @@ func TestGetServingCert(t *testing.T) {
 	}
 }
 
+func TestGetServingCertTimeout(t *testing.T) {
+	// The listener accepts connections but never completes the TLS handshake.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "test"},
+		Status: corev1.NodeStatus{
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalIP, Address: "127.0.0.1"},
+			},
+			DaemonEndpoints: corev1.NodeDaemonEndpoints{
+				KubeletEndpoint: corev1.DaemonEndpoint{
+					Port: int32(listener.Addr().(*net.TCPAddr).Port),
+				},
+			},
+		},
+	}
+	certPool := x509.NewCertPool()
+	certPool.AddCert(parseCert(t, rootCertGood))
+
+	start := time.Now()
+	if _, err := getServingCert(context.Background(), fake.NewFakeClient(node), nil, "test", []*x509.CertPool{certPool}, false, "", 0, 100*time.Millisecond, baseTime); err == nil {
+		t.Errorf("expected the connection to time out")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected connection to time out quickly, took %s", elapsed)
+	}
+}
+
+func TestGetServingCertCancelled(t *testing.T) {
+	// The listener accepts connections but never completes the TLS handshake.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "test"},
+		Status: corev1.NodeStatus{
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalIP, Address: "127.0.0.1"},
+				{Type: corev1.NodeInternalIP, Address: "127.0.0.2"},
+			},
+			DaemonEndpoints: corev1.NodeDaemonEndpoints{
+				KubeletEndpoint: corev1.DaemonEndpoint{
+					Port: int32(listener.Addr().(*net.TCPAddr).Port),
+				},
+			},
+		},
+	}
+	certPool := x509.NewCertPool()
+	certPool.AddCert(parseCert(t, rootCertGood))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := getServingCert(ctx, fake.NewFakeClient(node), nil, "test", []*x509.CertPool{certPool}, false, "", 0, time.Minute, baseTime); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the dial to be cancelled, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the dial to be cancelled quickly, took %s", elapsed)
+	}
+}
+
+func TestGetServingCertNoKubeletPort(t *testing.T) {
+	crt, err := tls.X509KeyPair([]byte(serverCertGood), []byte(serverKeyGood))
+	if err != nil {
+		t.Fatalf("failed to parse key pair: %v", err)
+	}
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{crt}})
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	// The node doesn't report its kubelet port yet.
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "test"},
+		Status: corev1.NodeStatus{
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalIP, Address: "localhost"},
+			},
+		},
+	}
+	certPool := x509.NewCertPool()
+	certPool.AddCert(parseCert(t, rootCertGood))
+
+	before := counterValue(t, kubeletDialFailuresTotal.WithLabelValues(dialFailureNoPort))
+	if _, err := getServingCert(context.Background(), fake.NewFakeClient(node), nil, "test", []*x509.CertPool{certPool}, false, "", 0, time.Second, baseTime); !errors.Is(err, errNoKubeletPort) {
+		t.Errorf("expected no kubelet port error, got: %v", err)
+	} else if want := "no kubelet port reported by node test"; err.Error() != want {
+		t.Errorf("expected error %q, got %q", want, err)
+	}
+	if after := counterValue(t, kubeletDialFailuresTotal.WithLabelValues(dialFailureNoPort)); after != before+1 {
+		t.Errorf("expected no port counter to be incremented from %v, got %v", before, after)
+	}
+
+	// The default port is used instead when configured.
+	defaultPort := listener.Addr().(*net.TCPAddr).Port
+	cert, err := getServingCert(context.Background(), fake.NewFakeClient(node), nil, "test", []*x509.CertPool{certPool}, false, "", defaultPort, time.Second, baseTime)
+	if err != nil {
+		t.Fatalf("getServingCert() error = %v", err)
+	}
+	if !cert.Equal(parseCert(t, serverCertGood)) {
+		t.Errorf("unexpected serving cert")
+	}
+}
+
 func TestRecentlyPendingNodeBootstrapperCSRs(t *testing.T) {
 	approvedNodeBootstrapperCSR := certificatesv1.CertificateSigningRequest{
 		Spec: certificatesv1.CertificateSigningRequestSpec{
@@ -1944,7 +6621,16 @@ func TestRecentlyPendingNodeBootstrapperCSRs(t *testing.T) {
 	}
 	pendingNodeServerCSR := certificatesv1.CertificateSigningRequest{
 		Spec: certificatesv1.CertificateSigningRequestSpec{
-			Username: nodeUserPrefix + "clustername-abcde-master-us-west-1a-0",
+			Username: "system:node:clustername-abcde-master-us-west-1a-0",
+		},
+	}
+	quarantinedNodeBootstrapperCSR := certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{quarantinedAnnotation: quarantineReasonSourceNetwork},
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Username: nodeBootstrapperUsername,
+			Groups:   nodeBootstrapperGroups.List(),
 		},
 	}
 	pendingCSR := certificatesv1.CertificateSigningRequest{}
@@ -1982,6 +6668,11 @@ func TestRecentlyPendingNodeBootstrapperCSRs(t *testing.T) {
 			csrs:          []certificatesv1.CertificateSigningRequest{createdAt(pendingTime, approvedNodeBootstrapperCSR)},
 			expectPending: 0,
 		},
+		{
+			name:          "recently quarantined csr",
+			csrs:          []certificatesv1.CertificateSigningRequest{createdAt(pendingTime, quarantinedNodeBootstrapperCSR)},
+			expectPending: 0,
+		},
 		{
 			name:          "pending past approval time",
 			csrs:          []certificatesv1.CertificateSigningRequest{createdAt(pastApprovalTime, pendingNodeBootstrapperCSR)},
@@ -2012,18 +6703,18 @@ func TestRecentlyPendingNodeBootstrapperCSRs(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if pending := recentlyPendingNodeCSRs(tt.csrs); pending != tt.expectPending {
+			if pending := recentlyPendingNodeCSRs(defaultNodeBootstrappers, "system:node:", tt.csrs, baseTime); pending != tt.expectPending {
 				t.Errorf("Expected %v pending CSRs, got: %v", tt.expectPending, pending)
 			}
 		})
 	}
 }
 
-func TestNodeInternalIP(t *testing.T) {
+func TestNodeKubeletIPs(t *testing.T) {
 	tests := []struct {
 		name    string
 		node    *corev1.Node
-		wantIP  string
+		wantIPs []string
 		wantErr string
 	}{
 		{
@@ -2036,7 +6727,7 @@ func TestNodeInternalIP(t *testing.T) {
 					Addresses: []corev1.NodeAddress{},
 				},
 			},
-			wantErr: "node no-addresses has no internal addresses",
+			wantErr: "node no-addresses has no internal or external addresses",
 		},
 		{
 			name: "no internal ip",
@@ -2050,7 +6741,7 @@ func TestNodeInternalIP(t *testing.T) {
 					},
 				},
 			},
-			wantErr: "node no-internal-ip has no internal addresses",
+			wantErr: "node no-internal-ip has no internal or external addresses",
 		},
 		{
 			name: "has internal ip",
@@ -2064,7 +6755,7 @@ func TestNodeInternalIP(t *testing.T) {
 					},
 				},
 			},
-			wantIP: "10.0.0.1",
+			wantIPs: []string{"10.0.0.1"},
 		},
 		{
 			name: "has ipv6 address",
@@ -2078,20 +6769,51 @@ func TestNodeInternalIP(t *testing.T) {
 					},
 				},
 			},
-			wantIP: "2600:1f18:4254:5100:ef8a:7b65:7782:9248",
+			wantIPs: []string{"2600:1f18:4254:5100:ef8a:7b65:7782:9248"},
+		},
+		{
+			name: "internal ips before external ips",
+			node: &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "multiple-addresses",
+				},
+				Status: corev1.NodeStatus{
+					Addresses: []corev1.NodeAddress{
+						{Type: corev1.NodeExternalIP, Address: "192.168.0.1"},
+						{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+						{Type: corev1.NodeHostName, Address: "host.example.com"},
+						{Type: corev1.NodeInternalIP, Address: "10.1.0.1"},
+					},
+				},
+			},
+			wantIPs: []string{"10.0.0.1", "10.1.0.1", "192.168.0.1"},
+		},
+		{
+			name: "external ip only",
+			node: &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "external-ip-only",
+				},
+				Status: corev1.NodeStatus{
+					Addresses: []corev1.NodeAddress{
+						{Type: corev1.NodeExternalIP, Address: "192.168.0.1"},
+					},
+				},
+			},
+			wantIPs: []string{"192.168.0.1"},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			ip, err := nodeInternalIP(tt.node)
+			ips, err := nodeKubeletIPs(tt.node)
 
 			if errString(err) != tt.wantErr {
 				t.Errorf("got: %v, want: %s", err, tt.wantErr)
 			}
 
-			if ip != tt.wantIP {
-				t.Errorf("got: %v, want: %s", err, tt.wantIP)
+			if !reflect.DeepEqual(ips, tt.wantIPs) {
+				t.Errorf("got: %v, want: %v", ips, tt.wantIPs)
 			}
 		})
 	}
@@ -2270,58 +6992,6 @@ func TestEqualURLs(t *testing.T) {
 	}
 }
 
-func TestEqualIPAddresses(t *testing.T) {
-	tenDotOne := net.ParseIP("10.0.0.1")
-	tenDotTwo := net.ParseIP("10.0.0.2")
-
-	tests := []struct {
-		name     string
-		a        []net.IP
-		b        []net.IP
-		expected bool
-	}{
-		{
-			name:     "both empty",
-			a:        []net.IP{},
-			b:        []net.IP{},
-			expected: true,
-		},
-		{
-			name:     "equal",
-			a:        []net.IP{tenDotOne, tenDotTwo},
-			b:        []net.IP{tenDotOne, tenDotTwo},
-			expected: true,
-		},
-		{
-			name:     "different order",
-			a:        []net.IP{tenDotTwo, tenDotOne},
-			b:        []net.IP{tenDotOne, tenDotTwo},
-			expected: true,
-		},
-		{
-			name:     "not equal",
-			a:        []net.IP{tenDotTwo},
-			b:        []net.IP{tenDotOne, tenDotTwo},
-			expected: false,
-		},
-		{
-			name:     "duplicates",
-			a:        []net.IP{tenDotOne, tenDotOne},
-			b:        []net.IP{tenDotOne, tenDotTwo},
-			expected: false,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if equal := equalIPAddresses(tt.a, tt.b); equal != tt.expected {
-				t.Errorf("%v == %v :: wanted %v, got %v",
-					tt.a, tt.b, tt.expected, equal)
-			}
-		})
-	}
-}
-
 func TestSubsetIPAddresses(t *testing.T) {
 	tenDotOne := net.ParseIP("10.0.0.1")
 	tenDotTwo := net.ParseIP("10.0.0.2")
@@ -2482,3 +7152,229 @@ func respond(server net.Listener) {
 		conn.Write([]byte(server.Addr().String()))
 	}
 }
+
+func TestAuthorizeCSRAmbiguousMachine(t *testing.T) {
+	servingCSR := createCSR("system:node:panda", defaultOrgs, nil, []string{"panda"})
+	servingReq := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "panda-serving-csr"},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageServerAuth,
+			},
+			Username: "system:node:panda",
+			Groups: []string{
+				"system:authenticated",
+				"system:nodes",
+			},
+			Request: []byte(servingCSR),
+		},
+	}
+	clientReq := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "panda-client-csr",
+			CreationTimestamp: creationTimestamp(2 * time.Minute),
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageKeyEncipherment,
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageClientAuth,
+			},
+			Username: nodeBootstrapperUsername,
+			Groups:   nodeBootstrapperGroups.List(),
+			Request:  []byte(clientGood),
+		},
+	}
+	machine := func(name string, nodeRef *corev1.ObjectReference) machinehandlerpkg.Machine {
+		return machinehandlerpkg.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				CreationTimestamp: creationTimestamp(0),
+			},
+			Status: machinehandlerpkg.MachineStatus{
+				NodeRef: nodeRef,
+				Addresses: []corev1.NodeAddress{
+					{Type: corev1.NodeInternalDNS, Address: "panda"},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		machines []machinehandlerpkg.Machine
+		req      *certificatesv1.CertificateSigningRequest
+		csr      string
+	}{
+		{
+			name: "serving CSR with two machines referencing the node",
+			machines: []machinehandlerpkg.Machine{
+				machine("panda-machine", &corev1.ObjectReference{Name: "panda"}),
+				machine("stale-panda-machine", &corev1.ObjectReference{Name: "panda"}),
+			},
+			req: servingReq,
+			csr: servingCSR,
+		},
+		{
+			name: "client CSR with two machines sharing the hostname",
+			machines: []machinehandlerpkg.Machine{
+				machine("panda-machine", nil),
+				machine("stale-panda-machine", nil),
+			},
+			req: clientReq,
+			csr: clientGood,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			approver := &CertificateApprover{NodeClient: fake.NewClientBuilder().Build()}
+			req := tt.req.DeepCopy()
+
+			machine, authorize, err := approver.authorizeCSR(context.Background(), tt.machines, req, parseCR(t, tt.csr), nil)
+			if authorize || machine != nil {
+				t.Errorf("authorizeCSR() = %v, machine = %v, want no approval", authorize, machine)
+			}
+			if !errors.Is(err, machinehandlerpkg.ErrAmbiguousMachine) {
+				t.Errorf("authorizeCSR() error = %v, want ambiguous machine error to requeue", err)
+			}
+			if got := req.Annotations[denialReasonAnnotation]; got != decisionReasonAmbiguousMachine {
+				t.Errorf("expected denial reason %q, got %q", decisionReasonAmbiguousMachine, got)
+			}
+		})
+	}
+}
+
+func TestAuthorizeCSRNodeBootstrappers(t *testing.T) {
+	customUsername := "system:serviceaccount:hosted-control-plane:node-bootstrapper"
+	customGroups := []string{
+		"system:serviceaccounts:hosted-control-plane",
+		"system:serviceaccounts",
+		"system:authenticated",
+	}
+	machines := []machinehandlerpkg.Machine{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "panda-machine",
+			CreationTimestamp: creationTimestamp(0),
+		},
+		Status: machinehandlerpkg.MachineStatus{
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalDNS, Address: "panda"},
+			},
+		},
+	}}
+	req := func(username string, groups []string) *certificatesv1.CertificateSigningRequest {
+		return &certificatesv1.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "panda-csr",
+				CreationTimestamp: creationTimestamp(2 * time.Minute),
+			},
+			Spec: certificatesv1.CertificateSigningRequestSpec{
+				Usages: []certificatesv1.KeyUsage{
+					certificatesv1.UsageKeyEncipherment,
+					certificatesv1.UsageDigitalSignature,
+					certificatesv1.UsageClientAuth,
+				},
+				Username: username,
+				Groups:   groups,
+				Request:  []byte(clientGood),
+			},
+		}
+	}
+	custom := []NodeBootstrapper{{Username: customUsername, Groups: customGroups}}
+	guestUsername := "system:serviceaccount:guest-mco:node-bootstrapper"
+	guestGroups := []string{
+		"system:authenticated",
+		"system:serviceaccounts",
+		"system:serviceaccounts:guest-mco",
+	}
+	namespaces := []string{"hosted-control-plane", "guest-mco"}
+
+	tests := []struct {
+		name          string
+		bootstrappers []NodeBootstrapper
+		namespaces    []string
+		req           *certificatesv1.CertificateSigningRequest
+		authorize     bool
+	}{
+		{
+			name:      "default bootstrapper",
+			req:       req(nodeBootstrapperUsername, nodeBootstrapperGroups.List()),
+			authorize: true,
+		},
+		{
+			name: "custom bootstrapper not configured",
+			req:  req(customUsername, customGroups),
+		},
+		{
+			name:          "custom bootstrapper",
+			bootstrappers: custom,
+			req:           req(customUsername, customGroups),
+			authorize:     true,
+		},
+		{
+			name:          "default bootstrapper not configured",
+			bootstrappers: custom,
+			req:           req(nodeBootstrapperUsername, nodeBootstrapperGroups.List()),
+		},
+		{
+			name:          "custom bootstrapper with other groups",
+			bootstrappers: custom,
+			req:           req(customUsername, append(customGroups, "system:masters")),
+		},
+		{
+			name:          "unknown bootstrapper",
+			bootstrappers: custom,
+			req:           req("system:serviceaccount:panda:node-bootstrapper", customGroups),
+		},
+		{
+			name:       "machine config operator namespace",
+			namespaces: namespaces,
+			req:        req(customUsername, customGroups),
+			authorize:  true,
+		},
+		{
+			name:       "other machine config operator namespace",
+			namespaces: namespaces,
+			req:        req(guestUsername, guestGroups),
+			authorize:  true,
+		},
+		{
+			name:       "machine config operator namespace with groups of another",
+			namespaces: namespaces,
+			req:        req(guestUsername, customGroups),
+		},
+		{
+			name:       "default namespace not configured",
+			namespaces: namespaces,
+			req:        req(nodeBootstrapperUsername, nodeBootstrapperGroups.List()),
+		},
+		{
+			name:          "machine config operator namespaces and bootstrappers",
+			bootstrappers: []NodeBootstrapper{{Username: nodeBootstrapperUsername, Groups: nodeBootstrapperGroups.List()}},
+			namespaces:    []string{"guest-mco"},
+			req:           req(nodeBootstrapperUsername, nodeBootstrapperGroups.List()),
+			authorize:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			approver := &CertificateApprover{
+				NodeClient: fake.NewClientBuilder().Build(),
+				Config: ClusterMachineApproverConfig{
+					NodeClientCert: NodeClientCert{
+						Bootstrappers:                   tt.bootstrappers,
+						MachineConfigOperatorNamespaces: tt.namespaces,
+					},
+				},
+			}
+
+			_, authorize, err := approver.authorizeCSR(context.Background(), machines, tt.req, parseCR(t, clientGood), nil)
+			if authorize != tt.authorize || err != nil {
+				t.Errorf("authorizeCSR() = %v, error = %v, want %v", authorize, err, tt.authorize)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	// DecisionsPath is the path of the debug endpoint listing the last
+	// decisions taken on node CSRs.
+	DecisionsPath = "/debug/decisions"
+
+	defaultDecisionTraceSize = 100
+)
+
+// tracedDecision is a decision taken on a node CSR, as listed by the decisions
+// debug endpoint.
+type tracedDecision struct {
+	CSR            string    `json:"csr"`
+	Type           string    `json:"type"`
+	NodeName       string    `json:"nodeName"`
+	Decision       string    `json:"decision"`
+	Reason         string    `json:"reason"`
+	MatchedMachine string    `json:"matchedMachine,omitempty"`
+	Timestamp      time.Time `json:"timestamp"`
+}
+
+// decisionTrace remembers the last decisions taken on node CSRs, in a ring
+// buffer, so that they can be inspected without scraping logs. The zero value
+// is ready to use.
+type decisionTrace struct {
+	lock      sync.Mutex
+	decisions []tracedDecision
+	// next is the index the next decision is recorded at, once the buffer is
+	// full.
+	next int
+}
+
+// add records a decision, replacing the oldest one once size decisions are
+// recorded.
+func (t *decisionTrace) add(size int, decision tracedDecision) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if size <= 0 {
+		return
+	}
+	if len(t.decisions) < size {
+		t.decisions = append(t.decisions, decision)
+		return
+	}
+	t.decisions[t.next] = decision
+	t.next = (t.next + 1) % len(t.decisions)
+}
+
+// list returns the decisions recorded, oldest first.
+func (t *decisionTrace) list() []tracedDecision {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	decisions := make([]tracedDecision, 0, len(t.decisions))
+	decisions = append(decisions, t.decisions[t.next:]...)
+	return append(decisions, t.decisions[:t.next]...)
+}
+
+// DecisionsHandler returns an HTTP handler listing the last decisions taken on
+// node CSRs, oldest first, as JSON.
+func (m *CertificateApprover) DecisionsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(m.decisions.list()); err != nil {
+			klog.Errorf("Unable to write the decisions: %v", err)
+		}
+	})
+}
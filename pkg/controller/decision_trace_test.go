@@ -0,0 +1,143 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	testingclock "k8s.io/utils/clock/testing"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	machinehandlerpkg "github.com/openshift/cluster-machine-approver/pkg/machinehandler"
+)
+
+func TestDecisionTrace(t *testing.T) {
+	decision := func(i int) tracedDecision {
+		return tracedDecision{CSR: fmt.Sprintf("csr-%d", i)}
+	}
+
+	tests := []struct {
+		name  string
+		size  int
+		added int
+		want  []string
+	}{
+		{
+			name:  "empty",
+			size:  3,
+			added: 0,
+			want:  []string{},
+		},
+		{
+			name:  "not full",
+			size:  3,
+			added: 2,
+			want:  []string{"csr-0", "csr-1"},
+		},
+		{
+			name:  "full",
+			size:  3,
+			added: 3,
+			want:  []string{"csr-0", "csr-1", "csr-2"},
+		},
+		{
+			name:  "wrapped around",
+			size:  3,
+			added: 5,
+			want:  []string{"csr-2", "csr-3", "csr-4"},
+		},
+		{
+			name:  "wrapped around twice",
+			size:  3,
+			added: 7,
+			want:  []string{"csr-4", "csr-5", "csr-6"},
+		},
+		{
+			name:  "disabled",
+			size:  0,
+			added: 2,
+			want:  []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trace := &decisionTrace{}
+			for i := 0; i < tt.added; i++ {
+				trace.add(tt.size, decision(i))
+			}
+
+			got := []string{}
+			for _, decision := range trace.list() {
+				got = append(got, decision.CSR)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("list() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecisionsHandler(t *testing.T) {
+	machines := []machinehandlerpkg.Machine{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "panda-machine",
+			Namespace:         "openshift-machine-api",
+			CreationTimestamp: creationTimestamp(-5 * time.Minute),
+		},
+		Status: machinehandlerpkg.MachineStatus{
+			Addresses: []corev1.NodeAddress{{Type: corev1.NodeInternalDNS, Address: "panda"}},
+		},
+	}}
+	req := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "panda-csr",
+			CreationTimestamp: creationTimestamp(-time.Minute),
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageKeyEncipherment,
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageClientAuth,
+			},
+			Username: nodeBootstrapperUsername,
+			Groups:   nodeBootstrapperGroups.List(),
+			Request:  []byte(clientGood),
+		},
+	}
+	approver := &CertificateApprover{
+		NodeClient: fake.NewFakeClient(),
+		Recorder:   record.NewFakeRecorder(10),
+		Clock:      testingclock.NewFakePassiveClock(baseTime),
+		Config:     ClusterMachineApproverConfig{DecisionTrace: DecisionTrace{Size: pointer.Int(10)}},
+	}
+
+	for _, machines := range [][]machinehandlerpkg.Machine{machines, nil} {
+		result := approver.Authorize(context.Background(), machines, req.DeepCopy(), parseCR(t, clientGood), nil)
+		approver.logDecision(req, parseCR(t, clientGood), result, baseTime)
+	}
+	// CSRs that are not node CSRs are not traced.
+	approver.logDecision(req, parseCR(t, clientGood), AuthorizeResult{}, baseTime)
+
+	w := httptest.NewRecorder()
+	approver.DecisionsHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, DecisionsPath, nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d", w.Code, http.StatusOK)
+	}
+	timestamp := baseTime.UTC().Format(time.RFC3339)
+	want := `[{"csr":"panda-csr","type":"client","nodeName":"panda","decision":"approved","reason":"Machine","matchedMachine":"openshift-machine-api/panda-machine","timestamp":"` + timestamp + `"},` +
+		`{"csr":"panda-csr","type":"client","nodeName":"panda","decision":"errored","reason":"MachineNotFound","timestamp":"` + timestamp + `"}]` + "\n"
+	if got := w.Body.String(); got != want {
+		t.Errorf("got decisions:\n%s\nwant:\n%s", got, want)
+	}
+}
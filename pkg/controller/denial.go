@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"context"
+
+	machinehandlerpkg "github.com/openshift/cluster-machine-approver/pkg/machinehandler"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// denialReasonAnnotation is set on node CSRs that cannot be approved, with the
+// reason of the decision as its value. The decision reasons are stable so that
+// the annotation can be consumed by external tooling.
+const denialReasonAnnotation = "machineapprover.openshift.io/denial-reason"
+
+// denyReasonInvalidSignature is the reason of the Denied condition set on CSRs
+// whose signature doesn't match their public key. Other invalid CSRs are
+// denied with their decision reason.
+const denyReasonInvalidSignature = "InvalidSignature"
+
+// decide records the outcome of the evaluation of a node CSR, sets its denial
+// reason annotation when it is not authorized, and returns it along with the
+// machine the CSR was matched to, which is nil when not authorized.
+func (m *CertificateApprover) decide(ctx context.Context, req *certificatesv1.CertificateSigningRequest, kind, reason string, machine *machinehandlerpkg.Machine, authorize bool, err error) AuthorizeResult {
+	if authorize {
+		m.setDenialReason(ctx, req, "")
+	} else {
+		m.setDenialReason(ctx, req, reason)
+		machine = nil
+	}
+	authorize, err = recordDecision(kind, reason, authorize, err)
+	return AuthorizeResult{Authorized: authorize, Reason: reason, Machine: machine, Err: err}
+}
+
+// denyInvalid sets the Denied condition on a node CSR that can never be
+// approved, when DenyInvalidCSRs is set, so that it reaches a terminal state
+// instead of being left pending until it is garbage collected. It must only be
+// called for unrecoverable validation failures, never for transient ones.
+func (m *CertificateApprover) denyInvalid(req *certificatesv1.CertificateSigningRequest, reason string, cause error) {
+	if !m.Config.DenyInvalidCSRs || m.Config.AuditOnly {
+		return
+	}
+	// CSRs not requested by nodes may be handled by another approver.
+	if !isReqFromNodeBootstrapper(m.Config.NodeClientCert.bootstrappers(), req) && !isRequestFromNodeUser(m.Config.nodeUserPrefix(), *req) {
+		return
+	}
+
+	if err := deny(m.NodeRestCfg, req, reason, cause.Error()); err != nil {
+		klog.Errorf("%v: Failed to deny invalid CSR: %v", req.Name, err)
+		return
+	}
+	klog.Infof("%v: Invalid CSR denied: %s: %v", req.Name, reason, cause)
+}
+
+// setDenialReason sets the denial reason annotation of the CSR to the given
+// reason, or removes it when the reason is empty. The CSR is only patched when
+// the annotation changes, so that requeued CSRs are not patched again.
+func (m *CertificateApprover) setDenialReason(ctx context.Context, req *certificatesv1.CertificateSigningRequest, reason string) {
+	if req.Annotations[denialReasonAnnotation] == reason {
+		return
+	}
+
+	patch := client.MergeFrom(req.DeepCopy())
+	if reason == "" {
+		delete(req.Annotations, denialReasonAnnotation)
+	} else {
+		if req.Annotations == nil {
+			req.Annotations = map[string]string{}
+		}
+		req.Annotations[denialReasonAnnotation] = reason
+	}
+
+	if err := m.NodeClient.Patch(ctx, req, patch); err != nil {
+		klog.Errorf("%v: Failed to set denial reason %q: %v", req.Name, reason, err)
+	}
+}
@@ -0,0 +1,235 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	configv1 "github.com/openshift/api/config/v1"
+	machinehandlerpkg "github.com/openshift/cluster-machine-approver/pkg/machinehandler"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestSetDenialReason(t *testing.T) {
+	req := &certificatesv1.CertificateSigningRequest{ObjectMeta: metav1.ObjectMeta{Name: "panda-csr"}}
+	cl := fake.NewClientBuilder().WithObjects(req.DeepCopy()).Build()
+	approver := &CertificateApprover{NodeClient: cl}
+
+	get := func() *certificatesv1.CertificateSigningRequest {
+		got := &certificatesv1.CertificateSigningRequest{}
+		if err := cl.Get(context.Background(), client.ObjectKey{Name: req.Name}, got); err != nil {
+			t.Fatalf("failed to get CSR: %v", err)
+		}
+		return got
+	}
+
+	if err := cl.Get(context.Background(), client.ObjectKey{Name: req.Name}, req); err != nil {
+		t.Fatalf("failed to get CSR: %v", err)
+	}
+
+	approver.setDenialReason(context.Background(), req, decisionReasonMachineNotFound)
+	denied := get()
+	if reason := denied.Annotations[denialReasonAnnotation]; reason != decisionReasonMachineNotFound {
+		t.Errorf("denial reason = %q, want %q", reason, decisionReasonMachineNotFound)
+	}
+
+	// Requeued CSRs denied for the same reason are not patched again.
+	approver.setDenialReason(context.Background(), req, decisionReasonMachineNotFound)
+	if got := get(); got.ResourceVersion != denied.ResourceVersion {
+		t.Errorf("CSR patched again for the same reason, resource version %s, want %s", got.ResourceVersion, denied.ResourceVersion)
+	}
+
+	approver.setDenialReason(context.Background(), req, decisionReasonNodeExists)
+	if reason := get().Annotations[denialReasonAnnotation]; reason != decisionReasonNodeExists {
+		t.Errorf("denial reason = %q, want %q", reason, decisionReasonNodeExists)
+	}
+
+	approver.setDenialReason(context.Background(), req, "")
+	if reason, ok := get().Annotations[denialReasonAnnotation]; ok {
+		t.Errorf("denial reason %q not removed", reason)
+	}
+}
+
+func TestAuthorizeCSRSetsDenialReason(t *testing.T) {
+	req := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "panda-csr",
+			CreationTimestamp: creationTimestamp(-time.Minute),
+			Annotations:       map[string]string{denialReasonAnnotation: decisionReasonMachineNotFound},
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Request: []byte(clientGood),
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageKeyEncipherment,
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageClientAuth,
+			},
+			Username: nodeBootstrapperUsername,
+			Groups:   nodeBootstrapperGroups.List(),
+		},
+	}
+	machine := machinehandlerpkg.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "panda-machine",
+			CreationTimestamp: creationTimestamp(-5 * time.Minute),
+		},
+		Status: machinehandlerpkg.MachineStatus{
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalDNS, Address: "panda"},
+			},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		machines   []machinehandlerpkg.Machine
+		authorize  bool
+		wantErr    string
+		wantReason string
+	}{
+		{
+			name:       "no matching machine",
+			machines:   nil,
+			authorize:  false,
+			wantErr:    "failed to find machine for node panda",
+			wantReason: decisionReasonMachineNotFound,
+		},
+		{
+			name:       "approved after a denial",
+			machines:   []machinehandlerpkg.Machine{machine},
+			authorize:  true,
+			wantReason: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := req.DeepCopy()
+			cl := fake.NewClientBuilder().WithObjects(req.DeepCopy()).Build()
+			if err := cl.Get(context.Background(), client.ObjectKey{Name: req.Name}, req); err != nil {
+				t.Fatalf("failed to get CSR: %v", err)
+			}
+			approver := &CertificateApprover{NodeClient: cl}
+
+			_, authorize, err := approver.authorizeCSR(context.Background(), tt.machines, req, parseCR(t, clientGood), nil)
+			if authorize != tt.authorize || errString(err) != tt.wantErr {
+				t.Fatalf("authorizeCSR() = %v, error = %v, want %v, error %s", authorize, err, tt.authorize, tt.wantErr)
+			}
+
+			got := &certificatesv1.CertificateSigningRequest{}
+			if err := cl.Get(context.Background(), client.ObjectKey{Name: req.Name}, got); err != nil {
+				t.Fatalf("failed to get CSR: %v", err)
+			}
+			if reason := got.Annotations[denialReasonAnnotation]; reason != tt.wantReason {
+				t.Errorf("denial reason = %q, want %q", reason, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestAuthorizeCSRDenyInvalidCSRs(t *testing.T) {
+	servingCSR := createCSR("system:node:panda", defaultOrgs, nil, []string{"panda"})
+	servingReq := func(usages ...certificatesv1.KeyUsage) *certificatesv1.CertificateSigningRequest {
+		return &certificatesv1.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{Name: "panda-csr"},
+			Spec: certificatesv1.CertificateSigningRequestSpec{
+				Usages:   usages,
+				Username: "system:node:panda",
+				Groups: []string{
+					"system:authenticated",
+					"system:nodes",
+				},
+				Request: []byte(servingCSR),
+			},
+		}
+	}
+
+	tests := []struct {
+		name            string
+		config          ClusterMachineApproverConfig
+		req             *certificatesv1.CertificateSigningRequest
+		wantDenied      bool
+		wantDenyReason  string
+		wantDenyMessage string
+	}{
+		{
+			name:            "invalid usages",
+			config:          ClusterMachineApproverConfig{DenyInvalidCSRs: true},
+			req:             servingReq(certificatesv1.UsageServerAuth),
+			wantDenied:      true,
+			wantDenyReason:  decisionReasonInvalidRequest,
+			wantDenyMessage: "Too few usages",
+		},
+		{
+			name:   "disabled",
+			config: ClusterMachineApproverConfig{},
+			req:    servingReq(certificatesv1.UsageServerAuth),
+		},
+		{
+			name:   "audit only",
+			config: ClusterMachineApproverConfig{DenyInvalidCSRs: true, AuditOnly: true},
+			req:    servingReq(certificatesv1.UsageServerAuth),
+		},
+		{
+			// Failing to find the machine of the node is transient.
+			name:   "machine not found",
+			config: ClusterMachineApproverConfig{DenyInvalidCSRs: true},
+			req:    servingReq(certificatesv1.UsageDigitalSignature, certificatesv1.UsageServerAuth),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var denied *certificatesv1.CertificateSigningRequest
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPut || r.URL.Path != "/apis/certificates.k8s.io/v1/certificatesigningrequests/panda-csr/approval" {
+					t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				denied = &certificatesv1.CertificateSigningRequest{}
+				if err := json.NewDecoder(r.Body).Decode(denied); err != nil {
+					t.Errorf("failed to decode CSR: %v", err)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(denied)
+			}))
+			defer server.Close()
+
+			approver := &CertificateApprover{
+				NodeClient:  fake.NewClientBuilder().WithObjects(&configv1.Network{ObjectMeta: metav1.ObjectMeta{Name: "cluster"}}).Build(),
+				NodeRestCfg: &rest.Config{Host: server.URL},
+				Config:      tt.config,
+			}
+
+			if _, authorize, _ := approver.authorizeCSR(context.Background(), nil, tt.req, parseCR(t, servingCSR), nil); authorize {
+				t.Fatalf("authorizeCSR() = true, want false")
+			}
+
+			if !tt.wantDenied {
+				if denied != nil {
+					t.Errorf("CSR denied, want it left pending")
+				}
+				return
+			}
+			if denied == nil {
+				t.Fatalf("CSR not denied")
+			}
+			if len(denied.Status.Conditions) != 1 {
+				t.Fatalf("expected a single condition, got %v", denied.Status.Conditions)
+			}
+			condition := denied.Status.Conditions[0]
+			if condition.Type != certificatesv1.CertificateDenied || condition.Reason != tt.wantDenyReason || condition.Message != tt.wantDenyMessage {
+				t.Errorf("got condition %s/%s: %q, want %s/%s: %q", condition.Type, condition.Reason, condition.Message, certificatesv1.CertificateDenied, tt.wantDenyReason, tt.wantDenyMessage)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/pointer"
+)
+
+// ConfigPath is the path of the debug endpoint serving the effective config.
+const ConfigPath = "/debug/config"
+
+// Effective returns the config with the defaults of unset tunables applied, as
+// used by the approver. Tunables disabled when unset, e.g. the default kubelet
+// port, are left unset.
+func (c ClusterMachineApproverConfig) Effective() ClusterMachineApproverConfig {
+	clockSkew, timeWindow := c.clientCertTimeWindow()
+	c.ClockSkew = metav1.Duration{Duration: clockSkew}
+	c.ClientCertTimeWindow = metav1.Duration{Duration: timeWindow}
+	c.KubeletConnectTimeout = metav1.Duration{Duration: c.kubeletConnectTimeout()}
+	c.MinRSAKeyBits = pointer.Int(c.minRSAKeyBits())
+	c.AllowedKeyAlgorithms = c.allowedKeyAlgorithms()
+	if c.NodeUser == nil {
+		c.NodeUser = pointer.String(defaultNodeUser)
+	}
+
+	c.NodeClientCert.Enabled = pointer.Bool(c.NodeClientCert.enabled())
+	if len(c.NodeClientCert.Bootstrappers) == 0 && len(c.NodeClientCert.MachineConfigOperatorNamespaces) == 0 {
+		c.NodeClientCert.Bootstrappers = defaultNodeBootstrappers
+	}
+	if c.NodeClientCert.SourceNetwork.ExtraKey == "" {
+		c.NodeClientCert.SourceNetwork.ExtraKey = defaultSourceIPExtraKey
+	}
+
+	c.NodeServingCert.Enabled = pointer.Bool(c.NodeServingCert.enabled())
+	c.NodeServingCert.MaxSANsPerCSR = pointer.Int(c.NodeServingCert.maxSANsPerCSR())
+	c.NodeServingCert.ApprovalRateLimit.Window = metav1.Duration{Duration: c.NodeServingCert.ApprovalRateLimit.window()}
+	if c.NodeServingCert.KubeletServerName == "" {
+		c.NodeServingCert.KubeletServerName = kubeletServerNameAddress
+	}
+	c.NodeServingCert.CurrentCertCacheTTL = metav1.Duration{Duration: c.NodeServingCert.currentCertCacheTTL()}
+	c.NodeServingCert.NodeRefRefresh.Delay = metav1.Duration{Duration: c.NodeServingCert.NodeRefRefresh.delay()}
+	c.NodeServingCert.DNSAddressTypes = c.NodeServingCert.dnsAddressTypes()
+	c.NodeServingCert.IPAddressTypes = c.NodeServingCert.ipAddressTypes()
+	c.NodeServingCert.SANAddressSources = c.NodeServingCert.sanAddressSource()
+
+	if len(c.Quarantine.Reasons) == 0 {
+		c.Quarantine.Reasons = quarantineReasons
+	}
+	if c.Events.MaxPerMinute == nil {
+		c.Events.MaxPerMinute = pointer.Int(defaultMaxEventsPerMinute)
+	}
+	if c.Events.Burst == nil {
+		c.Events.Burst = pointer.Int(defaultEventBurst)
+	}
+	if c.LogRedaction.Mode == "" {
+		c.LogRedaction.Mode = redactionModeHash
+	}
+	if c.LogRedaction.TruncateLength == nil {
+		c.LogRedaction.TruncateLength = pointer.Int(defaultRedactionTruncateLength)
+	}
+	if c.ReconcileAll.MaxPerMinute == nil || *c.ReconcileAll.MaxPerMinute <= 0 {
+		c.ReconcileAll.MaxPerMinute = pointer.Int(defaultReconcileAllPerMinute)
+	}
+	if c.Retries.InitialBackoff.Duration <= 0 {
+		c.Retries.InitialBackoff = metav1.Duration{Duration: defaultRetryInitialBackoff}
+	}
+	if c.Retries.MaxBackoff.Duration <= 0 {
+		c.Retries.MaxBackoff = metav1.Duration{Duration: defaultRetryMaxBackoff}
+	}
+	c.MachineCache.TTL = metav1.Duration{Duration: c.MachineCache.ttl()}
+	c.MachineAddressWatch.Interval = metav1.Duration{Duration: c.MachineAddressWatch.interval()}
+	c.DecisionTrace.Size = pointer.Int(c.DecisionTrace.size())
+
+	return c
+}
+
+// LogEffectiveConfig logs the effective config, so that the tunables decisions
+// were taken with are known. The config holds no credentials, the clients of
+// the clusters being configured by flags.
+func LogEffectiveConfig(config ClusterMachineApproverConfig) {
+	effective, err := json.Marshal(config.Effective())
+	if err != nil {
+		klog.Errorf("Unable to serialize the effective config: %v", err)
+		return
+	}
+	klog.Infof("Effective config: %s", effective)
+}
+
+// ConfigHandler serves the effective config of the approver as JSON.
+func (m *CertificateApprover) ConfigHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(m.Config.Effective()); err != nil {
+			klog.Errorf("Unable to write the effective config: %v", err)
+		}
+	})
+}
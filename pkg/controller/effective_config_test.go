@@ -0,0 +1,207 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/utils/pointer"
+)
+
+func TestEffectiveConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config ClusterMachineApproverConfig
+		check  func(t *testing.T, effective ClusterMachineApproverConfig)
+	}{
+		{
+			name:   "defaults applied",
+			config: ClusterMachineApproverConfig{},
+			check: func(t *testing.T, effective ClusterMachineApproverConfig) {
+				if effective.KubeletConnectTimeout.Duration != defaultKubeletConnectTimeout {
+					t.Errorf("kubeletConnectTimeout = %s, want %s", effective.KubeletConnectTimeout.Duration, defaultKubeletConnectTimeout)
+				}
+				if *effective.NodeServingCert.MaxSANsPerCSR != defaultMaxSANsPerCSR {
+					t.Errorf("nodeServingCert.maxSANsPerCSR = %d, want %d", *effective.NodeServingCert.MaxSANsPerCSR, defaultMaxSANsPerCSR)
+				}
+				if !reflect.DeepEqual(effective.NodeClientCert.Bootstrappers, defaultNodeBootstrappers) {
+					t.Errorf("nodeClientCert.bootstrappers = %v, want %v", effective.NodeClientCert.Bootstrappers, defaultNodeBootstrappers)
+				}
+				if effective.NodeServingCert.SANAddressSources != sanAddressSourceMachineOnly {
+					t.Errorf("nodeServingCert.sanAddressSources = %s, want %s", effective.NodeServingCert.SANAddressSources, sanAddressSourceMachineOnly)
+				}
+				// Disabled when unset.
+				if effective.NodeServingCert.DefaultKubeletPort != nil {
+					t.Errorf("nodeServingCert.defaultKubeletPort = %d, want unset", *effective.NodeServingCert.DefaultKubeletPort)
+				}
+			},
+		},
+		{
+			name: "configured values kept",
+			config: ClusterMachineApproverConfig{
+				KubeletConnectTimeout: metav1.Duration{Duration: time.Second},
+				NodeServingCert: NodeServingCert{
+					MaxSANsPerCSR:       pointer.Int(5),
+					PreferNodeAddresses: true,
+				},
+				NodeClientCert: NodeClientCert{
+					MachineConfigOperatorNamespaces: []string{"hosted"},
+				},
+			},
+			check: func(t *testing.T, effective ClusterMachineApproverConfig) {
+				if effective.KubeletConnectTimeout.Duration != time.Second {
+					t.Errorf("kubeletConnectTimeout = %s, want 1s", effective.KubeletConnectTimeout.Duration)
+				}
+				if *effective.NodeServingCert.MaxSANsPerCSR != 5 {
+					t.Errorf("nodeServingCert.maxSANsPerCSR = %d, want 5", *effective.NodeServingCert.MaxSANsPerCSR)
+				}
+				// The default bootstrappers are replaced by those of the
+				// namespaces.
+				if len(effective.NodeClientCert.Bootstrappers) != 0 {
+					t.Errorf("nodeClientCert.bootstrappers = %v, want none", effective.NodeClientCert.Bootstrappers)
+				}
+				if effective.NodeServingCert.SANAddressSources != sanAddressSourceUnion {
+					t.Errorf("nodeServingCert.sanAddressSources = %s, want %s", effective.NodeServingCert.SANAddressSources, sanAddressSourceUnion)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			effective := tt.config.Effective()
+			tt.check(t, effective)
+			if err := effective.Validate(); err != nil {
+				t.Errorf("effective config is invalid: %v", err)
+			}
+		})
+	}
+}
+
+func TestConfigHandler(t *testing.T) {
+	// Every tunable is set, so that none is omitted when serialized.
+	config := ClusterMachineApproverConfig{}
+	fillValue(reflect.ValueOf(&config).Elem())
+
+	approver := &CertificateApprover{
+		Config:         config,
+		MachineRestCfg: &rest.Config{Host: "https://management", BearerToken: "management-token"},
+		NodeRestCfg:    &rest.Config{Host: "https://workload", Password: "workload-password"},
+	}
+	w := httptest.NewRecorder()
+	approver.ConfigHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, ConfigPath, nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want %d", w.Code, http.StatusOK)
+	}
+	body := w.Body.String()
+	for _, secret := range []string{"management-token", "workload-password"} {
+		if strings.Contains(body, secret) {
+			t.Errorf("served config contains credential %q: %s", secret, body)
+		}
+	}
+
+	served := map[string]interface{}{}
+	if err := json.Unmarshal(w.Body.Bytes(), &served); err != nil {
+		t.Fatalf("failed to unmarshal served config: %v", err)
+	}
+	for _, field := range jsonFields(reflect.TypeOf(config), "") {
+		if !hasJSONField(served, field) {
+			t.Errorf("served config is missing %s", field)
+		}
+	}
+
+	got := ClusterMachineApproverConfig{}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("failed to unmarshal served config: %v", err)
+	}
+	if want := config.Effective(); !reflect.DeepEqual(got, want) {
+		t.Errorf("served config = %+v, want %+v", got, want)
+	}
+}
+
+// fillValue sets every exported field of the value to a non-zero value.
+func fillValue(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				fillValue(v.Field(i))
+			}
+		}
+	case reflect.Ptr:
+		v.Set(reflect.New(v.Type().Elem()))
+		fillValue(v.Elem())
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), 1, 1))
+		fillValue(v.Index(0))
+	case reflect.Map:
+		v.Set(reflect.MakeMap(v.Type()))
+		key, value := reflect.New(v.Type().Key()).Elem(), reflect.New(v.Type().Elem()).Elem()
+		fillValue(key)
+		fillValue(value)
+		v.SetMapIndex(key, value)
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.String:
+		v.SetString("a")
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		v.SetInt(1)
+	case reflect.Float64:
+		v.SetFloat(0.5)
+	}
+}
+
+// jsonFields returns the paths of the JSON fields of the config type,
+// including those of nested config types.
+func jsonFields(t reflect.Type, prefix string) []string {
+	var fields []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		path := prefix + name
+		fields = append(fields, path)
+
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Ptr || fieldType.Kind() == reflect.Slice {
+			fieldType = fieldType.Elem()
+		}
+		if fieldType.Kind() == reflect.Struct && fieldType.PkgPath() == t.PkgPath() {
+			fields = append(fields, jsonFields(fieldType, path+".")...)
+		}
+	}
+	return fields
+}
+
+// hasJSONField returns whether the field at the path is set in the decoded
+// JSON, looking into the first element of lists.
+func hasJSONField(value interface{}, path string) bool {
+	name, rest, nested := strings.Cut(path, ".")
+	if list, ok := value.([]interface{}); ok {
+		if len(list) == 0 {
+			return false
+		}
+		value = list[0]
+	}
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	field, ok := object[name]
+	if !ok {
+		return false
+	}
+	if !nested {
+		return true
+	}
+	return hasJSONField(field, rest)
+}
@@ -0,0 +1,45 @@
+package controller
+
+import "errors"
+
+// Kinds of the errors CSRs are not authorized with, for callers to tell them
+// apart with errors.Is rather than by their message.
+var (
+	// ErrTransientAPI is an API request failing, which may succeed when
+	// retried.
+	ErrTransientAPI = errors.New("transient API error")
+	// ErrNoMatchingMachine is no machine matching the node of a CSR, e.g.
+	// before the machine is created or linked to its node.
+	ErrNoMatchingMachine = errors.New("no matching machine")
+	// ErrSANMismatch is a serving CSR requesting names that are not addresses
+	// of the machine of its node.
+	ErrSANMismatch = errors.New("SAN mismatch")
+	// ErrMachineNotReady is the machine of a CSR not being ready for it yet,
+	// e.g. too recent, in a phase not allowed or without addresses.
+	ErrMachineNotReady = errors.New("machine not ready")
+	// ErrFlowDisabled is the approval of the kind of a CSR being disabled.
+	ErrFlowDisabled = errors.New("flow disabled")
+)
+
+// kindError marks an error with its kind, keeping its message.
+type kindError struct {
+	kind error
+	err  error
+}
+
+// withKind returns the error marked with the given kind.
+func withKind(kind, err error) error {
+	return &kindError{kind: kind, err: err}
+}
+
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+func (e *kindError) Is(target error) bool {
+	return target == e.kind
+}
+
+func (e *kindError) Unwrap() error {
+	return e.err
+}
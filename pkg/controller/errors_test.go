@@ -0,0 +1,187 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	configv1 "github.com/openshift/api/config/v1"
+	machinehandlerpkg "github.com/openshift/cluster-machine-approver/pkg/machinehandler"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testingclock "k8s.io/utils/clock/testing"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestWithKind(t *testing.T) {
+	cause := errors.New("panda")
+	err := withKind(ErrTransientAPI, fmt.Errorf("failed to get node: %w", cause))
+
+	if err.Error() != "failed to get node: panda" {
+		t.Errorf("Error() = %q, want the message kept", err)
+	}
+	if !errors.Is(err, ErrTransientAPI) {
+		t.Errorf("expected error to be a transient API error")
+	}
+	if !errors.Is(err, cause) {
+		t.Errorf("expected error to wrap its cause")
+	}
+	if errors.Is(err, ErrNoMatchingMachine) {
+		t.Errorf("expected error not to be of another kind")
+	}
+	if !errors.Is(fmt.Errorf("could not authorize CSR: %w", err), ErrTransientAPI) {
+		t.Errorf("expected wrapped error to be a transient API error")
+	}
+}
+
+func TestAuthorizeErrorKinds(t *testing.T) {
+	clientReq := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "panda-csr",
+			CreationTimestamp: creationTimestamp(-time.Minute),
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Request: []byte(clientGood),
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageKeyEncipherment,
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageClientAuth,
+			},
+			Username: nodeBootstrapperUsername,
+			Groups:   nodeBootstrapperGroups.List(),
+		},
+	}
+	servingCSR := createCSR("system:node:panda", defaultOrgs, []net.IP{net.ParseIP("10.0.0.1")}, []string{"panda"})
+	servingReq := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "panda-serving-csr"},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageServerAuth,
+			},
+			Username: "system:node:panda",
+			Groups: []string{
+				"system:authenticated",
+				"system:nodes",
+			},
+			Request: []byte(servingCSR),
+		},
+	}
+	machine := func(nodeRef *corev1.ObjectReference, address string) []machinehandlerpkg.Machine {
+		return []machinehandlerpkg.Machine{{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "panda-machine",
+				CreationTimestamp: creationTimestamp(-2 * time.Minute),
+			},
+			Status: machinehandlerpkg.MachineStatus{
+				NodeRef: nodeRef,
+				Addresses: []corev1.NodeAddress{
+					{Type: corev1.NodeInternalDNS, Address: "panda"},
+					{Type: corev1.NodeInternalIP, Address: address},
+				},
+			},
+		}}
+	}
+	failingNodeGets := interceptor.Funcs{
+		Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+			if _, ok := obj.(*corev1.Node); ok {
+				return errors.New("connection refused")
+			}
+			return c.Get(ctx, key, obj, opts...)
+		},
+	}
+	network := &configv1.Network{ObjectMeta: metav1.ObjectMeta{Name: "cluster"}}
+
+	tests := []struct {
+		name      string
+		req       *certificatesv1.CertificateSigningRequest
+		csr       string
+		machines  []machinehandlerpkg.Machine
+		config    ClusterMachineApproverConfig
+		intercept interceptor.Funcs
+		wantKind  error
+		wantErr   string
+	}{
+		{
+			name:      "node lookup failure",
+			req:       clientReq,
+			csr:       clientGood,
+			machines:  machine(nil, "10.0.0.1"),
+			intercept: failingNodeGets,
+			wantKind:  ErrTransientAPI,
+			wantErr:   "failed get existing nodes panda",
+		},
+		{
+			name:     "no machine for client CSR",
+			req:      clientReq,
+			csr:      clientGood,
+			wantKind: ErrNoMatchingMachine,
+			wantErr:  "failed to find machine for node panda",
+		},
+		{
+			name:     "machine too recent",
+			req:      clientReq,
+			csr:      clientGood,
+			machines: machine(nil, "10.0.0.1"),
+			config: ClusterMachineApproverConfig{
+				NodeClientCert: NodeClientCert{MinMachineAge: metav1.Duration{Duration: time.Hour}},
+			},
+			wantKind: ErrMachineNotReady,
+			wantErr:  "machine panda-machine created 2m0s ago, below minimum age 1h0m0s",
+		},
+		{
+			name:     "client flow disabled",
+			req:      clientReq,
+			csr:      clientGood,
+			machines: machine(nil, "10.0.0.1"),
+			config: ClusterMachineApproverConfig{
+				NodeClientCert: NodeClientCert{Enabled: pointer.Bool(false)},
+			},
+			wantKind: ErrFlowDisabled,
+			wantErr:  "CSR panda-csr for node client cert rejected as the flow is disabled",
+		},
+		{
+			name:     "no machine for serving CSR",
+			req:      servingReq,
+			csr:      servingCSR,
+			wantKind: ErrNoMatchingMachine,
+			wantErr:  "could not authorize CSR: exhausted all authorization methods: Unable to find machine for node",
+		},
+		{
+			name:     "serving CSR not matching machine addresses",
+			req:      servingReq,
+			csr:      servingCSR,
+			machines: machine(&corev1.ObjectReference{Name: "panda"}, "10.0.0.2"),
+			wantKind: ErrSANMismatch,
+			wantErr:  "could not authorize CSR: exhausted all authorization methods: IP address '10.0.0.1' not in machine addresses: 10.0.0.2",
+		},
+	}
+
+	kinds := []error{ErrTransientAPI, ErrNoMatchingMachine, ErrSANMismatch, ErrMachineNotReady, ErrFlowDisabled}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			approver := &CertificateApprover{
+				NodeClient: fake.NewClientBuilder().WithObjects(network).WithInterceptorFuncs(tt.intercept).Build(),
+				Clock:      testingclock.NewFakePassiveClock(baseTime),
+				Config:     tt.config,
+			}
+
+			got := approver.Authorize(context.Background(), tt.machines, tt.req, parseCR(t, tt.csr), nil)
+			if got.Authorized || errString(got.Err) != tt.wantErr {
+				t.Fatalf("Authorize() = %+v, want error %q", got, tt.wantErr)
+			}
+			for _, kind := range kinds {
+				if is := errors.Is(got.Err, kind); is != (kind == tt.wantKind) {
+					t.Errorf("errors.Is(%v, %v) = %v", got.Err, kind, is)
+				}
+			}
+		})
+	}
+}
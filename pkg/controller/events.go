@@ -0,0 +1,136 @@
+package controller
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/v2"
+)
+
+const (
+	defaultMaxEventsPerMinute = 60
+	defaultEventBurst         = 20
+
+	// eventCoalesceWindow is the period during which identical events are
+	// only recorded once.
+	eventCoalesceWindow = time.Minute
+)
+
+// Reasons of the events recorded for the decisions taken on CSRs.
+const (
+	csrApprovedEventReason    = "CSRApproved"
+	csrDeniedEventReason      = "CSRDenied"
+	csrQuarantinedEventReason = "CSRQuarantined"
+
+	csrApprovalPausedEventReason = "CSRApprovalPaused"
+
+	csrRenewalFallbackEventReason = "CSRRenewalFallback"
+
+	csrRetriesExhaustedEventReason = "CSRRetriesExhausted"
+)
+
+// eventf records an event on the given object, if an event recorder is set.
+// Events on CSRs, which are cluster scoped, are recorded in the default
+// namespace.
+func (m *CertificateApprover) eventf(obj runtime.Object, eventType, reason, messageFmt string, args ...interface{}) {
+	if m.Recorder == nil {
+		return
+	}
+
+	message := fmt.Sprintf(messageFmt, args...)
+	allowed, suppressed := m.events.allow(m.Config.Events, eventKey{eventType: eventType, reason: reason, message: message}, m.clock().Now())
+	if !allowed {
+		klog.V(4).Infof("Event %s suppressed: %s", reason, message)
+		suppressedEventsTotal.WithLabelValues(eventType).Inc()
+		return
+	}
+	if suppressed > 0 {
+		message = fmt.Sprintf("%s (%d identical events suppressed)", message, suppressed)
+	}
+
+	m.Recorder.Event(obj, eventType, reason, message)
+}
+
+// eventKey identifies identical events.
+type eventKey struct {
+	eventType string
+	reason    string
+	message   string
+}
+
+type coalescedEvent struct {
+	lastRecorded time.Time
+	lastSeen     time.Time
+	suppressed   int
+}
+
+// eventLimiter limits the rate at which events are recorded, and coalesces
+// identical events. Warning events, such as CSRs being declined for security
+// reasons, take priority over normal events when the rate limit is reached.
+// The zero value is ready to use.
+type eventLimiter struct {
+	lock    sync.Mutex
+	limiter *rate.Limiter
+	events  map[eventKey]*coalescedEvent
+}
+
+// allow returns true if the event may be recorded, along with the number of
+// identical events suppressed since it was last recorded.
+func (l *eventLimiter) allow(config Events, key eventKey, currentTime time.Time) (bool, int) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.limiter == nil {
+		maxPerMinute, burst := defaultMaxEventsPerMinute, defaultEventBurst
+		if config.MaxPerMinute != nil {
+			maxPerMinute = *config.MaxPerMinute
+		}
+		if config.Burst != nil {
+			burst = *config.Burst
+		}
+		l.limiter = rate.NewLimiter(rate.Limit(float64(maxPerMinute)/time.Minute.Seconds()), burst)
+		l.events = map[eventKey]*coalescedEvent{}
+	}
+
+	if l.limiter.Limit() <= 0 {
+		// Events are disabled.
+		return false, 0
+	}
+
+	for k, e := range l.events {
+		if currentTime.Sub(e.lastSeen) > eventCoalesceWindow {
+			delete(l.events, k)
+		}
+	}
+
+	event, ok := l.events[key]
+	if !ok {
+		event = &coalescedEvent{}
+		l.events[key] = event
+	}
+	event.lastSeen = currentTime
+
+	if !event.lastRecorded.IsZero() && currentTime.Sub(event.lastRecorded) < eventCoalesceWindow {
+		event.suppressed++
+		return false, 0
+	}
+
+	// Keep part of the burst for warning events.
+	if key.eventType != corev1.EventTypeWarning && l.limiter.TokensAt(currentTime) < float64(l.limiter.Burst()/4+1) {
+		event.suppressed++
+		return false, 0
+	}
+	if !l.limiter.AllowN(currentTime, 1) {
+		event.suppressed++
+		return false, 0
+	}
+
+	suppressed := event.suppressed
+	event.lastRecorded = currentTime
+	event.suppressed = 0
+	return true, suppressed
+}
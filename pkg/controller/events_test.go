@@ -0,0 +1,293 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	machinehandlerpkg "github.com/openshift/cluster-machine-approver/pkg/machinehandler"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	testingclock "k8s.io/utils/clock/testing"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestEventLimiterCoalescing(t *testing.T) {
+	clock := testingclock.NewFakePassiveClock(baseTime)
+	limiter := &eventLimiter{}
+	key := eventKey{eventType: corev1.EventTypeWarning, reason: "Declined", message: "node panda"}
+	otherKey := eventKey{eventType: corev1.EventTypeWarning, reason: "Declined", message: "node bamboo"}
+
+	if allowed, suppressed := limiter.allow(Events{}, key, clock.Now()); !allowed || suppressed != 0 {
+		t.Errorf("expected first event to be allowed, got allowed: %v, suppressed: %d", allowed, suppressed)
+	}
+	for i := 0; i < 3; i++ {
+		clock.SetTime(clock.Now().Add(time.Second))
+		if allowed, _ := limiter.allow(Events{}, key, clock.Now()); allowed {
+			t.Errorf("expected identical event to be coalesced")
+		}
+	}
+	if allowed, _ := limiter.allow(Events{}, otherKey, clock.Now()); !allowed {
+		t.Errorf("expected different event to be allowed")
+	}
+
+	clock.SetTime(clock.Now().Add(eventCoalesceWindow))
+	if allowed, suppressed := limiter.allow(Events{}, key, clock.Now()); !allowed || suppressed != 3 {
+		t.Errorf("expected event to be allowed after coalesce window with 3 suppressed, got allowed: %v, suppressed: %d", allowed, suppressed)
+	}
+}
+
+func TestEventLimiterRateLimit(t *testing.T) {
+	config := Events{MaxPerMinute: pointer.Int(1), Burst: pointer.Int(4)}
+	limiter := &eventLimiter{}
+
+	normal := func(i int) eventKey {
+		return eventKey{eventType: corev1.EventTypeNormal, reason: "Approved", message: strings.Repeat("a", i)}
+	}
+	warning := func(i int) eventKey {
+		return eventKey{eventType: corev1.EventTypeWarning, reason: "Declined", message: strings.Repeat("w", i)}
+	}
+
+	// A quarter of the burst is kept for warning events.
+	for i := 1; i <= 3; i++ {
+		if allowed, _ := limiter.allow(config, normal(i), baseTime); !allowed {
+			t.Errorf("expected normal event %d to be allowed", i)
+		}
+	}
+	if allowed, _ := limiter.allow(config, normal(4), baseTime); allowed {
+		t.Errorf("expected normal event to be rate limited")
+	}
+	if allowed, _ := limiter.allow(config, warning(1), baseTime); !allowed {
+		t.Errorf("expected warning event to be allowed")
+	}
+	if allowed, _ := limiter.allow(config, warning(2), baseTime); allowed {
+		t.Errorf("expected warning event to be rate limited")
+	}
+}
+
+func TestEventLimiterDisabled(t *testing.T) {
+	limiter := &eventLimiter{}
+	key := eventKey{eventType: corev1.EventTypeWarning, reason: "Declined", message: "node panda"}
+	if allowed, _ := limiter.allow(Events{MaxPerMinute: pointer.Int(0)}, key, baseTime); allowed {
+		t.Errorf("expected events to be disabled")
+	}
+}
+
+func TestEventf(t *testing.T) {
+	clock := testingclock.NewFakePassiveClock(baseTime)
+	recorder := record.NewFakeRecorder(10)
+	approver := &CertificateApprover{Recorder: recorder, Clock: clock}
+	csr := &certificatesv1.CertificateSigningRequest{ObjectMeta: metav1.ObjectMeta{Name: "panda-csr"}}
+
+	approver.eventf(csr, corev1.EventTypeWarning, "Declined", "node %s", "panda")
+	approver.eventf(csr, corev1.EventTypeWarning, "Declined", "node %s", "panda")
+	clock.SetTime(clock.Now().Add(eventCoalesceWindow))
+	approver.eventf(csr, corev1.EventTypeWarning, "Declined", "node %s", "panda")
+
+	want := []string{
+		"Warning Declined node panda",
+		"Warning Declined node panda (1 identical events suppressed)",
+	}
+	for _, w := range want {
+		select {
+		case event := <-recorder.Events:
+			if event != w {
+				t.Errorf("got event %q, want %q", event, w)
+			}
+		default:
+			t.Errorf("expected event %q", w)
+		}
+	}
+	select {
+	case event := <-recorder.Events:
+		t.Errorf("unexpected event %q", event)
+	default:
+	}
+}
+
+func TestAuthorizeCSRDeniedEvents(t *testing.T) {
+	clientReq := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "panda-csr",
+			CreationTimestamp: creationTimestamp(2 * time.Minute),
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Request: []byte(clientGood),
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageKeyEncipherment,
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageClientAuth,
+			},
+			Username: nodeBootstrapperUsername,
+			Groups:   nodeBootstrapperGroups.List(),
+		},
+	}
+	machine := func(nodeRef *corev1.ObjectReference) []machinehandlerpkg.Machine {
+		return []machinehandlerpkg.Machine{{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "panda-machine",
+				CreationTimestamp: creationTimestamp(0),
+			},
+			Status: machinehandlerpkg.MachineStatus{
+				NodeRef: nodeRef,
+				Addresses: []corev1.NodeAddress{
+					{Type: corev1.NodeInternalDNS, Address: "panda"},
+				},
+			},
+		}}
+	}
+
+	tests := []struct {
+		name      string
+		machines  []machinehandlerpkg.Machine
+		objects   []client.Object
+		wantEvent string
+	}{
+		{
+			name:      "node already exists",
+			machines:  machine(nil),
+			objects:   []client.Object{&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "panda"}}},
+			wantEvent: "Warning CSRDenied node panda already exists",
+		},
+		{
+			name:      "machine already has node ref",
+			machines:  machine(&corev1.ObjectReference{Name: "bamboo"}),
+			wantEvent: "Warning CSRDenied machine panda-machine for node panda already has node ref bamboo",
+		},
+		{
+			name:     "approved",
+			machines: machine(nil),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			approver := &CertificateApprover{
+				NodeClient: fake.NewClientBuilder().WithObjects(tt.objects...).Build(),
+				Recorder:   recorder,
+			}
+
+			if _, _, err := approver.authorizeCSR(context.Background(), tt.machines, clientReq, parseCR(t, clientGood), nil); err != nil {
+				t.Fatalf("authorizeCSR() error = %v", err)
+			}
+
+			select {
+			case event := <-recorder.Events:
+				if event != tt.wantEvent {
+					t.Errorf("got event %q, want %q", event, tt.wantEvent)
+				}
+			default:
+				if tt.wantEvent != "" {
+					t.Errorf("expected event %q", tt.wantEvent)
+				}
+			}
+		})
+	}
+}
+
+func TestAuthorizeCSRMatchedMachine(t *testing.T) {
+	clientReq := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "panda-csr",
+			CreationTimestamp: creationTimestamp(2 * time.Minute),
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Request: []byte(clientGood),
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageKeyEncipherment,
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageClientAuth,
+			},
+			Username: nodeBootstrapperUsername,
+			Groups:   nodeBootstrapperGroups.List(),
+		},
+	}
+	servingCSR := createCSR("system:node:panda", defaultOrgs, nil, []string{"panda"})
+	servingReq := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "panda-serving-csr"},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Request: []byte(servingCSR),
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageServerAuth,
+			},
+			Username: "system:node:panda",
+			Groups: []string{
+				"system:authenticated",
+				"system:nodes",
+			},
+		},
+	}
+	machine := func(nodeRef *corev1.ObjectReference) []machinehandlerpkg.Machine {
+		return []machinehandlerpkg.Machine{{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "panda-machine",
+				Namespace:         "openshift-machine-api",
+				CreationTimestamp: creationTimestamp(0),
+			},
+			Status: machinehandlerpkg.MachineStatus{
+				NodeRef: nodeRef,
+				Addresses: []corev1.NodeAddress{
+					{Type: corev1.NodeInternalDNS, Address: "panda"},
+				},
+			},
+		}}
+	}
+
+	tests := []struct {
+		name        string
+		machines    []machinehandlerpkg.Machine
+		objects     []client.Object
+		req         *certificatesv1.CertificateSigningRequest
+		csr         string
+		wantMachine string
+	}{
+		{
+			name:        "client CSR matched by internal DNS",
+			machines:    machine(nil),
+			req:         clientReq,
+			csr:         clientGood,
+			wantMachine: "panda-machine",
+		},
+		{
+			name:     "declined client CSR",
+			machines: machine(nil),
+			objects:  []client.Object{&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "panda"}}},
+			req:      clientReq,
+			csr:      clientGood,
+		},
+		{
+			name:        "serving CSR matched by node ref",
+			machines:    machine(&corev1.ObjectReference{Name: "panda"}),
+			req:         servingReq,
+			csr:         servingCSR,
+			wantMachine: "panda-machine",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			approver := &CertificateApprover{
+				NodeClient: fake.NewClientBuilder().WithObjects(tt.objects...).Build(),
+			}
+
+			machine, _, err := approver.authorizeCSR(context.Background(), tt.machines, tt.req.DeepCopy(), parseCR(t, tt.csr), nil)
+			if err != nil {
+				t.Fatalf("authorizeCSR() error = %v", err)
+			}
+			var got string
+			if machine != nil {
+				got = machine.Name
+			}
+			if got != tt.wantMachine {
+				t.Errorf("authorizeCSR() machine = %q, want %q", got, tt.wantMachine)
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+// defaultKubeletCAConfigMapKey is the key of the CA bundle in the kubelet CA
+// ConfigMaps.
+const defaultKubeletCAConfigMapKey = "ca-bundle.crt"
+
+// kubeletCATracker remembers the kubelet CA bundle it replaced when the
+// kubelet CA rotates, so that kubelets still presenting a serving cert signed
+// by the previous CA can renew it. The zero value is ready to use.
+type kubeletCATracker struct {
+	lock     sync.Mutex
+	bundle   string
+	current  *x509.CertPool
+	previous *x509.CertPool
+}
+
+// observe records the current kubelet CA bundle, parsed into the given pool,
+// and returns the CA pools serving certs may be signed by: the current one,
+// followed by the previous one if the CA rotated while the controller is
+// running.
+func (t *kubeletCATracker) observe(bundle string, pool *x509.CertPool) []*x509.CertPool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if bundle != t.bundle {
+		if t.current != nil {
+			t.previous = t.current
+		}
+		t.bundle, t.current = bundle, pool
+	}
+
+	if t.previous == nil {
+		return []*x509.CertPool{t.current}
+	}
+	return []*x509.CertPool{t.current, t.previous}
+}
+
+// kubeletCABundle returns the kubelet CA bundle, from the kubelet CA file when
+// configured, or from the ConfigMap in the openshift-config-managed namespace.
+func (m *CertificateApprover) kubeletCABundle(ctx context.Context) (string, error) {
+	if path := m.Config.NodeServingCert.KubeletCAFile; path != "" {
+		bundle, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read kubelet CA file: %w", err)
+		}
+		return string(bundle), nil
+	}
+
+	configMap := &corev1.ConfigMap{}
+	key := client.ObjectKey{
+		Namespace: configNamespace,
+		Name:      kubeletCAConfigMap,
+	}
+	if err := m.NodeClient.Get(ctx, key, configMap); err != nil {
+		return "", fmt.Errorf("failed to get kubelet CA: %w", err)
+	}
+
+	caBundle, ok := configMap.Data[defaultKubeletCAConfigMapKey]
+	if !ok {
+		return "", fmt.Errorf("no %s in %s", defaultKubeletCAConfigMapKey, kubeletCAConfigMap)
+	}
+	return caBundle, nil
+}
+
+// additionalKubeletCABundle returns the CA bundle of an additional kubelet CA
+// source.
+func (m *CertificateApprover) additionalKubeletCABundle(ctx context.Context, source KubeletCASource) (string, error) {
+	if source.File != "" {
+		bundle, err := os.ReadFile(source.File)
+		if err != nil {
+			return "", fmt.Errorf("failed to read additional kubelet CA file: %w", err)
+		}
+		return string(bundle), nil
+	}
+
+	configMap := &corev1.ConfigMap{}
+	if err := m.NodeClient.Get(ctx, source.ConfigMap.objectKey(), configMap); err != nil {
+		return "", fmt.Errorf("failed to get additional kubelet CA: %w", err)
+	}
+	caBundle, ok := configMap.Data[source.ConfigMap.key()]
+	if !ok {
+		return "", fmt.Errorf("no %s in %s", source.ConfigMap.key(), source.ConfigMap.objectKey())
+	}
+	return caBundle, nil
+}
+
+// kubeletCABundles returns the kubelet CA bundle followed by those of the
+// additional kubelet CA sources. Sources failing to load are logged and
+// skipped, so that serving certs signed by the CAs of the others can still be
+// verified.
+func (m *CertificateApprover) kubeletCABundles(ctx context.Context) []string {
+	var bundles []string
+	if bundle, err := m.kubeletCABundle(ctx); err != nil {
+		klog.Errorf("%v", err)
+	} else {
+		bundles = append(bundles, bundle)
+	}
+
+	for _, source := range m.Config.NodeServingCert.AdditionalKubeletCAs {
+		bundle, err := m.additionalKubeletCABundle(ctx, source)
+		if err != nil {
+			klog.Errorf("%v", err)
+			continue
+		}
+		bundles = append(bundles, bundle)
+	}
+	return bundles
+}
+
+// parseCABundles merges the certs of the given PEM bundles into a pool, and
+// returns it along with the number of certs. Blocks that are not certs, or
+// fail to parse, are skipped.
+func parseCABundles(bundles []string) (*x509.CertPool, int) {
+	pool := x509.NewCertPool()
+	count := 0
+	for _, bundle := range bundles {
+		rest := []byte(bundle)
+		for len(rest) > 0 {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			if block.Type != "CERTIFICATE" || len(block.Headers) != 0 {
+				continue
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				continue
+			}
+			pool.AddCert(cert)
+			count++
+		}
+	}
+	return pool, count
+}
+
+// additionalKubeletCAConfigMapFilter returns whether the object is the
+// ConfigMap of an additional kubelet CA source.
+func (m *CertificateApprover) additionalKubeletCAConfigMapFilter(obj runtime.Object) bool {
+	cm, ok := obj.(*corev1.ConfigMap)
+	if !ok {
+		return false
+	}
+	for _, source := range m.Config.NodeServingCert.AdditionalKubeletCAs {
+		if source.ConfigMap != nil && source.ConfigMap.objectKey() == client.ObjectKeyFromObject(cm) {
+			return true
+		}
+	}
+	return false
+}
+
+// watchKubeletCAFiles enqueues the pending CSRs whenever the content of the
+// kubelet CA file, or of an additional kubelet CA file, changes, so that
+// renewals held back by a rotated CA are evaluated again, as when the kubelet
+// CA ConfigMap changes. The directories of the files are watched, files
+// mounted from ConfigMaps and Secrets being replaced through symlinks rather
+// than written.
+func (m *CertificateApprover) watchKubeletCAFiles(ctx context.Context) error {
+	paths := m.Config.NodeServingCert.kubeletCAFiles()
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create kubelet CA file watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	// A missing file is reported when CSRs are evaluated.
+	bundles := make(map[string][]byte, len(paths))
+	for _, path := range paths {
+		if err := watcher.Add(filepath.Dir(path)); err != nil {
+			return fmt.Errorf("failed to watch kubelet CA file %s: %w", path, err)
+		}
+		bundles[path], _ = os.ReadFile(path)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-watcher.Errors:
+			klog.Errorf("Failed to watch kubelet CA files %v: %v", paths, err)
+		case <-watcher.Events:
+			for _, path := range paths {
+				current, err := os.ReadFile(path)
+				if err != nil || bytes.Equal(current, bundles[path]) {
+					continue
+				}
+				bundles[path] = current
+				klog.Infof("Kubelet CA file %s changed, reconciling pending CSRs", path)
+
+				select {
+				case m.kubeletCAFileEvents <- event.GenericEvent{Object: &metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{Name: path}}}:
+				case <-ctx.Done():
+					return nil
+				}
+			}
+		}
+	}
+}
+
+// recordRenewalCapable updates the metric tracking whether serving CSRs can be
+// approved as renewals of the serving cert currently presented by kubelets,
+// which requires the renewal fast path to be enabled and a kubelet CA to
+// verify the serving certs against. It returns whether they can.
+func (m *CertificateApprover) recordRenewalCapable(cas []*x509.CertPool) bool {
+	capable := m.Config.NodeServingCert.enabled() && !m.Config.NodeServingCert.DisableRenewalFastPath && len(cas) > 0
+	if capable {
+		renewalCapable.Set(1)
+	} else {
+		renewalCapable.Set(0)
+	}
+	return capable
+}
+
+// checkRenewalCapable warns once on startup when renewals are expected to be
+// approved but no kubelet CA could be loaded, in which case every serving CSR
+// falls back to the machine-api flow.
+func (m *CertificateApprover) checkRenewalCapable(ctx context.Context) error {
+	if m.recordRenewalCapable(m.getKubeletCAs(ctx)) {
+		return nil
+	}
+	if m.Config.NodeServingCert.enabled() && !m.Config.NodeServingCert.DisableRenewalFastPath {
+		klog.Warningf("No kubelet CA could be loaded, serving CSRs can't be approved as renewals of the current serving certs of nodes until it is")
+	}
+	return nil
+}
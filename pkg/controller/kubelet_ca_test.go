@@ -0,0 +1,290 @@
+package controller
+
+import (
+	"context"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr/funcr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestKubeletCATracker(t *testing.T) {
+	tracker := &kubeletCATracker{}
+	oldCA, newCA := x509.NewCertPool(), x509.NewCertPool()
+
+	if pools := tracker.observe("old", oldCA); len(pools) != 1 || pools[0] != oldCA {
+		t.Errorf("expected only the current CA, got %v", pools)
+	}
+	if pools := tracker.observe("old", x509.NewCertPool()); len(pools) != 1 || pools[0] != oldCA {
+		t.Errorf("expected the CA to be unchanged, got %v", pools)
+	}
+
+	// The CA rotated, serving certs signed by the previous CA are still
+	// accepted.
+	if pools := tracker.observe("new", newCA); len(pools) != 2 || pools[0] != newCA || pools[1] != oldCA {
+		t.Errorf("expected the current and previous CAs, got %v", pools)
+	}
+	if pools := tracker.observe("new", newCA); len(pools) != 2 || pools[0] != newCA || pools[1] != oldCA {
+		t.Errorf("expected the previous CA to be kept, got %v", pools)
+	}
+}
+
+func TestGetKubeletCAsFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ca-bundle.crt")
+	if err := os.WriteFile(path, []byte(differentCert), 0o600); err != nil {
+		t.Fatalf("failed to write kubelet CA file: %v", err)
+	}
+	approver := &CertificateApprover{
+		NodeClient: fake.NewFakeClient(),
+		Config: ClusterMachineApproverConfig{
+			NodeServingCert: NodeServingCert{KubeletCAFile: path},
+		},
+	}
+	servingCert := parseCert(t, serverCertGood)
+	verifies := func(pool *x509.CertPool) bool {
+		_, err := servingCert.Verify(x509.VerifyOptions{Roots: pool, CurrentTime: servingCert.NotBefore})
+		return err == nil
+	}
+
+	pools := approver.getKubeletCAs(context.Background())
+	if len(pools) != 1 || verifies(pools[0]) {
+		t.Fatalf("expected only the CA of the file, not signing the serving cert, got %v", pools)
+	}
+
+	// The CA rotates while the controller is running.
+	if err := os.WriteFile(path, []byte(rootCertGood), 0o600); err != nil {
+		t.Fatalf("failed to write kubelet CA file: %v", err)
+	}
+	pools = approver.getKubeletCAs(context.Background())
+	if len(pools) != 2 || !verifies(pools[0]) || verifies(pools[1]) {
+		t.Fatalf("expected the rotated CA signing the serving cert, followed by the previous CA, got %v", pools)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("failed to remove kubelet CA file: %v", err)
+	}
+	if pools := approver.getKubeletCAs(context.Background()); pools != nil {
+		t.Errorf("expected no CA without the kubelet CA file, got %v", pools)
+	}
+}
+
+func TestGetKubeletCAsFromMultipleSources(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ca-bundle.crt")
+	if err := os.WriteFile(path, []byte(differentCert), 0o600); err != nil {
+		t.Fatalf("failed to write kubelet CA file: %v", err)
+	}
+	additionalPath := filepath.Join(t.TempDir(), "additional.crt")
+	if err := os.WriteFile(additionalPath, []byte(rootCertGood), 0o600); err != nil {
+		t.Fatalf("failed to write additional kubelet CA file: %v", err)
+	}
+	additionalCA := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kubelet-ca", Name: "additional"},
+		Data:       map[string]string{"ca.crt": rootCertGood},
+	}
+	servingCert := parseCert(t, serverCertGood)
+	verifies := func(pool *x509.CertPool) bool {
+		_, err := servingCert.Verify(x509.VerifyOptions{Roots: pool, CurrentTime: servingCert.NotBefore})
+		return err == nil
+	}
+
+	tests := []struct {
+		name         string
+		config       NodeServingCert
+		wantCerts    float64
+		wantVerified bool
+	}{
+		{
+			name:         "only the kubelet CA file",
+			config:       NodeServingCert{KubeletCAFile: path},
+			wantCerts:    1,
+			wantVerified: false,
+		},
+		{
+			name: "kubelet CA file merged with an additional ConfigMap",
+			config: NodeServingCert{
+				KubeletCAFile: path,
+				AdditionalKubeletCAs: []KubeletCASource{{
+					ConfigMap: &KubeletCAConfigMap{Namespace: "kubelet-ca", Name: "additional", Key: "ca.crt"},
+				}},
+			},
+			wantCerts:    2,
+			wantVerified: true,
+		},
+		{
+			name: "kubelet CA file merged with an additional file",
+			config: NodeServingCert{
+				KubeletCAFile:        path,
+				AdditionalKubeletCAs: []KubeletCASource{{File: additionalPath}},
+			},
+			wantCerts:    2,
+			wantVerified: true,
+		},
+		{
+			name: "kubelet CA ConfigMap missing, additional file and ConfigMap merged",
+			config: NodeServingCert{
+				AdditionalKubeletCAs: []KubeletCASource{
+					{File: path},
+					{ConfigMap: &KubeletCAConfigMap{Namespace: "kubelet-ca", Name: "additional", Key: "ca.crt"}},
+				},
+			},
+			wantCerts:    2,
+			wantVerified: true,
+		},
+		{
+			name: "additional source failing to load skipped",
+			config: NodeServingCert{
+				KubeletCAFile: path,
+				AdditionalKubeletCAs: []KubeletCASource{
+					{File: filepath.Join(t.TempDir(), "missing.crt")},
+					{ConfigMap: &KubeletCAConfigMap{Namespace: "kubelet-ca", Name: "missing"}},
+				},
+			},
+			wantCerts:    1,
+			wantVerified: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			approver := &CertificateApprover{
+				NodeClient: fake.NewClientBuilder().WithObjects(additionalCA.DeepCopy()).Build(),
+				Config:     ClusterMachineApproverConfig{NodeServingCert: tt.config},
+			}
+
+			pools := approver.getKubeletCAs(context.Background())
+			if len(pools) != 1 {
+				t.Fatalf("expected a single merged pool, got %v", pools)
+			}
+			if verified := verifies(pools[0]); verified != tt.wantVerified {
+				t.Errorf("serving cert verified = %v, want %v", verified, tt.wantVerified)
+			}
+			if certs := gaugeValue(t, kubeletCACerts); certs != tt.wantCerts {
+				t.Errorf("kubelet CA certs gauge = %v, want %v", certs, tt.wantCerts)
+			}
+		})
+	}
+}
+
+func TestWatchKubeletCAFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ca-bundle.crt")
+	if err := os.WriteFile(path, []byte(differentCert), 0o600); err != nil {
+		t.Fatalf("failed to write kubelet CA file: %v", err)
+	}
+	events := make(chan event.GenericEvent)
+	approver := &CertificateApprover{
+		Config: ClusterMachineApproverConfig{
+			NodeServingCert: NodeServingCert{KubeletCAFile: path},
+		},
+		kubeletCAFileEvents: events,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error)
+	go func() {
+		done <- approver.watchKubeletCAFiles(ctx)
+	}()
+
+	// The file is rewritten until the watcher, which may not be watching yet,
+	// notices the change.
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Errorf("failed to write kubelet CA file: %v", err)
+		}
+	}
+	write(rootCertGood)
+	timeout := time.After(10 * time.Second)
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for changed := false; !changed; {
+		select {
+		case <-events:
+			changed = true
+		case <-ticker.C:
+			write(differentCert)
+			write(rootCertGood)
+		case <-timeout:
+			t.Fatalf("expected pending CSRs to be enqueued when the kubelet CA file changes")
+		}
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("watchKubeletCAFiles() error = %v", err)
+	}
+}
+
+func TestCheckRenewalCapable(t *testing.T) {
+	caConfigMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: configNamespace, Name: kubeletCAConfigMap},
+		Data:       map[string]string{"ca-bundle.crt": rootCertGood},
+	}
+
+	tests := []struct {
+		name        string
+		objects     []client.Object
+		config      NodeServingCert
+		wantCapable bool
+		wantWarning bool
+	}{
+		{
+			name:        "kubelet CA loaded",
+			objects:     []client.Object{caConfigMap},
+			wantCapable: true,
+		},
+		{
+			name:        "no kubelet CA",
+			wantWarning: true,
+		},
+		{
+			name:   "no kubelet CA with the renewal fast path disabled",
+			config: NodeServingCert{DisableRenewalFastPath: true},
+		},
+		{
+			name:    "renewal fast path disabled",
+			objects: []client.Object{caConfigMap},
+			config:  NodeServingCert{DisableRenewalFastPath: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var warnings []string
+			klog.SetLogger(funcr.New(func(prefix, args string) {
+				if strings.Contains(args, "No kubelet CA could be loaded") {
+					warnings = append(warnings, args)
+				}
+			}, funcr.Options{}))
+			defer klog.ClearLogger()
+
+			approver := &CertificateApprover{
+				NodeClient: fake.NewClientBuilder().WithObjects(tt.objects...).Build(),
+				Config:     ClusterMachineApproverConfig{NodeServingCert: tt.config},
+			}
+			if err := approver.checkRenewalCapable(context.Background()); err != nil {
+				t.Fatalf("checkRenewalCapable() error = %v", err)
+			}
+
+			if warned := len(warnings) > 0; warned != tt.wantWarning {
+				t.Errorf("warnings = %v, want warning %v", warnings, tt.wantWarning)
+			}
+			want := 0.0
+			if tt.wantCapable {
+				want = 1
+			}
+			if got := gaugeValue(t, renewalCapable); got != want {
+				t.Errorf("renewal capable gauge = %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"sync"
+)
+
+// kubeletDialLimiter bounds the number of concurrent connections to kubelets,
+// so that a mass rotation of serving certs doesn't exhaust file descriptors.
+// The zero value is ready to use.
+type kubeletDialLimiter struct {
+	once  sync.Once
+	slots chan struct{}
+}
+
+// limit returns a connector connecting to kubelets with the given connector,
+// or over the network when nil, once fewer than max connections are in
+// progress. Waiting for a slot is bounded by the context of the connection,
+// including the kubelet connect timeout. Connections are not limited when max
+// is not positive.
+func (l *kubeletDialLimiter) limit(max int, connect KubeletConnector) KubeletConnector {
+	if connect == nil {
+		connect = dialKubelet
+	}
+	if max <= 0 {
+		return connect
+	}
+
+	// The limit can't change while the controller is running.
+	l.once.Do(func() {
+		l.slots = make(chan struct{}, max)
+	})
+	return func(ctx context.Context, addr string, tlsConfig *tls.Config) (tls.ConnectionState, error) {
+		select {
+		case l.slots <- struct{}{}:
+		case <-ctx.Done():
+			return tls.ConnectionState{}, fmt.Errorf("gave up waiting to connect to kubelet %s, %d connections already in progress: %w", addr, max, ctx.Err())
+		}
+		defer func() { <-l.slots }()
+
+		return connect(ctx, addr, tlsConfig)
+	}
+}
@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestKubeletDialLimiter(t *testing.T) {
+	const maxDials = 2
+
+	var inProgress, maxInProgress, dials int32
+	release := make(chan struct{})
+	connect := func(ctx context.Context, addr string, tlsConfig *tls.Config) (tls.ConnectionState, error) {
+		current := atomic.AddInt32(&inProgress, 1)
+		defer atomic.AddInt32(&inProgress, -1)
+		for {
+			observed := atomic.LoadInt32(&maxInProgress)
+			if current <= observed || atomic.CompareAndSwapInt32(&maxInProgress, observed, current) {
+				break
+			}
+		}
+		atomic.AddInt32(&dials, 1)
+		<-release
+		return tls.ConnectionState{}, nil
+	}
+
+	limiter := &kubeletDialLimiter{}
+	limited := limiter.limit(maxDials, connect)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := limited(context.Background(), "10.0.0.1:10250", nil); err != nil {
+				t.Errorf("connect error = %v", err)
+			}
+		}()
+	}
+
+	// Let the dials through one at a time once the limit is reached.
+	deadline := time.After(10 * time.Second)
+	for completed := 0; completed < 10; completed++ {
+		for atomic.LoadInt32(&inProgress) < maxDials && atomic.LoadInt32(&dials) < 10 {
+			select {
+			case <-deadline:
+				t.Fatalf("timed out waiting for dials, %d in progress", atomic.LoadInt32(&inProgress))
+			case <-time.After(time.Millisecond):
+			}
+		}
+		release <- struct{}{}
+	}
+	wg.Wait()
+
+	if maxInProgress != maxDials {
+		t.Errorf("at most %d dials in progress, want %d", maxInProgress, maxDials)
+	}
+	if dials != 10 {
+		t.Errorf("%d dials, want 10", dials)
+	}
+}
+
+func TestKubeletDialLimiterTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	connect := func(ctx context.Context, addr string, tlsConfig *tls.Config) (tls.ConnectionState, error) {
+		<-release
+		return tls.ConnectionState{}, nil
+	}
+
+	limiter := &kubeletDialLimiter{}
+	limited := limiter.limit(1, connect)
+
+	// The only slot is held by a dial that doesn't complete.
+	go limited(context.Background(), "10.0.0.1:10250", nil)
+	for len(limiter.slots) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := connectKubelet(ctx, limited, "10.0.0.2:10250", nil, 0)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("connect error = %v, want deadline exceeded", err)
+	}
+	// Waiting for a slot times out as the dial itself would.
+	if got := kubeletDialFailureReason(err); got != dialFailureTimeout {
+		t.Errorf("dial failure reason = %s, want %s", got, dialFailureTimeout)
+	}
+}
+
+func TestKubeletDialLimiterUnlimited(t *testing.T) {
+	limiter := &kubeletDialLimiter{}
+	called := false
+	connect := func(ctx context.Context, addr string, tlsConfig *tls.Config) (tls.ConnectionState, error) {
+		called = true
+		return tls.ConnectionState{}, nil
+	}
+
+	if _, err := limiter.limit(0, connect)(context.Background(), "10.0.0.1:10250", nil); err != nil || !called {
+		t.Errorf("connect error = %v, called = %v", err, called)
+	}
+	if limiter.slots != nil {
+		t.Errorf("expected no slots without a limit")
+	}
+}
@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+const defaultMachineAddressWatchInterval = 30 * time.Second
+
+// watchMachineAddresses lists the machines at the configured interval until
+// the context is done, and enqueues the pending serving CSRs of the nodes
+// whose machine addresses changed, e.g. CSRs declined while the addresses of
+// the machine were stale. Machines may live in another cluster than the
+// manager watches, so they are listed rather than watched.
+func (m *CertificateApprover) watchMachineAddresses(ctx context.Context) error {
+	ticker := time.NewTicker(m.Config.MachineAddressWatch.interval())
+	defer ticker.Stop()
+
+	var seen map[string][]corev1.NodeAddress
+	for {
+		seen = m.checkMachineAddresses(ctx, seen)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkMachineAddresses lists the machines and enqueues the pending serving
+// CSRs of the nodes of the machines whose addresses changed since they were
+// last seen. The addresses of the machines listed are returned, or those last
+// seen if the machines could not be listed. Nothing is enqueued for the
+// machines seen the first time.
+func (m *CertificateApprover) checkMachineAddresses(ctx context.Context, seen map[string][]corev1.NodeAddress) map[string][]corev1.NodeAddress {
+	machines, err := m.listMachines(ctx)
+	if err != nil {
+		klog.Errorf("Machine address watch: %v", err)
+		return seen
+	}
+
+	current := make(map[string][]corev1.NodeAddress, len(machines))
+	nodeNames := sets.NewString()
+	for _, machine := range machines {
+		key := machine.Namespace + "/" + machine.Name
+		current[key] = machine.Status.Addresses
+		if seen == nil || machine.Status.NodeRef == nil {
+			continue
+		}
+		if previous, ok := seen[key]; ok && equality.Semantic.DeepEqual(previous, machine.Status.Addresses) {
+			continue
+		}
+		klog.Infof("Machine address watch: Addresses of machine %s of node %s changed", key, machine.Status.NodeRef.Name)
+		nodeNames.Insert(machine.Status.NodeRef.Name)
+	}
+
+	if nodeNames.Len() > 0 {
+		// Reconciles must not authorize the CSRs against stale machines.
+		if m.Config.MachineCache.Enabled {
+			m.machines.set(machines, m.clock().Now())
+		}
+		m.enqueueServingCSRs(ctx, nodeNames)
+	}
+	return current
+}
+
+// enqueueServingCSRs enqueues the pending serving CSRs of the given nodes.
+func (m *CertificateApprover) enqueueServingCSRs(ctx context.Context, nodeNames sets.String) {
+	csrs := &certificatesv1.CertificateSigningRequestList{}
+	if err := m.NodeClient.List(ctx, csrs); err != nil {
+		klog.Errorf("Machine address watch: Failed to list CSRs: %v", err)
+		return
+	}
+
+	nodeUserPrefix := m.Config.nodeUserPrefix()
+	for i := range csrs.Items {
+		csr := &csrs.Items[i]
+		// Approved, denied and failed CSRs all have a condition set.
+		if len(csr.Status.Conditions) > 0 || !isRequestFromNodeUser(nodeUserPrefix, *csr) {
+			continue
+		}
+		if !nodeNames.Has(strings.TrimPrefix(csr.Spec.Username, nodeUserPrefix)) {
+			continue
+		}
+
+		klog.Infof("Machine address watch: %v: Re-evaluating serving CSR", csr.Name)
+		select {
+		case m.machineAddressEvents <- event.GenericEvent{Object: csr}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package controller
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	configv1 "github.com/openshift/api/config/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	testingclock "k8s.io/utils/clock/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestCheckMachineAddresses(t *testing.T) {
+	server := newMachineDiscoveryServer()
+	defer server.Close()
+
+	machine := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "machine.openshift.io/v1beta1",
+			"kind":       "Machine",
+			"metadata": map[string]interface{}{
+				"name":      "panda-machine",
+				"namespace": "openshift-machine-api",
+			},
+			"status": map[string]interface{}{
+				"nodeRef": map[string]interface{}{"name": "panda"},
+				"addresses": []interface{}{
+					map[string]interface{}{"type": "InternalDNS", "address": "panda"},
+					map[string]interface{}{"type": "InternalIP", "address": "10.0.0.2"},
+				},
+			},
+		},
+	}
+	servingCSR := createCSR("system:node:panda", defaultOrgs, []net.IP{net.ParseIP("10.0.0.1")}, []string{"panda"})
+	csr := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "panda-serving-csr"},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageServerAuth,
+			},
+			SignerName: certificatesv1.KubeletServingSignerName,
+			Username:   "system:node:panda",
+			Groups: []string{
+				"system:authenticated",
+				"system:nodes",
+			},
+			Request: []byte(servingCSR),
+		},
+	}
+	otherCSR := csr.DeepCopy()
+	otherCSR.Name = "bamboo-serving-csr"
+	otherCSR.Spec.Username = "system:node:bamboo"
+	network := &configv1.Network{ObjectMeta: metav1.ObjectMeta{Name: "cluster"}}
+
+	machineClient := fake.NewClientBuilder().WithObjects(machine).Build()
+	approver := &CertificateApprover{
+		MachineClient:        machineClient,
+		MachineRestCfg:       &rest.Config{Host: server.URL},
+		APIGroupVersions:     []schema.GroupVersion{{Group: "machine.openshift.io"}},
+		NodeClient:           fake.NewClientBuilder().WithObjects(csr, otherCSR, network).Build(),
+		Clock:                testingclock.NewFakePassiveClock(baseTime),
+		Config:               ClusterMachineApproverConfig{AuditOnly: true},
+		machineAddressEvents: make(chan event.GenericEvent, 10),
+	}
+
+	// The CSR is declined as the machine doesn't have the requested address
+	// yet.
+	seen := approver.checkMachineAddresses(context.Background(), nil)
+	machines, err := approver.getCachedMachines(context.Background())
+	if err != nil {
+		t.Fatalf("getCachedMachines() error = %v", err)
+	}
+	if err := approver.reconcileCSR(context.Background(), *csr, machines); err == nil {
+		t.Fatalf("reconcileCSR() succeeded, want the CSR declined")
+	}
+	if decisions := approver.decisions.list(); len(decisions) != 1 || decisions[0].Reason != decisionReasonAuthorizationExhausted {
+		t.Fatalf("decisions = %+v, want the CSR declined", decisions)
+	}
+
+	// Nothing is enqueued while the addresses don't change.
+	seen = approver.checkMachineAddresses(context.Background(), seen)
+	if len(approver.machineAddressEvents) != 0 {
+		t.Fatalf("%d CSRs enqueued, want none", len(approver.machineAddressEvents))
+	}
+
+	if err := unstructured.SetNestedSlice(machine.Object, []interface{}{
+		map[string]interface{}{"type": "InternalDNS", "address": "panda"},
+		map[string]interface{}{"type": "InternalIP", "address": "10.0.0.1"},
+	}, "status", "addresses"); err != nil {
+		t.Fatalf("failed to set machine addresses: %v", err)
+	}
+	if err := machineClient.Update(context.Background(), machine); err != nil {
+		t.Fatalf("failed to update machine: %v", err)
+	}
+
+	// Only the CSR of the node of the machine is enqueued, and approved once
+	// reconciled again, without waiting for the machine cache to expire.
+	approver.checkMachineAddresses(context.Background(), seen)
+	if len(approver.machineAddressEvents) != 1 {
+		t.Fatalf("%d CSRs enqueued, want 1", len(approver.machineAddressEvents))
+	}
+	if enqueued := (<-approver.machineAddressEvents).Object.GetName(); enqueued != csr.Name {
+		t.Fatalf("enqueued CSR %s, want %s", enqueued, csr.Name)
+	}
+
+	machines, err = approver.getCachedMachines(context.Background())
+	if err != nil {
+		t.Fatalf("getCachedMachines() error = %v", err)
+	}
+	if err := approver.reconcileCSR(context.Background(), *csr, machines); err != nil {
+		t.Fatalf("reconcileCSR() error = %v", err)
+	}
+	if decisions := approver.decisions.list(); len(decisions) != 2 || decisions[1].Decision != decisionApproved {
+		t.Errorf("decisions = %+v, want the CSR approved", decisions)
+	}
+}
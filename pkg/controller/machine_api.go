@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2"
+)
+
+// machineAPIDetector remembers the API groups machines are served in, detected
+// once after the controller starts. The zero value is ready to use.
+type machineAPIDetector struct {
+	lock     sync.Mutex
+	detected bool
+	served   []schema.GroupVersion
+}
+
+// machineAPIGroups returns the API groups of the approver machines are served
+// in. They are detected on the first call, failures to detect them are not
+// remembered. When the Machine CRD is not installed in any of them, machines
+// are no longer listed, and CSRs can only be approved by renewal of the
+// current serving cert.
+func (m *CertificateApprover) machineAPIGroups(ctx context.Context) ([]schema.GroupVersion, error) {
+	m.machineAPI.lock.Lock()
+	defer m.machineAPI.lock.Unlock()
+
+	if m.machineAPI.detected {
+		return m.machineAPI.served, nil
+	}
+
+	machineHandler := m.machineHandler(ctx)
+	var served []schema.GroupVersion
+	for _, apiGroupVersion := range m.APIGroupVersions {
+		ok, err := machineHandler.MachinesServed(apiGroupVersion)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list machines in API group %v: %w", apiGroupVersion, err)
+		}
+		if !ok {
+			klog.Infof("Machines are not served in API group %v", apiGroupVersion)
+			continue
+		}
+		served = append(served, apiGroupVersion)
+	}
+
+	if len(served) == 0 {
+		klog.Warningf("Machines are not served in any of the API groups %v, the Machine API may not be installed. Only renewals of the current serving certs of nodes can be approved until the controller is restarted", m.APIGroupVersions)
+		machineAPIAvailable.Set(0)
+	} else {
+		machineAPIAvailable.Set(1)
+	}
+	m.machineAPI.detected = true
+	m.machineAPI.served = served
+	return served, nil
+}
@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestMachineAPIGroups(t *testing.T) {
+	server := newMachineDiscoveryServer()
+	defer server.Close()
+
+	tests := []struct {
+		name          string
+		apiGroup      string
+		listErr       error
+		wantAvailable float64
+		wantLists     int
+	}{
+		{
+			name:          "machines served",
+			apiGroup:      "machine.openshift.io",
+			wantAvailable: 1,
+			// Machines are listed on each call and pinged once detected.
+			wantLists: 4,
+		},
+		{
+			name:     "machine CRD not found",
+			apiGroup: "machine.openshift.io",
+			listErr:  apierrors.NewNotFound(schema.GroupResource{Group: "machine.openshift.io", Resource: "machines"}, ""),
+			// Machines are only listed to detect them.
+			wantLists: 1,
+		},
+		{
+			name:      "machine kind not registered",
+			apiGroup:  "machine.openshift.io",
+			listErr:   &meta.NoKindMatchError{GroupKind: schema.GroupKind{Group: "machine.openshift.io", Kind: "MachineList"}},
+			wantLists: 1,
+		},
+		{
+			name:     "API group not served",
+			apiGroup: "cluster.x-k8s.io",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lists int
+			machineClient := fake.NewClientBuilder().WithInterceptorFuncs(interceptor.Funcs{
+				List: func(ctx context.Context, client client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+					lists++
+					if tt.listErr != nil {
+						return tt.listErr
+					}
+					return client.List(ctx, list, opts...)
+				},
+			}).Build()
+			approver := &CertificateApprover{
+				MachineClient:    machineClient,
+				MachineRestCfg:   &rest.Config{Host: server.URL},
+				APIGroupVersions: []schema.GroupVersion{{Group: tt.apiGroup}},
+			}
+
+			// Reconciles keep working without machines.
+			for i := 0; i < 2; i++ {
+				machines, err := approver.listMachines(context.Background())
+				if err != nil {
+					t.Fatalf("listMachines() error = %v", err)
+				}
+				if len(machines) != 0 {
+					t.Errorf("listMachines() = %v, want no machines", machines)
+				}
+			}
+			if err := approver.MachinesReadyzCheck(httptest.NewRequest(http.MethodGet, "/readyz", nil)); err != nil {
+				t.Errorf("MachinesReadyzCheck() error = %v", err)
+			}
+
+			if lists != tt.wantLists {
+				t.Errorf("machines listed %d times, want %d", lists, tt.wantLists)
+			}
+			if available := gaugeValue(t, machineAPIAvailable); available != tt.wantAvailable {
+				t.Errorf("machine API available = %v, want %v", available, tt.wantAvailable)
+			}
+		})
+	}
+}
+
+func TestMachineAPIGroupsDetectionFailure(t *testing.T) {
+	server := newMachineDiscoveryServer()
+	defer server.Close()
+
+	listErr := errors.New("connection refused")
+	machineClient := fake.NewClientBuilder().WithInterceptorFuncs(interceptor.Funcs{
+		List: func(ctx context.Context, client client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+			if listErr != nil {
+				return listErr
+			}
+			return client.List(ctx, list, opts...)
+		},
+	}).Build()
+	approver := &CertificateApprover{
+		MachineClient:    machineClient,
+		MachineRestCfg:   &rest.Config{Host: server.URL},
+		APIGroupVersions: []schema.GroupVersion{{Group: "machine.openshift.io"}},
+	}
+
+	if _, err := approver.listMachines(context.Background()); err == nil {
+		t.Fatalf("expected an error detecting machines")
+	}
+
+	// Failures to detect machines are not remembered.
+	listErr = nil
+	if _, err := approver.listMachines(context.Background()); err != nil {
+		t.Fatalf("listMachines() error = %v", err)
+	}
+	if groups, _ := approver.machineAPIGroups(context.Background()); len(groups) != 1 {
+		t.Errorf("machineAPIGroups() = %v, want the machine.openshift.io API group", groups)
+	}
+}
@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	machinehandlerpkg "github.com/openshift/cluster-machine-approver/pkg/machinehandler"
+	"k8s.io/klog/v2"
+)
+
+const (
+	defaultMachineCacheTTL = 10 * time.Second
+
+	// maxNodeRefRefreshAttempts bounds the time a reconcile may wait for the
+	// node ref of a machine to be set.
+	maxNodeRefRefreshAttempts  = 5
+	defaultNodeRefRefreshDelay = time.Second
+)
+
+// machineCache remembers the machines recently listed, so that the CSRs
+// reconciled close together, e.g. when many nodes join during a scale up,
+// don't each list all the machines. The zero value is ready to use.
+type machineCache struct {
+	lock     sync.Mutex
+	machines []machinehandlerpkg.Machine
+	listedAt time.Time
+}
+
+// get returns the machines listed within the TTL, if any, along with when
+// they were listed.
+func (c *machineCache) get(now time.Time, ttl time.Duration) ([]machinehandlerpkg.Machine, time.Time, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.listedAt.IsZero() || now.Sub(c.listedAt) >= ttl {
+		return nil, time.Time{}, false
+	}
+	return c.machines, c.listedAt, true
+}
+
+// set records the machines listed at the given time.
+func (c *machineCache) set(machines []machinehandlerpkg.Machine, listedAt time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.machines = machines
+	c.listedAt = listedAt
+}
+
+// getCachedMachines returns the machines in all the API groups of the
+// approver, reusing those listed within the TTL if any. Failures to list them
+// are not cached. The machines returned must not be modified, they are shared
+// by the reconciles.
+func (m *CertificateApprover) getCachedMachines(ctx context.Context) ([]machinehandlerpkg.Machine, error) {
+	now := m.clock().Now()
+	if !m.Config.MachineCache.Enabled {
+		machineCacheAgeSeconds.Set(0)
+		return m.listMachines(ctx)
+	}
+
+	if machines, listedAt, ok := m.machines.get(now, m.Config.MachineCache.ttl()); ok {
+		klog.V(4).Infof("Reusing %d machines listed %s ago", len(machines), now.Sub(listedAt))
+		machineCacheAgeSeconds.Set(now.Sub(listedAt).Seconds())
+		return machines, nil
+	}
+
+	machines, err := m.listMachines(ctx)
+	if err != nil {
+		return nil, err
+	}
+	m.machines.set(machines, now)
+	machineCacheAgeSeconds.Set(0)
+	return machines, nil
+}
+
+// refreshMachinesForNodeRef lists the machines again, up to the configured
+// number of attempts, while none has the node as node ref. The machines last
+// listed are returned, or the given ones if they could not be listed again.
+// Machines listed again are cached, so that the following reconciles find the
+// node ref too.
+func (m *CertificateApprover) refreshMachinesForNodeRef(ctx context.Context, machines []machinehandlerpkg.Machine, nodeName string) []machinehandlerpkg.Machine {
+	refresh := m.Config.NodeServingCert.NodeRefRefresh
+	for attempt := 1; attempt <= refresh.Attempts; attempt++ {
+		if _, err := machinehandlerpkg.FindMatchingMachineFromNodeRef(machines, nodeName); err == nil || errors.Is(err, machinehandlerpkg.ErrAmbiguousMachine) {
+			return machines
+		}
+
+		select {
+		case <-ctx.Done():
+			return machines
+		case <-time.After(refresh.delay()):
+		}
+
+		klog.Infof("No machine has node %s as node ref yet, listing machines again (attempt %d/%d)", nodeName, attempt, refresh.Attempts)
+		listed, err := m.listMachines(ctx)
+		if err != nil {
+			klog.Errorf("Failed to list machines again for node %s: %v", nodeName, err)
+			return machines
+		}
+		if m.Config.MachineCache.Enabled {
+			m.machines.set(listed, m.clock().Now())
+		}
+		machines = listed
+	}
+	return machines
+}
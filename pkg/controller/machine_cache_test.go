@@ -0,0 +1,273 @@
+package controller
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	configv1 "github.com/openshift/api/config/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	testingclock "k8s.io/utils/clock/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestGetCachedMachines(t *testing.T) {
+	server := newMachineDiscoveryServer()
+	defer server.Close()
+
+	req := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "panda-csr",
+			CreationTimestamp: creationTimestamp(-time.Minute),
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Request: []byte(clientGood),
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageKeyEncipherment,
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageClientAuth,
+			},
+			Username: nodeBootstrapperUsername,
+			Groups:   nodeBootstrapperGroups.List(),
+		},
+	}
+
+	type step struct {
+		advance       time.Duration
+		wantMachines  int
+		wantAuthorize bool
+		wantAge       float64
+	}
+
+	tests := []struct {
+		name   string
+		config MachineCache
+		steps  []step
+	}{
+		{
+			name:   "default TTL",
+			config: MachineCache{Enabled: true},
+			steps: []step{
+				// The machine is deleted after being listed, the cached one is
+				// still used within the TTL.
+				{advance: 5 * time.Second, wantMachines: 1, wantAuthorize: true, wantAge: 5},
+				{advance: 4 * time.Second, wantMachines: 1, wantAuthorize: true, wantAge: 9},
+				// Listed again once expired.
+				{advance: time.Second, wantMachines: 0, wantAuthorize: false, wantAge: 0},
+			},
+		},
+		{
+			name:   "configured TTL",
+			config: MachineCache{Enabled: true, TTL: metav1.Duration{Duration: time.Minute}},
+			steps: []step{
+				{advance: 30 * time.Second, wantMachines: 1, wantAuthorize: true, wantAge: 30},
+				{advance: 30 * time.Second, wantMachines: 0, wantAuthorize: false, wantAge: 0},
+			},
+		},
+		{
+			name: "disabled by default",
+			steps: []step{
+				{advance: time.Second, wantMachines: 0, wantAuthorize: false, wantAge: 0},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			machine := &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"apiVersion": "machine.openshift.io/v1beta1",
+					"kind":       "Machine",
+					"metadata": map[string]interface{}{
+						"name":              "panda",
+						"namespace":         "openshift-machine-api",
+						"creationTimestamp": creationTimestamp(-5 * time.Minute).UTC().Format(time.RFC3339),
+					},
+					"status": map[string]interface{}{
+						"addresses": []interface{}{
+							map[string]interface{}{"type": "InternalDNS", "address": "panda"},
+						},
+					},
+				},
+			}
+			machineClient := fake.NewClientBuilder().WithObjects(machine).Build()
+			clock := testingclock.NewFakePassiveClock(baseTime)
+			approver := &CertificateApprover{
+				MachineClient:    machineClient,
+				MachineRestCfg:   &rest.Config{Host: server.URL},
+				APIGroupVersions: []schema.GroupVersion{{Group: "machine.openshift.io"}},
+				NodeClient:       fake.NewFakeClient(),
+				Config:           ClusterMachineApproverConfig{MachineCache: tt.config},
+				Clock:            clock,
+			}
+
+			machines, err := approver.getCachedMachines(context.Background())
+			if err != nil {
+				t.Fatalf("getCachedMachines() error = %v", err)
+			}
+			if len(machines) != 1 {
+				t.Fatalf("getCachedMachines() returned %d machines, want 1", len(machines))
+			}
+			if err := machineClient.Delete(context.Background(), machine); err != nil {
+				t.Fatalf("failed to delete machine: %v", err)
+			}
+
+			for i, step := range tt.steps {
+				clock.SetTime(clock.Now().Add(step.advance))
+				machines, err := approver.getCachedMachines(context.Background())
+				if err != nil {
+					t.Fatalf("step %d: getCachedMachines() error = %v", i, err)
+				}
+				if len(machines) != step.wantMachines {
+					t.Errorf("step %d: getCachedMachines() returned %d machines, want %d", i, len(machines), step.wantMachines)
+				}
+				if age := gaugeValue(t, machineCacheAgeSeconds); age != step.wantAge {
+					t.Errorf("step %d: machine cache age = %v, want %v", i, age, step.wantAge)
+				}
+
+				// Decisions are taken against the cached machines.
+				if _, authorize, _ := approver.authorizeCSR(context.Background(), machines, req, parseCR(t, clientGood), nil); authorize != step.wantAuthorize {
+					t.Errorf("step %d: authorizeCSR() = %v, want %v", i, authorize, step.wantAuthorize)
+				}
+			}
+		})
+	}
+}
+
+func TestRefreshMachinesForNodeRef(t *testing.T) {
+	server := newMachineDiscoveryServer()
+	defer server.Close()
+
+	servingCSR := createCSR("system:node:panda", defaultOrgs, []net.IP{net.ParseIP("10.0.0.1")}, []string{"panda"})
+	req := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "panda-serving-csr"},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageServerAuth,
+			},
+			Username: "system:node:panda",
+			Groups: []string{
+				"system:authenticated",
+				"system:nodes",
+			},
+			Request: []byte(servingCSR),
+		},
+	}
+
+	tests := []struct {
+		name          string
+		attempts      int
+		nodeRefOnList int
+		wantAuthorize bool
+		wantLists     int
+	}{
+		{
+			name:          "disabled",
+			nodeRefOnList: 1,
+		},
+		{
+			name:          "node ref on the first list again",
+			attempts:      2,
+			nodeRefOnList: 1,
+			wantAuthorize: true,
+			wantLists:     1,
+		},
+		{
+			name:          "node ref on the second list again",
+			attempts:      2,
+			nodeRefOnList: 2,
+			wantAuthorize: true,
+			wantLists:     2,
+		},
+		{
+			name:      "node ref never set",
+			attempts:  2,
+			wantLists: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			machine := &unstructured.Unstructured{
+				Object: map[string]interface{}{
+					"apiVersion": "machine.openshift.io/v1beta1",
+					"kind":       "Machine",
+					"metadata": map[string]interface{}{
+						"name":      "panda-machine",
+						"namespace": "openshift-machine-api",
+					},
+					"status": map[string]interface{}{
+						"addresses": []interface{}{
+							map[string]interface{}{"type": "InternalDNS", "address": "panda"},
+							map[string]interface{}{"type": "InternalIP", "address": "10.0.0.1"},
+						},
+					},
+				},
+			}
+
+			// The node linker sets the node ref right before the given list
+			// again.
+			var listing bool
+			var lists int
+			machineClient := fake.NewClientBuilder().WithObjects(machine).WithInterceptorFuncs(interceptor.Funcs{
+				List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+					if !listing {
+						return c.List(ctx, list, opts...)
+					}
+					lists++
+					if lists == tt.nodeRefOnList {
+						if err := unstructured.SetNestedField(machine.Object, map[string]interface{}{"name": "panda"}, "status", "nodeRef"); err != nil {
+							return err
+						}
+						if err := c.Update(ctx, machine); err != nil {
+							return err
+						}
+					}
+					return c.List(ctx, list, opts...)
+				},
+			}).Build()
+			approver := &CertificateApprover{
+				MachineClient:    machineClient,
+				MachineRestCfg:   &rest.Config{Host: server.URL},
+				APIGroupVersions: []schema.GroupVersion{{Group: "machine.openshift.io"}},
+				NodeClient:       fake.NewFakeClient(&configv1.Network{ObjectMeta: metav1.ObjectMeta{Name: "cluster"}}),
+				Recorder:         record.NewFakeRecorder(10),
+				Config: ClusterMachineApproverConfig{
+					MachineCache: MachineCache{Enabled: true},
+					NodeServingCert: NodeServingCert{NodeRefRefresh: NodeRefRefresh{
+						Attempts: tt.attempts,
+						Delay:    metav1.Duration{Duration: time.Millisecond},
+					}},
+				},
+			}
+
+			machines, err := approver.getCachedMachines(context.Background())
+			if err != nil {
+				t.Fatalf("getCachedMachines() error = %v", err)
+			}
+			listing = true
+
+			got := approver.Authorize(context.Background(), machines, req.DeepCopy(), parseCR(t, servingCSR), nil)
+			if got.Authorized != tt.wantAuthorize {
+				t.Errorf("Authorize() = %+v, want authorized %v", got, tt.wantAuthorize)
+			}
+			if lists != tt.wantLists {
+				t.Errorf("machines listed %d times, want %d", lists, tt.wantLists)
+			}
+
+			// The machines listed again are reused by the following reconciles.
+			if cached, _, ok := approver.machines.get(approver.clock().Now(), approver.Config.MachineCache.ttl()); !ok || (nodeRefMachine(cached, "panda") != nil) != tt.wantAuthorize {
+				t.Errorf("cached machines = %v, want node ref set %v", cached, tt.wantAuthorize)
+			}
+		})
+	}
+}
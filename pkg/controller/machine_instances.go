@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"sync"
+	"time"
+
+	machinehandlerpkg "github.com/openshift/cluster-machine-approver/pkg/machinehandler"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// machineInstanceTracker remembers the provider ID first observed for each
+// machine, to detect the provider instance backing a machine being replaced.
+// The zero value is ready to use.
+type machineInstanceTracker struct {
+	lock      sync.Mutex
+	instances map[types.UID]*machineInstance
+}
+
+type machineInstance struct {
+	providerID string
+	replacedAt time.Time
+}
+
+// observe records the provider ID of the given machine and returns the time at
+// which its provider instance was last seen to be replaced. A zero time is
+// returned when no replacement has been observed. Replacements observed now are
+// recorded at currentTime.
+func (t *machineInstanceTracker) observe(machine *machinehandlerpkg.Machine, currentTime time.Time) time.Time {
+	if machine.Spec.ProviderID == nil || *machine.Spec.ProviderID == "" {
+		// Not yet provisioned, nothing to compare against.
+		return time.Time{}
+	}
+	providerID := *machine.Spec.ProviderID
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.instances == nil {
+		t.instances = map[types.UID]*machineInstance{}
+	}
+
+	instance, ok := t.instances[machine.UID]
+	if !ok {
+		t.instances[machine.UID] = &machineInstance{providerID: providerID}
+		return time.Time{}
+	}
+
+	if instance.providerID != providerID {
+		instance.providerID = providerID
+		instance.replacedAt = currentTime
+	}
+
+	return instance.replacedAt
+}
@@ -0,0 +1,311 @@
+package controller
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	sanTypeIPv4      = "ipv4"
+	sanTypeIPv6      = "ipv6"
+	sanTypeDualStack = "dual-stack"
+	sanTypeDNSOnly   = "dns-only"
+	sanTypeOther     = "other"
+)
+
+// Kinds of node CSRs.
+const (
+	csrKindClient  = "client"
+	csrKindServing = "serving"
+)
+
+// Reasons for failures to retrieve the serving cert of kubelets.
+const (
+	dialFailureTimeout    = "timeout"
+	dialFailureCancelled  = "cancelled"
+	dialFailureConnection = "connection"
+	dialFailureTLS        = "tls"
+	dialFailureNoAddress  = "no_address"
+	dialFailureNoPort     = "no_port"
+)
+
+// Causes of serving CSRs falling back from the renewal to the machine-api flow.
+const (
+	renewalFallbackNoCA           = "no_ca"
+	renewalFallbackDisabled       = "disabled"
+	renewalFallbackDialFailed     = "dial_failed"
+	renewalFallbackCertNotFound   = "cert_not_found"
+	renewalFallbackStaleCert      = "stale_cert"
+	renewalFallbackRenewalInvalid = "renewal_invalid"
+)
+
+// Reasons for the decisions taken on node CSRs. Reasons for which CSRs may be
+// quarantined are also used as is. They are set as the denial reason
+// annotation of CSRs that are not approved, and must not be changed.
+const (
+	decisionReasonMachine                = "Machine"
+	decisionReasonRenewal                = "Renewal"
+	decisionReasonEgressIPRenewal        = "EgressIPRenewal"
+	decisionReasonFlowDisabled           = "FlowDisabled"
+	decisionReasonApprovalPaused         = "ApprovalPaused"
+	decisionReasonNotNodeBootstrapper    = "NotNodeBootstrapper"
+	decisionReasonSourceNetwork          = "SourceNetworkNotAllowed"
+	decisionReasonInvalidCommonName      = "InvalidCommonName"
+	decisionReasonNodeNameNotAllowed     = "NodeNameNotAllowed"
+	decisionReasonInvalidRequest         = "InvalidRequest"
+	decisionReasonWeakKey                = "WeakKey"
+	decisionReasonTooManySANs            = "TooManySANs"
+	decisionReasonApprovalRateExceeded   = "ApprovalRateExceeded"
+	decisionReasonNodeLookupFailed       = "NodeLookupFailed"
+	decisionReasonNodeExists             = "NodeExists"
+	decisionReasonNodeReRequested        = "NodeReRequested"
+	decisionReasonMachineNotFound        = "MachineNotFound"
+	decisionReasonMachineLookupExpired   = "MachineLookupExpired"
+	decisionReasonAmbiguousMachine       = "AmbiguousMachine"
+	decisionReasonNoMachineAddresses     = "NoMachineAddresses"
+	decisionReasonNodeRefExists          = "NodeRefExists"
+	decisionReasonMachineTooRecent       = "MachineTooRecent"
+	decisionReasonCSRPredatesMachine     = "CSRPredatesMachine"
+	decisionReasonCSRPastTimeWindow      = "CSRPastTimeWindow"
+	decisionReasonKubeletVersion         = "KubeletVersion"
+	decisionReasonNodeHostnameMismatch   = "NodeHostnameMismatch"
+	decisionReasonRenewalRequired        = "RenewalRequired"
+	decisionReasonMachineTerminating     = "MachineTerminating"
+	decisionReasonMachinePhase           = "MachinePhaseNotAllowed"
+	decisionReasonPlatformLookupFailed   = "PlatformLookupFailed"
+	decisionReasonEgressLookupFailed     = "EgressLookupFailed"
+	decisionReasonDeadlineExceeded       = "DeadlineExceeded"
+	decisionReasonAuthorizationExhausted = "AuthorizationExhausted"
+)
+
+var (
+	// servingSANTypesTotal counts approved serving CSRs by the types of SANs they requested.
+	servingSANTypesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mapi_csr_approved_serving_san_types_total",
+		Help: "Count of approved node serving CSRs by the types of Subject Alternative Names requested",
+	}, []string{"type"})
+
+	// approvalOverridesTotal counts failed soft checks overridden by an administrator.
+	approvalOverridesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mapi_csr_approval_overrides_total",
+		Help: "Count of failed CSR checks overridden by an administrator using an approval override annotation",
+	}, []string{"check"})
+
+	// suppressedEventsTotal counts events not recorded due to rate limiting or coalescing.
+	suppressedEventsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mapi_csr_events_suppressed_total",
+		Help: "Count of events on CSRs not recorded due to rate limiting or coalescing of identical events",
+	}, []string{"type"})
+
+	// csrApprovedTotal counts node CSRs authorized for approval.
+	csrApprovedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mapi_csr_approved_total",
+		Help: "Count of node CSRs authorized for approval by kind and reason",
+	}, []string{"kind", "reason"})
+
+	// csrDeniedTotal counts node CSRs declined.
+	csrDeniedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mapi_csr_denied_total",
+		Help: "Count of node CSRs declined by kind and reason",
+	}, []string{"kind", "reason"})
+
+	// csrErroredTotal counts node CSRs not approved due to an error, after which they are requeued.
+	csrErroredTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mapi_csr_errored_total",
+		Help: "Count of node CSRs not approved due to an error, and requeued, by kind and reason",
+	}, []string{"kind", "reason"})
+
+	// csrInvalidSignatureTotal counts CSRs declined as their signature doesn't match their public key.
+	csrInvalidSignatureTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "mapi_csr_invalid_signature_total",
+		Help: "Count of CSRs declined as their signature does not match their public key",
+	})
+
+	// csrWouldApproveTotal counts node CSRs that would have been approved in audit only mode.
+	csrWouldApproveTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mapi_csr_would_approve_total",
+		Help: "Count of node CSRs that would have been approved, when running in audit only mode, by kind",
+	}, []string{"kind"})
+
+	// kubeletDialDuration observes the time taken to retrieve the serving cert of kubelets.
+	kubeletDialDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "mapi_csr_kubelet_dial_duration_seconds",
+		Help:    "Duration of the connections to kubelets to retrieve their serving cert, including the TLS handshake",
+		Buckets: prometheus.ExponentialBuckets(0.005, 2, 14),
+	})
+
+	// machineToApprovalSeconds tracks the delay between the creation of machines and the approval of their node client CSRs.
+	machineToApprovalSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "mapi_csr_machine_to_approval_seconds",
+		Help:    "Delay between the creation of machines and the approval of the client CSRs of their nodes",
+		Buckets: prometheus.ExponentialBuckets(15, 2, 12),
+	})
+
+	// kubeletDialFailuresTotal counts failures to retrieve the serving cert of kubelets.
+	kubeletDialFailuresTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mapi_csr_kubelet_dial_failures_total",
+		Help: "Count of failures to retrieve the serving cert of kubelets by reason",
+	}, []string{"reason"})
+
+	// renewalFallbackTotal counts serving CSRs not authorized as renewals of the current serving cert.
+	renewalFallbackTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mapi_csr_renewal_fallback_total",
+		Help: "Count of serving CSRs falling back from the renewal of the current serving cert to the machine-api flow by cause",
+	}, []string{"cause"})
+
+	// pendingLimitExceededTotal counts reconciles skipped as too many node CSRs were recently pending.
+	pendingLimitExceededTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "mapi_csr_pending_limit_exceeded_total",
+		Help: "Count of CSR reconciles skipped as more node CSRs were recently pending than the threshold reported by mapi_max_pending_csr",
+	})
+
+	// csrFlowEnabled tracks whether the approval of each kind of node CSR is enabled.
+	csrFlowEnabled = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "mapi_csr_flow_enabled",
+		Help: "Whether node CSRs of the kind are approved, 1 when they are, 0 when the flow is disabled",
+	}, []string{"kind"})
+
+	// renewalCapable tracks whether serving CSRs can be approved as renewals of the current serving certs.
+	renewalCapable = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "mapi_csr_renewal_capable",
+		Help: "Whether serving CSRs can be approved as renewals of the serving certs currently presented by kubelets, 0 when the renewal fast path is disabled or no kubelet CA could be loaded",
+	})
+
+	// kubeletCACerts tracks the number of kubelet CA certs serving certs are verified against.
+	kubeletCACerts = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "mapi_csr_kubelet_ca_certs",
+		Help: "Number of kubelet CA certs loaded from the kubelet CA bundle and the additional kubelet CA sources",
+	})
+
+	// machineAPIAvailable tracks whether machines are served in any of the API groups of the approver.
+	machineAPIAvailable = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "mapi_machine_api_available",
+		Help: "Whether machines are served in any of the machine API groups, 1 when they are, 0 when only renewals can be approved",
+	})
+
+	// machineCacheAgeSeconds tracks how stale the machines CSRs are evaluated against are.
+	machineCacheAgeSeconds = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "mapi_machine_cache_age_seconds",
+		Help: "Time since the machines the last reconciled CSR was evaluated against were listed",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		servingSANTypesTotal,
+		approvalOverridesTotal,
+		suppressedEventsTotal,
+		csrApprovedTotal,
+		csrDeniedTotal,
+		csrErroredTotal,
+		csrWouldApproveTotal,
+		csrInvalidSignatureTotal,
+		kubeletDialDuration,
+		machineToApprovalSeconds,
+		kubeletDialFailuresTotal,
+		renewalFallbackTotal,
+		pendingLimitExceededTotal,
+		csrFlowEnabled,
+		renewalCapable,
+		kubeletCACerts,
+		machineAPIAvailable,
+		machineCacheAgeSeconds,
+	)
+}
+
+// servingSANType classifies the Subject Alternative Names requested by a
+// serving CSR. CSRs requesting URI or email SANs are classified as other.
+func servingSANType(csr *x509.CertificateRequest) string {
+	if len(csr.URIs) > 0 || len(csr.EmailAddresses) > 0 {
+		return sanTypeOther
+	}
+
+	var hasIPv4, hasIPv6 bool
+	for _, ip := range csr.IPAddresses {
+		if ip.To4() != nil {
+			hasIPv4 = true
+		} else {
+			hasIPv6 = true
+		}
+	}
+
+	switch {
+	case hasIPv4 && hasIPv6:
+		return sanTypeDualStack
+	case hasIPv4:
+		return sanTypeIPv4
+	case hasIPv6:
+		return sanTypeIPv6
+	case len(csr.DNSNames) > 0:
+		return sanTypeDNSOnly
+	default:
+		return sanTypeOther
+	}
+}
+
+// recordServingApproval updates the metrics tracking approved serving CSRs.
+func recordServingApproval(csr *x509.CertificateRequest) {
+	servingSANTypesTotal.WithLabelValues(servingSANType(csr)).Inc()
+}
+
+// recordDecision counts the outcome of the evaluation of a node CSR, and
+// returns it unchanged.
+func recordDecision(kind, reason string, authorize bool, err error) (bool, error) {
+	switch {
+	case err != nil:
+		csrErroredTotal.WithLabelValues(kind, reason).Inc()
+	case authorize:
+		csrApprovedTotal.WithLabelValues(kind, reason).Inc()
+	default:
+		csrDeniedTotal.WithLabelValues(kind, reason).Inc()
+	}
+	return authorize, err
+}
+
+// recordFlowsEnabled updates the metrics tracking whether the approval of
+// each kind of node CSR is enabled.
+func recordFlowsEnabled(config ClusterMachineApproverConfig) {
+	for kind, enabled := range map[string]bool{
+		csrKindClient:  config.NodeClientCert.enabled(),
+		csrKindServing: config.NodeServingCert.enabled(),
+	} {
+		value := 0.0
+		if enabled {
+			value = 1
+		}
+		csrFlowEnabled.WithLabelValues(kind).Set(value)
+	}
+}
+
+// kubeletDialFailureReason classifies the failure to retrieve the serving cert
+// of a kubelet. Failures to establish the TCP connection are told apart from
+// failures of the TLS handshake, e.g. when the serving cert is not trusted.
+func kubeletDialFailureReason(err error) string {
+	var netErr net.Error
+	var opErr *net.OpError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return dialFailureTimeout
+	case errors.Is(err, context.Canceled):
+		return dialFailureCancelled
+	case errors.As(err, &opErr) && opErr.Op == "dial":
+		return dialFailureConnection
+	default:
+		return dialFailureTLS
+	}
+}
+
+// recordKubeletDial updates the metrics tracking the connections to kubelets
+// started at the given time.
+func recordKubeletDial(start time.Time, err error) {
+	kubeletDialDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		kubeletDialFailuresTotal.WithLabelValues(kubeletDialFailureReason(err)).Inc()
+	}
+}
@@ -0,0 +1,275 @@
+package controller
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestServingSANType(t *testing.T) {
+	uri, _ := url.Parse("spiffe://cluster/node/test")
+
+	tests := []struct {
+		name string
+		csr  *x509.CertificateRequest
+		want string
+	}{
+		{
+			name: "ipv4 only",
+			csr: &x509.CertificateRequest{
+				DNSNames:    []string{"node1"},
+				IPAddresses: []net.IP{net.ParseIP("10.0.0.1")},
+			},
+			want: sanTypeIPv4,
+		},
+		{
+			name: "ipv6 only",
+			csr: &x509.CertificateRequest{
+				DNSNames:    []string{"node1"},
+				IPAddresses: []net.IP{net.ParseIP("fd00::1")},
+			},
+			want: sanTypeIPv6,
+		},
+		{
+			name: "dual stack",
+			csr: &x509.CertificateRequest{
+				IPAddresses: []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("fd00::1")},
+			},
+			want: sanTypeDualStack,
+		},
+		{
+			name: "dns only",
+			csr: &x509.CertificateRequest{
+				DNSNames: []string{"node1", "node1.local"},
+			},
+			want: sanTypeDNSOnly,
+		},
+		{
+			name: "uri",
+			csr: &x509.CertificateRequest{
+				IPAddresses: []net.IP{net.ParseIP("10.0.0.1")},
+				URIs:        []*url.URL{uri},
+			},
+			want: sanTypeOther,
+		},
+		{
+			name: "no SANs",
+			csr:  &x509.CertificateRequest{},
+			want: sanTypeOther,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := servingSANType(tt.csr); got != tt.want {
+				t.Errorf("got: %s, want: %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordServingApproval(t *testing.T) {
+	before := counterValue(t, servingSANTypesTotal.WithLabelValues(sanTypeDualStack))
+
+	recordServingApproval(&x509.CertificateRequest{
+		IPAddresses: []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("fd00::1")},
+	})
+
+	if after := counterValue(t, servingSANTypesTotal.WithLabelValues(sanTypeDualStack)); after != before+1 {
+		t.Errorf("expected counter to be incremented from %v, got %v", before, after)
+	}
+}
+
+func TestRecordDecision(t *testing.T) {
+	tests := []struct {
+		name      string
+		authorize bool
+		err       error
+		counter   *prometheus.CounterVec
+	}{
+		{
+			name:      "approved",
+			authorize: true,
+			counter:   csrApprovedTotal,
+		},
+		{
+			name:    "denied",
+			counter: csrDeniedTotal,
+		},
+		{
+			name:    "errored",
+			err:     errors.New("panda"),
+			counter: csrErroredTotal,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := counterValue(t, tt.counter.WithLabelValues(csrKindClient, decisionReasonMachine))
+
+			authorize, err := recordDecision(csrKindClient, decisionReasonMachine, tt.authorize, tt.err)
+			if authorize != tt.authorize || err != tt.err {
+				t.Errorf("recordDecision() = %v, %v, want %v, %v", authorize, err, tt.authorize, tt.err)
+			}
+
+			if after := counterValue(t, tt.counter.WithLabelValues(csrKindClient, decisionReasonMachine)); after != before+1 {
+				t.Errorf("expected counter to be incremented from %v, got %v", before, after)
+			}
+		})
+	}
+}
+
+func TestAuthorizeCSRRecordsDecision(t *testing.T) {
+	req := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "panda-csr"},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Request: []byte(clientGood),
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageKeyEncipherment,
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageClientAuth,
+			},
+			Username: nodeBootstrapperUsername,
+			Groups:   nodeBootstrapperGroups.List(),
+		},
+	}
+	approver := &CertificateApprover{
+		NodeClient: fake.NewClientBuilder().WithObjects(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "panda"}}).Build(),
+	}
+
+	before := counterValue(t, csrDeniedTotal.WithLabelValues(csrKindClient, decisionReasonNodeExists))
+
+	if _, authorize, err := approver.authorizeCSR(context.Background(), nil, req, parseCR(t, clientGood), nil); authorize || err != nil {
+		t.Fatalf("authorizeCSR() = %v, %v, want false", authorize, err)
+	}
+
+	if after := counterValue(t, csrDeniedTotal.WithLabelValues(csrKindClient, decisionReasonNodeExists)); after != before+1 {
+		t.Errorf("expected denied counter to be incremented from %v, got %v", before, after)
+	}
+}
+
+func TestKubeletDialFailureReason(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			name: "connection refused",
+			err:  &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED},
+			want: dialFailureConnection,
+		},
+		{
+			name: "connect timeout",
+			err:  &net.OpError{Op: "dial", Net: "tcp", Err: os.ErrDeadlineExceeded},
+			want: dialFailureTimeout,
+		},
+		{
+			name: "handshake timeout",
+			err:  context.DeadlineExceeded,
+			want: dialFailureTimeout,
+		},
+		{
+			name: "cancelled",
+			err:  fmt.Errorf("handshake: %w", context.Canceled),
+			want: dialFailureCancelled,
+		},
+		{
+			name: "untrusted serving cert",
+			err:  x509.UnknownAuthorityError{},
+			want: dialFailureTLS,
+		},
+		{
+			name: "not TLS",
+			err:  tls.RecordHeaderError{Msg: "first record does not look like a TLS handshake"},
+			want: dialFailureTLS,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := kubeletDialFailureReason(tt.err); got != tt.want {
+				t.Errorf("got: %s, want: %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetServingCertRecordsDialFailures(t *testing.T) {
+	certPool := x509.NewCertPool()
+	certPool.AddCert(parseCert(t, rootCertGood))
+
+	// The kubelet of a node without addresses can't be dialed.
+	before := counterValue(t, kubeletDialFailuresTotal.WithLabelValues(dialFailureNoAddress))
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "test"}}
+	if _, err := getServingCert(context.Background(), fake.NewFakeClient(node), nil, "test", []*x509.CertPool{certPool}, false, "", 0, time.Second, baseTime); err == nil {
+		t.Fatalf("expected an error retrieving the serving cert of a node without addresses")
+	}
+	if after := counterValue(t, kubeletDialFailuresTotal.WithLabelValues(dialFailureNoAddress)); after != before+1 {
+		t.Errorf("expected no address counter to be incremented from %v, got %v", before, after)
+	}
+
+	// Nothing is listening on the port of a closed listener.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	listener.Close()
+	node.Status = corev1.NodeStatus{
+		Addresses: []corev1.NodeAddress{
+			{Type: corev1.NodeInternalIP, Address: "127.0.0.1"},
+		},
+		DaemonEndpoints: corev1.NodeDaemonEndpoints{
+			KubeletEndpoint: corev1.DaemonEndpoint{
+				Port: int32(listener.Addr().(*net.TCPAddr).Port),
+			},
+		},
+	}
+
+	before = counterValue(t, kubeletDialFailuresTotal.WithLabelValues(dialFailureConnection))
+	if _, err := getServingCert(context.Background(), fake.NewFakeClient(node), nil, "test", []*x509.CertPool{certPool}, false, "", 0, time.Second, baseTime); err == nil {
+		t.Fatalf("expected an error retrieving the serving cert of an unreachable kubelet")
+	}
+	if after := counterValue(t, kubeletDialFailuresTotal.WithLabelValues(dialFailureConnection)); after != before+1 {
+		t.Errorf("expected connection counter to be incremented from %v, got %v", before, after)
+	}
+}
+
+func counterValue(t *testing.T, counter prometheus.Counter) float64 {
+	metric := &dto.Metric{}
+	if err := counter.Write(metric); err != nil {
+		t.Fatalf("failed to read counter: %v", err)
+	}
+	return metric.GetCounter().GetValue()
+}
+
+func histogramValue(t *testing.T, histogram prometheus.Histogram) (uint64, float64) {
+	metric := &dto.Metric{}
+	if err := histogram.Write(metric); err != nil {
+		t.Fatalf("failed to read histogram: %v", err)
+	}
+	return metric.GetHistogram().GetSampleCount(), metric.GetHistogram().GetSampleSum()
+}
+
+func gaugeValue(t *testing.T, gauge prometheus.Gauge) float64 {
+	metric := &dto.Metric{}
+	if err := gauge.Write(metric); err != nil {
+		t.Fatalf("failed to read gauge: %v", err)
+	}
+	return metric.GetGauge().GetValue()
+}
@@ -40,7 +40,7 @@ var kubeletClientUsages = []certificatesv1.KeyUsage{
 	certificatesv1.UsageClientAuth,
 }
 
-func isNodeClientCert(csr *certificatesv1.CertificateSigningRequest, x509cr *x509.CertificateRequest) bool {
+func isNodeClientCert(nodeUserPrefix string, csr *certificatesv1.CertificateSigningRequest, x509cr *x509.CertificateRequest) bool {
 	if !reflect.DeepEqual([]string{"system:nodes"}, x509cr.Subject.Organization) {
 		return false
 	}
@@ -50,7 +50,7 @@ func isNodeClientCert(csr *certificatesv1.CertificateSigningRequest, x509cr *x50
 	if !hasExactUsages(csr, kubeletClientUsagesLegacy) && !hasExactUsages(csr, kubeletClientUsages) {
 		return false
 	}
-	if !strings.HasPrefix(x509cr.Subject.CommonName, "system:node:") {
+	if !strings.HasPrefix(x509cr.Subject.CommonName, nodeUserPrefix) {
 		return false
 	}
 	return true
@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"bytes"
+	"crypto/x509"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// verifyOCSPStaple checks that the OCSP response stapled with the given
+// certificate reports it as good. The response must be signed by the issuer of
+// the certificate, or by a responder certificate delegated by the issuer.
+// Certificates presented without a staple are not checked. The staple must be
+// valid at currentTime.
+func verifyOCSPStaple(cert, issuer *x509.Certificate, staple []byte, currentTime time.Time) error {
+	if len(staple) == 0 {
+		return nil
+	}
+
+	resp, err := ocsp.ParseResponseForCert(staple, cert, issuer)
+	if err != nil {
+		return fmt.Errorf("failed to parse OCSP staple: %v", err)
+	}
+	// The library checks that a delegated responder is signed by the issuer,
+	// but not that it is allowed to sign OCSP responses.
+	if resp.Certificate != nil && !bytes.Equal(resp.Certificate.Raw, issuer.Raw) && !hasExtKeyUsage(resp.Certificate, x509.ExtKeyUsageOCSPSigning) {
+		return fmt.Errorf("invalid OCSP staple signature: responder certificate not allowed for OCSP signing")
+	}
+
+	if resp.ThisUpdate.After(currentTime.Add(maxMachineClockSkew)) {
+		return fmt.Errorf("OCSP staple for serial %s is not yet valid", cert.SerialNumber)
+	}
+	if !resp.NextUpdate.IsZero() && resp.NextUpdate.Before(currentTime) {
+		return fmt.Errorf("OCSP staple for serial %s has expired", cert.SerialNumber)
+	}
+
+	switch resp.Status {
+	case ocsp.Good:
+		return nil
+	case ocsp.Unknown:
+		return fmt.Errorf("OCSP staple reports unknown status for serial %s", cert.SerialNumber)
+	default:
+		return fmt.Errorf("OCSP staple reports serial %s as revoked at %s", cert.SerialNumber, resp.RevokedAt)
+	}
+}
+
+func hasExtKeyUsage(cert *x509.Certificate, usage x509.ExtKeyUsage) bool {
+	for _, u := range cert.ExtKeyUsage {
+		if u == usage {
+			return true
+		}
+	}
+	return false
+}
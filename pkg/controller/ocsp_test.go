@@ -0,0 +1,177 @@
+package controller
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+var testOCSPSerialNumber = big.NewInt(42)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func createTestCert(t *testing.T, template *x509.Certificate, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	template.NotBefore = baseTime.Add(-time.Hour)
+	template.NotAfter = baseTime.Add(time.Hour)
+
+	parentCert, parentKey := template, key
+	if parent != nil {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parentCert, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+
+	return &testCert{cert: cert, key: key}
+}
+
+func createTestCA(t *testing.T, name string) *testCert {
+	return createTestCert(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}, nil)
+}
+
+// createOCSPStaple builds an OCSP response for the given serial number, issued
+// by issuer and signed by signer.
+func createOCSPStaple(t *testing.T, serial *big.Int, issuer, signer *testCert, status int, nextUpdate time.Time) []byte {
+	template := ocsp.Response{
+		Status:       status,
+		SerialNumber: serial,
+		ThisUpdate:   baseTime.Add(-time.Minute),
+		NextUpdate:   nextUpdate,
+		RevokedAt:    baseTime.Add(-time.Minute),
+		IssuerHash:   crypto.SHA256,
+	}
+	if signer.cert != issuer.cert {
+		template.Certificate = signer.cert
+	}
+
+	staple, err := ocsp.CreateResponse(issuer.cert, signer.cert, template, signer.key)
+	if err != nil {
+		t.Fatalf("failed to create response: %v", err)
+	}
+	return staple
+}
+
+func TestVerifyOCSPStaple(t *testing.T) {
+	ca := createTestCA(t, "kubelet-ca")
+	otherCA := createTestCA(t, "other-ca")
+	leaf := createTestCert(t, &x509.Certificate{
+		SerialNumber: testOCSPSerialNumber,
+		Subject:      pkix.Name{CommonName: "system:node:panda"},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca)
+	responder := createTestCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "ocsp-responder"},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning},
+	}, ca)
+	nonResponder := createTestCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "not-a-responder"},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca)
+
+	nextUpdate := baseTime.Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		staple  []byte
+		wantErr string
+	}{
+		{
+			name: "no staple",
+		},
+		{
+			name:   "good",
+			staple: createOCSPStaple(t, testOCSPSerialNumber, ca, ca, ocsp.Good, nextUpdate),
+		},
+		{
+			name:   "good without next update",
+			staple: createOCSPStaple(t, testOCSPSerialNumber, ca, ca, ocsp.Good, time.Time{}),
+		},
+		{
+			name:    "revoked",
+			staple:  createOCSPStaple(t, testOCSPSerialNumber, ca, ca, ocsp.Revoked, nextUpdate),
+			wantErr: "OCSP staple reports serial 42 as revoked",
+		},
+		{
+			name:    "unknown",
+			staple:  createOCSPStaple(t, testOCSPSerialNumber, ca, ca, ocsp.Unknown, nextUpdate),
+			wantErr: "OCSP staple reports unknown status for serial 42",
+		},
+		{
+			name:    "expired",
+			staple:  createOCSPStaple(t, testOCSPSerialNumber, ca, ca, ocsp.Good, baseTime.Add(-time.Second)),
+			wantErr: "OCSP staple for serial 42 has expired",
+		},
+		{
+			name:    "other serial",
+			staple:  createOCSPStaple(t, big.NewInt(43), ca, ca, ocsp.Good, nextUpdate),
+			wantErr: "failed to parse OCSP staple: no response matching the supplied certificate",
+		},
+		{
+			name:    "signed by other CA",
+			staple:  createOCSPStaple(t, testOCSPSerialNumber, ca, &testCert{cert: ca.cert, key: otherCA.key}, ocsp.Good, nextUpdate),
+			wantErr: "failed to parse OCSP staple: bad OCSP signature",
+		},
+		{
+			name:   "good from delegated responder",
+			staple: createOCSPStaple(t, testOCSPSerialNumber, ca, responder, ocsp.Good, nextUpdate),
+		},
+		{
+			name:    "good from responder not allowed for OCSP signing",
+			staple:  createOCSPStaple(t, testOCSPSerialNumber, ca, nonResponder, ocsp.Good, nextUpdate),
+			wantErr: "invalid OCSP staple signature: responder certificate not allowed for OCSP signing",
+		},
+		{
+			name:    "good from responder of other CA",
+			staple:  createOCSPStaple(t, testOCSPSerialNumber, ca, otherCA, ocsp.Good, nextUpdate),
+			wantErr: "failed to parse OCSP staple: bad OCSP signature",
+		},
+		{
+			name:    "garbage",
+			staple:  []byte("panda"),
+			wantErr: "failed to parse OCSP staple",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyOCSPStaple(leaf.cert, ca.cert, tt.staple, baseTime)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("got: %v, want: %s", err, tt.wantErr)
+			}
+		})
+	}
+}
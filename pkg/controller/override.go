@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"encoding/json"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// approvalOverrideAnnotation may be set by an administrator on a CSR to
+	// have it approved despite failing soft checks. Its value should justify
+	// the override.
+	approvalOverrideAnnotation = "machineapprover.openshift.io/approval-override"
+
+	approvalOverrideEventReason = "ApprovalOverride"
+)
+
+// Soft checks that can be overridden. Checks guarding against impersonation
+// of a node, such as the machine and address matching, or against the replay
+// of a client CSR, such as its creation time, can't be overridden.
+const (
+	overrideCheckKubeletVersion = "KubeletVersion"
+)
+
+// overrideSoftFailure returns true if the CSR carries an approval override, in
+// which case the failure of the given soft check is ignored. Overrides are
+// recorded in the audit log, as an event on the CSR and in metrics.
+//
+// The requester of a CSR may set any annotation when creating it, so the
+// override is only honoured when set afterwards, by another field manager than
+// the one which created the CSR, as recorded in its managed fields. Node
+// bootstrappers and nodes may create CSRs, not update them.
+func (m *CertificateApprover) overrideSoftFailure(req *certificatesv1.CertificateSigningRequest, check string, cause error) bool {
+	justification, ok := req.Annotations[approvalOverrideAnnotation]
+	if !ok {
+		return false
+	}
+	if !overrideSetAfterCreation(req) {
+		klog.Warningf("%v: Ignoring %s annotation not set after the CSR creation by another field manager", req.Name, approvalOverrideAnnotation)
+		return false
+	}
+
+	klog.Infof("%v: AUDIT: failed %s check of CSR from %s overridden by %s annotation (%q): %v",
+		req.Name, check, req.Spec.Username, approvalOverrideAnnotation, justification, cause)
+	m.eventf(req, corev1.EventTypeWarning, approvalOverrideEventReason,
+		"Failed %s check overridden by %s annotation (%q): %v", check, approvalOverrideAnnotation, justification, cause)
+	approvalOverridesTotal.WithLabelValues(check).Inc()
+
+	return true
+}
+
+// overrideSetAfterCreation returns true if the approval override annotation
+// of the CSR is only owned by field managers which updated the CSR after its
+// creation, none of which created it.
+func overrideSetAfterCreation(req *certificatesv1.CertificateSigningRequest) bool {
+	creators := sets.NewString()
+	for _, entry := range req.ManagedFields {
+		if entry.Time == nil || !entry.Time.After(req.CreationTimestamp.Time) {
+			creators.Insert(entry.Manager)
+		}
+	}
+
+	owned := false
+	for _, entry := range req.ManagedFields {
+		if !ownsAnnotation(entry, approvalOverrideAnnotation) {
+			continue
+		}
+		if creators.Has(entry.Manager) {
+			return false
+		}
+		owned = true
+	}
+	return owned
+}
+
+// ownsAnnotation returns true if the managed fields entry owns the given
+// annotation.
+func ownsAnnotation(entry metav1.ManagedFieldsEntry, annotation string) bool {
+	if entry.FieldsV1 == nil {
+		return false
+	}
+	fields := map[string]map[string]map[string]json.RawMessage{}
+	if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+		return false
+	}
+	_, ok := fields["f:metadata"]["f:annotations"]["f:"+annotation]
+	return ok
+}
@@ -0,0 +1,309 @@
+package controller
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	machinehandlerpkg "github.com/openshift/cluster-machine-approver/pkg/machinehandler"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// overrideManagedFields returns managed fields recording the approval override
+// annotation as set by the given manager, at the given time.
+func overrideManagedFields(creator, manager string, at time.Time) []metav1.ManagedFieldsEntry {
+	created := metav1.NewTime(baseTime.Add(-time.Minute))
+	set := metav1.NewTime(at)
+	return []metav1.ManagedFieldsEntry{
+		{
+			Manager:    creator,
+			Operation:  metav1.ManagedFieldsOperationUpdate,
+			Time:       &created,
+			FieldsType: "FieldsV1",
+			FieldsV1:   &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:request":{}}}`)},
+		},
+		{
+			Manager:    manager,
+			Operation:  metav1.ManagedFieldsOperationUpdate,
+			Time:       &set,
+			FieldsType: "FieldsV1",
+			FieldsV1:   &metav1.FieldsV1{Raw: []byte(`{"f:metadata":{"f:annotations":{"f:` + approvalOverrideAnnotation + `":{}}}}`)},
+		},
+	}
+}
+
+func TestOverrideSetAfterCreation(t *testing.T) {
+	created := baseTime.Add(-time.Minute)
+
+	tests := []struct {
+		name          string
+		managedFields []metav1.ManagedFieldsEntry
+		want          bool
+	}{
+		{
+			name: "no managed fields",
+			want: false,
+		},
+		{
+			name:          "set at creation by the requester",
+			managedFields: overrideManagedFields("kubelet", "kubelet", created),
+			want:          false,
+		},
+		{
+			name:          "set at creation by another manager",
+			managedFields: overrideManagedFields("kubelet", "kubectl-annotate", created),
+			want:          false,
+		},
+		{
+			name: "set by the requester and updated by an administrator",
+			managedFields: append(overrideManagedFields("kubelet", "kubectl-annotate", baseTime),
+				overrideManagedFields("kubelet", "kubelet", created)[1]),
+			want: false,
+		},
+		{
+			name:          "set after creation by an administrator",
+			managedFields: overrideManagedFields("kubelet", "kubectl-annotate", baseTime),
+			want:          true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &certificatesv1.CertificateSigningRequest{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "panda-csr",
+					Annotations:       map[string]string{approvalOverrideAnnotation: "machine was stopped for maintenance"},
+					CreationTimestamp: metav1.NewTime(created),
+					ManagedFields:     tt.managedFields,
+				},
+			}
+			if got := overrideSetAfterCreation(req); got != tt.want {
+				t.Errorf("overrideSetAfterCreation() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthorizeCSRApprovalOverrideCreationTime(t *testing.T) {
+	// The creation time of client CSRs guards against their replay, it can't
+	// be overridden however the annotation was set.
+	machines := []machinehandlerpkg.Machine{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "panda-machine",
+			CreationTimestamp: creationTimestamp(-5 * time.Hour),
+		},
+		Status: machinehandlerpkg.MachineStatus{
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalDNS, Address: "panda"},
+			},
+		},
+	}}
+	clientReq := func(managedFields []metav1.ManagedFieldsEntry) *certificatesv1.CertificateSigningRequest {
+		req := &certificatesv1.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "panda-csr",
+				CreationTimestamp: creationTimestamp(-time.Minute),
+				ManagedFields:     managedFields,
+			},
+			Spec: certificatesv1.CertificateSigningRequestSpec{
+				Request: []byte(clientGood),
+				Usages: []certificatesv1.KeyUsage{
+					certificatesv1.UsageKeyEncipherment,
+					certificatesv1.UsageDigitalSignature,
+					certificatesv1.UsageClientAuth,
+				},
+				Username: nodeBootstrapperUsername,
+				Groups:   nodeBootstrapperGroups.List(),
+			},
+		}
+		if managedFields != nil {
+			req.Annotations = map[string]string{approvalOverrideAnnotation: "machine was stopped for maintenance"}
+		}
+		return req
+	}
+
+	tests := []struct {
+		name          string
+		managedFields []metav1.ManagedFieldsEntry
+	}{
+		{
+			name: "without override",
+		},
+		{
+			name:          "with override set at creation by the requester",
+			managedFields: overrideManagedFields("kubelet", "kubelet", baseTime.Add(-time.Minute)),
+		},
+		{
+			name:          "with override set by an administrator",
+			managedFields: overrideManagedFields("kubelet", "kubectl-annotate", baseTime),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			approver := &CertificateApprover{
+				NodeClient: fake.NewFakeClient(),
+				Recorder:   recorder,
+			}
+
+			_, authorize, err := approver.authorizeCSR(context.Background(), machines, clientReq(tt.managedFields), parseCR(t, clientGood), nil)
+			if authorize || err != nil {
+				t.Errorf("authorizeCSR() = %v, error = %v, want false", authorize, err)
+			}
+
+			select {
+			case event := <-recorder.Events:
+				if !strings.Contains(event, csrDeniedEventReason) {
+					t.Errorf("unexpected event: %s", event)
+				}
+			default:
+				t.Errorf("expected the decline to be recorded")
+			}
+		})
+	}
+}
+
+func TestAuthorizeCSRApprovalOverrideSecurityFailure(t *testing.T) {
+	// A machine that already has a node is a security failure which can't be
+	// overridden.
+	machines := []machinehandlerpkg.Machine{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "panda-machine",
+			CreationTimestamp: creationTimestamp(-5 * time.Minute),
+		},
+		Status: machinehandlerpkg.MachineStatus{
+			NodeRef: &corev1.ObjectReference{Name: "panda"},
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalDNS, Address: "panda"},
+			},
+		},
+	}}
+	req := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "panda-csr",
+			Annotations:       map[string]string{approvalOverrideAnnotation: "please"},
+			CreationTimestamp: creationTimestamp(-time.Minute),
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Request: []byte(clientGood),
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageKeyEncipherment,
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageClientAuth,
+			},
+			Username: nodeBootstrapperUsername,
+			Groups:   nodeBootstrapperGroups.List(),
+		},
+	}
+
+	approver := &CertificateApprover{NodeClient: fake.NewFakeClient()}
+	if _, authorize, err := approver.authorizeCSR(context.Background(), machines, req, parseCR(t, clientGood), nil); authorize || err != nil {
+		t.Errorf("authorizeCSR() = %v, error = %v, want false", authorize, err)
+	}
+}
+
+func TestAuthorizeCSRApprovalOverrideKubeletVersion(t *testing.T) {
+	machines := []machinehandlerpkg.Machine{{
+		ObjectMeta: metav1.ObjectMeta{Name: "panda-machine"},
+		Status: machinehandlerpkg.MachineStatus{
+			NodeRef: &corev1.ObjectReference{Name: "panda"},
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalDNS, Address: "panda"},
+				{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+			},
+		},
+	}}
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "panda"},
+		Status: corev1.NodeStatus{
+			NodeInfo: corev1.NodeSystemInfo{KubeletVersion: "v1.29.0"},
+		},
+	}
+	servingCSR := createCSR("system:node:panda", defaultOrgs, []net.IP{net.ParseIP("10.0.0.1")}, []string{"panda"})
+	servingReq := func(managedFields []metav1.ManagedFieldsEntry) *certificatesv1.CertificateSigningRequest {
+		return &certificatesv1.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "panda-serving-csr",
+				Annotations:       map[string]string{approvalOverrideAnnotation: "kubelet upgraded ahead of its machine"},
+				CreationTimestamp: creationTimestamp(-time.Minute),
+				ManagedFields:     managedFields,
+			},
+			Spec: certificatesv1.CertificateSigningRequestSpec{
+				Request: []byte(servingCSR),
+				Usages: []certificatesv1.KeyUsage{
+					certificatesv1.UsageDigitalSignature,
+					certificatesv1.UsageServerAuth,
+				},
+				Username: "system:node:panda",
+				Groups:   []string{"system:authenticated", "system:nodes"},
+			},
+		}
+	}
+
+	tests := []struct {
+		name          string
+		managedFields []metav1.ManagedFieldsEntry
+		wantReason    string
+		wantOverride  bool
+	}{
+		{
+			name:          "override set at creation by the requester",
+			managedFields: overrideManagedFields("kubelet", "kubelet", baseTime.Add(-time.Minute)),
+			wantReason:    decisionReasonKubeletVersion,
+		},
+		{
+			name:          "override set by an administrator",
+			managedFields: overrideManagedFields("kubelet", "kubectl-annotate", baseTime),
+			wantReason:    decisionReasonMachine,
+			wantOverride:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			approver := &CertificateApprover{
+				NodeClient: fake.NewFakeClient(node),
+				Recorder:   recorder,
+				Config: ClusterMachineApproverConfig{
+					NodeServingCert: NodeServingCert{
+						KubeletVersionCheck: KubeletVersionCheck{Enabled: true, MaxVersion: "v1.28.0"},
+					},
+				},
+			}
+
+			before := counterValue(t, approvalOverridesTotal.WithLabelValues(overrideCheckKubeletVersion))
+
+			got := approver.Authorize(context.Background(), machines, servingReq(tt.managedFields), parseCR(t, servingCSR), nil)
+			if got.Reason != tt.wantReason || got.Authorized != tt.wantOverride {
+				t.Errorf("Authorize() = %+v, want reason %s", got, tt.wantReason)
+			}
+
+			wantOverrides := before
+			if tt.wantOverride {
+				wantOverrides++
+			}
+			if after := counterValue(t, approvalOverridesTotal.WithLabelValues(overrideCheckKubeletVersion)); after != wantOverrides {
+				t.Errorf("expected %v overrides, got %v", wantOverrides, after)
+			}
+
+			select {
+			case event := <-recorder.Events:
+				if !tt.wantOverride || !strings.Contains(event, approvalOverrideEventReason) {
+					t.Errorf("unexpected event: %s", event)
+				}
+			default:
+				if tt.wantOverride {
+					t.Errorf("expected an event to be recorded")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// pausedKey is the key of the pause ConfigMap pausing approvals when true.
+const pausedKey = "paused"
+
+// approvalPaused returns whether approvals are paused by the pause ConfigMap.
+// Approvals are not paused when the ConfigMap doesn't exist, or its paused key
+// is not a boolean.
+func (m *CertificateApprover) approvalPaused(ctx context.Context) (bool, error) {
+	if !m.Config.Pause.enabled() {
+		return false, nil
+	}
+
+	configMap := &corev1.ConfigMap{}
+	if err := m.NodeClient.Get(ctx, m.Config.Pause.key(), configMap); err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get pause ConfigMap %s: %w", m.Config.Pause.key(), err)
+	}
+
+	value, ok := configMap.Data[pausedKey]
+	if !ok {
+		return false, nil
+	}
+	paused, err := strconv.ParseBool(value)
+	if err != nil {
+		klog.Warningf("Ignoring invalid %s value %q of pause ConfigMap %s", pausedKey, value, m.Config.Pause.key())
+		return false, nil
+	}
+	return paused, nil
+}
+
+// declineIfPaused declines the node CSR when approvals are paused, and returns
+// whether it did. CSRs for which it can't be determined are requeued.
+func (m *CertificateApprover) declineIfPaused(ctx context.Context, req *certificatesv1.CertificateSigningRequest, kind string) (AuthorizeResult, bool) {
+	paused, err := m.approvalPaused(ctx)
+	if err != nil {
+		klog.Errorf("%v: %v", req.Name, err)
+		return m.decide(ctx, req, kind, decisionReasonApprovalPaused, nil, false, err), true
+	}
+	if !paused {
+		return AuthorizeResult{}, false
+	}
+
+	klog.Warningf("%v: CSR approval is paused by ConfigMap %s, cannot approve", req.Name, m.Config.Pause.key())
+	m.eventf(req, corev1.EventTypeWarning, csrApprovalPausedEventReason, "CSR approval is paused by ConfigMap %s", m.Config.Pause.key())
+	return m.decide(ctx, req, kind, decisionReasonApprovalPaused, nil, false, nil), true
+}
+
+// pauseConfigMapFilter returns true for the pause ConfigMap, so that pending
+// CSRs are reconciled again when approvals are paused or resumed.
+func (m *CertificateApprover) pauseConfigMapFilter(obj runtime.Object) bool {
+	cm, ok := obj.(*corev1.ConfigMap)
+	return ok && m.Config.Pause.enabled() && client.ObjectKeyFromObject(cm) == m.Config.Pause.key()
+}
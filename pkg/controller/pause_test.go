@@ -0,0 +1,176 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	machinehandlerpkg "github.com/openshift/cluster-machine-approver/pkg/machinehandler"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	testingclock "k8s.io/utils/clock/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestAuthorizePaused(t *testing.T) {
+	machines := []machinehandlerpkg.Machine{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "panda-machine",
+			CreationTimestamp: creationTimestamp(-5 * time.Minute),
+		},
+		Status: machinehandlerpkg.MachineStatus{
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalDNS, Address: "panda"},
+			},
+		},
+	}}
+	req := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "panda-csr",
+			CreationTimestamp: creationTimestamp(-time.Minute),
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Request: []byte(clientGood),
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageKeyEncipherment,
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageClientAuth,
+			},
+			Username: nodeBootstrapperUsername,
+			Groups:   nodeBootstrapperGroups.List(),
+		},
+	}
+	pause := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "openshift-cluster-machine-approver",
+			Name:      "machine-approver-pause",
+		},
+	}
+
+	ctx := context.Background()
+	cl := fake.NewFakeClient(pause)
+	recorder := record.NewFakeRecorder(10)
+	clock := testingclock.NewFakeClock(time.Now())
+	approver := &CertificateApprover{
+		NodeClient: cl,
+		Recorder:   recorder,
+		Clock:      clock,
+		Config: ClusterMachineApproverConfig{
+			Pause: Pause{Namespace: pause.Namespace, Name: pause.Name},
+		},
+	}
+
+	steps := []struct {
+		name       string
+		data       map[string]string
+		delete     bool
+		wantPaused bool
+	}{
+		{
+			name: "paused key unset",
+		},
+		{
+			name:       "paused",
+			data:       map[string]string{pausedKey: "true"},
+			wantPaused: true,
+		},
+		{
+			name: "resumed",
+			data: map[string]string{pausedKey: "false"},
+		},
+		{
+			name: "invalid paused key",
+			data: map[string]string{pausedKey: "maybe"},
+		},
+		{
+			name:       "paused again",
+			data:       map[string]string{pausedKey: "True"},
+			wantPaused: true,
+		},
+		{
+			name:   "ConfigMap deleted",
+			delete: true,
+		},
+	}
+
+	for _, step := range steps {
+		// Don't coalesce the identical events of each pause.
+		clock.Step(time.Hour)
+
+		if step.delete {
+			if err := cl.Delete(ctx, pause); err != nil {
+				t.Fatalf("%s: failed to delete the pause ConfigMap: %v", step.name, err)
+			}
+		} else {
+			pause.Data = step.data
+			if err := cl.Update(ctx, pause); err != nil {
+				t.Fatalf("%s: failed to update the pause ConfigMap: %v", step.name, err)
+			}
+		}
+
+		result := approver.Authorize(ctx, machines, req.DeepCopy(), parseCR(t, clientGood), nil)
+		if result.Err != nil {
+			t.Errorf("%s: Authorize() error = %v", step.name, result.Err)
+		}
+		if result.Authorized == step.wantPaused {
+			t.Errorf("%s: Authorize() authorized = %v, want %v", step.name, result.Authorized, !step.wantPaused)
+		}
+		if paused := result.Reason == decisionReasonApprovalPaused; paused != step.wantPaused {
+			t.Errorf("%s: Authorize() reason = %q", step.name, result.Reason)
+		}
+
+		select {
+		case event := <-recorder.Events:
+			if !step.wantPaused || !strings.Contains(event, corev1.EventTypeWarning) || !strings.Contains(event, csrApprovalPausedEventReason) {
+				t.Errorf("%s: unexpected event: %s", step.name, event)
+			}
+		default:
+			if step.wantPaused {
+				t.Errorf("%s: expected an event to be recorded", step.name)
+			}
+		}
+	}
+}
+
+func TestPauseConfigMapFilter(t *testing.T) {
+	approver := &CertificateApprover{
+		Config: ClusterMachineApproverConfig{
+			Pause: Pause{Namespace: "ns", Name: "pause"},
+		},
+	}
+
+	tests := []struct {
+		name string
+		obj  *corev1.ConfigMap
+		want bool
+	}{
+		{
+			name: "pause ConfigMap",
+			obj:  &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "pause"}},
+			want: true,
+		},
+		{
+			name: "other namespace",
+			obj:  &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "pause"}},
+		},
+		{
+			name: "other name",
+			obj:  &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "other"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := approver.pauseConfigMapFilter(tt.obj); got != tt.want {
+				t.Errorf("pauseConfigMapFilter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if (&CertificateApprover{}).pauseConfigMapFilter(&corev1.ConfigMap{}) {
+		t.Errorf("pauseConfigMapFilter() = true without a pause ConfigMap")
+	}
+}
@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+)
+
+// Public key algorithms of node CSRs, including the curve of ECDSA keys.
+const (
+	keyAlgorithmRSA       = "RSA"
+	keyAlgorithmECDSAP256 = "ECDSA-P256"
+	keyAlgorithmECDSAP384 = "ECDSA-P384"
+	keyAlgorithmECDSAP521 = "ECDSA-P521"
+	keyAlgorithmEd25519   = "Ed25519"
+
+	defaultMinRSAKeyBits = 2048
+)
+
+var keyAlgorithms = []string{
+	keyAlgorithmRSA,
+	keyAlgorithmECDSAP256,
+	keyAlgorithmECDSAP384,
+	keyAlgorithmECDSAP521,
+	keyAlgorithmEd25519,
+}
+
+var defaultKeyAlgorithms = []string{
+	keyAlgorithmRSA,
+	keyAlgorithmECDSAP256,
+	keyAlgorithmECDSAP384,
+}
+
+// publicKeyAlgorithm returns the algorithm of the public key of the CSR.
+func publicKeyAlgorithm(csr *x509.CertificateRequest) (string, error) {
+	return keyAlgorithm(csr.PublicKey, csr.PublicKeyAlgorithm)
+}
+
+// keyAlgorithm returns the algorithm of the public key, of a CSR or a cert,
+// parsed as the given x509 algorithm.
+func keyAlgorithm(publicKey crypto.PublicKey, x509Algorithm x509.PublicKeyAlgorithm) (string, error) {
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		return keyAlgorithmRSA, nil
+	case *ecdsa.PublicKey:
+		switch key.Curve {
+		case elliptic.P256():
+			return keyAlgorithmECDSAP256, nil
+		case elliptic.P384():
+			return keyAlgorithmECDSAP384, nil
+		case elliptic.P521():
+			return keyAlgorithmECDSAP521, nil
+		}
+		return "", fmt.Errorf("unsupported ECDSA curve %s", key.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return keyAlgorithmEd25519, nil
+	}
+	return "", fmt.Errorf("unsupported public key algorithm %s", x509Algorithm)
+}
+
+// verifyKeyAlgorithmContinuity checks that the public key of a renewal CSR
+// uses the same algorithm as the current cert it renews.
+func verifyKeyAlgorithmContinuity(csr *x509.CertificateRequest, currentCert *x509.Certificate) error {
+	csrAlgorithm, err := publicKeyAlgorithm(csr)
+	if err != nil {
+		return err
+	}
+	certAlgorithm, err := keyAlgorithm(currentCert.PublicKey, currentCert.PublicKeyAlgorithm)
+	if err != nil {
+		return fmt.Errorf("current cert: %v", err)
+	}
+	if csrAlgorithm != certAlgorithm {
+		return fmt.Errorf("CSR public key algorithm %s does not match %s of the current cert", csrAlgorithm, certAlgorithm)
+	}
+	return nil
+}
+
+// validatePublicKey checks that the public key of the CSR uses one of the
+// allowed algorithms, and that RSA keys are large enough.
+func validatePublicKey(config ClusterMachineApproverConfig, csr *x509.CertificateRequest) error {
+	algorithm, err := publicKeyAlgorithm(csr)
+	if err != nil {
+		return err
+	}
+
+	allowed := false
+	for _, a := range config.allowedKeyAlgorithms() {
+		if a == algorithm {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("public key algorithm %s is not allowed", algorithm)
+	}
+
+	if key, ok := csr.PublicKey.(*rsa.PublicKey); ok {
+		if bits, minBits := key.N.BitLen(), config.minRSAKeyBits(); bits < minBits {
+			return fmt.Errorf("RSA key of %d bits is below the minimum of %d bits", bits, minBits)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,181 @@
+package controller
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"testing"
+
+	"k8s.io/utils/pointer"
+)
+
+func TestValidatePublicKey(t *testing.T) {
+	rsaKey := func(bits int) crypto.PublicKey {
+		key, err := rsa.GenerateKey(rand.Reader, bits)
+		if err != nil {
+			t.Fatalf("failed to generate RSA key: %v", err)
+		}
+		return key.Public()
+	}
+	ecdsaKey := func(curve elliptic.Curve) crypto.PublicKey {
+		key, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			t.Fatalf("failed to generate ECDSA key: %v", err)
+		}
+		return key.Public()
+	}
+	ed25519Key, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate Ed25519 key: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		config  ClusterMachineApproverConfig
+		key     crypto.PublicKey
+		wantErr string
+	}{
+		{
+			name: "RSA 2048",
+			key:  rsaKey(2048),
+		},
+		{
+			name:    "RSA 1024",
+			key:     rsaKey(1024),
+			wantErr: "RSA key of 1024 bits is below the minimum of 2048 bits",
+		},
+		{
+			name:   "RSA 1024 with lower minimum",
+			config: ClusterMachineApproverConfig{MinRSAKeyBits: pointer.Int(1024)},
+			key:    rsaKey(1024),
+		},
+		{
+			name:    "RSA 2048 with higher minimum",
+			config:  ClusterMachineApproverConfig{MinRSAKeyBits: pointer.Int(3072)},
+			key:     rsaKey(2048),
+			wantErr: "RSA key of 2048 bits is below the minimum of 3072 bits",
+		},
+		{
+			name: "ECDSA P-256",
+			key:  ecdsaKey(elliptic.P256()),
+		},
+		{
+			name: "ECDSA P-384",
+			key:  ecdsaKey(elliptic.P384()),
+		},
+		{
+			name:    "ECDSA P-224",
+			key:     ecdsaKey(elliptic.P224()),
+			wantErr: "unsupported ECDSA curve P-224",
+		},
+		{
+			name:    "ECDSA P-521 not allowed by default",
+			key:     ecdsaKey(elliptic.P521()),
+			wantErr: "public key algorithm ECDSA-P521 is not allowed",
+		},
+		{
+			name:   "ECDSA P-521 allowed",
+			config: ClusterMachineApproverConfig{AllowedKeyAlgorithms: []string{keyAlgorithmECDSAP521}},
+			key:    ecdsaKey(elliptic.P521()),
+		},
+		{
+			name:    "Ed25519 not allowed by default",
+			key:     ed25519Key,
+			wantErr: "public key algorithm Ed25519 is not allowed",
+		},
+		{
+			name:    "RSA not allowed",
+			config:  ClusterMachineApproverConfig{AllowedKeyAlgorithms: []string{keyAlgorithmECDSAP256}},
+			key:     rsaKey(2048),
+			wantErr: "public key algorithm RSA is not allowed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			csr := &x509.CertificateRequest{PublicKey: tt.key}
+			if err := validatePublicKey(tt.config, csr); errString(err) != tt.wantErr {
+				t.Errorf("validatePublicKey() error = %v, wantErr %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyKeyAlgorithmContinuity(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+	ecdsaKey := func(curve elliptic.Curve) crypto.PublicKey {
+		key, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			t.Fatalf("failed to generate ECDSA key: %v", err)
+		}
+		return key.Public()
+	}
+	ed25519Key, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate Ed25519 key: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		certKey crypto.PublicKey
+		csrKey  crypto.PublicKey
+		wantErr string
+	}{
+		{
+			name:    "RSA",
+			certKey: rsaKey.Public(),
+			csrKey:  rsaKey.Public(),
+		},
+		{
+			name:    "ECDSA",
+			certKey: ecdsaKey(elliptic.P256()),
+			csrKey:  ecdsaKey(elliptic.P256()),
+		},
+		{
+			name:    "Ed25519",
+			certKey: ed25519Key,
+			csrKey:  ed25519Key,
+		},
+		{
+			name:    "RSA to ECDSA",
+			certKey: rsaKey.Public(),
+			csrKey:  ecdsaKey(elliptic.P256()),
+			wantErr: "CSR public key algorithm ECDSA-P256 does not match RSA of the current cert",
+		},
+		{
+			name:    "ECDSA curve changed",
+			certKey: ecdsaKey(elliptic.P256()),
+			csrKey:  ecdsaKey(elliptic.P384()),
+			wantErr: "CSR public key algorithm ECDSA-P384 does not match ECDSA-P256 of the current cert",
+		},
+		{
+			name:    "ECDSA to Ed25519",
+			certKey: ecdsaKey(elliptic.P256()),
+			csrKey:  ed25519Key,
+			wantErr: "CSR public key algorithm Ed25519 does not match ECDSA-P256 of the current cert",
+		},
+		{
+			name:    "unsupported current cert key",
+			certKey: ecdsaKey(elliptic.P224()),
+			csrKey:  ecdsaKey(elliptic.P256()),
+			wantErr: "current cert: unsupported ECDSA curve P-224",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			csr := &x509.CertificateRequest{PublicKey: tt.csrKey}
+			cert := &x509.Certificate{PublicKey: tt.certKey}
+			if err := verifyKeyAlgorithmContinuity(csr, cert); errString(err) != tt.wantErr {
+				t.Errorf("verifyKeyAlgorithmContinuity() error = %v, wantErr %s", err, tt.wantErr)
+			}
+		})
+	}
+}
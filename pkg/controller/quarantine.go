@@ -0,0 +1,154 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// quarantinedAnnotation is set on CSRs held for manual review, with the
+	// reason they were quarantined for as its value.
+	quarantinedAnnotation = "machineapprover.openshift.io/quarantined"
+
+	// QuarantinedCSRsPath is the path of the debug endpoint listing the
+	// quarantined CSRs.
+	QuarantinedCSRsPath = "/debug/quarantined"
+)
+
+// Reasons for which CSRs may be quarantined.
+const (
+	quarantineReasonSourceNetwork    = "SourceNetworkMismatch"
+	quarantineReasonInstanceReplaced = "InstanceReplaced"
+	quarantineReasonStaleServingCert = "StaleServingCert"
+	quarantineReasonExcessDNSNames   = "ExcessDNSNames"
+)
+
+var quarantineReasons = []string{
+	quarantineReasonSourceNetwork,
+	quarantineReasonInstanceReplaced,
+	quarantineReasonStaleServingCert,
+	quarantineReasonExcessDNSNames,
+}
+
+// suspiciousCSRError is returned by checks for which the CSR may be
+// quarantined instead of being declined.
+type suspiciousCSRError struct {
+	reason string
+	err    error
+}
+
+func (e *suspiciousCSRError) Error() string {
+	return e.err.Error()
+}
+
+func (e *suspiciousCSRError) Unwrap() error {
+	return e.err
+}
+
+// quarantinedCSR describes a quarantined CSR for the debug endpoint.
+type quarantinedCSR struct {
+	Name              string      `json:"name"`
+	Username          string      `json:"username"`
+	SignerName        string      `json:"signerName"`
+	Reason            string      `json:"reason"`
+	CreationTimestamp metav1.Time `json:"creationTimestamp"`
+}
+
+// shouldQuarantine returns true if CSRs failing for the given reason are to be
+// quarantined.
+func shouldQuarantine(config Quarantine, reason string) bool {
+	if !config.Enabled {
+		return false
+	}
+
+	reasons := config.Reasons
+	if len(reasons) == 0 {
+		reasons = quarantineReasons
+	}
+	for _, r := range reasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// quarantine annotates the CSR with the reason it failed for, if quarantine
+// is enabled for that reason, so that it is left pending for manual review.
+// It returns true if the CSR was quarantined.
+func (m *CertificateApprover) quarantine(ctx context.Context, req *certificatesv1.CertificateSigningRequest, reason string, cause error) bool {
+	if !shouldQuarantine(m.Config.Quarantine, reason) {
+		return false
+	}
+
+	patch := client.MergeFrom(req.DeepCopy())
+	if req.Annotations == nil {
+		req.Annotations = map[string]string{}
+	}
+	req.Annotations[quarantinedAnnotation] = reason
+
+	if err := m.NodeClient.Patch(ctx, req, patch); err != nil {
+		klog.Errorf("%v: Failed to quarantine CSR: %v", req.Name, err)
+		return false
+	}
+
+	klog.Warningf("%v: CSR quarantined for manual review: %s: %v", req.Name, reason, cause)
+	m.eventf(req, corev1.EventTypeWarning, csrQuarantinedEventReason, "CSR quarantined for manual review: %s: %v", reason, cause)
+	return true
+}
+
+func isQuarantined(csr certificatesv1.CertificateSigningRequest) bool {
+	_, ok := csr.Annotations[quarantinedAnnotation]
+	return ok
+}
+
+// listQuarantinedCSRs returns the pending CSRs that have been quarantined.
+func (m *CertificateApprover) listQuarantinedCSRs(ctx context.Context) ([]quarantinedCSR, error) {
+	csrs := &certificatesv1.CertificateSigningRequestList{}
+	if err := m.NodeClient.List(ctx, csrs); err != nil {
+		return nil, fmt.Errorf("failed to list CSRs: %w", err)
+	}
+
+	quarantined := []quarantinedCSR{}
+	for _, csr := range csrs.Items {
+		// Approved, denied and failed CSRs all have a condition set.
+		if !isQuarantined(csr) || len(csr.Status.Conditions) > 0 {
+			continue
+		}
+		quarantined = append(quarantined, quarantinedCSR{
+			Name:              csr.Name,
+			Username:          csr.Spec.Username,
+			SignerName:        csr.Spec.SignerName,
+			Reason:            csr.Annotations[quarantinedAnnotation],
+			CreationTimestamp: csr.CreationTimestamp,
+		})
+	}
+
+	return quarantined, nil
+}
+
+// QuarantinedCSRsHandler returns an HTTP handler listing the quarantined CSRs
+// that are still pending, as JSON.
+func (m *CertificateApprover) QuarantinedCSRsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		quarantined, err := m.listQuarantinedCSRs(r.Context())
+		if err != nil {
+			klog.Errorf("Unable to list quarantined CSRs: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(quarantined); err != nil {
+			klog.Errorf("Unable to write quarantined CSRs: %v", err)
+		}
+	})
+}
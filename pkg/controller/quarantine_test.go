@@ -0,0 +1,220 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	machinehandlerpkg "github.com/openshift/cluster-machine-approver/pkg/machinehandler"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestShouldQuarantine(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Quarantine
+		reason string
+		want   bool
+	}{
+		{
+			name:   "disabled",
+			config: Quarantine{},
+			reason: quarantineReasonSourceNetwork,
+			want:   false,
+		},
+		{
+			name:   "enabled with default reasons",
+			config: Quarantine{Enabled: true},
+			reason: quarantineReasonExcessDNSNames,
+			want:   true,
+		},
+		{
+			name:   "enabled with listed reason",
+			config: Quarantine{Enabled: true, Reasons: []string{quarantineReasonInstanceReplaced}},
+			reason: quarantineReasonInstanceReplaced,
+			want:   true,
+		},
+		{
+			name:   "enabled with unlisted reason",
+			config: Quarantine{Enabled: true, Reasons: []string{quarantineReasonInstanceReplaced}},
+			reason: quarantineReasonStaleServingCert,
+			want:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shouldQuarantine(tt.config, tt.reason); got != tt.want {
+				t.Errorf("shouldQuarantine() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthorizeCSRQuarantine(t *testing.T) {
+	clientReq := func() *certificatesv1.CertificateSigningRequest {
+		return &certificatesv1.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "panda-csr",
+				CreationTimestamp: creationTimestamp(-time.Minute),
+			},
+			Spec: certificatesv1.CertificateSigningRequestSpec{
+				Request: []byte(clientGood),
+				Usages: []certificatesv1.KeyUsage{
+					certificatesv1.UsageKeyEncipherment,
+					certificatesv1.UsageDigitalSignature,
+					certificatesv1.UsageClientAuth,
+				},
+				Username: nodeBootstrapperUsername,
+				Groups:   nodeBootstrapperGroups.List(),
+				Extra: map[string]certificatesv1.ExtraValue{
+					defaultSourceIPExtraKey: {"192.168.0.10"},
+				},
+			},
+		}
+	}
+	machines := []machinehandlerpkg.Machine{{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "panda-machine",
+			CreationTimestamp: creationTimestamp(-5 * time.Minute),
+		},
+		Status: machinehandlerpkg.MachineStatus{
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalDNS, Address: "panda"},
+			},
+		},
+	}}
+
+	tests := []struct {
+		name            string
+		quarantine      Quarantine
+		wantQuarantined bool
+	}{
+		{
+			name:            "quarantine disabled",
+			quarantine:      Quarantine{},
+			wantQuarantined: false,
+		},
+		{
+			name:            "quarantine enabled for all reasons",
+			quarantine:      Quarantine{Enabled: true},
+			wantQuarantined: true,
+		},
+		{
+			name:            "quarantine enabled for other reasons",
+			quarantine:      Quarantine{Enabled: true, Reasons: []string{quarantineReasonExcessDNSNames}},
+			wantQuarantined: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := clientReq()
+			cl := fake.NewClientBuilder().WithObjects(req.DeepCopy()).Build()
+			approver := &CertificateApprover{
+				NodeClient: cl,
+				Config: ClusterMachineApproverConfig{
+					NodeClientCert: NodeClientCert{
+						SourceNetwork: SourceNetwork{CIDRs: []string{"10.0.0.0/16"}},
+					},
+					Quarantine: tt.quarantine,
+				},
+			}
+
+			_, authorize, err := approver.authorizeCSR(context.Background(), machines, req, parseCR(t, clientGood), nil)
+			if authorize || err != nil {
+				t.Fatalf("authorizeCSR() = %v, error = %v, want false", authorize, err)
+			}
+
+			got := &certificatesv1.CertificateSigningRequest{}
+			if err := cl.Get(context.Background(), client.ObjectKey{Name: req.Name}, got); err != nil {
+				t.Fatalf("failed to get CSR: %v", err)
+			}
+			if isQuarantined(*got) != tt.wantQuarantined {
+				t.Errorf("CSR quarantined = %v, want %v", isQuarantined(*got), tt.wantQuarantined)
+			}
+			if tt.wantQuarantined && got.Annotations[quarantinedAnnotation] != quarantineReasonSourceNetwork {
+				t.Errorf("CSR quarantined for %q, want %q", got.Annotations[quarantinedAnnotation], quarantineReasonSourceNetwork)
+			}
+			if got.Annotations[denialReasonAnnotation] != decisionReasonSourceNetwork {
+				t.Errorf("CSR denial reason = %q, want %q", got.Annotations[denialReasonAnnotation], decisionReasonSourceNetwork)
+			}
+		})
+	}
+}
+
+func TestReconcileQuarantinedCSR(t *testing.T) {
+	csr := certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "panda-csr",
+			Annotations: map[string]string{quarantinedAnnotation: quarantineReasonSourceNetwork},
+		},
+		// Not a valid CSR, it must not be parsed.
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Request: []byte("invalid"),
+		},
+	}
+
+	approver := &CertificateApprover{}
+	if err := approver.reconcileCSR(context.Background(), csr, nil); err != nil {
+		t.Errorf("reconcileCSR() error = %v, want nil", err)
+	}
+}
+
+func TestQuarantinedCSRsHandler(t *testing.T) {
+	quarantined := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "quarantined",
+			Annotations: map[string]string{quarantinedAnnotation: quarantineReasonInstanceReplaced},
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Username:   nodeBootstrapperUsername,
+			SignerName: certificatesv1.KubeAPIServerClientKubeletSignerName,
+		},
+	}
+	approved := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "approved",
+			Annotations: map[string]string{quarantinedAnnotation: quarantineReasonInstanceReplaced},
+		},
+		Status: certificatesv1.CertificateSigningRequestStatus{
+			Conditions: []certificatesv1.CertificateSigningRequestCondition{{
+				Type: certificatesv1.CertificateApproved,
+			}},
+		},
+	}
+	pending := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "pending",
+		},
+	}
+
+	approver := &CertificateApprover{
+		NodeClient: fake.NewClientBuilder().WithObjects(quarantined, approved, pending).Build(),
+	}
+
+	rec := httptest.NewRecorder()
+	approver.QuarantinedCSRsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, QuarantinedCSRsPath, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status code %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var got []quarantinedCSR
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("expected 1 quarantined CSR, got %v", got)
+	}
+	if got[0].Name != "quarantined" || got[0].Reason != quarantineReasonInstanceReplaced ||
+		got[0].Username != nodeBootstrapperUsername || got[0].SignerName != certificatesv1.KubeAPIServerClientKubeletSignerName {
+		t.Errorf("unexpected quarantined CSR: %+v", got[0])
+	}
+}
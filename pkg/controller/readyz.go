@@ -0,0 +1,25 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// MachinesReadyzCheck is a readiness check failing when machines cannot be
+// listed, as no CSR can be correctly authorized without them. API groups
+// machines are not served in don't fail the check.
+func (m *CertificateApprover) MachinesReadyzCheck(req *http.Request) error {
+	apiGroupVersions, err := m.machineAPIGroups(req.Context())
+	if err != nil {
+		return err
+	}
+	machineHandler := m.machineHandler(req.Context())
+
+	for _, apiGroupVersion := range apiGroupVersions {
+		if err := machineHandler.PingMachines(apiGroupVersion); err != nil {
+			return fmt.Errorf("failed to list machines in API group %v: %w", apiGroupVersion, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestMachinesReadyzCheck(t *testing.T) {
+	server := newMachineDiscoveryServer()
+	defer server.Close()
+
+	failingList := interceptor.Funcs{
+		List: func(ctx context.Context, client client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+			return errors.New("connection refused")
+		},
+	}
+
+	tests := []struct {
+		name      string
+		apiGroup  string
+		restCfg   *rest.Config
+		intercept interceptor.Funcs
+		wantErr   string
+	}{
+		{
+			name:     "machines listed",
+			apiGroup: "machine.openshift.io",
+			restCfg:  &rest.Config{Host: server.URL},
+		},
+		{
+			name:      "machines cannot be listed",
+			apiGroup:  "machine.openshift.io",
+			restCfg:   &rest.Config{Host: server.URL},
+			intercept: failingList,
+			wantErr:   "failed to list machines in API group machine.openshift.io/: connection refused",
+		},
+		{
+			name:      "API group not served",
+			apiGroup:  "cluster.x-k8s.io",
+			restCfg:   &rest.Config{Host: server.URL},
+			intercept: failingList,
+		},
+		{
+			name:     "discovery unreachable",
+			apiGroup: "machine.openshift.io",
+			restCfg:  &rest.Config{Host: "http://127.0.0.1:1"},
+			wantErr:  "failed to list machines in API group machine.openshift.io/: failed to get ServerGroups",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			approver := &CertificateApprover{
+				MachineClient:    fake.NewClientBuilder().WithInterceptorFuncs(tt.intercept).Build(),
+				MachineRestCfg:   tt.restCfg,
+				APIGroupVersions: []schema.GroupVersion{{Group: tt.apiGroup}},
+			}
+
+			err := approver.MachinesReadyzCheck(httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			if (tt.wantErr == "") != (err == nil) || !strings.HasPrefix(errString(err), tt.wantErr) {
+				t.Errorf("MachinesReadyzCheck() error = %v, wantErr %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// newMachineDiscoveryServer returns a server serving the discovery of the
+// machine.openshift.io API group.
+func newMachineDiscoveryServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api":
+			w.Write([]byte(`{"kind": "APIVersions", "versions": ["v1"]}`))
+		case "/apis":
+			w.Write([]byte(`{"kind": "APIGroupList", "apiVersion": "v1", "groups": [{
+				"name": "machine.openshift.io",
+				"versions": [{"groupVersion": "machine.openshift.io/v1beta1", "version": "v1beta1"}],
+				"preferredVersion": {"groupVersion": "machine.openshift.io/v1beta1", "version": "v1beta1"}
+			}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/time/rate"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+const defaultReconcileAllPerMinute = 60
+
+// approverAnnotations are the annotations set by the controller on pending
+// CSRs, which are cleared by the reconcile-all pass.
+var approverAnnotations = []string{quarantinedAnnotation, denialReasonAnnotation}
+
+// reconcileAll re-evaluates every pending CSR once, so that CSRs left pending
+// by a previous version of the controller get a fresh decision. Annotations
+// set by the controller on them are cleared first. CSRs are enqueued at a
+// limited rate to avoid a thundering herd. In dry run mode, the CSRs are only
+// logged.
+func (m *CertificateApprover) reconcileAll(ctx context.Context) error {
+	config := m.Config.ReconcileAll
+
+	csrs := &certificatesv1.CertificateSigningRequestList{}
+	if err := m.NodeClient.List(ctx, csrs); err != nil {
+		// Don't stop the manager, CSRs are still reconciled as usual.
+		klog.Errorf("Reconcile-all: Failed to list CSRs: %v", err)
+		return nil
+	}
+
+	perMinute := defaultReconcileAllPerMinute
+	if config.MaxPerMinute != nil && *config.MaxPerMinute > 0 {
+		perMinute = *config.MaxPerMinute
+	}
+	limiter := rate.NewLimiter(rate.Limit(float64(perMinute)/time.Minute.Seconds()), 1)
+
+	reconciled := 0
+	for i := range csrs.Items {
+		csr := &csrs.Items[i]
+		// Approved, denied and failed CSRs all have a condition set.
+		if len(csr.Status.Conditions) > 0 {
+			continue
+		}
+
+		stale := staleApproverAnnotations(*csr)
+		if config.DryRun {
+			klog.Infof("Reconcile-all (dry run): %v: Would clear annotations %v and re-evaluate CSR", csr.Name, stale)
+			reconciled++
+			continue
+		}
+
+		if err := limiter.Wait(ctx); err != nil {
+			klog.Infof("Reconcile-all: Stopped after re-evaluating %d pending CSRs: %v", reconciled, err)
+			return nil
+		}
+
+		if len(stale) > 0 {
+			patch := client.MergeFrom(csr.DeepCopy())
+			for _, annotation := range stale {
+				delete(csr.Annotations, annotation)
+			}
+			if err := m.NodeClient.Patch(ctx, csr, patch); err != nil {
+				klog.Errorf("Reconcile-all: %v: Failed to clear annotations %v: %v", csr.Name, stale, err)
+				continue
+			}
+			klog.Infof("Reconcile-all: %v: Cleared annotations %v", csr.Name, stale)
+		}
+
+		if m.reconcileAllEvents != nil {
+			select {
+			case m.reconcileAllEvents <- event.GenericEvent{Object: csr}:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+		reconciled++
+	}
+
+	if config.DryRun {
+		klog.Infof("Reconcile-all (dry run): Would re-evaluate %d pending CSRs", reconciled)
+		return nil
+	}
+	klog.Infof("Reconcile-all: Re-evaluated %d pending CSRs", reconciled)
+	return nil
+}
+
+// staleApproverAnnotations returns the annotations set by the controller on
+// the CSR.
+func staleApproverAnnotations(csr certificatesv1.CertificateSigningRequest) []string {
+	stale := []string{}
+	for _, annotation := range approverAnnotations {
+		if _, ok := csr.Annotations[annotation]; ok {
+			stale = append(stale, annotation)
+		}
+	}
+	return stale
+}
@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestReconcileAll(t *testing.T) {
+	newCSRs := func() []client.Object {
+		return []client.Object{
+			&certificatesv1.CertificateSigningRequest{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "quarantined",
+					Annotations: map[string]string{quarantinedAnnotation: quarantineReasonSourceNetwork},
+				},
+			},
+			&certificatesv1.CertificateSigningRequest{
+				ObjectMeta: metav1.ObjectMeta{Name: "pending"},
+			},
+			&certificatesv1.CertificateSigningRequest{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "approved",
+					Annotations: map[string]string{quarantinedAnnotation: quarantineReasonSourceNetwork},
+				},
+				Status: certificatesv1.CertificateSigningRequestStatus{
+					Conditions: []certificatesv1.CertificateSigningRequestCondition{{
+						Type: certificatesv1.CertificateApproved,
+					}},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name            string
+		config          ReconcileAll
+		wantEnqueued    []string
+		wantQuarantined []string
+	}{
+		{
+			name:            "clears annotations and enqueues pending CSRs",
+			config:          ReconcileAll{Enabled: true, MaxPerMinute: pointer.Int(6000)},
+			wantEnqueued:    []string{"pending", "quarantined"},
+			wantQuarantined: []string{"approved"},
+		},
+		{
+			name:            "dry run",
+			config:          ReconcileAll{Enabled: true, DryRun: true},
+			wantEnqueued:    []string{},
+			wantQuarantined: []string{"approved", "quarantined"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cl := fake.NewClientBuilder().WithObjects(newCSRs()...).Build()
+			events := make(chan event.GenericEvent, 10)
+			approver := &CertificateApprover{
+				NodeClient:         cl,
+				Config:             ClusterMachineApproverConfig{ReconcileAll: tt.config},
+				reconcileAllEvents: events,
+			}
+
+			if err := approver.reconcileAll(context.Background()); err != nil {
+				t.Fatalf("reconcileAll() error = %v", err)
+			}
+			close(events)
+
+			enqueued := []string{}
+			for e := range events {
+				enqueued = append(enqueued, e.Object.GetName())
+			}
+			sort.Strings(enqueued)
+			if !reflect.DeepEqual(enqueued, tt.wantEnqueued) {
+				t.Errorf("enqueued %v, want %v", enqueued, tt.wantEnqueued)
+			}
+
+			csrs := &certificatesv1.CertificateSigningRequestList{}
+			if err := cl.List(context.Background(), csrs); err != nil {
+				t.Fatalf("failed to list CSRs: %v", err)
+			}
+			quarantined := []string{}
+			for _, csr := range csrs.Items {
+				if isQuarantined(csr) {
+					quarantined = append(quarantined, csr.Name)
+				}
+			}
+			sort.Strings(quarantined)
+			if !reflect.DeepEqual(quarantined, tt.wantQuarantined) {
+				t.Errorf("quarantined %v, want %v", quarantined, tt.wantQuarantined)
+			}
+		})
+	}
+}
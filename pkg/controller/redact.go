@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+)
+
+const (
+	redactionModeHash     = "hash"
+	redactionModeTruncate = "truncate"
+	redactionModeNone     = "none"
+
+	defaultRedactionTruncateLength = 8
+)
+
+var redactionModes = []string{
+	redactionModeHash,
+	redactionModeTruncate,
+	redactionModeNone,
+}
+
+// redactor redacts sensitive values, such as CSR requests and the extra user
+// info of CSRs which may hold tokens, before they are logged.
+type redactor struct {
+	mode   string
+	length int
+}
+
+func newRedactor(config LogRedaction) redactor {
+	r := redactor{mode: config.Mode, length: defaultRedactionTruncateLength}
+	if r.mode == "" {
+		r.mode = redactionModeHash
+	}
+	if config.TruncateLength != nil {
+		r.length = *config.TruncateLength
+	}
+	return r
+}
+
+// value returns the redacted form of a sensitive value. Hashed values can be
+// correlated across log lines without revealing them.
+func (r redactor) value(v string) string {
+	switch r.mode {
+	case redactionModeNone:
+		return v
+	case redactionModeTruncate:
+		if len(v) <= r.length {
+			return v
+		}
+		return fmt.Sprintf("%s...(%d bytes)", v[:r.length], len(v))
+	default:
+		sum := sha256.Sum256([]byte(v))
+		return "sha256:" + hex.EncodeToString(sum[:6])
+	}
+}
+
+// extra returns the extra user info of a CSR with all values redacted.
+func (r redactor) extra(extra map[string]certificatesv1.ExtraValue) map[string][]string {
+	redacted := make(map[string][]string, len(extra))
+	for key, values := range extra {
+		for _, v := range values {
+			redacted[key] = append(redacted[key], r.value(v))
+		}
+	}
+	return redacted
+}
@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	"k8s.io/utils/pointer"
+)
+
+func TestRedactorValue(t *testing.T) {
+	tests := []struct {
+		name   string
+		config LogRedaction
+		value  string
+		want   string
+	}{
+		{
+			name:  "hash by default",
+			value: "secret-token",
+			want:  "sha256:930bbdc51b6a",
+		},
+		{
+			name:   "truncate",
+			config: LogRedaction{Mode: redactionModeTruncate},
+			value:  "-----BEGIN CERTIFICATE REQUEST-----",
+			want:   "-----BEG...(35 bytes)",
+		},
+		{
+			name:   "truncate with custom length",
+			config: LogRedaction{Mode: redactionModeTruncate, TruncateLength: pointer.Int(3)},
+			value:  "secret-token",
+			want:   "sec...(12 bytes)",
+		},
+		{
+			name:   "truncate short value",
+			config: LogRedaction{Mode: redactionModeTruncate},
+			value:  "short",
+			want:   "short",
+		},
+		{
+			name:   "none",
+			config: LogRedaction{Mode: redactionModeNone},
+			value:  "secret-token",
+			want:   "secret-token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newRedactor(tt.config).value(tt.value); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactorExtra(t *testing.T) {
+	extra := map[string]certificatesv1.ExtraValue{
+		"authentication.kubernetes.io/credential-id": {"JTI=panda"},
+		"scopes": {"a-very-long-scope", "b"},
+	}
+	want := map[string][]string{
+		"authentication.kubernetes.io/credential-id": {"JTI=pand...(9 bytes)"},
+		"scopes": {"a-very-l...(17 bytes)", "b"},
+	}
+
+	if got := newRedactor(LogRedaction{Mode: redactionModeTruncate}).extra(extra); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
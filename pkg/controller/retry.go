@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"sync"
+	"time"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	defaultRetryInitialBackoff = time.Second
+	defaultRetryMaxBackoff     = 5 * time.Minute
+)
+
+// retryTracker counts the consecutive failed attempts to reconcile CSRs, by
+// UID. The zero value is ready to use.
+type retryTracker struct {
+	lock     sync.Mutex
+	attempts map[types.UID]int
+}
+
+// failed records a failed attempt to reconcile the CSR and returns the number
+// of consecutive failed attempts.
+func (t *retryTracker) failed(uid types.UID) int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.attempts == nil {
+		t.attempts = map[types.UID]int{}
+	}
+	t.attempts[uid]++
+	return t.attempts[uid]
+}
+
+// reset forgets the failed attempts to reconcile the CSR.
+func (t *retryTracker) reset(uid types.UID) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	delete(t.attempts, uid)
+}
+
+// prune forgets the failed attempts to reconcile CSRs that no longer exist.
+func (t *retryTracker) prune(csrs []certificatesv1.CertificateSigningRequest) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if len(t.attempts) == 0 {
+		return
+	}
+	existing := make(map[types.UID]bool, len(csrs))
+	for _, csr := range csrs {
+		existing[csr.UID] = true
+	}
+	for uid := range t.attempts {
+		if !existing[uid] {
+			delete(t.attempts, uid)
+		}
+	}
+}
+
+// retry requeues the CSR after a failed attempt to reconcile it, with an
+// exponential backoff, optionally jittered. Once the maximum number of attempts
+// is reached, the CSR is no longer requeued and a warning event is recorded
+// instead. The attempts are then forgotten, so that a later event for the CSR,
+// e.g. once its machine appears, is retried again from the start.
+func (m *CertificateApprover) retry(csr *certificatesv1.CertificateSigningRequest, err error) (reconcile.Result, error) {
+	attempts := m.retries.failed(csr.UID)
+
+	if maxAttempts := m.Config.Retries.MaxAttempts; maxAttempts != nil && attempts >= *maxAttempts {
+		klog.Errorf("%v: Giving up after %d failed attempts: %v", csr.Name, attempts, err)
+		m.eventf(csr, corev1.EventTypeWarning, csrRetriesExhaustedEventReason, "giving up after %d failed attempts: %v", attempts, err)
+		m.retries.reset(csr.UID)
+		return reconcile.Result{}, nil
+	}
+
+	backoff := m.Config.Retries.jitter(m.Config.Retries.backoff(attempts))
+	klog.Errorf("%v: Failed attempt %d, retrying in %s: %v", csr.Name, attempts, backoff, err)
+	return reconcile.Result{RequeueAfter: backoff}, nil
+}
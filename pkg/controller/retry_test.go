@@ -0,0 +1,144 @@
+package controller
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+)
+
+func TestRetriesBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   Retries
+		attempts int
+		want     time.Duration
+	}{
+		{
+			name:     "first attempt",
+			attempts: 1,
+			want:     time.Second,
+		},
+		{
+			name:     "doubled after each attempt",
+			attempts: 4,
+			want:     8 * time.Second,
+		},
+		{
+			name:     "capped to default maximum",
+			attempts: 20,
+			want:     5 * time.Minute,
+		},
+		{
+			name:     "configured backoff",
+			config:   Retries{InitialBackoff: metav1.Duration{Duration: 10 * time.Second}, MaxBackoff: metav1.Duration{Duration: 30 * time.Second}},
+			attempts: 3,
+			want:     30 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.config.backoff(tt.attempts); got != tt.want {
+				t.Errorf("backoff() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryJitter(t *testing.T) {
+	approver := &CertificateApprover{
+		Config: ClusterMachineApproverConfig{
+			Retries: Retries{RequeueJitter: pointer.Float64(0.5)},
+		},
+	}
+	cause := fmt.Errorf("Unable to find machine for node")
+
+	// CSRs failing together on their first attempt are retried at different
+	// times, within the jitter.
+	delays := map[time.Duration]bool{}
+	for i := 0; i < 20; i++ {
+		csr := &certificatesv1.CertificateSigningRequest{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("csr-%d", i), UID: types.UID(fmt.Sprintf("uid-%d", i))}}
+		result, err := approver.retry(csr, cause)
+		if err != nil {
+			t.Fatalf("retry() error = %v", err)
+		}
+		if result.RequeueAfter < time.Second || result.RequeueAfter > 1500*time.Millisecond {
+			t.Errorf("retry() requeue after %s, want between 1s and 1.5s", result.RequeueAfter)
+		}
+		delays[result.RequeueAfter] = true
+	}
+	if len(delays) < 2 {
+		t.Errorf("expected requeue delays to vary, got %v", delays)
+	}
+}
+
+func TestRetryTracker(t *testing.T) {
+	tracker := &retryTracker{}
+	panda := certificatesv1.CertificateSigningRequest{ObjectMeta: metav1.ObjectMeta{UID: "panda"}}
+
+	for i := 1; i <= 3; i++ {
+		if attempts := tracker.failed(panda.UID); attempts != i {
+			t.Errorf("expected %d attempts, got %d", i, attempts)
+		}
+	}
+
+	tracker.reset(panda.UID)
+	if attempts := tracker.failed(panda.UID); attempts != 1 {
+		t.Errorf("expected attempts to be reset, got %d", attempts)
+	}
+
+	tracker.prune([]certificatesv1.CertificateSigningRequest{panda})
+	if attempts := tracker.failed(panda.UID); attempts != 2 {
+		t.Errorf("expected attempts of existing CSR to be kept, got %d", attempts)
+	}
+
+	tracker.prune(nil)
+	if attempts := tracker.failed(panda.UID); attempts != 1 {
+		t.Errorf("expected attempts of deleted CSR to be forgotten, got %d", attempts)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	approver := &CertificateApprover{
+		Recorder: recorder,
+		Config: ClusterMachineApproverConfig{
+			Retries: Retries{MaxAttempts: pointer.Int(3)},
+		},
+	}
+	csr := &certificatesv1.CertificateSigningRequest{ObjectMeta: metav1.ObjectMeta{Name: "panda-csr", UID: "panda"}}
+	cause := fmt.Errorf("Unable to find machine for node")
+
+	for i, want := range []time.Duration{time.Second, 2 * time.Second} {
+		result, err := approver.retry(csr, cause)
+		if err != nil || result.RequeueAfter != want {
+			t.Errorf("attempt %d: retry() = %+v, error = %v, want requeue after %s", i+1, result, err, want)
+		}
+	}
+
+	result, err := approver.retry(csr, cause)
+	if err != nil || result.RequeueAfter != 0 || result.Requeue {
+		t.Errorf("retry() = %+v, error = %v, want no requeue", result, err)
+	}
+
+	select {
+	case event := <-recorder.Events:
+		if want := "Warning CSRRetriesExhausted giving up after 3 failed attempts: Unable to find machine for node"; event != want {
+			t.Errorf("got event %q, want %q", event, want)
+		}
+	default:
+		t.Errorf("expected a retries exhausted event")
+	}
+
+	// A later event for the CSR is retried again from the start.
+	result, err = approver.retry(csr, cause)
+	if err != nil || result.RequeueAfter != time.Second {
+		t.Errorf("retry() after giving up = %+v, error = %v, want requeue after %s", result, err, time.Second)
+	}
+}
@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"sync"
+	"time"
+
+	machinehandlerpkg "github.com/openshift/cluster-machine-approver/pkg/machinehandler"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/cache"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// maxServingApprovalNodes bounds the number of nodes whose recent serving
+	// cert approvals are remembered.
+	maxServingApprovalNodes = 10000
+
+	defaultServingApprovalWindow = time.Hour
+)
+
+// servingApprovalTracker remembers the times at which serving certs were
+// recently approved for each node, in an LRU cache. The zero value is ready to
+// use.
+type servingApprovalTracker struct {
+	lock      sync.Mutex
+	approvals *cache.LRUExpireCache
+}
+
+// allow returns an error if maxApprovals serving certs have already been
+// approved for the given node within the window.
+func (t *servingApprovalTracker) allow(clock Clock, nodeName string, maxApprovals int, window time.Duration) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if recent := t.recent(clock, nodeName, window); len(recent) >= maxApprovals {
+		return fmt.Errorf("%d serving certs already approved for node %s within %s", len(recent), nodeName, window)
+	}
+	return nil
+}
+
+// record records an approval of a serving cert for the given node.
+func (t *servingApprovalTracker) record(clock Clock, nodeName string, window time.Duration) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.approvals.Add(nodeName, append(t.recent(clock, nodeName, window), clock.Now()), window)
+}
+
+// recent returns the times at which serving certs were approved for the given
+// node within the window. The lock must be held.
+func (t *servingApprovalTracker) recent(clock Clock, nodeName string, window time.Duration) []time.Time {
+	if t.approvals == nil {
+		t.approvals = cache.NewLRUExpireCacheWithClock(maxServingApprovalNodes, clock)
+	}
+
+	currentTime := clock.Now()
+	var recent []time.Time
+	if value, ok := t.approvals.Get(nodeName); ok {
+		for _, approvedAt := range value.([]time.Time) {
+			if currentTime.Sub(approvedAt) < window {
+				recent = append(recent, approvedAt)
+			}
+		}
+	}
+	return recent
+}
+
+// authorizeServingApproval authorizes a serving CSR for the given node, unless
+// too many serving certs were recently approved for it, in which case the CSR
+// is left for manual approval. The approval is only counted once the CSR is
+// actually approved, see servingApproved.
+func (m *CertificateApprover) authorizeServingApproval(ctx context.Context, req *certificatesv1.CertificateSigningRequest, nodeName, reason string, machine *machinehandlerpkg.Machine) AuthorizeResult {
+	if limit := m.Config.NodeServingCert.ApprovalRateLimit; limit.MaxApprovals != nil {
+		if err := m.servingApprovals.allow(m.clock(), nodeName, *limit.MaxApprovals, limit.window()); err != nil {
+			klog.Errorf("%v: Serving cert approval rate exceeded, requires manual approval: %v", req.Name, err)
+			m.eventf(req, corev1.EventTypeWarning, csrDeniedEventReason, "serving cert approval rate exceeded, requires manual approval: %v", err)
+			return m.decide(ctx, req, csrKindServing, decisionReasonApprovalRateExceeded, nil, false, nil)
+		}
+	}
+
+	return m.decide(ctx, req, csrKindServing, reason, machine, true, nil)
+}
+
+// servingApproved counts a serving CSR approved for the given node against its
+// approval rate limit and in the metrics.
+func (m *CertificateApprover) servingApproved(nodeName string, csr *x509.CertificateRequest) {
+	if limit := m.Config.NodeServingCert.ApprovalRateLimit; limit.MaxApprovals != nil {
+		m.servingApprovals.record(m.clock(), nodeName, limit.window())
+	}
+	recordServingApproval(csr)
+}
@@ -0,0 +1,150 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	machinehandlerpkg "github.com/openshift/cluster-machine-approver/pkg/machinehandler"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	testingclock "k8s.io/utils/clock/testing"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestServingApprovalTracker(t *testing.T) {
+	clock := testingclock.NewFakePassiveClock(baseTime)
+	tracker := &servingApprovalTracker{}
+
+	steps := []struct {
+		node    string
+		advance time.Duration
+		wantErr string
+	}{
+		{node: "panda"},
+		{node: "panda", advance: 10 * time.Minute},
+		{node: "panda", advance: 10 * time.Minute, wantErr: "2 serving certs already approved for node panda within 1h0m0s"},
+		{node: "bamboo"},
+		// The first approval is out of the window.
+		{node: "panda", advance: 41 * time.Minute},
+		{node: "panda", wantErr: "2 serving certs already approved for node panda within 1h0m0s"},
+	}
+
+	for i, step := range steps {
+		clock.SetTime(clock.Now().Add(step.advance))
+		err := tracker.allow(clock, step.node, 2, time.Hour)
+		if errString(err) != step.wantErr {
+			t.Errorf("step %d: allow() error = %v, wantErr %s", i, err, step.wantErr)
+		}
+		if err == nil {
+			tracker.record(clock, step.node, time.Hour)
+		}
+	}
+
+	// Approvals are only counted once recorded.
+	for i := 0; i < 3; i++ {
+		if err := tracker.allow(clock, "koala", 2, time.Hour); err != nil {
+			t.Errorf("allow() error = %v for a node without recorded approvals", err)
+		}
+	}
+}
+
+func TestAuthorizeCSRServingApprovalRateLimit(t *testing.T) {
+	machines := []machinehandlerpkg.Machine{{
+		ObjectMeta: metav1.ObjectMeta{Name: "panda-machine"},
+		Status: machinehandlerpkg.MachineStatus{
+			NodeRef: &corev1.ObjectReference{Name: "panda"},
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalDNS, Address: "panda"},
+			},
+		},
+	}}
+	csr := createCSR("system:node:panda", defaultOrgs, nil, []string{"panda"})
+	req := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "panda-csr"},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageServerAuth,
+			},
+			Username: "system:node:panda",
+			Groups: []string{
+				"system:authenticated",
+				"system:nodes",
+			},
+			Request: []byte(csr),
+		},
+	}
+
+	approvals := 0
+	failApproval := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failApproval {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		approved := &certificatesv1.CertificateSigningRequest{}
+		if err := json.NewDecoder(r.Body).Decode(approved); err != nil {
+			t.Errorf("failed to decode CSR: %v", err)
+		}
+		approvals++
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(approved)
+	}))
+	defer server.Close()
+
+	clock := testingclock.NewFakePassiveClock(baseTime)
+	approver := &CertificateApprover{
+		NodeClient:  fake.NewClientBuilder().WithObjects(req.DeepCopy()).Build(),
+		NodeRestCfg: &rest.Config{Host: server.URL},
+		Config: ClusterMachineApproverConfig{
+			NodeServingCert: NodeServingCert{
+				ApprovalRateLimit: ApprovalRateLimit{MaxApprovals: pointer.Int(3), Window: metav1.Duration{Duration: 10 * time.Minute}},
+			},
+		},
+		Clock: clock,
+	}
+	before := counterValue(t, servingSANTypesTotal.WithLabelValues(sanTypeDNSOnly))
+
+	// Neither failed approvals nor audits use up the budget of the node.
+	failApproval = true
+	for i := 0; i < 3; i++ {
+		if err := approver.reconcileCSR(context.Background(), *req, machines); err == nil {
+			t.Errorf("attempt %d: reconcileCSR() succeeded, want approval failure", i+1)
+		}
+	}
+	failApproval = false
+	approver.Config.AuditOnly = true
+	for i := 0; i < 3; i++ {
+		if err := approver.reconcileCSR(context.Background(), *req, machines); err != nil {
+			t.Errorf("audit %d: reconcileCSR() error = %v", i+1, err)
+		}
+	}
+	approver.Config.AuditOnly = false
+
+	// Rapid repeated renewals are approved up to the limit.
+	for i := 0; i < 5; i++ {
+		clock.SetTime(clock.Now().Add(time.Second))
+		if err := approver.reconcileCSR(context.Background(), *req, machines); err != nil {
+			t.Errorf("renewal %d: reconcileCSR() error = %v", i+1, err)
+		}
+	}
+	if approvals != 3 {
+		t.Errorf("%d CSRs approved, want 3", approvals)
+	}
+
+	clock.SetTime(clock.Now().Add(10 * time.Minute))
+	if err := approver.reconcileCSR(context.Background(), *req, machines); err != nil || approvals != 4 {
+		t.Errorf("reconcileCSR() error = %v, %d CSRs approved, want approval after the window", err, approvals)
+	}
+
+	if after := counterValue(t, servingSANTypesTotal.WithLabelValues(sanTypeDNSOnly)); after != before+4 {
+		t.Errorf("expected approved serving SAN types counter to be incremented from %v to %v, got %v", before, before+4, after)
+	}
+}
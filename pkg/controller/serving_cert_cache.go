@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"context"
+	"crypto/x509"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/cache"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// maxServingCertCacheNodes bounds the number of nodes whose serving cert
+	// is cached.
+	maxServingCertCacheNodes = 10000
+
+	defaultServingCertCacheTTL = 30 * time.Second
+)
+
+// servingCertCache remembers the serving certs recently retrieved from each
+// node's kubelet, in an LRU cache, so that the CSRs of a node arriving close
+// together don't each connect to its kubelet. The zero value is ready to use.
+type servingCertCache struct {
+	lock  sync.Mutex
+	certs *cache.LRUExpireCache
+}
+
+// get returns the serving cert retrieved for the given node within the TTL, if
+// any.
+func (c *servingCertCache) get(nodeName string) *x509.Certificate {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.certs == nil {
+		return nil
+	}
+	if value, ok := c.certs.Get(nodeName); ok {
+		return value.(*x509.Certificate)
+	}
+	return nil
+}
+
+// add records the serving cert retrieved for the given node, for the TTL.
+func (c *servingCertCache) add(clock Clock, nodeName string, cert *x509.Certificate, ttl time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.certs == nil {
+		c.certs = cache.NewLRUExpireCacheWithClock(maxServingCertCacheNodes, clock)
+	}
+	c.certs.Add(nodeName, cert, ttl)
+}
+
+// approverServingCertRetriever retrieves the serving certs presented by
+// kubelets through the serving cert cache of the approver.
+type approverServingCertRetriever struct {
+	approver *CertificateApprover
+}
+
+func (r approverServingCertRetriever) ServingCert(ctx context.Context, nodeName string, cas []*x509.CertPool) (*x509.Certificate, error) {
+	return r.approver.getCachedServingCert(ctx, nodeName, cas)
+}
+
+// servingCertRetriever returns the configured serving cert retriever, or one
+// connecting to kubelets, through the serving cert cache, when unset.
+func (m *CertificateApprover) servingCertRetriever() ServingCertRetriever {
+	if m.ServingCertRetriever == nil {
+		return approverServingCertRetriever{approver: m}
+	}
+	return m.ServingCertRetriever
+}
+
+// getCachedServingCert returns the serving cert presented by the kubelet of
+// the given node, reusing the one retrieved within the TTL if any. Failures to
+// retrieve it are not cached, the kubelet may just be restarting.
+func (m *CertificateApprover) getCachedServingCert(ctx context.Context, nodeName string, cas []*x509.CertPool) (*x509.Certificate, error) {
+	if cert := m.servingCerts.get(nodeName); cert != nil {
+		klog.V(2).Infof("Reusing serving cert recently retrieved from %s", nodeName)
+		return cert, nil
+	}
+
+	connect := m.kubeletDials.limit(m.Config.MaxConcurrentKubeletDials, m.KubeletConnector)
+	cert, err := getServingCert(ctx, m.NodeClient, connect, nodeName, cas, m.Config.NodeServingCert.VerifyOCSPStaple, m.Config.NodeServingCert.KubeletServerName, m.Config.NodeServingCert.defaultKubeletPort(), m.Config.kubeletConnectTimeout(), m.clock().Now())
+	if err != nil {
+		return nil, err
+	}
+	m.servingCerts.add(m.clock(), nodeName, cert, m.Config.NodeServingCert.currentCertCacheTTL())
+	return cert, nil
+}
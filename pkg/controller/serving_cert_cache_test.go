@@ -0,0 +1,96 @@
+package controller
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testingclock "k8s.io/utils/clock/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestGetCachedServingCert(t *testing.T) {
+	crt, err := tls.X509KeyPair([]byte(serverCertGood), []byte(serverKeyGood))
+	if err != nil {
+		t.Fatalf("failed to parse key pair: %v", err)
+	}
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{crt}})
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	var dials int32
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&dials, 1)
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "test"},
+		Status: corev1.NodeStatus{
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalIP, Address: "localhost"},
+			},
+			DaemonEndpoints: corev1.NodeDaemonEndpoints{
+				KubeletEndpoint: corev1.DaemonEndpoint{
+					Port: int32(listener.Addr().(*net.TCPAddr).Port),
+				},
+			},
+		},
+	}
+	certPool := x509.NewCertPool()
+	certPool.AddCert(parseCert(t, rootCertGood))
+
+	clock := testingclock.NewFakePassiveClock(baseTime)
+	cl := fake.NewFakeClient()
+	approver := &CertificateApprover{NodeClient: cl, Clock: clock}
+
+	// Failures to retrieve the serving cert are not cached.
+	if _, err := approver.getCachedServingCert(context.Background(), "test", []*x509.CertPool{certPool}); err == nil {
+		t.Fatalf("expected an error retrieving the serving cert of a missing node")
+	}
+	if err := cl.Create(context.Background(), node); err != nil {
+		t.Fatalf("failed to create node: %v", err)
+	}
+
+	steps := []struct {
+		advance   time.Duration
+		wantDials int32
+	}{
+		{wantDials: 1},
+		// Reused within the TTL.
+		{advance: 10 * time.Second, wantDials: 1},
+		{advance: 19 * time.Second, wantDials: 1},
+		// Retrieved again once expired.
+		{advance: 2 * time.Second, wantDials: 2},
+		{advance: time.Second, wantDials: 2},
+	}
+
+	for i, step := range steps {
+		clock.SetTime(clock.Now().Add(step.advance))
+		cert, err := approver.getCachedServingCert(context.Background(), "test", []*x509.CertPool{certPool})
+		if err != nil {
+			t.Fatalf("step %d: getCachedServingCert() error = %v", i, err)
+		}
+		if !cert.Equal(parseCert(t, serverCertGood)) {
+			t.Errorf("step %d: unexpected serving cert", i)
+		}
+		if got := atomic.LoadInt32(&dials); got != step.wantDials {
+			t.Errorf("step %d: kubelet dialed %d times, want %d", i, got, step.wantDials)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package controller
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// maxSupersededServingSerials bounds the number of superseded serials
+	// remembered for each node.
+	maxSupersededServingSerials = 10
+
+	// servingSerialsAnnotation persists on each node the serials of the
+	// serving certs presented by its kubelet, so that they survive restarts
+	// and leader changes of the controller.
+	servingSerialsAnnotation = "machineapprover.openshift.io/serving-cert-serials"
+)
+
+// servingSerialTracker remembers the serials of the serving certs presented by
+// each node's kubelet. The serials of a node are loaded from, and persisted
+// to, the node by the approver. The zero value is ready to use.
+type servingSerialTracker struct {
+	lock  sync.Mutex
+	nodes map[string]*nodeServingSerials
+}
+
+type nodeServingSerials struct {
+	Current    string   `json:"current"`
+	Superseded []string `json:"superseded,omitempty"`
+}
+
+// known returns true if the serials of the given node have been observed or
+// restored.
+func (t *servingSerialTracker) known(nodeName string) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	_, ok := t.nodes[nodeName]
+	return ok
+}
+
+// restore records the serials of the given node persisted by a previous
+// controller, unless serials have been observed for it meanwhile.
+func (t *servingSerialTracker) restore(nodeName string, serials nodeServingSerials) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.nodes == nil {
+		t.nodes = map[string]*nodeServingSerials{}
+	}
+	if _, ok := t.nodes[nodeName]; !ok {
+		t.nodes[nodeName] = &serials
+	}
+}
+
+// observe records the serial of the serving cert presented by the given node,
+// and returns the serials of the node when they changed. It returns an error,
+// without recording anything, if the serial belongs to a cert that a newer one
+// has already superseded.
+func (t *servingSerialTracker) observe(nodeName string, cert *x509.Certificate) (*nodeServingSerials, error) {
+	serial := cert.SerialNumber.String()
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.nodes == nil {
+		t.nodes = map[string]*nodeServingSerials{}
+	}
+
+	serials, ok := t.nodes[nodeName]
+	if !ok {
+		t.nodes[nodeName] = &nodeServingSerials{Current: serial}
+		return &nodeServingSerials{Current: serial}, nil
+	}
+
+	if serials.Current == serial {
+		return nil, nil
+	}
+
+	for _, s := range serials.Superseded {
+		if s == serial {
+			return nil, fmt.Errorf("serving cert serial %s for node %s has been superseded by %s", serial, nodeName, serials.Current)
+		}
+	}
+
+	serials.Superseded = append(serials.Superseded, serials.Current)
+	if len(serials.Superseded) > maxSupersededServingSerials {
+		serials.Superseded = serials.Superseded[1:]
+	}
+	serials.Current = serial
+
+	return &nodeServingSerials{Current: serials.Current, Superseded: append([]string(nil), serials.Superseded...)}, nil
+}
+
+// observeServingSerial records the serial of the serving cert presented by the
+// given node, as servingSerialTracker.observe. The serials of the node are
+// first restored from the node when unknown, e.g. after a restart, and
+// persisted on the node when they change. Failures to read or write the node
+// are logged, the serials still being tracked in memory.
+func (m *CertificateApprover) observeServingSerial(ctx context.Context, nodeName string, cert *x509.Certificate) error {
+	node := &corev1.Node{}
+	if err := m.NodeClient.Get(ctx, client.ObjectKey{Name: nodeName}, node); err != nil {
+		klog.Errorf("Failed to get node %s to persist its serving cert serials: %v", nodeName, err)
+		node = nil
+	}
+
+	if node != nil && !m.servingSerials.known(nodeName) {
+		if value, ok := node.Annotations[servingSerialsAnnotation]; ok {
+			serials := nodeServingSerials{}
+			if err := json.Unmarshal([]byte(value), &serials); err != nil {
+				klog.Errorf("Failed to parse serving cert serials of node %s: %v", nodeName, err)
+			} else {
+				m.servingSerials.restore(nodeName, serials)
+			}
+		}
+	}
+
+	serials, err := m.servingSerials.observe(nodeName, cert)
+	if err != nil || serials == nil || node == nil {
+		return err
+	}
+
+	value, err := json.Marshal(serials)
+	if err != nil {
+		klog.Errorf("Failed to serialize serving cert serials of node %s: %v", nodeName, err)
+		return nil
+	}
+	patch := client.MergeFrom(node.DeepCopy())
+	if node.Annotations == nil {
+		node.Annotations = map[string]string{}
+	}
+	node.Annotations[servingSerialsAnnotation] = string(value)
+	if err := m.NodeClient.Patch(ctx, node, patch); err != nil && !apierrors.IsNotFound(err) {
+		klog.Errorf("Failed to persist serving cert serials of node %s: %v", nodeName, err)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+)
+
+const (
+	spiffeScheme = "spiffe"
+	// spiffeNodePathPrefix is the path of the SPIFFE IDs of nodes, followed by
+	// the node name.
+	spiffeNodePathPrefix = "/node/"
+)
+
+// spiffeTrustDomainRegexp matches the characters allowed in SPIFFE trust
+// domains.
+var spiffeTrustDomainRegexp = regexp.MustCompile(`^[a-z0-9._-]+$`)
+
+// validateServingURIs checks the URI SANs requested by a serving CSR. None are
+// allowed, unless a SPIFFE trust domain is configured, in which case a single
+// SPIFFE ID of the requesting node in that trust domain is.
+func validateServingURIs(spiffe SPIFFE, nodeName string, uris []*url.URL) error {
+	if len(uris) == 0 {
+		return nil
+	}
+	// Serving certs only carry DNS and IP SANs, other SANs would not be
+	// checked against the machine addresses.
+	if spiffe.TrustDomain == "" {
+		return fmt.Errorf("URI SANs are not allowed: %v", uris)
+	}
+	if len(uris) > 1 {
+		return fmt.Errorf("only a single SPIFFE ID URI SAN is allowed: %v", uris)
+	}
+
+	id := uris[0]
+	switch {
+	case id.Scheme != spiffeScheme:
+		return fmt.Errorf("URI SAN %s is not a SPIFFE ID", id)
+	case id.User != nil || id.Port() != "" || id.RawQuery != "" || id.Fragment != "" || id.Opaque != "":
+		return fmt.Errorf("SPIFFE ID %s must only have a trust domain and a path", id)
+	case id.Host != spiffe.TrustDomain:
+		return fmt.Errorf("SPIFFE ID %s is not in trust domain %s", id, spiffe.TrustDomain)
+	case id.Path != spiffeNodePathPrefix+nodeName:
+		return fmt.Errorf("SPIFFE ID %s does not identify node %s", id, nodeName)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestValidateServingURIs(t *testing.T) {
+	spiffe := SPIFFE{TrustDomain: "cluster"}
+	parse := func(rawURL string) *url.URL {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			t.Fatalf("failed to parse %s: %v", rawURL, err)
+		}
+		return u
+	}
+
+	tests := []struct {
+		name    string
+		spiffe  SPIFFE
+		uris    []string
+		wantErr string
+	}{
+		{
+			name: "no URI SAN",
+		},
+		{
+			name:    "URI SAN without trust domain",
+			uris:    []string{"spiffe://cluster/node/panda"},
+			wantErr: "URI SANs are not allowed: [spiffe://cluster/node/panda]",
+		},
+		{
+			name:   "SPIFFE ID of node",
+			spiffe: spiffe,
+			uris:   []string{"spiffe://cluster/node/panda"},
+		},
+		{
+			name:    "SPIFFE ID of other node",
+			spiffe:  spiffe,
+			uris:    []string{"spiffe://cluster/node/koala"},
+			wantErr: "SPIFFE ID spiffe://cluster/node/koala does not identify node panda",
+		},
+		{
+			name:    "SPIFFE ID of node prefix",
+			spiffe:  spiffe,
+			uris:    []string{"spiffe://cluster/node/panda/extra"},
+			wantErr: "SPIFFE ID spiffe://cluster/node/panda/extra does not identify node panda",
+		},
+		{
+			name:    "SPIFFE ID of workload",
+			spiffe:  spiffe,
+			uris:    []string{"spiffe://cluster/ns/default/sa/panda"},
+			wantErr: "SPIFFE ID spiffe://cluster/ns/default/sa/panda does not identify node panda",
+		},
+		{
+			name:    "SPIFFE ID in other trust domain",
+			spiffe:  spiffe,
+			uris:    []string{"spiffe://cluster.local/node/panda"},
+			wantErr: "SPIFFE ID spiffe://cluster.local/node/panda is not in trust domain cluster",
+		},
+		{
+			name:    "SPIFFE ID with port",
+			spiffe:  spiffe,
+			uris:    []string{"spiffe://cluster:8443/node/panda"},
+			wantErr: "SPIFFE ID spiffe://cluster:8443/node/panda must only have a trust domain and a path",
+		},
+		{
+			name:    "SPIFFE ID with query",
+			spiffe:  spiffe,
+			uris:    []string{"spiffe://cluster/node/panda?admin=true"},
+			wantErr: "SPIFFE ID spiffe://cluster/node/panda?admin=true must only have a trust domain and a path",
+		},
+		{
+			name:    "not a SPIFFE ID",
+			spiffe:  spiffe,
+			uris:    []string{"https://cluster/node/panda"},
+			wantErr: "URI SAN https://cluster/node/panda is not a SPIFFE ID",
+		},
+		{
+			name:    "several SPIFFE IDs",
+			spiffe:  spiffe,
+			uris:    []string{"spiffe://cluster/node/panda", "spiffe://cluster/node/panda"},
+			wantErr: "only a single SPIFFE ID URI SAN is allowed: [spiffe://cluster/node/panda spiffe://cluster/node/panda]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var uris []*url.URL
+			for _, uri := range tt.uris {
+				uris = append(uris, parse(uri))
+			}
+			if err := validateServingURIs(tt.spiffe, "panda", uris); errString(err) != tt.wantErr {
+				t.Errorf("validateServingURIs() error = %v, wantErr %s", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"context"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// syncPendingCSRs reconciles every pending CSR once on startup, so that CSRs
+// left pending while the controller was down are decided without waiting for
+// them to be enqueued. As for any reconcile, nothing is done when there are
+// too many recently pending CSRs. CSRs failing to be reconciled are left to
+// the regular reconciles, which retry them.
+func (m *CertificateApprover) syncPendingCSRs(ctx context.Context) error {
+	// Don't stop the manager on failures, CSRs are still reconciled as usual.
+	csrs := &certificatesv1.CertificateSigningRequestList{}
+	if err := m.NodeClient.List(ctx, csrs); err != nil {
+		klog.Errorf("Startup sync: Failed to list CSRs: %v", err)
+		return nil
+	}
+
+	machines, err := m.listMachines(ctx)
+	if err != nil {
+		klog.Errorf("Startup sync: %v", err)
+		return nil
+	}
+
+	nodes := &corev1.NodeList{}
+	if err := m.NodeClient.List(ctx, nodes); err != nil {
+		klog.Errorf("Startup sync: Failed to list Nodes: %v", err)
+		return nil
+	}
+
+	if offLimits := reconcileLimits(m.Config.NodeClientCert.bootstrappers(), m.Config.nodeUserPrefix(), "Startup sync", machines, nodes, csrs, m.clock().Now()); offLimits {
+		pendingLimitExceededTotal.Inc()
+		return nil
+	}
+
+	synced := 0
+	for _, csr := range csrs.Items {
+		// Approved, denied and failed CSRs all have a condition set.
+		if len(csr.Status.Conditions) > 0 {
+			continue
+		}
+		if ctx.Err() != nil {
+			klog.Infof("Startup sync: Stopped after reconciling %d pending CSRs: %v", synced, ctx.Err())
+			return nil
+		}
+
+		if err := m.reconcileCSR(ctx, csr, machines); err != nil {
+			klog.Infof("Startup sync: %v: Failed to reconcile CSR: %v", csr.Name, err)
+		}
+		synced++
+	}
+
+	klog.Infof("Startup sync: Reconciled %d pending CSRs", synced)
+	return nil
+}
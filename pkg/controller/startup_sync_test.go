@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync/atomic"
+	"testing"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testingclock "k8s.io/utils/clock/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestSyncPendingCSRs(t *testing.T) {
+	clientCSR := func(name string) *certificatesv1.CertificateSigningRequest {
+		return &certificatesv1.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				CreationTimestamp: metav1.NewTime(baseTime),
+			},
+			Spec: certificatesv1.CertificateSigningRequestSpec{
+				Request: []byte(clientGood),
+				Usages: []certificatesv1.KeyUsage{
+					certificatesv1.UsageKeyEncipherment,
+					certificatesv1.UsageDigitalSignature,
+					certificatesv1.UsageClientAuth,
+				},
+				SignerName: certificatesv1.KubeAPIServerClientKubeletSignerName,
+				Username:   nodeBootstrapperUsername,
+				Groups:     nodeBootstrapperGroups.List(),
+			},
+		}
+	}
+	approved := clientCSR("approved")
+	approved.Status.Conditions = []certificatesv1.CertificateSigningRequestCondition{{
+		Type: certificatesv1.CertificateApproved,
+	}}
+
+	tests := []struct {
+		name        string
+		pending     int
+		wantSynced  int
+		wantBlocked bool
+	}{
+		{
+			name:       "pending CSRs reconciled",
+			pending:    3,
+			wantSynced: 3,
+		},
+		{
+			name:        "too many pending CSRs",
+			pending:     maxDiffBetweenPendingCSRsAndMachinesCount + 1,
+			wantSynced:  0,
+			wantBlocked: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects := []client.Object{approved.DeepCopy()}
+			for i := 0; i < tt.pending; i++ {
+				objects = append(objects, clientCSR(fmt.Sprintf("pending-%03d", i)))
+			}
+			cl := fake.NewClientBuilder().WithObjects(objects...).Build()
+			approver := &CertificateApprover{
+				NodeClient: cl,
+				Clock:      testingclock.NewFakePassiveClock(baseTime),
+			}
+
+			blockedBefore := counterValue(t, pendingLimitExceededTotal)
+			if err := approver.syncPendingCSRs(context.Background()); err != nil {
+				t.Fatalf("syncPendingCSRs() error = %v", err)
+			}
+
+			// The approved CSR isn't pending, and without machines nor nodes
+			// the threshold is the allowed difference.
+			if pending := atomic.LoadUint32(&PendingCSRs); pending != uint32(tt.pending) {
+				t.Errorf("PendingCSRs = %d, want %d", pending, tt.pending)
+			}
+			if maxPending := atomic.LoadUint32(&MaxPendingCSRs); maxPending != maxDiffBetweenPendingCSRsAndMachinesCount {
+				t.Errorf("MaxPendingCSRs = %d, want %d", maxPending, maxDiffBetweenPendingCSRsAndMachinesCount)
+			}
+			wantBlocked := blockedBefore
+			if tt.wantBlocked {
+				wantBlocked++
+			}
+			if blocked := counterValue(t, pendingLimitExceededTotal); blocked != wantBlocked {
+				t.Errorf("pending limit exceeded counter = %v, want %v", blocked, wantBlocked)
+			}
+
+			// Without machines, the pending CSRs reconciled are declined with a
+			// reason.
+			csrs := &certificatesv1.CertificateSigningRequestList{}
+			if err := cl.List(context.Background(), csrs); err != nil {
+				t.Fatalf("failed to list CSRs: %v", err)
+			}
+			synced := []string{}
+			for _, csr := range csrs.Items {
+				if _, ok := csr.Annotations[denialReasonAnnotation]; ok {
+					synced = append(synced, csr.Name)
+				}
+			}
+			sort.Strings(synced)
+
+			wantSynced := []string{}
+			for i := 0; i < tt.wantSynced; i++ {
+				wantSynced = append(wantSynced, fmt.Sprintf("pending-%03d", i))
+			}
+			if !reflect.DeepEqual(synced, wantSynced) {
+				t.Errorf("reconciled %v, want %v", synced, wantSynced)
+			}
+		})
+	}
+}
@@ -5,12 +5,16 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"strings"
 	"time"
 
 	"github.com/mitchellh/mapstructure"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/rest"
@@ -19,6 +23,18 @@ import (
 
 var (
 	ErrApiGroupNotFound = errors.New("failed to find API group")
+
+	// ErrAmbiguousMachine is returned when more than one machine matches a
+	// node, e.g. while two machines transiently claim the same node name.
+	ErrAmbiguousMachine = errors.New("more than one machine matches node")
+)
+
+const (
+	// OSIDLabel is the label set on machines to the operating system of their
+	// node.
+	OSIDLabel = "machine.openshift.io/os-id"
+	// OSIDWindows is the value of the OS ID label of Windows machines.
+	OSIDWindows = "Windows"
 )
 
 type MachineHandler struct {
@@ -26,48 +42,36 @@ type MachineHandler struct {
 	Config    *rest.Config
 	Ctx       context.Context
 	Namespace string
+	// LabelSelector restricts the machines listed, all machines are listed
+	// when unset.
+	LabelSelector labels.Selector
 }
 
 type Machine struct {
 	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              MachineSpec   `json:"spec,omitempty"`
 	Status            MachineStatus `json:"status,omitempty"`
 }
+type MachineSpec struct {
+	ProviderID *string `json:"providerID,omitempty"`
+	// Version is the Kubernetes version expected on the node, only set by
+	// some Cluster API providers.
+	Version *string `json:"version,omitempty"`
+}
 type MachineStatus struct {
 	NodeRef   *corev1.ObjectReference `json:"nodeRef,omitempty"`
 	Addresses []corev1.NodeAddress    `json:"addresses,omitempty"`
+	// Phase is the lifecycle phase of the machine, e.g. Provisioned or
+	// Running.
+	Phase string `json:"phase,omitempty"`
+	// ProviderStatus is kept unstructured as its content is provider specific.
+	ProviderStatus map[string]interface{} `json:"providerStatus,omitempty"`
 }
 
 // ListMachines list all machines using given client
 func (m *MachineHandler) ListMachines(apiGroupVersion schema.GroupVersion) ([]Machine, error) {
-	apiVersion, err := m.getAPIGroupPreferredVersion(apiGroupVersion.Group)
-	if err != nil {
-		// when MachineAPI capability is disabled we ignore error
-		// that we can't find api version/group for given group
-		// and return nil, because there are no machines,
-		// and it makes no sense to continue function
-		if err == ErrApiGroupNotFound {
-			return nil, nil
-		}
-		return nil, err
-	}
-
-	// we set group version to user provided one
-	// if not, set preffered version from discovery above
-	if apiGroupVersion.Version != "" {
-		apiVersion = apiGroupVersion.Version
-	}
-
-	unstructuredMachineList := &unstructured.UnstructuredList{}
-	unstructuredMachineList.SetGroupVersionKind(schema.GroupVersionKind{
-		Group:   apiGroupVersion.Group,
-		Kind:    "MachineList",
-		Version: apiVersion,
-	})
-	listOpts := make([]client.ListOption, 0)
-	if m.Namespace != "" {
-		listOpts = append(listOpts, client.InNamespace(m.Namespace))
-	}
-	if err := m.Client.List(m.Ctx, unstructuredMachineList, listOpts...); err != nil {
+	unstructuredMachineList, err := m.listUnstructuredMachines(apiGroupVersion)
+	if err != nil || unstructuredMachineList == nil {
 		return nil, err
 	}
 
@@ -101,6 +105,69 @@ func (m *MachineHandler) ListMachines(apiGroupVersion schema.GroupVersion) ([]Ma
 	return machines, nil
 }
 
+// PingMachines checks that machines can be listed using given client, while
+// retrieving at most one of them.
+func (m *MachineHandler) PingMachines(apiGroupVersion schema.GroupVersion) error {
+	_, err := m.listUnstructuredMachines(apiGroupVersion, client.Limit(1))
+	return err
+}
+
+// MachinesServed checks whether machines are served in the API group, i.e.
+// the API group is served and machines can be listed in it. Machines are not
+// served when the Machine CRD is not installed, e.g. when the MachineAPI
+// capability is disabled.
+func (m *MachineHandler) MachinesServed(apiGroupVersion schema.GroupVersion) (bool, error) {
+	list, err := m.listUnstructuredMachines(apiGroupVersion, client.Limit(1))
+	if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return list != nil, nil
+}
+
+// listUnstructuredMachines lists the machines of the API group, it returns nil
+// when the API group is not served.
+func (m *MachineHandler) listUnstructuredMachines(apiGroupVersion schema.GroupVersion, opts ...client.ListOption) (*unstructured.UnstructuredList, error) {
+	apiVersion, err := m.getAPIGroupPreferredVersion(apiGroupVersion.Group)
+	if err != nil {
+		// when MachineAPI capability is disabled we ignore error
+		// that we can't find api version/group for given group
+		// and return nil, because there are no machines,
+		// and it makes no sense to continue function
+		if err == ErrApiGroupNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	// we set group version to user provided one
+	// if not, set preffered version from discovery above
+	if apiGroupVersion.Version != "" {
+		apiVersion = apiGroupVersion.Version
+	}
+
+	unstructuredMachineList := &unstructured.UnstructuredList{}
+	unstructuredMachineList.SetGroupVersionKind(schema.GroupVersionKind{
+		Group:   apiGroupVersion.Group,
+		Kind:    "MachineList",
+		Version: apiVersion,
+	})
+	listOpts := append([]client.ListOption{}, opts...)
+	if m.Namespace != "" {
+		listOpts = append(listOpts, client.InNamespace(m.Namespace))
+	}
+	if m.LabelSelector != nil {
+		listOpts = append(listOpts, client.MatchingLabelsSelector{Selector: m.LabelSelector})
+	}
+	if err := m.Client.List(m.Ctx, unstructuredMachineList, listOpts...); err != nil {
+		return nil, err
+	}
+
+	return unstructuredMachineList, nil
+}
+
 // getAPIGroupPreferredVersion get preferred API version using API group
 func (m *MachineHandler) getAPIGroupPreferredVersion(apiGroup string) (string, error) {
 	if m.Config == nil {
@@ -126,25 +193,153 @@ func (m *MachineHandler) getAPIGroupPreferredVersion(apiGroup string) (string, e
 	return "", ErrApiGroupNotFound
 }
 
-// FindMatchingMachineFromInternalDNS find matching machine for node using internal DNS
+// FindMatchingMachineFromInternalDNS find matching machine for node using
+// internal DNS. Windows nodes may register with the hostname label of the
+// internal DNS name of their machine only, which is then matched too.
 func FindMatchingMachineFromInternalDNS(machines []Machine, nodeName string) (*Machine, error) {
-	for _, machine := range machines {
+	return singleMatchingMachine(MachinesForNodeName(machines, nodeName), nodeName)
+}
+
+// MachinesForNodeName returns all the machines with an internal DNS name
+// matching the node, as FindMatchingMachineFromInternalDNS, e.g. so that
+// callers can tell which machines share a DNS alias.
+func MachinesForNodeName(machines []Machine, nodeName string) []Machine {
+	return findMatchingMachines(machines, func(machine Machine) bool {
+		for _, address := range machine.Status.Addresses {
+			if corev1.NodeAddressType(address.Type) != corev1.NodeInternalDNS {
+				continue
+			}
+			if EqualDNSNames(address.Address, nodeName) {
+				return true
+			}
+			if IsWindowsMachine(machine) && IsHostnameLabel(address.Address, nodeName) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// FindMatchingMachineFromInternalDNSFirstLabel finds the matching machine for
+// a node named after the first label of the internal DNS name of its machine,
+// e.g. a GCP instance name with an internal DNS name of
+// <name>.c.<project>.internal.
+func FindMatchingMachineFromInternalDNSFirstLabel(machines []Machine, nodeName string) (*Machine, error) {
+	return findSingleMatchingMachine(machines, nodeName, func(machine Machine) bool {
 		for _, address := range machine.Status.Addresses {
-			if corev1.NodeAddressType(address.Type) == corev1.NodeInternalDNS && address.Address == nodeName {
-				return &machine, nil
+			if corev1.NodeAddressType(address.Type) == corev1.NodeInternalDNS && IsHostnameLabel(address.Address, nodeName) {
+				return true
 			}
 		}
+		return false
+	})
+}
+
+// IsWindowsMachine returns true if the machine is labeled as running Windows.
+func IsWindowsMachine(machine Machine) bool {
+	return strings.EqualFold(machine.Labels[OSIDLabel], OSIDWindows)
+}
+
+// IsHostnameLabel returns true if the name is a single label matching the
+// hostname label of the fully qualified DNS name, ignoring case.
+func IsHostnameLabel(fqdn, name string) bool {
+	if name == "" || strings.Contains(name, ".") {
+		return false
 	}
-	return nil, fmt.Errorf("matching machine not found")
+	label, _, isFQDN := strings.Cut(fqdn, ".")
+	return isFQDN && strings.EqualFold(label, name)
+}
+
+// EqualDNSNames returns true if both DNS names are the same, ignoring case and
+// the trailing dot of fully qualified names.
+func EqualDNSNames(a, b string) bool {
+	return strings.EqualFold(strings.TrimSuffix(a, "."), strings.TrimSuffix(b, "."))
 }
 
 // FindMatchingMachineFromNodeRef find matching machine for node using node ref
 func FindMatchingMachineFromNodeRef(machines []Machine, nodeName string) (*Machine, error) {
+	return findSingleMatchingMachine(machines, nodeName, func(machine Machine) bool {
+		return machine.Status.NodeRef != nil && machine.Status.NodeRef.Name == nodeName
+	})
+}
+
+// FindMatchingMachineFromInstanceID finds the machine of a node by the cloud
+// instance ID the node is annotated with, held by the given annotation of the
+// machine.
+func FindMatchingMachineFromInstanceID(machines []Machine, nodeName, annotation, instanceID string) (*Machine, error) {
+	if instanceID == "" {
+		return nil, fmt.Errorf("matching machine not found")
+	}
+	return findSingleMatchingMachine(machines, nodeName, func(machine Machine) bool {
+		return machine.Annotations[annotation] == instanceID
+	})
+}
+
+// findSingleMatchingMachine returns the only machine matching the node. An
+// error wrapping ErrAmbiguousMachine is returned when several machines match,
+// rather than picking one of them arbitrarily.
+func findSingleMatchingMachine(machines []Machine, nodeName string, matches func(Machine) bool) (*Machine, error) {
+	return singleMatchingMachine(findMatchingMachines(machines, matches), nodeName)
+}
+
+// findMatchingMachines returns the machines matching, in order.
+func findMatchingMachines(machines []Machine, matches func(Machine) bool) []Machine {
+	var found []Machine
 	for _, machine := range machines {
-		if machine.Status.NodeRef != nil && machine.Status.NodeRef.Name == nodeName {
-			return &machine, nil
+		if matches(machine) {
+			found = append(found, machine)
 		}
+	}
+	return found
+}
 
+// singleMatchingMachine returns the only machine of those matching the node,
+// naming all of them in the error wrapping ErrAmbiguousMachine otherwise.
+func singleMatchingMachine(found []Machine, nodeName string) (*Machine, error) {
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("matching machine not found")
+	case 1:
+		return &found[0], nil
+	}
+
+	names := make([]string, len(found))
+	for i := range found {
+		names[i] = found[i].Name
+	}
+	last := len(names) - 1
+	return nil, fmt.Errorf("%w %s: %s and %s", ErrAmbiguousMachine, nodeName, strings.Join(names[:last], ", "), names[last])
+}
+
+// FindMatchingMachineFromProviderID finds the machine of a node by provider ID,
+// e.g. that of the node spec, as the node linker does.
+func FindMatchingMachineFromProviderID(machines []Machine, nodeName, providerID string) (*Machine, error) {
+	if providerID == "" {
+		return nil, fmt.Errorf("matching machine not found")
+	}
+	return findSingleMatchingMachine(machines, nodeName, func(machine Machine) bool {
+		return machine.Spec.ProviderID != nil && *machine.Spec.ProviderID == providerID
+	})
+}
+
+// ProviderIDResolver maps the name of a node, which may not exist yet, to the
+// provider ID of the instance expected to run it.
+type ProviderIDResolver func(machines []Machine, nodeName string) (string, error)
+
+// AnnotationProviderIDResolver returns a ProviderIDResolver using the provider
+// ID of the machine whose given annotation holds the node name. An error
+// wrapping ErrAmbiguousMachine is returned when several machines hold it.
+func AnnotationProviderIDResolver(annotation string) ProviderIDResolver {
+	return func(machines []Machine, nodeName string) (string, error) {
+		machine, err := findSingleMatchingMachine(machines, nodeName, func(machine Machine) bool {
+			return machine.Annotations[annotation] == nodeName && machine.Spec.ProviderID != nil
+		})
+		if errors.Is(err, ErrAmbiguousMachine) {
+			return "", err
+		}
+		if err != nil {
+			return "", fmt.Errorf("no machine with provider ID annotated with node name %s", nodeName)
+		}
+		return *machine.Spec.ProviderID, nil
 	}
-	return nil, fmt.Errorf("matching machine not found")
 }
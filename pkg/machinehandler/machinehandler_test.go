@@ -3,11 +3,15 @@ package machinehandler
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io/ioutil"
 	"net/http"
+	"reflect"
 	"strings"
 	"testing"
 
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/rest"
@@ -83,7 +87,9 @@ func createUnstructuredMachine(apiVersion, name, namespace, ip, nodeName string)
 				"name":      name,
 				"namespace": namespace,
 			},
-			"spec": map[string]interface{}{},
+			"spec": map[string]interface{}{
+				"providerID": "fake:///" + name,
+			},
 			"status": map[string]interface{}{
 				"addresses": []interface{}{
 					map[string]interface{}{
@@ -99,6 +105,13 @@ func createUnstructuredMachine(apiVersion, name, namespace, ip, nodeName string)
 					"kind": "Node",
 					"name": nodeName,
 				},
+				"providerStatus": map[string]interface{}{
+					"networkInterfaces": []interface{}{
+						map[string]interface{}{
+							"ipAddresses": []interface{}{ip},
+						},
+					},
+				},
 			},
 		},
 	}
@@ -174,8 +187,385 @@ func Test_authorizeCSR(t *testing.T) {
 					t.Errorf("unexpected machines returned. want machine names: %v, got machines: %v.", tt.wantMachineNames, machines)
 					break
 				}
+				if m.Spec.ProviderID == nil || *m.Spec.ProviderID != "fake:///"+m.Name {
+					t.Errorf("unexpected provider ID returned for machine %s: %v.", m.Name, m.Spec.ProviderID)
+				}
+				if _, ok := m.Status.ProviderStatus["networkInterfaces"]; !ok {
+					t.Errorf("unexpected provider status returned for machine %s: %v.", m.Name, m.Status.ProviderStatus)
+				}
+			}
+		})
+	}
+
+}
+
+func TestFindMatchingMachineFromProviderID(t *testing.T) {
+	providerID := "aws:///us-east-1a/i-0123"
+	duplicateID := "aws:///us-east-1a/i-4567"
+	machines := []Machine{
+		{ObjectMeta: metav1.ObjectMeta{Name: "no-provider-id"}},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "panda"},
+			Spec:       MachineSpec{ProviderID: &providerID},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "bamboo"},
+			Spec:       MachineSpec{ProviderID: &duplicateID},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "bamboo-copy"},
+			Spec:       MachineSpec{ProviderID: &duplicateID},
+		},
+	}
+	if machine, err := FindMatchingMachineFromProviderID(machines, "panda", providerID); err != nil || machine.Name != "panda" {
+		t.Errorf("expected machine panda, got: %v, error: %v", machine, err)
+	}
+	if _, err := FindMatchingMachineFromProviderID(machines, "panda", "aws:///us-east-1a/i-8901"); err == nil {
+		t.Errorf("expected no machine to match")
+	}
+	if _, err := FindMatchingMachineFromProviderID(machines, "panda", ""); err == nil {
+		t.Errorf("expected no machine to match an empty provider ID")
+	}
+	if _, err := FindMatchingMachineFromProviderID(machines, "bamboo", duplicateID); !errors.Is(err, ErrAmbiguousMachine) {
+		t.Errorf("expected ambiguous machine error, got: %v", err)
+	}
+}
+
+func TestFindMatchingMachineFromInternalDNS(t *testing.T) {
+	machines := []Machine{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "panda"},
+			Status: MachineStatus{
+				Addresses: []corev1.NodeAddress{
+					{Type: corev1.NodeInternalDNS, Address: "IP-10-0-128-123.EC2.Internal"},
+					{Type: corev1.NodeExternalDNS, Address: "ec2-1-2-3-4.compute.amazonaws.com"},
+				},
+			},
+		},
+	}
+
+	// DNS names are case-insensitive.
+	for _, nodeName := range []string{"ip-10-0-128-123.ec2.internal", "IP-10-0-128-123.EC2.INTERNAL"} {
+		if machine, err := FindMatchingMachineFromInternalDNS(machines, nodeName); err != nil || machine.Name != "panda" {
+			t.Errorf("expected machine panda for node %s, got: %v, error: %v", nodeName, machine, err)
+		}
+	}
+	if _, err := FindMatchingMachineFromInternalDNS(machines, "ec2-1-2-3-4.compute.amazonaws.com"); err == nil {
+		t.Errorf("expected no machine to match an external DNS name")
+	}
+}
+
+func TestMachinesForNodeName(t *testing.T) {
+	internalDNS := func(names ...string) MachineStatus {
+		status := MachineStatus{}
+		for _, name := range names {
+			status.Addresses = append(status.Addresses, corev1.NodeAddress{Type: corev1.NodeInternalDNS, Address: name})
+		}
+		return status
+	}
+	machines := []Machine{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "panda"},
+			Status:     internalDNS("panda", "panda.ec2.internal"),
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "bamboo"},
+			Status:     internalDNS("bamboo", "bamboo.ec2.internal"),
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "panda-replacement"},
+			Status:     internalDNS("panda-replacement", "panda.ec2.internal"),
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "panda-alias"},
+			Status:     internalDNS("PANDA.ec2.internal."),
+		},
+	}
+
+	tests := []struct {
+		nodeName string
+		want     []string
+		wantErr  string
+	}{
+		{
+			nodeName: "bamboo.ec2.internal",
+			want:     []string{"bamboo"},
+		},
+		{
+			nodeName: "panda",
+			want:     []string{"panda"},
+		},
+		{
+			nodeName: "panda.ec2.internal",
+			want:     []string{"panda", "panda-replacement", "panda-alias"},
+			wantErr:  "more than one machine matches node panda.ec2.internal: panda, panda-replacement and panda-alias",
+		},
+		{
+			nodeName: "unknown",
+			wantErr:  "matching machine not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.nodeName, func(t *testing.T) {
+			var got []string
+			for _, machine := range MachinesForNodeName(machines, tt.nodeName) {
+				got = append(got, machine.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MachinesForNodeName() = %v, want %v", got, tt.want)
+			}
+
+			// A single machine is only found when no other shares its DNS
+			// name.
+			machine, err := FindMatchingMachineFromInternalDNS(machines, tt.nodeName)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("FindMatchingMachineFromInternalDNS() error = %v, want %q", err, tt.wantErr)
+				}
+				if len(tt.want) > 1 && !errors.Is(err, ErrAmbiguousMachine) {
+					t.Errorf("FindMatchingMachineFromInternalDNS() error = %v, want ambiguous machine", err)
+				}
+			} else if err != nil || machine.Name != tt.want[0] {
+				t.Errorf("FindMatchingMachineFromInternalDNS() = %v, %v, want machine %s", machine, err, tt.want[0])
 			}
 		})
 	}
+}
+
+func TestFindMatchingMachineFromInternalDNSTrailingDot(t *testing.T) {
+	machines := []Machine{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "panda"},
+			Status: MachineStatus{
+				Addresses: []corev1.NodeAddress{
+					{Type: corev1.NodeInternalDNS, Address: "ip-10-0-128-123.ec2.internal"},
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "koala"},
+			Status: MachineStatus{
+				Addresses: []corev1.NodeAddress{
+					{Type: corev1.NodeInternalDNS, Address: "ip-10-0-128-124.ec2.internal."},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		nodeName    string
+		wantMachine string
+	}{
+		{nodeName: "ip-10-0-128-123.ec2.internal", wantMachine: "panda"},
+		{nodeName: "ip-10-0-128-123.ec2.internal.", wantMachine: "panda"},
+		{nodeName: "ip-10-0-128-124.ec2.internal", wantMachine: "koala"},
+		{nodeName: "ip-10-0-128-124.ec2.internal.", wantMachine: "koala"},
+	}
+
+	for _, tt := range tests {
+		if machine, err := FindMatchingMachineFromInternalDNS(machines, tt.nodeName); err != nil || machine.Name != tt.wantMachine {
+			t.Errorf("expected machine %s for node %s, got: %v, error: %v", tt.wantMachine, tt.nodeName, machine, err)
+		}
+	}
+	if _, err := FindMatchingMachineFromInternalDNS(machines, "ip-10-0-128-123.ec2.internal.."); err == nil {
+		t.Errorf("expected no machine to match a node name with two trailing dots")
+	}
+}
+
+func TestFindMatchingMachineFromInternalDNSWindows(t *testing.T) {
+	machines := []Machine{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "windows",
+				Labels: map[string]string{OSIDLabel: OSIDWindows},
+			},
+			Status: MachineStatus{
+				Addresses: []corev1.NodeAddress{
+					{Type: corev1.NodeInternalDNS, Address: "win-abc123.ec2.internal"},
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "linux"},
+			Status: MachineStatus{
+				Addresses: []corev1.NodeAddress{
+					{Type: corev1.NodeInternalDNS, Address: "ip-10-0-128-123.ec2.internal"},
+				},
+			},
+		},
+	}
+
+	for _, nodeName := range []string{"WIN-ABC123", "win-abc123", "WIN-ABC123.EC2.INTERNAL"} {
+		if machine, err := FindMatchingMachineFromInternalDNS(machines, nodeName); err != nil || machine.Name != "windows" {
+			t.Errorf("expected machine windows for node %s, got: %v, error: %v", nodeName, machine, err)
+		}
+	}
+	// Only the hostname label of Windows machines is matched.
+	for _, nodeName := range []string{"WIN-ABC123.ec2", "IP-10-0-128-123", ""} {
+		if machine, err := FindMatchingMachineFromInternalDNS(machines, nodeName); err == nil {
+			t.Errorf("expected no machine to match node %q, got: %v", nodeName, machine.Name)
+		}
+	}
+}
+
+func TestFindMatchingMachineFromInternalDNSFirstLabel(t *testing.T) {
+	machines := []Machine{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "panda"},
+			Status: MachineStatus{
+				Addresses: []corev1.NodeAddress{
+					{Type: corev1.NodeInternalIP, Address: "10.0.0.1"},
+					{Type: corev1.NodeInternalDNS, Address: "panda-worker-a-xyz.c.my-project.internal"},
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "bamboo"},
+			Status: MachineStatus{
+				Addresses: []corev1.NodeAddress{
+					{Type: corev1.NodeExternalDNS, Address: "bamboo-worker-b-abc.c.my-project.internal"},
+				},
+			},
+		},
+	}
+
+	for _, nodeName := range []string{"panda-worker-a-xyz", "PANDA-WORKER-A-XYZ"} {
+		if machine, err := FindMatchingMachineFromInternalDNSFirstLabel(machines, nodeName); err != nil || machine.Name != "panda" {
+			t.Errorf("expected machine panda for node %s, got: %v, error: %v", nodeName, machine, err)
+		}
+	}
+	// Only the first label of internal DNS names is matched.
+	for _, nodeName := range []string{"panda-worker-a-xyz.c.my-project.internal", "panda-worker-a-xyz.c", "c", "bamboo-worker-b-abc", ""} {
+		if machine, err := FindMatchingMachineFromInternalDNSFirstLabel(machines, nodeName); err == nil {
+			t.Errorf("expected no machine to match node %q, got: %v", nodeName, machine.Name)
+		}
+	}
+
+	// Machines of different projects may share the first label.
+	machines = append(machines, Machine{
+		ObjectMeta: metav1.ObjectMeta{Name: "panda-other-project"},
+		Status: MachineStatus{
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalDNS, Address: "panda-worker-a-xyz.c.other-project.internal"},
+			},
+		},
+	})
+	if _, err := FindMatchingMachineFromInternalDNSFirstLabel(machines, "panda-worker-a-xyz"); !errors.Is(err, ErrAmbiguousMachine) {
+		t.Errorf("expected ambiguous machine error, got: %v", err)
+	}
+}
+
+func TestFindMatchingMachineFromNodeRefAmbiguous(t *testing.T) {
+	machines := []Machine{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "panda"},
+			Status:     MachineStatus{NodeRef: &corev1.ObjectReference{Name: "panda"}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "bamboo"},
+			Status:     MachineStatus{NodeRef: &corev1.ObjectReference{Name: "bamboo"}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "panda-replacement"},
+			Status:     MachineStatus{NodeRef: &corev1.ObjectReference{Name: "panda"}},
+		},
+	}
+
+	if machine, err := FindMatchingMachineFromNodeRef(machines, "bamboo"); err != nil || machine.Name != "bamboo" {
+		t.Errorf("expected machine bamboo, got: %v, error: %v", machine, err)
+	}
+	_, err := FindMatchingMachineFromNodeRef(machines, "panda")
+	if !errors.Is(err, ErrAmbiguousMachine) {
+		t.Fatalf("expected ambiguous machine error, got: %v", err)
+	}
+	if want := "more than one machine matches node panda: panda and panda-replacement"; err.Error() != want {
+		t.Errorf("expected error %q, got %q", want, err)
+	}
+}
 
+func TestFindMatchingMachineFromInstanceID(t *testing.T) {
+	annotation := "example.com/instance-id"
+	machines := []Machine{
+		{ObjectMeta: metav1.ObjectMeta{Name: "no-instance-id"}},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "panda",
+				Annotations: map[string]string{annotation: "i-0123"},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "bamboo",
+				Annotations: map[string]string{annotation: "i-4567"},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "bamboo-replacement",
+				Annotations: map[string]string{annotation: "i-4567"},
+			},
+		},
+	}
+
+	if machine, err := FindMatchingMachineFromInstanceID(machines, "panda", annotation, "i-0123"); err != nil || machine.Name != "panda" {
+		t.Errorf("expected machine panda, got: %v, error: %v", machine, err)
+	}
+	if _, err := FindMatchingMachineFromInstanceID(machines, "koala", annotation, "i-89ab"); err == nil {
+		t.Errorf("expected no machine to match")
+	}
+	if _, err := FindMatchingMachineFromInstanceID(machines, "koala", annotation, ""); err == nil {
+		t.Errorf("expected no machine to match an empty instance ID")
+	}
+	if _, err := FindMatchingMachineFromInstanceID(machines, "bamboo", annotation, "i-4567"); !errors.Is(err, ErrAmbiguousMachine) {
+		t.Errorf("expected ambiguous machine error, got: %v", err)
+	}
+}
+
+func TestAnnotationProviderIDResolver(t *testing.T) {
+	providerID := "baremetal:///panda-host"
+	otherID := "baremetal:///koala-host"
+	machines := []Machine{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "without-provider-id",
+				Annotations: map[string]string{"example.com/node-name": "bamboo"},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "panda",
+				Annotations: map[string]string{"example.com/node-name": "panda"},
+			},
+			Spec: MachineSpec{ProviderID: &providerID},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "koala",
+				Annotations: map[string]string{"example.com/node-name": "koala"},
+			},
+			Spec: MachineSpec{ProviderID: &otherID},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "koala-copy",
+				Annotations: map[string]string{"example.com/node-name": "koala"},
+			},
+			Spec: MachineSpec{ProviderID: &otherID},
+		},
+	}
+	resolve := AnnotationProviderIDResolver("example.com/node-name")
+
+	if got, err := resolve(machines, "panda"); err != nil || got != providerID {
+		t.Errorf("expected provider ID %s, got: %s, error: %v", providerID, got, err)
+	}
+	if _, err := resolve(machines, "bamboo"); err == nil {
+		t.Errorf("expected no provider ID for a machine without one")
+	}
+	if _, err := resolve(machines, "grizzly"); err == nil {
+		t.Errorf("expected no provider ID for an unknown node")
+	}
+	if _, err := resolve(machines, "koala"); !errors.Is(err, ErrAmbiguousMachine) {
+		t.Errorf("expected ambiguous machine error, got: %v", err)
+	}
 }